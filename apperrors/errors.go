@@ -0,0 +1,144 @@
+// Package apperrors classifies the failures the application reports, so that
+// callers can tell a missing note from a conflict or an outage without
+// knowing which layer or database produced the error.
+package apperrors
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// Kind is the class of a failure. Kinds are errors themselves, so
+// errors.Is(err, apperrors.NotFound) reports whether err is of that kind.
+type Kind uint8
+
+const (
+	// Internal is a failure the client cannot fix, such as a database outage.
+	Internal Kind = iota
+	// NotFound means the requested resource does not exist.
+	NotFound
+	// Conflict means the request clashes with existing data, such as a
+	// duplicate title.
+	Conflict
+	// Validation means the request itself is invalid.
+	Validation
+	// PreconditionFailed means a conditional request no longer matches the
+	// stored resource, such as a stale version.
+	PreconditionFailed
+	// Timeout means the request took longer than the server allows, such as
+	// a database query running past its timeout.
+	Timeout
+	// Canceled means the client went away before the request was handled.
+	Canceled
+)
+
+var kindNames = map[Kind]string{
+	Internal:           "internal",
+	NotFound:           "not found",
+	Conflict:           "conflict",
+	Validation:         "validation",
+	PreconditionFailed: "precondition failed",
+	Timeout:            "timeout",
+	Canceled:           "canceled",
+}
+
+func (k Kind) Error() string {
+	return kindNames[k]
+}
+
+// Error is a failure of a known kind. Message describes it to clients; Err,
+// when set, is the underlying cause, which is logged but never shown.
+type Error struct {
+	Kind    Kind
+	Message string
+	Err     error
+}
+
+// New returns an error of the given kind. It is typically used to declare
+// sentinel errors such as "note not found".
+func New(kind Kind, message string) *Error {
+	return &Error{Kind: kind, Message: message}
+}
+
+// Wrap returns an error of the given kind caused by err.
+func Wrap(kind Kind, message string, err error) *Error {
+	return &Error{Kind: kind, Message: message, Err: err}
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether target is the Kind of e.
+func (e *Error) Is(target error) bool {
+	kind, ok := target.(Kind)
+	return ok && kind == e.Kind
+}
+
+// KindOf returns the kind of err. The errors of a context that timed out or
+// was canceled are Timeout and Canceled. Errors that are not classified,
+// including nil, are Internal.
+func KindOf(err error) Kind {
+	var appErr *Error
+	if errors.As(err, &appErr) {
+		return appErr.Kind
+	}
+	for _, kind := range []Kind{NotFound, Conflict, Validation, PreconditionFailed, Timeout, Canceled} {
+		if errors.Is(err, kind) {
+			return kind
+		}
+	}
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return Timeout
+	case errors.Is(err, context.Canceled):
+		return Canceled
+	}
+	return Internal
+}
+
+// hiddenMessages are the messages of the kinds of error whose cause is kept
+// from clients, since it only describes the server, for errors that were not
+// wrapped with a message of their own.
+var hiddenMessages = map[Kind]string{
+	Internal: "internal server error",
+	Timeout:  "the request took too long",
+	Canceled: "the request was canceled",
+}
+
+// MessageOf returns the text of err that is safe to show to clients.
+// Internal, Timeout and Canceled errors only reveal the message they were
+// wrapped with, never their cause. Messages start with a capital letter, as
+// in the API's other responses.
+func MessageOf(err error) string {
+	message := err.Error()
+	if hidden, ok := hiddenMessages[KindOf(err)]; ok {
+		message = hidden
+		var appErr *Error
+		if errors.As(err, &appErr) {
+			message = appErr.Message
+		}
+	}
+	if message == "" {
+		return message
+	}
+	return strings.ToUpper(message[:1]) + message[1:]
+}
+
+// FieldsOf returns the extra response fields err carries, such as the
+// position of a query syntax error, or nil.
+func FieldsOf(err error) map[string]interface{} {
+	var fielder interface{ Fields() map[string]interface{} }
+	if errors.As(err, &fielder) {
+		return fielder.Fields()
+	}
+	return nil
+}
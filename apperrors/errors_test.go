@@ -0,0 +1,35 @@
+package apperrors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKindOf(t *testing.T) {
+	notFound := New(NotFound, "note not found")
+
+	assert.Equal(t, NotFound, KindOf(notFound))
+	assert.Equal(t, NotFound, KindOf(fmt.Errorf("loading: %w", notFound)))
+	assert.Equal(t, Internal, KindOf(errors.New("connection refused")))
+	assert.Equal(t, Internal, KindOf(nil))
+	assert.Equal(t, Timeout, KindOf(fmt.Errorf("listing: %w", context.DeadlineExceeded)))
+	assert.Equal(t, Canceled, KindOf(context.Canceled))
+
+	assert.ErrorIs(t, fmt.Errorf("loading: %w", notFound), NotFound)
+	assert.NotErrorIs(t, notFound, Conflict)
+}
+
+func TestWrap(t *testing.T) {
+	cause := errors.New("connection refused")
+	err := Wrap(Internal, "failed to list notes", cause)
+
+	assert.ErrorIs(t, err, cause)
+	assert.EqualError(t, err, "failed to list notes: connection refused")
+	assert.Equal(t, "Failed to list notes", MessageOf(err))
+	assert.Equal(t, "Internal server error", MessageOf(cause))
+	assert.Equal(t, "The request took too long", MessageOf(context.DeadlineExceeded))
+}
@@ -3,18 +3,43 @@ package config
 import (
 	"fmt"
 
+	"github.com/glebarez/sqlite"
 	"github.com/sarita-growexx/note_with_alarm/helper"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 )
 
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
 func ConnectionDB(config *Config) *gorm.DB {
 
-	sqlInfo := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", config.DBHost, config.DBPort, config.DBUsername, config.DBPassword, config.DBName)
+	dialector, err := Dialector(config)
+	helper.ErrorPanic(err)
 
-	db, err := gorm.Open(postgres.Open(sqlInfo), &gorm.Config{})
+	db, err := gorm.Open(dialector, &gorm.Config{})
 	helper.ErrorPanic(err)
 
 	fmt.Println("🚀 Connected Successfully to the Database")
 	return db
 }
+
+// Dialector returns the gorm dialector for the configured database driver.
+func Dialector(config *Config) (gorm.Dialector, error) {
+	switch config.DBDriver {
+	case DriverPostgres, "":
+		sqlInfo := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable", config.DBHost, config.DBPort, config.DBUsername, config.DBPassword, config.DBName)
+		return postgres.Open(sqlInfo), nil
+	case DriverSQLite:
+		return OpenSQLite(config.SQLitePath), nil
+	}
+	return nil, fmt.Errorf("unsupported database driver %q", config.DBDriver)
+}
+
+// OpenSQLite returns a dialector for the SQLite database file at path, with
+// a busy timeout so concurrent writers wait instead of failing.
+func OpenSQLite(path string) gorm.Dialector {
+	return sqlite.Open(path + "?_pragma=busy_timeout(5000)&_pragma=foreign_keys(1)")
+}
@@ -1,10 +1,16 @@
 package config
 
 import (
+	"time"
+
 	"github.com/spf13/viper"
 )
 
 type Config struct {
+	// DBDriver selects the database: "postgres" (the default) or "sqlite".
+	DBDriver   string `mapstructure:"DB_DRIVER"`
+	SQLitePath string `mapstructure:"SQLITE_PATH"`
+
 	DBHost     string `mapstructure:"POSTGRES_HOST"`
 	DBUsername string `mapstructure:"POSTGRES_USER"`
 	DBPassword string `mapstructure:"POSTGRES_PASSWORD"`
@@ -13,6 +19,16 @@ type Config struct {
 
 	ServerPort string `mapstructure:"PORT"`
 
+	// DBQueryTimeout bounds the database work of a single request, e.g. "5s".
+	DBQueryTimeout time.Duration `mapstructure:"DB_QUERY_TIMEOUT"`
+
+	// DeadlineGrace is how far in the past the deadline of a new note may be,
+	// to allow for clock skew between clients and the server, e.g. "1m".
+	DeadlineGrace time.Duration `mapstructure:"DEADLINE_GRACE"`
+
+	// IdempotencyTTL is how long the response to a request sent with an
+	// Idempotency-Key header is replayed to its retries, e.g. "24h".
+	IdempotencyTTL time.Duration `mapstructure:"IDEMPOTENCY_TTL"`
 }
 
 func LoadConfig(path string) (config Config, err error) {
@@ -20,6 +36,11 @@ func LoadConfig(path string) (config Config, err error) {
 	viper.SetConfigType("env")
 	viper.SetConfigName("app")
 
+	viper.SetDefault("DB_DRIVER", DriverPostgres)
+	viper.SetDefault("SQLITE_PATH", "note_with_alarm.db")
+	viper.SetDefault("DB_QUERY_TIMEOUT", 5*time.Second)
+	viper.SetDefault("DEADLINE_GRACE", time.Minute)
+	viper.SetDefault("IDEMPOTENCY_TTL", 24*time.Hour)
 	viper.AutomaticEnv()
 
 	err = viper.ReadInConfig()
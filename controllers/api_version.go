@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiVersion shapes the successful responses of a version of the API, so
+// both versions share their handlers and only differ in the envelope.
+type apiVersion interface {
+	// one writes a single resource. In v1 it is named key, with an optional
+	// message alongside, or written bare when key is empty.
+	one(ctx *gin.Context, status int, key string, value interface{}, message string)
+	// many writes a list. In v1 it is named key, or written bare when key
+	// is empty.
+	many(ctx *gin.Context, key string, items interface{}, page pagination)
+	// deleted confirms that a resource was deleted.
+	deleted(ctx *gin.Context, message string)
+}
+
+// pagination describes the page of a list response.
+type pagination struct {
+	Total      int64  `json:"total"`
+	Limit      int    `json:"limit,omitempty"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	// paged is set for lists fetched a page at a time, whose v1 responses
+	// carry the total and next cursor beside the items.
+	paged bool
+}
+
+// v1 keeps the responses of the original API: resources and lists under
+// a name of their own, or bare, and messages confirming changes.
+type v1 struct{}
+
+func (v1) one(ctx *gin.Context, status int, key string, value interface{}, message string) {
+	if key == "" {
+		ctx.JSON(status, value)
+		return
+	}
+	body := gin.H{key: value}
+	if message != "" {
+		body["message"] = message
+	}
+	ctx.JSON(status, body)
+}
+
+func (v1) many(ctx *gin.Context, key string, items interface{}, page pagination) {
+	if key == "" {
+		ctx.JSON(http.StatusOK, items)
+		return
+	}
+	body := gin.H{key: items}
+	if page.paged {
+		body["total"] = page.Total
+		if page.NextCursor != "" {
+			body["next_cursor"] = page.NextCursor
+		}
+	}
+	ctx.JSON(http.StatusOK, body)
+}
+
+func (v1) deleted(ctx *gin.Context, message string) {
+	ctx.JSON(http.StatusOK, gin.H{"message": message})
+}
+
+// v2 wraps every resource in {"data": ...} and every list in
+// {"data": [...], "pagination": {...}}. Deletions answer 204 No Content.
+type v2 struct{}
+
+type dataEnvelope struct {
+	Data interface{} `json:"data"`
+}
+
+type listEnvelope struct {
+	Data       interface{} `json:"data"`
+	Pagination pagination  `json:"pagination"`
+}
+
+func (v2) one(ctx *gin.Context, status int, _ string, value interface{}, _ string) {
+	ctx.JSON(status, dataEnvelope{Data: value})
+}
+
+func (v2) many(ctx *gin.Context, _ string, items interface{}, page pagination) {
+	ctx.JSON(http.StatusOK, listEnvelope{Data: items, Pagination: page})
+}
+
+func (v2) deleted(ctx *gin.Context, _ string) {
+	ctx.Status(http.StatusNoContent)
+}
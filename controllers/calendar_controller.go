@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sarita-growexx/note_with_alarm/ical"
+	"github.com/sarita-growexx/note_with_alarm/models"
+	"github.com/sarita-growexx/note_with_alarm/problem"
+	"github.com/sarita-growexx/note_with_alarm/services"
+)
+
+type CalendarController struct {
+	calendarService services.CalendarService
+	version         apiVersion
+}
+
+const missingUserErr = "The X-User header must name the user the calendar token is for"
+
+func NewCalendarController(calendarService services.CalendarService) *CalendarController {
+	return &CalendarController{
+		calendarService: calendarService,
+		version:         v1{},
+	}
+}
+
+// V2 returns a controller for version 2 of the API, which shares the
+// calendar service of c.
+func (c *CalendarController) V2() *CalendarController {
+	return &CalendarController{
+		calendarService: c.calendarService,
+		version:         v2{},
+	}
+}
+
+// calendarFeedParams are the query parameters of a calendar feed.
+type calendarFeedParams struct {
+	Token     string `form:"token" binding:"required"`
+	Tag       string `form:"tag" binding:"omitempty,tagname"`
+	Component string `form:"component" binding:"omitempty,oneof=event todo"`
+}
+
+// calendarTokenResponse is a new calendar token, and the URL of the feed it
+// opens, to subscribe to from a calendar app. The token is only shown once.
+type calendarTokenResponse struct {
+	Token   string `json:"token"`
+	FeedURL string `json:"feed_url"`
+}
+
+// CreateCalendarTokenHandler gives the user named by X-User a new token for
+// their calendar feed. Their previous token, if any, stops working.
+func (c *CalendarController) CreateCalendarTokenHandler(ctx *gin.Context) {
+	userName := ctx.GetHeader("X-User")
+	if userName == "" {
+		problem.Write(ctx, problem.New(http.StatusBadRequest, missingUserErr))
+		return
+	}
+
+	token, err := c.calendarService.CreateToken(ctx.Request.Context(), userName)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	response := calendarTokenResponse{Token: token, FeedURL: calendarFeedURL(ctx, token)}
+	c.version.one(ctx, http.StatusCreated, "calendar_token", response, "Calendar token created successfully")
+}
+
+// RevokeCalendarTokenHandler deletes the calendar token of the user named
+// by X-User, closing their feed.
+func (c *CalendarController) RevokeCalendarTokenHandler(ctx *gin.Context) {
+	userName := ctx.GetHeader("X-User")
+	if userName == "" {
+		problem.Write(ctx, problem.New(http.StatusBadRequest, missingUserErr))
+		return
+	}
+
+	if err := c.calendarService.RevokeToken(ctx.Request.Context(), userName); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	c.version.deleted(ctx, "Calendar token revoked successfully")
+}
+
+// CalendarFeedHandler streams the notes, or those with a tag, as an RFC 5545
+// calendar of events or to-dos at their deadlines, for calendar apps to
+// subscribe to. The token in the URL stands in for credentials, which
+// calendar apps cannot send; an unknown token answers 404. Like exports, the
+// feed is not bounded by the request timeout, and a feed that fails once
+// it has started breaks the connection rather than end without
+// END:VCALENDAR.
+func (c *CalendarController) CalendarFeedHandler(ctx *gin.Context) {
+	var params calendarFeedParams
+	if err := ctx.ShouldBindQuery(&params); err != nil {
+		problem.Write(ctx, problem.Validation(err))
+		return
+	}
+
+	w := ical.NewWriter(ctx.Writer)
+	started := false
+	start := func() {
+		if started {
+			return
+		}
+		started = true
+		ctx.Header("Content-Type", "text/calendar; charset=utf-8")
+		ctx.Header("Content-Disposition", `inline; filename="notes.ics"`)
+		// The URL holds a secret, so the feed must not be kept by caches
+		// shared between users
+		ctx.Header("Cache-Control", "private, max-age=0")
+		ctx.Status(http.StatusOK)
+		writeCalendarStart(w, params.Tag)
+	}
+
+	err := c.calendarService.Feed(ctx.Request.Context(), params.Token, params.Tag, func(note *models.Note) error {
+		start()
+		extendWriteDeadline(ctx)
+		writeNoteComponent(w, note, params.Component)
+		return w.Err()
+	})
+	if err != nil && !started {
+		ctx.Error(err)
+		return
+	}
+	if err == nil {
+		start()
+		extendWriteDeadline(ctx)
+		w.End("VCALENDAR")
+		err = w.Err()
+	}
+	if err != nil {
+		abortStream(ctx, "calendar feed", err)
+	}
+}
+
+// calendarFeedURL is the absolute URL of the feed of token, in the version
+// of the API of the request. Calendar apps only subscribe to absolute URLs.
+func calendarFeedURL(ctx *gin.Context, token string) string {
+	scheme := "http"
+	if ctx.Request.TLS != nil || ctx.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	feed := url.URL{
+		Scheme:   scheme,
+		Host:     ctx.Request.Host,
+		Path:     strings.TrimSuffix(ctx.Request.URL.Path, "/tokens") + ".ics",
+		RawQuery: url.Values{"token": {token}}.Encode(),
+	}
+	return feed.String()
+}
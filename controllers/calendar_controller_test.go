@@ -0,0 +1,192 @@
+package controllers_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sarita-growexx/note_with_alarm/controllers"
+	"github.com/sarita-growexx/note_with_alarm/middleware"
+	"github.com/sarita-growexx/note_with_alarm/models"
+	"github.com/sarita-growexx/note_with_alarm/repository"
+	"github.com/sarita-growexx/note_with_alarm/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Define a mock calendar service for testing
+type MockCalendarService struct {
+	mock.Mock
+}
+
+func (m *MockCalendarService) CreateToken(ctx context.Context, userName string) (string, error) {
+	args := m.Called(userName)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockCalendarService) RevokeToken(ctx context.Context, userName string) error {
+	args := m.Called(userName)
+	return args.Error(0)
+}
+
+// Feed passes the notes the expectation is set up with to fn
+func (m *MockCalendarService) Feed(ctx context.Context, token string, tag string, fn func(note *models.Note) error) error {
+	args := m.Called(token, tag)
+	notes, _ := args.Get(0).([]*models.Note)
+	for _, note := range notes {
+		if err := fn(note); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
+func TestCalendarFeedHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockCalendarService)
+	controller := controllers.NewCalendarController(mockService)
+
+	router := gin.New()
+	router.Use(middleware.Errors())
+	router.GET("/calendar.ics", controller.CalendarFeedHandler)
+
+	created := time.Date(2026, time.October, 1, 8, 0, 0, 0, time.UTC)
+	deadline := time.Date(2026, time.November, 1, 9, 0, 0, 0, time.UTC)
+	notes := []*models.Note{
+		{ID: 3, Title: "Pay invoice", Description: "Rent, water; power", Deadline: deadline, Tags: []string{"home", "bills"}, Reminders: []int{60, 15}, Version: 2, CreatedAt: created, UpdatedAt: created},
+		{ID: 4, Title: "Call bank", Deadline: deadline, Version: 1, CreatedAt: created, UpdatedAt: created},
+	}
+	feed := func(query string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/calendar.ics"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Events", func(t *testing.T) {
+		mockService.On("Feed", "secret", "").Return(notes, nil).Once()
+		w := feed("?token=secret")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "text/calendar; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Equal(t, "BEGIN:VCALENDAR\r\n"+
+			"VERSION:2.0\r\n"+
+			"PRODID:-//note_with_alarm//Notes//EN\r\n"+
+			"CALSCALE:GREGORIAN\r\n"+
+			"X-WR-CALNAME:Notes\r\n"+
+			"REFRESH-INTERVAL;VALUE=DURATION:PT1H\r\n"+
+			"X-PUBLISHED-TTL:PT1H\r\n"+
+			"BEGIN:VEVENT\r\n"+
+			"UID:note-3@note-with-alarm\r\n"+
+			"DTSTAMP:20261001T080000Z\r\n"+
+			"CREATED:20261001T080000Z\r\n"+
+			"LAST-MODIFIED:20261001T080000Z\r\n"+
+			"SEQUENCE:1\r\n"+
+			"DTSTART:20261101T090000Z\r\n"+
+			"TRANSP:TRANSPARENT\r\n"+
+			"SUMMARY:Pay invoice\r\n"+
+			`DESCRIPTION:Rent\, water\; power`+"\r\n"+
+			"CATEGORIES:home,bills\r\n"+
+			"BEGIN:VALARM\r\nACTION:DISPLAY\r\nDESCRIPTION:Pay invoice\r\nTRIGGER:-PT1H\r\nEND:VALARM\r\n"+
+			"BEGIN:VALARM\r\nACTION:DISPLAY\r\nDESCRIPTION:Pay invoice\r\nTRIGGER:-PT15M\r\nEND:VALARM\r\n"+
+			"END:VEVENT\r\n", w.Body.String()[:strings.Index(w.Body.String(), "BEGIN:VEVENT\r\nUID:note-4")])
+		// Notes without reminders get the default alarms
+		assert.Equal(t, 6, strings.Count(w.Body.String(), "BEGIN:VALARM"))
+		assert.True(t, strings.HasSuffix(w.Body.String(), "END:VEVENT\r\nEND:VCALENDAR\r\n"))
+	})
+
+	t.Run("To-dos with a tag", func(t *testing.T) {
+		mockService.On("Feed", "secret", "home").Return(notes[:1], nil).Once()
+		w := feed("?token=secret&tag=home&component=todo")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		body := w.Body.String()
+		assert.Contains(t, body, "X-WR-CALNAME:Notes: home\r\n")
+		assert.Contains(t, body, "BEGIN:VTODO\r\n")
+		assert.Contains(t, body, "DUE:20261101T090000Z\r\n")
+		assert.Contains(t, body, "TRIGGER;RELATED=END:-PT15M\r\n")
+		assert.NotContains(t, body, "DTSTART")
+	})
+
+	t.Run("Empty calendar", func(t *testing.T) {
+		mockService.On("Feed", "secret", "").Return(nil, nil).Once()
+		w := feed("?token=secret")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.True(t, strings.HasSuffix(w.Body.String(), "X-PUBLISHED-TTL:PT1H\r\nEND:VCALENDAR\r\n"))
+	})
+
+	t.Run("Failure after some notes", func(t *testing.T) {
+		mockService.On("Feed", "secret", "").Return(notes, errors.New("database is down")).Once()
+		// The connection is broken rather than the calendar ended early
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() { feed("?token=secret") })
+	})
+
+	t.Run("Unknown token", func(t *testing.T) {
+		mockService.On("Feed", "stale", "").Return(nil, services.ErrCalendarFeedNotFound).Once()
+		w := feed("?token=stale")
+		assert.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+		assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	})
+
+	t.Run("Missing token", func(t *testing.T) {
+		w := feed("?tag=home")
+		assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	})
+
+	mockService.AssertExpectations(t)
+}
+
+func TestCalendarTokenHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockCalendarService)
+	controller := controllers.NewCalendarController(mockService)
+
+	router := gin.New()
+	router.Use(middleware.Errors())
+	router.POST("/api/v2/calendar/tokens", controller.V2().CreateCalendarTokenHandler)
+	router.DELETE("/api/v2/calendar/tokens", controller.V2().RevokeCalendarTokenHandler)
+
+	send := func(method, user string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, "/api/v2/calendar/tokens", nil)
+		req.Host = "notes.example.com"
+		req.Header.Set("X-Forwarded-Proto", "https")
+		if user != "" {
+			req.Header.Set("X-User", user)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	mockService.On("CreateToken", "asha").Return("s3cr3t", nil).Once()
+	w := send("POST", "asha")
+	assert.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var response struct {
+		Data struct {
+			Token   string `json:"token"`
+			FeedURL string `json:"feed_url"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "s3cr3t", response.Data.Token)
+	assert.Equal(t, "https://notes.example.com/api/v2/calendar.ics?token=s3cr3t", response.Data.FeedURL)
+
+	w = send("POST", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+
+	mockService.On("RevokeToken", "asha").Return(nil).Once()
+	w = send("DELETE", "asha")
+	assert.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+
+	mockService.On("RevokeToken", "ravi").Return(repository.ErrCalendarTokenNotFound).Once()
+	w = send("DELETE", "ravi")
+	assert.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,86 @@
+package controllers
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/sarita-growexx/note_with_alarm/ical"
+	"github.com/sarita-growexx/note_with_alarm/models"
+)
+
+// Components a note is written as in a calendar feed. Events show in every
+// calendar app; to-dos carry a due date but are ignored by some, such as
+// Google Calendar.
+const (
+	componentEvent = "event"
+	componentTodo  = "todo"
+)
+
+const (
+	// calendarProductID identifies the app that wrote a calendar.
+	calendarProductID = "-//note_with_alarm//Notes//EN"
+	// calendarRefreshInterval is how often subscribers are asked to fetch
+	// the feed again.
+	calendarRefreshInterval = time.Hour
+)
+
+// writeCalendarStart starts a calendar holding notes, named after the tag
+// it is filtered by, if any.
+func writeCalendarStart(w *ical.Writer, tag string) {
+	name := "Notes"
+	if tag != "" {
+		name += ": " + tag
+	}
+	w.Begin("VCALENDAR")
+	w.Property("VERSION", "2.0")
+	w.Property("PRODID", calendarProductID)
+	w.Property("CALSCALE", "GREGORIAN")
+	w.Text("X-WR-CALNAME", name)
+	w.Property("REFRESH-INTERVAL;VALUE=DURATION", ical.FormatDuration(calendarRefreshInterval))
+	w.Property("X-PUBLISHED-TTL", ical.FormatDuration(calendarRefreshInterval))
+}
+
+// writeNoteComponent writes note as an event starting at its deadline, or
+// as a to-do due then, with an alarm for each of its reminders.
+func writeNoteComponent(w *ical.Writer, note *models.Note, component string) {
+	name, trigger := "VEVENT", "TRIGGER"
+	if component == componentTodo {
+		// To-do alarms go off relative to the due date
+		name, trigger = "VTODO", "TRIGGER;RELATED=END"
+	}
+
+	w.Begin(name)
+	w.Property("UID", fmt.Sprintf("note-%d@note-with-alarm", note.ID))
+	w.Property("DTSTAMP", ical.FormatDateTime(note.UpdatedAt))
+	w.Property("CREATED", ical.FormatDateTime(note.CreatedAt))
+	w.Property("LAST-MODIFIED", ical.FormatDateTime(note.UpdatedAt))
+	// Notes start at version 1, events at sequence 0
+	sequence := uint64(note.Version)
+	if sequence > 0 {
+		sequence--
+	}
+	w.Property("SEQUENCE", strconv.FormatUint(sequence, 10))
+	if component == componentTodo {
+		w.Property("DUE", ical.FormatDateTime(note.Deadline))
+		w.Property("STATUS", "NEEDS-ACTION")
+	} else {
+		w.Property("DTSTART", ical.FormatDateTime(note.Deadline))
+		w.Property("TRANSP", "TRANSPARENT")
+	}
+	w.Text("SUMMARY", note.Title)
+	if note.Description != "" {
+		w.Text("DESCRIPTION", note.Description)
+	}
+	if len(note.Tags) > 0 {
+		w.TextList("CATEGORIES", note.Tags)
+	}
+	for _, offset := range note.EffectiveReminders().Offsets() {
+		w.Begin("VALARM")
+		w.Property("ACTION", "DISPLAY")
+		w.Text("DESCRIPTION", note.Title)
+		w.Property(trigger, ical.FormatDuration(-offset))
+		w.End("VALARM")
+	}
+	w.End(name)
+}
@@ -1,172 +1,748 @@
 package controllers
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"github.com/sarita-growexx/note_with_alarm/apperrors"
 	"github.com/sarita-growexx/note_with_alarm/models"
+	"github.com/sarita-growexx/note_with_alarm/problem"
+	"github.com/sarita-growexx/note_with_alarm/repository"
 	"github.com/sarita-growexx/note_with_alarm/services"
 )
 
 type NoteController struct {
 	noteService services.NoteService
+	version     apiVersion
 }
 
 const invalidIDErr = "Invalid note ID"
+const invalidRevisionErr = "Invalid revision number"
 
-var validate *validator.Validate
+const (
+	defaultSimilarityThreshold = 0.3
+	defaultSuggestionLimit     = 10
+)
 
 func NewNoteController(noteService services.NoteService) *NoteController {
 	return &NoteController{
 		noteService: noteService,
+		version:     v1{},
+	}
+}
+
+// V2 returns a controller for version 2 of the API, which shares the note
+// service of c.
+func (c *NoteController) V2() *NoteController {
+	return &NoteController{
+		noteService: c.noteService,
+		version:     v2{},
 	}
 }
 
 func (c *NoteController) CreateNoteHandler(ctx *gin.Context) {
-	var note models.Note
+	var request createNoteRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		problem.Write(ctx, problem.Validation(err))
+		return
+	}
+
+	note := request.toModel()
+	if err := c.noteService.CreateNote(ctx.Request.Context(), note, changeInfo(ctx)); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	setETag(ctx, note.Version)
+	c.version.one(ctx, http.StatusCreated, "note", newNoteResponse(note, time.Now()), "Note created successfully")
+}
+
+func (c *NoteController) UpdateNoteHandler(ctx *gin.Context) {
+	noteID, ok := uintParam(ctx, "id", invalidIDErr)
+	if !ok {
+		return
+	}
+
+	version, ok := c.ifMatchVersion(ctx, noteID)
+	if !ok {
+		return
+	}
+
+	var request updateNoteRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		problem.Write(ctx, problem.Validation(err))
+		return
+	}
+
+	// Set the ID and expected version of the note
+	updatedNote := request.toModel()
+	updatedNote.ID = noteID
+	updatedNote.Version = version
+
+	if err := c.noteService.UpdateNote(ctx.Request.Context(), updatedNote, changeInfo(ctx)); err != nil {
+		ctx.Error(err)
+		return
+	}
 
-	if err := ctx.ShouldBindJSON(&note); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	setETag(ctx, updatedNote.Version)
+	c.version.one(ctx, http.StatusOK, "note", newNoteResponse(updatedNote, time.Now()), "Note updated successfully")
+}
+
+func (c *NoteController) PatchNoteHandler(ctx *gin.Context) {
+	noteID, ok := uintParam(ctx, "id", invalidIDErr)
+	if !ok {
 		return
 	}
 
-	if err := validateNoteFields(&note); err != nil {
-		fmt.Println("Error:: ", err.Error())
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	contentType := ctx.ContentType()
+	if contentType != mergePatchContentType && contentType != jsonPatchContentType {
+		problem.Write(ctx, problem.New(http.StatusUnsupportedMediaType, "Content-Type must be "+mergePatchContentType+" or "+jsonPatchContentType))
 		return
 	}
 
-	err := c.noteService.CreateNote(&note)
+	version, ok := c.ifMatchVersion(ctx, noteID)
+	if !ok {
+		return
+	}
+
+	patch, err := io.ReadAll(ctx.Request.Body)
 	if err != nil {
-		if strings.Contains(err.Error(), "duplicate title") {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Duplicate title, please choose a different title"})
-			return
+		problem.Write(ctx, problem.New(http.StatusBadRequest, err.Error()))
+		return
+	}
+
+	note, err := c.noteService.PatchNote(ctx.Request.Context(), noteID, version, func(note *models.Note) error {
+		if err := applyNotePatch(note, contentType, patch); err != nil {
+			return err
+		}
+		return validateRequest(newUpdateNoteRequest(note))
+	}, changeInfo(ctx))
+	if err != nil {
+		var patchErr *patchError
+		var validationErrs validator.ValidationErrors
+		switch {
+		case errors.As(err, &patchErr):
+			problem.Write(ctx, problem.New(http.StatusUnprocessableEntity, err.Error()))
+		case errors.As(err, &validationErrs):
+			problem.Write(ctx, problem.Validation(err))
+		default:
+			ctx.Error(err)
 		}
+		return
+	}
 
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create note"})
+	setETag(ctx, note.Version)
+	c.version.one(ctx, http.StatusOK, "note", newNoteResponse(note, time.Now()), "Note updated successfully")
+}
+
+func (c *NoteController) DeleteNoteHandler(ctx *gin.Context) {
+	noteID, ok := uintParam(ctx, "id", invalidIDErr)
+	if !ok {
 		return
 	}
 
-	ctx.JSON(http.StatusCreated, gin.H{"message": "Note created successfully", "note": note})
+	version, ok := c.ifMatchVersion(ctx, noteID)
+	if !ok {
+		return
+	}
+
+	if err := c.noteService.DeleteNote(ctx.Request.Context(), noteID, version); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	c.version.deleted(ctx, "Note deleted successfully")
 }
 
-func (c *NoteController) UpdateNoteHandler(ctx *gin.Context) {
-	noteID, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+// BulkNotesHandler applies the create, update and delete operations of a
+// request and answers 200 when all of them succeed, or 207 Multi-Status with
+// the problem of each that failed.
+func (c *NoteController) BulkNotesHandler(ctx *gin.Context) {
+	var request bulkNotesRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		problem.Write(ctx, problem.Validation(err))
+		return
+	}
+	atomic := request.Mode != bulkModeBestEffort
+
+	results := make([]bulkResultResponse, len(request.Operations))
+	operations := make([]models.BulkOperation, 0, len(request.Operations))
+	indexes := make([]int, 0, len(request.Operations))
+	for i, operationRequest := range request.Operations {
+		results[i] = bulkResultResponse{Index: i, Action: models.BulkAction(operationRequest.Action)}
+		operation, err := operationRequest.toModel()
+		if err != nil {
+			results[i].Error = problem.Validation(err)
+			continue
+		}
+		operations = append(operations, operation)
+		indexes = append(indexes, i)
+	}
+
+	// Nothing is applied when a note of an atomic request is invalid
+	var bulkResults []models.BulkResult
+	if !atomic || len(operations) == len(request.Operations) {
+		var err error
+		bulkResults, err = c.noteService.BulkNotes(ctx.Request.Context(), operations, atomic, changeInfo(ctx))
+		if err != nil {
+			ctx.Error(err)
+			return
+		}
+	}
+
+	now := time.Now()
+	for i, result := range bulkResults {
+		r := &results[indexes[i]]
+		switch {
+		case result.Err != nil:
+			r.Error = bulkProblem(ctx, indexes[i], result.Err)
+		case r.Action == models.BulkCreate:
+			r.Status = http.StatusCreated
+		case r.Action == models.BulkUpdate:
+			r.Status = http.StatusOK
+		default:
+			r.Status = http.StatusNoContent
+		}
+		if result.Err == nil && result.Note != nil {
+			note := newNoteResponse(result.Note, now)
+			r.Note = &note
+		}
+	}
+
+	response := bulkNotesResponse{Results: results}
+	for i := range results {
+		r := &results[i]
+		if r.Error == nil && r.Status == 0 {
+			r.Error = bulkProblem(ctx, i, services.ErrBulkAborted)
+		}
+		if r.Error != nil {
+			r.Error.Translate(ctx.GetHeader("Accept-Language"))
+			r.Status = r.Error.Status
+			response.Failed++
+		} else {
+			response.Succeeded++
+		}
+	}
+
+	status := http.StatusOK
+	if response.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	c.version.one(ctx, status, "", response, "")
+}
+
+// bulkProblem describes why a bulk operation failed. Internal errors are
+// logged with their cause, as for single requests.
+func bulkProblem(ctx *gin.Context, index int, err error) *problem.Problem {
+	if errors.Is(err, services.ErrBulkAborted) {
+		return problem.New(http.StatusFailedDependency, "Not applied, another operation of the request failed")
+	}
+	if apperrors.KindOf(err) == apperrors.Internal {
+		log.Printf("%s %s: operation %d: %v", ctx.Request.Method, ctx.Request.URL.Path, index, err)
+	}
+	return problem.FromError(err)
+}
+
+// listNotesParams are the query parameters accepted when listing notes.
+type listNotesParams struct {
+	Limit          int        `form:"limit" binding:"omitempty,min=1,max=100"`
+	Cursor         string     `form:"cursor"`
+	Sort           string     `form:"sort" binding:"omitempty,oneof=deadline created_at updated_at title"`
+	Order          string     `form:"order" binding:"omitempty,oneof=asc desc"`
+	DeadlineBefore *time.Time `form:"deadline_before" time_format:"2006-01-02T15:04:05Z07:00"`
+	DeadlineAfter  *time.Time `form:"deadline_after" time_format:"2006-01-02T15:04:05Z07:00"`
+	Overdue        *bool      `form:"overdue"`
+}
+
+func (c *NoteController) GetAllNotesHandler(ctx *gin.Context) {
+	var params listNotesParams
+	if err := ctx.ShouldBindQuery(&params); err != nil {
+		problem.Write(ctx, problem.Validation(err))
+		return
+	}
+
+	page, err := c.noteService.ListNotes(ctx.Request.Context(), models.NoteListQuery{
+		Limit:          params.Limit,
+		Cursor:         params.Cursor,
+		Sort:           params.Sort,
+		Order:          params.Order,
+		DeadlineBefore: params.DeadlineBefore,
+		DeadlineAfter:  params.DeadlineAfter,
+		Overdue:        params.Overdue,
+	})
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": invalidIDErr})
+		ctx.Error(err)
 		return
 	}
 
-	var updatedNote models.Note
-	if err := ctx.ShouldBindJSON(&updatedNote); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	limit := params.Limit
+	if limit == 0 {
+		limit = repository.DefaultListLimit
+	}
+	c.version.many(ctx, "notes", newNoteResponses(page.Notes, time.Now()), pagination{
+		Total:      page.Total,
+		Limit:      limit,
+		NextCursor: page.NextCursor,
+		paged:      true,
+	})
+}
+
+// ExportNotesHandler streams the notes matching the filters of a list as a
+// CSV, JSON or NDJSON attachment. Notes are written as they are read, so an
+// error after the first of them were sent breaks the connection, for the
+// client to tell the export was cut short.
+func (c *NoteController) ExportNotesHandler(ctx *gin.Context) {
+	var params exportNotesParams
+	if err := ctx.ShouldBindQuery(&params); err != nil {
+		problem.Write(ctx, problem.Validation(err))
 		return
 	}
+	format := params.Format
+	if format == "" {
+		format = formatJSON
+	}
 
-	// Validate note fields
-	if err := validateNoteFields(&updatedNote); err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	encoder := newNoteEncoder(format, ctx.Writer)
+	started := false
+	start := func() {
+		if started {
+			return
+		}
+		started = true
+		ctx.Header("Content-Type", exportContentTypes[format])
+		ctx.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="notes.%s"`, format))
+		ctx.Status(http.StatusOK)
+	}
+
+	err := c.noteService.ExportNotes(ctx.Request.Context(), models.NoteListQuery{
+		Sort:           params.Sort,
+		Order:          params.Order,
+		DeadlineBefore: params.DeadlineBefore,
+		DeadlineAfter:  params.DeadlineAfter,
+		Overdue:        params.Overdue,
+	}, func(note *models.Note) error {
+		start()
+		extendWriteDeadline(ctx)
+		return encoder.encode(newExportedNote(note))
+	})
+	if err != nil && !started {
+		ctx.Error(err)
+		return
+	}
+	if err == nil {
+		start()
+		err = encoder.close()
+	}
+	if err != nil {
+		abortStream(ctx, "export", err)
+	}
+}
+
+// ImportNotesHandler creates notes from a CSV, JSON or NDJSON body, as its
+// Content-Type says, and reports what was done with each. Notes whose title
+// is taken are skipped, renamed or written over the stored note, as
+// on_conflict says; with dry_run nothing is written, and each row previews
+// the note it would write. It answers 200 when no note failed, or 207
+// Multi-Status with the problem of each that did.
+func (c *NoteController) ImportNotesHandler(ctx *gin.Context) {
+	var params importNotesParams
+	if err := ctx.ShouldBindQuery(&params); err != nil {
+		problem.Write(ctx, problem.Validation(err))
+		return
+	}
+	format, ok := importFormats[ctx.ContentType()]
+	if !ok {
+		problem.Write(ctx, problem.New(http.StatusUnsupportedMediaType, "Notes are imported as text/csv, application/json or application/x-ndjson"))
+		return
+	}
+
+	rows, err := readImport(format, ctx.Request.Body)
+	if errors.Is(err, errTooManyRows) {
+		problem.Write(ctx, problem.New(http.StatusRequestEntityTooLarge, fmt.Sprintf("An import holds at most %d notes", maxImportRows)))
+		return
+	}
+	if p := problem.TooLarge(err); p != nil {
+		problem.Write(ctx, p)
+		return
+	}
+	if err != nil {
+		problem.Write(ctx, problem.New(http.StatusBadRequest, "The import cannot be read: "+err.Error()))
 		return
 	}
 
-	// Set the ID of the note
-	updatedNote.ID = uint(noteID)
+	c.writeImportReport(ctx, rows, params)
+}
 
-	err = c.noteService.UpdateNote(&updatedNote)
-	// if err != nil {
-	// 	ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update note"})
-	// 	return
-	// }
+// ImportICSHandler creates notes from the events and to-dos of iCalendar
+// files, sent as a text/calendar body or as the files of a multipart form.
+// Recurring events are due at their next occurrence, and their alarms become
+// reminders. Titles that are taken and dry_run are handled as when importing
+// other formats, so a dry run previews the notes the files hold.
+func (c *NoteController) ImportICSHandler(ctx *gin.Context) {
+	var params importNotesParams
+	if err := ctx.ShouldBindQuery(&params); err != nil {
+		problem.Write(ctx, problem.Validation(err))
+		return
+	}
+	mediaType := ctx.ContentType()
+	if mediaType != "text/calendar" && mediaType != "multipart/form-data" {
+		problem.Write(ctx, problem.New(http.StatusUnsupportedMediaType, "iCalendar files are imported as text/calendar or multipart/form-data"))
+		return
+	}
+	location, err := services.DeadlineLocation()
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
 
+	readCtx, cancel := context.WithTimeout(ctx.Request.Context(), icsReadTimeout)
+	defer cancel()
+	var rows []importRow
+	files, err := icsFiles(ctx.Request, mediaType)
+	if err == nil {
+		rows, err = readICSImport(readCtx, files, location, time.Now())
+	}
+	if errors.Is(err, errTooManyRows) {
+		problem.Write(ctx, problem.New(http.StatusRequestEntityTooLarge, fmt.Sprintf("An import holds at most %d notes", maxImportRows)))
+		return
+	}
+	if p := problem.TooLarge(err); p != nil {
+		problem.Write(ctx, p)
+		return
+	}
 	if err != nil {
-		// Check for specific error messages
-		if strings.Contains(err.Error(), "duplicate title") {
-			ctx.JSON(http.StatusBadRequest, gin.H{"error": "Duplicate title, please choose a different title"})
+		problem.Write(ctx, problem.New(http.StatusBadRequest, "The import cannot be read: "+err.Error()))
+		return
+	}
+
+	c.writeImportReport(ctx, rows, params)
+}
+
+// writeImportReport imports the valid notes of rows and answers with the
+// report of every row: 200 when no note failed, or 207 Multi-Status.
+func (c *NoteController) writeImportReport(ctx *gin.Context, rows []importRow, params importNotesParams) {
+	report := importReportResponse{DryRun: params.DryRun, Rows: make([]importRowResponse, len(rows))}
+	notes := make([]*models.Note, 0, len(rows))
+	indexes := make([]int, 0, len(rows))
+	for i, row := range rows {
+		report.Rows[i] = importRowResponse{Row: i + 1}
+		switch {
+		case row.problem != nil:
+			report.Rows[i].Error = row.problem
+		case row.skip != "":
+			report.Rows[i].Action = string(models.ImportSkip)
+			report.Rows[i].Title = row.note.Title
+			report.Rows[i].Reason = row.skip
+		default:
+			notes = append(notes, row.note)
+			indexes = append(indexes, i)
+		}
+	}
+
+	policy := models.ConflictPolicy(params.OnConflict)
+	if policy == "" {
+		policy = models.ConflictSkip
+	}
+	var results []models.ImportResult
+	if len(notes) > 0 {
+		var err error
+		results, err = c.noteService.ImportNotes(ctx.Request.Context(), notes, policy, params.DryRun, changeInfo(ctx))
+		if err != nil {
+			ctx.Error(err)
 			return
 		}
+	}
+
+	now := time.Now()
+	for j, result := range results {
+		r := &report.Rows[indexes[j]]
+		r.Title = result.Note.Title
+		r.RenamedFrom = result.RenamedFrom
+		if result.Err != nil {
+			r.Error = importProblem(ctx, r.Row, result.Err)
+			continue
+		}
+		r.Action = string(result.Action)
+		if result.Action == models.ImportSkip {
+			r.Reason = skipReasonTitleTaken
+			continue
+		}
+		note := newNoteResponse(result.Note, now)
+		r.Note = &note
+	}
+
+	for i := range report.Rows {
+		r := &report.Rows[i]
+		switch {
+		case r.Error != nil:
+			r.Error.Translate(ctx.GetHeader("Accept-Language"))
+			r.Action = importActionFailed
+			report.Failed++
+		case r.Action == string(models.ImportCreate):
+			report.Created++
+		case r.Action == string(models.ImportUpdate):
+			report.Updated++
+		default:
+			report.Skipped++
+		}
+	}
+
+	status := http.StatusOK
+	if report.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+	c.version.one(ctx, status, "", report, "")
+}
+
+// importProblem describes why a note of an import failed. Internal errors
+// are logged with their cause, as for single requests.
+func importProblem(ctx *gin.Context, row int, err error) *problem.Problem {
+	if apperrors.KindOf(err) == apperrors.Internal {
+		log.Printf("%s %s: row %d: %v", ctx.Request.Method, ctx.Request.URL.Path, row, err)
+	}
+	return problem.FromError(err)
+}
+
+func (c *NoteController) GetNoteByIDHandler(ctx *gin.Context) {
+	noteID, ok := uintParam(ctx, "id", invalidIDErr)
+	if !ok {
+		return
+	}
 
-		// Handle other error cases
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update note"})
+	note, err := c.noteService.GetNoteById(ctx.Request.Context(), noteID)
+	if err != nil {
+		ctx.Error(err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"message": "Note updated successfully", "note": updatedNote})
+	setETag(ctx, note.Version)
+	c.version.one(ctx, http.StatusOK, "note", newNoteResponse(note, time.Now()), "")
 }
 
-func (c *NoteController) DeleteNoteHandler(ctx *gin.Context) {
-	noteID, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+func (nc *NoteController) SearchNotesHandler(ctx *gin.Context) {
+	search := ctx.Query("query")
+	if search == "" {
+		problem.Write(ctx, problem.New(http.StatusBadRequest, "Query parameter 'query' is required"))
+		return
+	}
+
+	var notes []*models.NoteSearchResult
+	var err error
+	switch ctx.DefaultQuery("mode", "keyword") {
+	case "keyword":
+		notes, err = nc.noteService.SearchNotes(ctx.Request.Context(), search)
+	case "fuzzy":
+		threshold := defaultSimilarityThreshold
+		if value, ok := ctx.GetQuery("threshold"); ok {
+			threshold, err = strconv.ParseFloat(value, 64)
+			if err != nil || threshold <= 0 || threshold > 1 {
+				problem.Write(ctx, problem.New(http.StatusBadRequest, "Query parameter 'threshold' must be a number in (0, 1]"))
+				return
+			}
+		}
+		notes, err = nc.noteService.FuzzySearchNotes(ctx.Request.Context(), search, threshold)
+	default:
+		problem.Write(ctx, problem.New(http.StatusBadRequest, "Query parameter 'mode' must be 'keyword' or 'fuzzy'"))
+		return
+	}
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": invalidIDErr})
+		ctx.Error(err)
 		return
 	}
 
-	err = c.noteService.DeleteNote(uint(noteID))
+	nc.version.many(ctx, "", newNoteSearchResultResponses(notes, time.Now()), pagination{Total: int64(len(notes))})
+}
+
+func (nc *NoteController) SuggestTitlesHandler(ctx *gin.Context) {
+	prefix := ctx.Query("prefix")
+	if prefix == "" {
+		problem.Write(ctx, problem.New(http.StatusBadRequest, "Query parameter 'prefix' is required"))
+		return
+	}
+
+	limit := defaultSuggestionLimit
+	if value, ok := ctx.GetQuery("limit"); ok {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > 50 {
+			problem.Write(ctx, problem.New(http.StatusBadRequest, "Query parameter 'limit' must be between 1 and 50"))
+			return
+		}
+		limit = parsed
+	}
+
+	titles, err := nc.noteService.SuggestTitles(ctx.Request.Context(), prefix, limit)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete note"})
+		ctx.Error(err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"message": "Note deleted successfully"})
+	nc.version.many(ctx, "suggestions", titles, pagination{Total: int64(len(titles)), Limit: limit})
 }
 
-func (c *NoteController) GetAllNotesHandler(ctx *gin.Context) {
-	notes, err := c.noteService.GetAllNotes()
+func (c *NoteController) GetNoteRevisionsHandler(ctx *gin.Context) {
+	noteID, ok := uintParam(ctx, "id", invalidIDErr)
+	if !ok {
+		return
+	}
+
+	revisions, err := c.noteService.GetNoteRevisions(ctx.Request.Context(), noteID)
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve notes"})
+		ctx.Error(err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"notes": notes})
+	c.version.many(ctx, "revisions", newNoteRevisionResponses(revisions), pagination{Total: int64(len(revisions))})
 }
 
-func (c *NoteController) GetNoteByIDHandler(ctx *gin.Context) {
-	noteID, err := strconv.ParseUint(ctx.Param("id"), 10, 64)
+func (c *NoteController) GetNoteRevisionHandler(ctx *gin.Context) {
+	noteID, ok := uintParam(ctx, "id", invalidIDErr)
+	if !ok {
+		return
+	}
+
+	rev, ok := uintParam(ctx, "rev", invalidRevisionErr)
+	if !ok {
+		return
+	}
+
+	revision, err := c.noteService.GetNoteRevision(ctx.Request.Context(), noteID, rev)
 	if err != nil {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": invalidIDErr})
+		ctx.Error(err)
 		return
 	}
 
-	note, err := c.noteService.GetNoteById(uint(noteID))
+	c.version.one(ctx, http.StatusOK, "revision", newNoteRevisionResponse(revision), "")
+}
+
+func (c *NoteController) DiffNoteRevisionsHandler(ctx *gin.Context) {
+	noteID, ok := uintParam(ctx, "id", invalidIDErr)
+	if !ok {
+		return
+	}
+
+	from, err := strconv.ParseUint(ctx.Query("from"), 10, 64)
 	if err != nil {
-		ctx.JSON(http.StatusNotFound, gin.H{"error": "Note not found"})
+		problem.Write(ctx, problem.New(http.StatusBadRequest, "Query parameter 'from' must be a revision number"))
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gin.H{"note": note})
+	to, err := strconv.ParseUint(ctx.Query("to"), 10, 64)
+	if err != nil {
+		problem.Write(ctx, problem.New(http.StatusBadRequest, "Query parameter 'to' must be a revision number"))
+		return
+	}
+
+	changes, err := c.noteService.DiffNoteRevisions(ctx.Request.Context(), noteID, uint(from), uint(to))
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	c.version.one(ctx, http.StatusOK, "", noteDiffResponse{From: from, To: to, Changes: changes}, "")
 }
 
-func (nc *NoteController) SearchNotesHandler(ctx *gin.Context) {
-	query := ctx.Query("query")
-	if query == "" {
-		ctx.JSON(http.StatusBadRequest, gin.H{"error": "Query parameter 'query' is required"})
+func (c *NoteController) RestoreNoteRevisionHandler(ctx *gin.Context) {
+	noteID, ok := uintParam(ctx, "id", invalidIDErr)
+	if !ok {
 		return
 	}
 
-	notes, err := nc.noteService.SearchNotes(query)
+	rev, ok := uintParam(ctx, "rev", invalidRevisionErr)
+	if !ok {
+		return
+	}
+
+	note, err := c.noteService.RestoreNoteRevision(ctx.Request.Context(), noteID, rev, changeInfo(ctx))
 	if err != nil {
-		ctx.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search notes"})
+		ctx.Error(err)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, notes)
+	setETag(ctx, note.Version)
+	c.version.one(ctx, http.StatusOK, "note", newNoteResponse(note, time.Now()), "Note restored successfully")
 }
 
-func validateNoteFields(note *models.Note) error {
-	validate = validator.New()
+// uintParam reads a numeric path parameter. Requests routed through the
+// OpenAPI middleware have been checked already; for others it writes the
+// error response and returns false when the parameter is not a number.
+func uintParam(ctx *gin.Context, name, invalidErr string) (uint, bool) {
+	value, err := strconv.ParseUint(ctx.Param(name), 10, 64)
+	if err != nil {
+		problem.Write(ctx, problem.New(http.StatusBadRequest, invalidErr))
+		return 0, false
+	}
+	return uint(value), true
+}
 
-	// Validate title
-	if err := validate.Var(note.Title, "required,min=3,max=50"); err != nil {
-		return err
+// setETag exposes the note version so clients can make conditional requests.
+func setETag(ctx *gin.Context, version uint) {
+	ctx.Header("ETag", strconv.Quote(strconv.FormatUint(uint64(version), 10)))
+}
+
+// ifMatchVersion reads the note version the client expects from the If-Match
+// header: models.AnyVersion for *, which only requires the note to exist. The
+// header may list several ETags, any of which may match; the version of the
+// note is then looked up, and the one matching it, if any, returned. It
+// writes the error response and returns false when the header is missing or
+// malformed, or holds only weak ETags, which never match as If-Match compares
+// ETags strongly.
+func (c *NoteController) ifMatchVersion(ctx *gin.Context, noteID uint) (uint, bool) {
+	header := strings.TrimSpace(ctx.GetHeader("If-Match"))
+	if header == "" {
+		problem.Write(ctx, problem.New(http.StatusPreconditionRequired, "If-Match header with the note ETag is required"))
+		return 0, false
+	}
+	if header == "*" {
+		return models.AnyVersion, true
+	}
+
+	var versions []uint
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if strings.HasPrefix(tag, "W/") {
+			continue
+		}
+		version, err := strconv.ParseUint(strings.Trim(tag, `"`), 10, 64)
+		if err != nil || version == 0 {
+			problem.Write(ctx, problem.New(http.StatusBadRequest, "If-Match header must be a note ETag"))
+			return 0, false
+		}
+		versions = append(versions, uint(version))
+	}
+	switch len(versions) {
+	case 0:
+		problem.Write(ctx, problem.New(http.StatusPreconditionFailed, "If-Match header must hold the strong note ETag, not a weak one"))
+		return 0, false
+	case 1:
+		return versions[0], true
 	}
 
-	// // Validate deadline format
-	// if err := validate.Var(note.Deadline, "required,rfc3339"); err != nil {
-	//     return err
-	// }
+	note, err := c.noteService.GetNoteById(ctx.Request.Context(), noteID)
+	if err != nil {
+		ctx.Error(err)
+		return 0, false
+	}
+	// When no ETag matches, the write fails with the usual version conflict.
+	if slices.Contains(versions, note.Version) {
+		return note.Version, true
+	}
+	return versions[0], true
+}
 
-	return nil
+// changeInfo extracts who is making a change, and why, from the request headers.
+func changeInfo(ctx *gin.Context) models.ChangeInfo {
+	return models.ChangeInfo{
+		Author: ctx.GetHeader("X-User"),
+		Reason: ctx.GetHeader("X-Change-Reason"),
+	}
 }
@@ -1,18 +1,28 @@
 package controllers_test
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/sarita-growexx/note_with_alarm/apperrors"
 	"github.com/sarita-growexx/note_with_alarm/controllers"
+	"github.com/sarita-growexx/note_with_alarm/middleware"
 	"github.com/sarita-growexx/note_with_alarm/models"
+	"github.com/sarita-growexx/note_with_alarm/problem"
+	"github.com/sarita-growexx/note_with_alarm/query"
+	"github.com/sarita-growexx/note_with_alarm/repository"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -25,39 +35,132 @@ type MockNoteService struct {
 // Implement methods for the mock note service
 
 // Mock CreateNote method
-func (m *MockNoteService) CreateNote(note *models.Note) error {
-	args := m.Called(note)
+func (m *MockNoteService) CreateNote(ctx context.Context, note *models.Note, change models.ChangeInfo) error {
+	args := m.Called(note, change)
 	return args.Error(0)
 }
 
 // Mock UpdateNote method
-func (m *MockNoteService) UpdateNote(note *models.Note) error {
-	args := m.Called(note)
+func (m *MockNoteService) UpdateNote(ctx context.Context, note *models.Note, change models.ChangeInfo) error {
+	args := m.Called(note, change)
 	return args.Error(0)
 }
 
+// Mock PatchNote method. The stored note returned by the expectation is
+// passed through the handler's patch function.
+func (m *MockNoteService) PatchNote(ctx context.Context, id uint, version uint, apply func(note *models.Note) error, change models.ChangeInfo) (*models.Note, error) {
+	args := m.Called(id, version, change)
+	if err := args.Error(1); err != nil {
+		return nil, err
+	}
+
+	patched := *args.Get(0).(*models.Note)
+	if err := apply(&patched); err != nil {
+		return nil, err
+	}
+	patched.Version++
+	return &patched, nil
+}
+
 // Mock DeleteNote method
-func (m *MockNoteService) DeleteNote(id uint) error {
-	args := m.Called(id)
+func (m *MockNoteService) DeleteNote(ctx context.Context, id uint, version uint) error {
+	args := m.Called(id, version)
 	return args.Error(0)
 }
 
+// Mock BulkNotes method
+func (m *MockNoteService) BulkNotes(ctx context.Context, operations []models.BulkOperation, atomic bool, change models.ChangeInfo) ([]models.BulkResult, error) {
+	args := m.Called(operations, atomic, change)
+	results, _ := args.Get(0).([]models.BulkResult)
+	return results, args.Error(1)
+}
+
 // Mock GetAllNotes method
-func (m *MockNoteService) GetAllNotes() ([]*models.Note, error) {
+func (m *MockNoteService) GetAllNotes(ctx context.Context) ([]*models.Note, error) {
 	args := m.Called()
 	return args.Get(0).([]*models.Note), args.Error(1)
 }
 
+// Mock ListNotes method
+func (m *MockNoteService) ListNotes(ctx context.Context, query models.NoteListQuery) (*models.NotePage, error) {
+	args := m.Called(query)
+	page, _ := args.Get(0).(*models.NotePage)
+	return page, args.Error(1)
+}
+
+// Mock ExportNotes method, which passes the notes it is set up with to fn
+func (m *MockNoteService) ExportNotes(ctx context.Context, query models.NoteListQuery, fn func(note *models.Note) error) error {
+	args := m.Called(query)
+	notes, _ := args.Get(0).([]*models.Note)
+	for _, note := range notes {
+		if err := fn(note); err != nil {
+			return err
+		}
+	}
+	return args.Error(1)
+}
+
+// Mock ImportNotes method
+func (m *MockNoteService) ImportNotes(ctx context.Context, notes []*models.Note, policy models.ConflictPolicy, dryRun bool, change models.ChangeInfo) ([]models.ImportResult, error) {
+	args := m.Called(notes, policy, dryRun, change)
+	results, _ := args.Get(0).([]models.ImportResult)
+	return results, args.Error(1)
+}
+
 // Mock GetNoteById method
-func (m *MockNoteService) GetNoteById(id uint) (*models.Note, error) {
+func (m *MockNoteService) GetNoteById(ctx context.Context, id uint) (*models.Note, error) {
 	args := m.Called(id)
-	return args.Get(0).(*models.Note), args.Error(1)
+	note, _ := args.Get(0).(*models.Note)
+	return note, args.Error(1)
 }
 
 // Mock SearchNotes method
-func (m *MockNoteService) SearchNotes(query string) ([]*models.Note, error) {
+func (m *MockNoteService) SearchNotes(ctx context.Context, query string) ([]*models.NoteSearchResult, error) {
 	args := m.Called(query)
-	return args.Get(0).([]*models.Note), args.Error(1)
+	results, _ := args.Get(0).([]*models.NoteSearchResult)
+	return results, args.Error(1)
+}
+
+// Mock FuzzySearchNotes method
+func (m *MockNoteService) FuzzySearchNotes(ctx context.Context, query string, threshold float64) ([]*models.NoteSearchResult, error) {
+	args := m.Called(query, threshold)
+	results, _ := args.Get(0).([]*models.NoteSearchResult)
+	return results, args.Error(1)
+}
+
+// Mock SuggestTitles method
+func (m *MockNoteService) SuggestTitles(ctx context.Context, prefix string, limit int) ([]string, error) {
+	args := m.Called(prefix, limit)
+	titles, _ := args.Get(0).([]string)
+	return titles, args.Error(1)
+}
+
+// Mock GetNoteRevisions method
+func (m *MockNoteService) GetNoteRevisions(ctx context.Context, id uint) ([]*models.NoteRevision, error) {
+	args := m.Called(id)
+	revisions, _ := args.Get(0).([]*models.NoteRevision)
+	return revisions, args.Error(1)
+}
+
+// Mock GetNoteRevision method
+func (m *MockNoteService) GetNoteRevision(ctx context.Context, id uint, revision uint) (*models.NoteRevision, error) {
+	args := m.Called(id, revision)
+	rev, _ := args.Get(0).(*models.NoteRevision)
+	return rev, args.Error(1)
+}
+
+// Mock DiffNoteRevisions method
+func (m *MockNoteService) DiffNoteRevisions(ctx context.Context, id uint, from uint, to uint) ([]models.FieldChange, error) {
+	args := m.Called(id, from, to)
+	changes, _ := args.Get(0).([]models.FieldChange)
+	return changes, args.Error(1)
+}
+
+// Mock RestoreNoteRevision method
+func (m *MockNoteService) RestoreNoteRevision(ctx context.Context, id uint, revision uint, change models.ChangeInfo) (*models.Note, error) {
+	args := m.Called(id, revision, change)
+	note, _ := args.Get(0).(*models.Note)
+	return note, args.Error(1)
 }
 
 // Test CreateNoteHandler function
@@ -68,11 +171,14 @@ func TestCreateNoteHandler(t *testing.T) {
 	controller := controllers.NewNoteController(mockService)
 
 	router := gin.Default()
+	router.Use(middleware.Errors())
 	router.POST("/note", controller.CreateNoteHandler)
 
+	deadline := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+
 	// Test case: Successful note creation
-	note := &models.Note{Title: "Test Note"}
-	mockService.On("CreateNote", note).Return(nil)
+	note := &models.Note{Title: "Test Note", Deadline: deadline}
+	mockService.On("CreateNote", note, models.ChangeInfo{}).Return(nil)
 	body, _ := json.Marshal(note)
 	req, _ := http.NewRequest("POST", "/note", strings.NewReader(string(body)))
 	req.Header.Set("Content-Type", "application/json")
@@ -81,6 +187,16 @@ func TestCreateNoteHandler(t *testing.T) {
 	assert.Equal(t, http.StatusCreated, w.Code)
 	mockService.AssertExpectations(t)
 
+	// Test case: Read-only fields are ignored
+	readOnly := `{"id":99,"version":7,"created_at":"2020-01-01T00:00:00Z","updated_at":"2020-01-01T00:00:00Z","title":"Read Only","deadline":"` + deadline.Format(time.RFC3339) + `"}`
+	mockService.On("CreateNote", &models.Note{Title: "Read Only", Deadline: deadline}, models.ChangeInfo{}).Return(nil).Once()
+	req, _ = http.NewRequest("POST", "/note", strings.NewReader(readOnly))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	mockService.AssertExpectations(t)
+
 	// Test case: Invalid request body
 	req, _ = http.NewRequest("POST", "/note", strings.NewReader("invalid json"))
 	req.Header.Set("Content-Type", "application/json")
@@ -89,20 +205,20 @@ func TestCreateNoteHandler(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 
 	// Test case: Duplicate title
-	duplicateNote := &models.Note{Title: "Duplicate Title"}
-	mockService.On("CreateNote", duplicateNote).Return(errors.New("duplicate title"))
+	duplicateNote := &models.Note{Title: "Duplicate Title", Deadline: deadline}
+	mockService.On("CreateNote", duplicateNote, models.ChangeInfo{}).Return(repository.ErrDuplicateTitle)
 	body, _ = json.Marshal(duplicateNote)
 	req, _ = http.NewRequest("POST", "/note", strings.NewReader(string(body)))
 	req.Header.Set("Content-Type", "application/json")
 	w = httptest.NewRecorder()
 	router.ServeHTTP(w, req)
-	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, http.StatusConflict, w.Code)
 	assert.Contains(t, w.Body.String(), "Duplicate title, please choose a different title")
 	mockService.AssertExpectations(t)
 
 	// Test case: Failed note creation
-	invalidNote := &models.Note{Title: "Invalid Note"} // Note without required fields
-	mockService.On("CreateNote", invalidNote).Return(errors.New("invalid note"))
+	invalidNote := &models.Note{Title: "Invalid Note", Deadline: deadline}
+	mockService.On("CreateNote", invalidNote, models.ChangeInfo{}).Return(errors.New("invalid note"))
 	body, _ = json.Marshal(invalidNote)
 	req, _ = http.NewRequest("POST", "/note", strings.NewReader(string(body)))
 	req.Header.Set("Content-Type", "application/json")
@@ -118,9 +234,65 @@ func TestCreateNoteHandler(t *testing.T) {
 	w = httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 	assert.Equal(t, http.StatusBadRequest, w.Code)
-	assert.Contains(t, w.Body.String(), "Field validation for '' failed on the 'required' tag") // Check for the actual error message
-	mockService.AssertNotCalled(t, "CreateNote")                                                // Ensure CreateNote is not called when validation fails
+	assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{
+		"type": "/problems/validation-error",
+		"title": "Bad Request",
+		"status": 400,
+		"detail": "The request is invalid",
+		"instance": "/note",
+		"errors": [
+			{"field": "title", "rule": "required", "message": "is required"},
+			{"field": "deadline", "rule": "required", "message": "is required"}
+		]
+	}`, w.Body.String())
+	mockService.AssertNotCalled(t, "CreateNote") // Ensure CreateNote is not called when validation fails
+
+	// Test case: Each invalid field is listed with the rule it broke
+	req, _ = http.NewRequest("POST", "/note", strings.NewReader(`{"title":"ab"}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `{"field":"title","rule":"min","param":"3","message":"must be at least 3 characters"}`)
+
+	req, _ = http.NewRequest("POST", "/note", strings.NewReader(`{"title":42}`))
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"field":"title","rule":"type"`)
+
+	// Test case: Deadlines, descriptions, tags and reminders are checked
+	invalidFields := []struct {
+		name  string
+		body  string
+		field string
+	}{
+		{"past deadline", `{"title":"Test Note","deadline":"2020-01-01T00:00:00Z"}`, `{"field":"deadline","rule":"future","message":"must be in the future"}`},
+		{"long description", `{"title":"Test Note","deadline":"` + deadline.Format(time.RFC3339) + `","description":"` + strings.Repeat("a", 2001) + `"}`, `{"field":"description","rule":"max","param":"2000","message":"must be at most 2000 characters"}`},
+		{"bad tag", `{"title":"Test Note","deadline":"` + deadline.Format(time.RFC3339) + `","tags":["ok","not ok"]}`, `{"field":"tags[1]","rule":"tagname","message":"must be 1 to 32 letters, digits, underscores or hyphens"}`},
+		{"duplicate tags", `{"title":"Test Note","deadline":"` + deadline.Format(time.RFC3339) + `","tags":["work","work"]}`, `{"field":"tags","rule":"unique","message":"must not contain duplicates"}`},
+		{"reminder too early", `{"title":"Test Note","deadline":"` + deadline.Format(time.RFC3339) + `","reminders":[60,50000]}`, `{"field":"reminders[1]","rule":"max","param":"43200","message":"must be at most 43200"}`},
+		{"reminder not before deadline", `{"title":"Test Note","deadline":"` + deadline.Format(time.RFC3339) + `","reminders":[0]}`, `{"field":"reminders[0]","rule":"min","param":"1","message":"must be at least 1"}`},
+	}
+	for _, tc := range invalidFields {
+		req, _ = http.NewRequest("POST", "/note", strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, tc.name)
+		assert.Contains(t, w.Body.String(), tc.field, tc.name)
+	}
 
+	// Test case: Messages follow Accept-Language
+	req, _ = http.NewRequest("POST", "/note", strings.NewReader(`{"title":"ab","deadline":"`+deadline.Format(time.RFC3339)+`"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept-Language", "es-MX,en;q=0.5")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `"message":"debe tener al menos 3 caracteres"`)
 }
 
 // Test UpdateNoteHandler function
@@ -131,14 +303,17 @@ func TestUpdateNoteHandler(t *testing.T) {
 	controller := controllers.NewNoteController(mockService)
 
 	router := gin.Default()
+	router.Use(middleware.Errors())
 	router.PUT("/note/:id", controller.UpdateNoteHandler)
 
 	// Test case: Successful note update
 	noteID := uint(1)
-	note := &models.Note{ID: noteID, Title: "Updated Note"}
-	mockService.On("UpdateNote", note).Return(nil)
+	deadline := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	note := &models.Note{ID: noteID, Title: "Updated Note", Deadline: deadline, Version: 1}
+	mockService.On("UpdateNote", note, models.ChangeInfo{}).Return(nil)
 	body, _ := json.Marshal(note)
 	req, _ := http.NewRequest("PUT", "/note/"+strconv.Itoa(int(noteID)), strings.NewReader(string(body)))
+	req.Header.Set("If-Match", `"1"`)
 	req.Header.Set("Content-Type", "application/json")
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
@@ -155,24 +330,36 @@ func TestUpdateNoteHandler(t *testing.T) {
 	invalidNote := &models.Note{} // Empty note, which should fail validation
 	body, _ = json.Marshal(invalidNote)
 	req, _ = http.NewRequest("PUT", "/note/1", strings.NewReader(string(body))) // Assuming note ID is 1
+	req.Header.Set("If-Match", `"1"`)
 	req.Header.Set("Content-Type", "application/json")
 	w = httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 	assert.Equal(t, http.StatusBadRequest, w.Code)
-	assert.Contains(t, w.Body.String(), "Field validation for '' failed on the 'required' tag") // Check for the actual error message
-	mockService.AssertNotCalled(t, "UpdateNote")                                                // Ensure UpdateNote is not called when validation fails
+	assert.Contains(t, w.Body.String(), `"errors":[{"field":"title","rule":"required","message":"is required"},{"field":"deadline","rule":"required","message":"is required"}]`)
+	mockService.AssertNotCalled(t, "UpdateNote") // Ensure UpdateNote is not called when validation fails
 
 	// Test case: Duplicate title error
-	duplicateTitleNote := &models.Note{ID: noteID, Title: "Duplicate Title"}
-	mockService.On("UpdateNote", duplicateTitleNote).Return(errors.New("duplicate title"))
+	duplicateTitleNote := &models.Note{ID: noteID, Title: "Duplicate Title", Deadline: deadline, Version: 1}
+	mockService.On("UpdateNote", duplicateTitleNote, models.ChangeInfo{}).Return(repository.ErrDuplicateTitle)
 	body, _ = json.Marshal(duplicateTitleNote)
 	req, _ = http.NewRequest("PUT", "/note/"+strconv.Itoa(int(noteID)), strings.NewReader(string(body)))
+	req.Header.Set("If-Match", `"1"`)
 	req.Header.Set("Content-Type", "application/json")
 	w = httptest.NewRecorder()
 	router.ServeHTTP(w, req)
-	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, http.StatusConflict, w.Code)
 	assert.Contains(t, w.Body.String(), "Duplicate title, please choose a different title") // Check for the specific error message
 
+	// Test case: Overdue notes can still be edited
+	overdueNote := &models.Note{ID: noteID, Title: "Overdue Note", Deadline: time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC), Version: 1}
+	mockService.On("UpdateNote", overdueNote, models.ChangeInfo{}).Return(nil)
+	body, _ = json.Marshal(overdueNote)
+	req, _ = http.NewRequest("PUT", "/note/"+strconv.Itoa(int(noteID)), strings.NewReader(string(body)))
+	req.Header.Set("If-Match", `"1"`)
+	req.Header.Set("Content-Type", "application/json")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
 }
 
 // Test DeleteNoteHandler function
@@ -183,12 +370,14 @@ func TestDeleteNoteHandler(t *testing.T) {
 	controller := controllers.NewNoteController(mockService)
 
 	router := gin.Default()
+	router.Use(middleware.Errors())
 	router.DELETE("/note/:id", controller.DeleteNoteHandler)
 
 	// Test case: Successful note deletion
 	noteID := uint(1)
-	mockService.On("DeleteNote", noteID).Return(nil)
+	mockService.On("DeleteNote", noteID, uint(1)).Return(nil)
 	req, _ := http.NewRequest("DELETE", "/note/"+strconv.Itoa(int(noteID)), nil)
+	req.Header.Set("If-Match", `"1"`)
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 	assert.Equal(t, http.StatusOK, w.Code)
@@ -202,12 +391,14 @@ func TestDeleteNoteHandler(t *testing.T) {
 
 	// Test case: Failed to delete note
 	noteID = uint(2)
-	mockService.On("DeleteNote", noteID).Return(errors.New("failed to delete note"))
+	mockService.On("DeleteNote", noteID, uint(1)).Return(apperrors.Wrap(apperrors.Internal, "failed to delete note", errors.New("connection refused")))
 	req, _ = http.NewRequest("DELETE", "/note/"+strconv.Itoa(int(noteID)), nil)
+	req.Header.Set("If-Match", `"1"`)
 	w = httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 	assert.Equal(t, http.StatusInternalServerError, w.Code)
 	assert.Contains(t, w.Body.String(), "Failed to delete note")
+	assert.NotContains(t, w.Body.String(), "connection refused")
 }
 
 // Test GetAllNotesHandler function
@@ -219,6 +410,7 @@ func TestGetAllNotesHandler(t *testing.T) {
 	controller := controllers.NewNoteController(mockService)
 
 	router := gin.Default()
+	router.Use(middleware.Errors())
 	router.GET("/notes", controller.GetAllNotesHandler)
 
 	// Test case: Failure to retrieve notes
@@ -227,9 +419,10 @@ func TestGetAllNotesHandler(t *testing.T) {
 		controller := controllers.NewNoteController(mockService)
 
 		router := gin.Default()
+		router.Use(middleware.Errors())
 		router.GET("/notes", controller.GetAllNotesHandler)
 
-		mockService.On("GetAllNotes").Return(nil, errors.New("failed to retrieve notes"))
+		mockService.On("ListNotes", models.NoteListQuery{}).Return(nil, errors.New("failed to retrieve notes"))
 		req, _ := http.NewRequest("GET", "/notes", nil)
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, req)
@@ -242,10 +435,11 @@ func TestGetAllNotesHandler(t *testing.T) {
 		controller := controllers.NewNoteController(mockService)
 
 		router := gin.Default()
+		router.Use(middleware.Errors())
 		router.GET("/notes", controller.GetAllNotesHandler)
 
 		notes := []*models.Note{{ID: 1, Title: "Test Note 1"}, {ID: 2, Title: "Test Note 2"}}
-		mockService.On("GetAllNotes").Return(notes, nil)
+		mockService.On("ListNotes", models.NoteListQuery{}).Return(&models.NotePage{Notes: notes, Total: 2}, nil)
 		req, _ := http.NewRequest("GET", "/notes", nil)
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, req)
@@ -253,6 +447,58 @@ func TestGetAllNotesHandler(t *testing.T) {
 		assert.NotNil(t, w.Body)
 	})
 
+	// Test case: Paginated, sorted and filtered retrieval
+	t.Run("Paginated retrieval of notes", func(t *testing.T) {
+		mockService := new(MockNoteService)
+		controller := controllers.NewNoteController(mockService)
+
+		router := gin.Default()
+		router.Use(middleware.Errors())
+		router.GET("/notes", controller.GetAllNotesHandler)
+
+		overdue := true
+		before := time.Date(2024, 3, 13, 12, 0, 0, 0, time.UTC)
+		query := models.NoteListQuery{Limit: 2, Cursor: "abc", Sort: "deadline", Order: "desc", DeadlineBefore: &before, Overdue: &overdue}
+		page := &models.NotePage{Notes: []*models.Note{{ID: 3, Title: "Test Note 3"}}, NextCursor: "def", Total: 7}
+		mockService.On("ListNotes", mock.MatchedBy(func(q models.NoteListQuery) bool {
+			return q.Limit == query.Limit && q.Cursor == query.Cursor && q.Sort == query.Sort && q.Order == query.Order &&
+				q.DeadlineBefore.Equal(before) && q.DeadlineAfter == nil && *q.Overdue
+		})).Return(page, nil)
+		req, _ := http.NewRequest("GET", "/notes?limit=2&cursor=abc&sort=deadline&order=desc&deadline_before=2024-03-13T12:00:00Z&overdue=true", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"next_cursor":"def"`)
+		assert.Contains(t, w.Body.String(), `"total":7`)
+		mockService.AssertExpectations(t)
+	})
+
+	// Test case: Invalid listing parameters
+	t.Run("Invalid listing parameters", func(t *testing.T) {
+		mockService := new(MockNoteService)
+		controller := controllers.NewNoteController(mockService)
+
+		router := gin.Default()
+		router.Use(middleware.Errors())
+		router.GET("/notes", controller.GetAllNotesHandler)
+
+		for query, field := range map[string]string{"sort=color": "sort", "limit=1000": "limit", "order=up": "order", "deadline_after=tomorrow": ""} {
+			req, _ := http.NewRequest("GET", "/notes?"+query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+			if field != "" {
+				assert.Contains(t, w.Body.String(), `"field":"`+field+`"`, query)
+			}
+		}
+
+		mockService.On("ListNotes", models.NoteListQuery{Cursor: "bogus"}).Return(nil, repository.ErrInvalidCursor)
+		req, _ := http.NewRequest("GET", "/notes?cursor=bogus", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
 }
 
 // Test GetNoteByIDHandler function
@@ -264,6 +510,7 @@ func TestGetNoteByIDHandler(t *testing.T) {
 	controller := controllers.NewNoteController(mockService)
 
 	router := gin.Default()
+	router.Use(middleware.Errors())
 	router.GET("/note/:id", controller.GetNoteByIDHandler)
 
 	// Test case: Note found
@@ -279,15 +526,48 @@ func TestGetNoteByIDHandler(t *testing.T) {
 	w := httptest.NewRecorder()
 	router.ServeHTTP(w, req)
 	assert.Equal(t, http.StatusOK, w.Code) // Note found, so status should be OK
-	// Add assertion to check the response body content if necessary
+	assert.Contains(t, w.Body.String(), `"overdue":true,"remaining_seconds":0,"next_alert_at":null`)
+
+	// Test case: Computed fields of a pending note, whose day-ahead reminder
+	// has passed but whose hour-ahead one is still to come
+	deadline := time.Now().Add(3 * time.Hour).UTC().Truncate(time.Second)
+	mockService.On("GetNoteById", uint(4)).Return(&models.Note{ID: 4, Title: "Pending Note", Deadline: deadline, Reminders: models.Reminders{24 * 60, 60}}, nil)
+
+	req, _ = http.NewRequest("GET", "/note/4", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var found struct {
+		Note struct {
+			Overdue          bool      `json:"overdue"`
+			RemainingSeconds int64     `json:"remaining_seconds"`
+			NextAlertAt      time.Time `json:"next_alert_at"`
+			Tags             []string  `json:"tags"`
+		} `json:"note"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &found))
+	assert.False(t, found.Note.Overdue)
+	assert.InDelta(t, 3*60*60, found.Note.RemainingSeconds, 5)
+	assert.True(t, deadline.Add(-time.Hour).Equal(found.Note.NextAlertAt))
+	assert.Equal(t, []string{}, found.Note.Tags)
 
 	// Test case: Note not found
-	mockService.On("GetNoteById", noteID).Return(nil, errors.New("Note not found"))
+	mockService.On("GetNoteById", uint(2)).Return(nil, repository.ErrNoteNotFound)
+
+	req, _ = http.NewRequest("GET", "/note/2", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.JSONEq(t, `{"type":"about:blank","title":"Not Found","status":404,"detail":"Note not found","instance":"/note/2"}`, w.Body.String())
+
+	// Test case: Database outage is not reported as a missing note
+	mockService.On("GetNoteById", uint(3)).Return(nil, apperrors.Wrap(apperrors.Internal, "failed to get note by ID", errors.New("connection refused")))
 
-	req, _ = http.NewRequest("GET", "/note/"+strconv.Itoa(int(noteID)), nil)
+	req, _ = http.NewRequest("GET", "/note/3", nil)
 	w = httptest.NewRecorder()
 	router.ServeHTTP(w, req)
-	assert.Equal(t, http.StatusNotFound, 404) // Note not found, so status should be Not Found
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.JSONEq(t, `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"Failed to get note by ID","instance":"/note/3"}`, w.Body.String())
 
 	// Test case: Invalid note ID
 	req, _ = http.NewRequest("GET", "/note/invalid", nil)
@@ -303,6 +583,7 @@ func TestSearchNotesHandler(t *testing.T) {
 
 	// Create a custom Gin router
 	router := gin.New()
+	router.Use(middleware.Errors())
 
 	// Create a new instance of the NoteController with a mocked service
 	mockService := new(MockNoteService)
@@ -313,12 +594,16 @@ func TestSearchNotesHandler(t *testing.T) {
 
 	t.Run("Successful Search", func(t *testing.T) {
 		query := "test"
-		notes := []*models.Note{{ID: 1, Title: "Test Note 1"}, {ID: 2, Title: "Test Note 2"}}
-		mockService.On("SearchNotes", query).Return(notes, nil)
+		results := []*models.NoteSearchResult{
+			{Note: models.Note{ID: 1, Title: "Test Note 1"}, Rank: 0.6, TitleHighlight: "<b>Test</b> Note 1"},
+			{Note: models.Note{ID: 2, Title: "Test Note 2"}, Rank: 0.3, TitleHighlight: "<b>Test</b> Note 2"},
+		}
+		mockService.On("SearchNotes", query).Return(results, nil)
 		req, _ := http.NewRequest("GET", "/notes/search?query="+query, nil)
 		w := httptest.NewRecorder()
 		router.ServeHTTP(w, req)
 		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"title_highlight":"\u003cb\u003eTest\u003c/b\u003e Note 1"`)
 		mockService.AssertExpectations(t)
 	})
 
@@ -341,4 +626,830 @@ func TestSearchNotesHandler(t *testing.T) {
 		mockService.AssertExpectations(t)
 	})
 
+	t.Run("Query syntax error", func(t *testing.T) {
+		mockService.On("SearchNotes", "due:soon").Return(nil, &query.ParseError{Offset: 0, Token: "due:soon", Message: "invalid due"}).Once()
+		req, _ := http.NewRequest("GET", "/notes/search?query=due:soon", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"token":"due:soon"`)
+	})
+
+	t.Run("Fuzzy Search", func(t *testing.T) {
+		results := []*models.NoteSearchResult{{Note: models.Note{ID: 3, Title: "Quarterly review"}, Similarity: 0.54}}
+		mockService.On("FuzzySearchNotes", "quartrly reveiw", 0.4).Return(results, nil).Once()
+		req, _ := http.NewRequest("GET", "/notes/search?mode=fuzzy&threshold=0.4&query=quartrly+reveiw", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"similarity":0.54`)
+	})
+
+	t.Run("Fuzzy Search default threshold", func(t *testing.T) {
+		mockService.On("FuzzySearchNotes", "quartrly", 0.3).Return([]*models.NoteSearchResult{}, nil).Once()
+		req, _ := http.NewRequest("GET", "/notes/search?mode=fuzzy&query=quartrly", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Invalid mode or threshold", func(t *testing.T) {
+		for _, query := range []string{"mode=regex&query=a", "mode=fuzzy&threshold=2&query=a", "mode=fuzzy&threshold=x&query=a"} {
+			req, _ := http.NewRequest("GET", "/notes/search?"+query, nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		}
+	})
+
+}
+
+// Test note revision handlers
+func TestNoteRevisionHandlers(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockNoteService)
+	controller := controllers.NewNoteController(mockService)
+
+	router := gin.New()
+	router.Use(middleware.Errors())
+	router.GET("/note/:id/revisions", controller.GetNoteRevisionsHandler)
+	router.GET("/note/:id/revisions/diff", controller.DiffNoteRevisionsHandler)
+	router.GET("/note/:id/revisions/:rev", controller.GetNoteRevisionHandler)
+	router.POST("/note/:id/revisions/:rev/restore", controller.RestoreNoteRevisionHandler)
+
+	t.Run("List revisions", func(t *testing.T) {
+		revisions := []*models.NoteRevision{{NoteID: 1, Revision: 1, Title: "First"}, {NoteID: 1, Revision: 2, Title: "Second"}}
+		mockService.On("GetNoteRevisions", uint(1)).Return(revisions, nil).Once()
+		req, _ := http.NewRequest("GET", "/note/1/revisions", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Second")
+	})
+
+	t.Run("List revisions of missing note", func(t *testing.T) {
+		mockService.On("GetNoteRevisions", uint(2)).Return(nil, repository.ErrNoteNotFound).Once()
+		req, _ := http.NewRequest("GET", "/note/2/revisions", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Missing revision", func(t *testing.T) {
+		mockService.On("GetNoteRevision", uint(1), uint(9)).Return(nil, repository.ErrRevisionNotFound).Once()
+		req, _ := http.NewRequest("GET", "/note/1/revisions/9", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Contains(t, w.Body.String(), "Revision not found")
+	})
+
+	t.Run("Diff revisions", func(t *testing.T) {
+		changes := []models.FieldChange{{Field: "title", From: "First", To: "Second"}}
+		mockService.On("DiffNoteRevisions", uint(1), uint(1), uint(2)).Return(changes, nil).Once()
+		req, _ := http.NewRequest("GET", "/note/1/revisions/diff?from=1&to=2", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"field":"title"`)
+	})
+
+	t.Run("Diff with invalid range", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/note/1/revisions/diff?from=abc&to=2", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Restore revision", func(t *testing.T) {
+		change := models.ChangeInfo{Author: "alice", Reason: "deadline moved by mistake"}
+		mockService.On("RestoreNoteRevision", uint(1), uint(1), change).Return(&models.Note{ID: 1, Title: "First"}, nil).Once()
+		req, _ := http.NewRequest("POST", "/note/1/revisions/1/restore", nil)
+		req.Header.Set("X-User", change.Author)
+		req.Header.Set("X-Change-Reason", change.Reason)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Note restored successfully")
+	})
+
+	mockService.AssertExpectations(t)
+}
+
+// Test optimistic concurrency control on note handlers
+func TestNoteConcurrencyControl(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockNoteService)
+	controller := controllers.NewNoteController(mockService)
+
+	router := gin.New()
+	router.Use(middleware.Errors())
+	router.GET("/note/:id", controller.GetNoteByIDHandler)
+	router.PUT("/note/:id", controller.UpdateNoteHandler)
+	router.DELETE("/note/:id", controller.DeleteNoteHandler)
+
+	t.Run("ETag on get", func(t *testing.T) {
+		mockService.On("GetNoteById", uint(1)).Return(&models.Note{ID: 1, Title: "Test Note", Version: 3}, nil).Once()
+		req, _ := http.NewRequest("GET", "/note/1", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, `"3"`, w.Header().Get("ETag"))
+	})
+
+	t.Run("Missing If-Match", func(t *testing.T) {
+		body, _ := json.Marshal(&models.Note{Title: "Updated Note"})
+		req, _ := http.NewRequest("PUT", "/note/1", strings.NewReader(string(body)))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+
+		req, _ = http.NewRequest("DELETE", "/note/1", nil)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusPreconditionRequired, w.Code)
+	})
+
+	t.Run("Malformed If-Match", func(t *testing.T) {
+		req, _ := http.NewRequest("DELETE", "/note/1", nil)
+		req.Header.Set("If-Match", `"abc"`)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Stale version on update", func(t *testing.T) {
+		note := &models.Note{ID: 1, Title: "Updated Note", Deadline: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), Version: 2}
+		mockService.On("UpdateNote", note, models.ChangeInfo{}).Return(repository.ErrVersionConflict).Once()
+		body, _ := json.Marshal(note)
+		req, _ := http.NewRequest("PUT", "/note/1", strings.NewReader(string(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("If-Match", `"2"`)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	})
+
+	t.Run("Weak ETag", func(t *testing.T) {
+		// If-Match compares strongly, so a weak ETag never matches
+		req, _ := http.NewRequest("DELETE", "/note/1", nil)
+		req.Header.Set("If-Match", `W/"2"`)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	})
+
+	t.Run("Any version", func(t *testing.T) {
+		mockService.On("DeleteNote", uint(1), models.AnyVersion).Return(nil).Once()
+		req, _ := http.NewRequest("DELETE", "/note/1", nil)
+		req.Header.Set("If-Match", "*")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		mockService.On("DeleteNote", uint(2), models.AnyVersion).Return(repository.ErrNoteNotFound).Once()
+		req, _ = http.NewRequest("DELETE", "/note/2", nil)
+		req.Header.Set("If-Match", "*")
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Several ETags", func(t *testing.T) {
+		// Any strong ETag of the list may match; weak ones never do
+		mockService.On("GetNoteById", uint(1)).Return(&models.Note{ID: 1, Version: 4}, nil).Twice()
+		mockService.On("DeleteNote", uint(1), uint(4)).Return(nil).Once()
+		req, _ := http.NewRequest("DELETE", "/note/1", nil)
+		req.Header.Set("If-Match", `"3", W/"5", "4"`)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		mockService.On("DeleteNote", uint(1), uint(2)).Return(repository.ErrVersionConflict).Once()
+		req, _ = http.NewRequest("DELETE", "/note/1", nil)
+		req.Header.Set("If-Match", `"2","3"`)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+		req, _ = http.NewRequest("DELETE", "/note/1", nil)
+		req.Header.Set("If-Match", `W/"2", W/"3"`)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+
+		req, _ = http.NewRequest("DELETE", "/note/1", nil)
+		req.Header.Set("If-Match", `"2", x`)
+		w = httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Stale version on delete", func(t *testing.T) {
+		mockService.On("DeleteNote", uint(1), uint(2)).Return(repository.ErrVersionConflict).Once()
+		req, _ := http.NewRequest("DELETE", "/note/1", nil)
+		req.Header.Set("If-Match", `"2"`)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	})
+
+	mockService.AssertExpectations(t)
+}
+
+// Test PatchNoteHandler function
+func TestPatchNoteHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockNoteService)
+	controller := controllers.NewNoteController(mockService)
+
+	router := gin.New()
+	router.Use(middleware.Errors())
+	router.PATCH("/note/:id", controller.PatchNoteHandler)
+
+	deadline := time.Date(2030, 1, 2, 15, 4, 5, 0, time.UTC)
+	stored := &models.Note{ID: 1, Title: "Stored Note", Description: "keep me", Deadline: deadline, Version: 4}
+
+	patch := func(contentType, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("PATCH", "/note/1", strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("If-Match", `"4"`)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Merge patch changes only the deadline", func(t *testing.T) {
+		mockService.On("PatchNote", uint(1), uint(4), models.ChangeInfo{}).Return(stored, nil).Once()
+		w := patch("application/merge-patch+json", `{"deadline":"2030-02-01T10:00:00Z"}`)
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var resp struct {
+			Note models.Note `json:"note"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+		assert.Equal(t, "keep me", resp.Note.Description)
+		assert.Equal(t, "Stored Note", resp.Note.Title)
+		assert.True(t, resp.Note.Deadline.Equal(time.Date(2030, 2, 1, 10, 0, 0, 0, time.UTC)))
+		assert.Equal(t, `"5"`, w.Header().Get("ETag"))
+	})
+
+	t.Run("JSON patch replaces the title", func(t *testing.T) {
+		mockService.On("PatchNote", uint(1), uint(4), models.ChangeInfo{}).Return(stored, nil).Once()
+		w := patch("application/json-patch+json", `[{"op":"test","path":"/title","value":"Stored Note"},{"op":"replace","path":"/title","value":"Patched Note"}]`)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), "Patched Note")
+	})
+
+	t.Run("Failed JSON patch test operation", func(t *testing.T) {
+		mockService.On("PatchNote", uint(1), uint(4), models.ChangeInfo{}).Return(stored, nil).Once()
+		w := patch("application/json-patch+json", `[{"op":"test","path":"/title","value":"Other"}]`)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("Read-only fields cannot be patched", func(t *testing.T) {
+		mockService.On("PatchNote", uint(1), uint(4), models.ChangeInfo{}).Return(stored, nil).Once()
+		w := patch("application/merge-patch+json", `{"id":99}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	})
+
+	t.Run("Patched note fails validation", func(t *testing.T) {
+		mockService.On("PatchNote", uint(1), uint(4), models.ChangeInfo{}).Return(stored, nil).Once()
+		w := patch("application/merge-patch+json", `{"title":"ab"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Unsupported content type", func(t *testing.T) {
+		w := patch("application/json", `{"title":"Patched Note"}`)
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	})
+
+	t.Run("Stale version", func(t *testing.T) {
+		mockService.On("PatchNote", uint(1), uint(4), models.ChangeInfo{}).Return(nil, repository.ErrVersionConflict).Once()
+		w := patch("application/merge-patch+json", `{"title":"Patched Note"}`)
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+	})
+
+	mockService.AssertExpectations(t)
+}
+
+// Test SuggestTitlesHandler function
+func TestSuggestTitlesHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockNoteService)
+	controller := controllers.NewNoteController(mockService)
+
+	router := gin.New()
+	router.Use(middleware.Errors())
+	router.GET("/notes/suggest", controller.SuggestTitlesHandler)
+
+	t.Run("Suggestions", func(t *testing.T) {
+		mockService.On("SuggestTitles", "qua", 10).Return([]string{"Quarterly review", "Quality checklist"}, nil).Once()
+		req, _ := http.NewRequest("GET", "/notes/suggest?prefix=qua", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"suggestions":["Quarterly review","Quality checklist"]}`, w.Body.String())
+	})
+
+	t.Run("Missing prefix", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/notes/suggest", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Invalid limit", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/notes/suggest?prefix=qua&limit=500", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	mockService.AssertExpectations(t)
+}
+
+// Test BulkNotesHandler function
+func TestBulkNotesHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockNoteService)
+	controller := controllers.NewNoteController(mockService)
+
+	router := gin.New()
+	router.Use(middleware.Errors())
+	router.POST("/notes/bulk", controller.BulkNotesHandler)
+
+	deadline := time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second)
+	bulk := func(body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/notes/bulk", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	operations := `[
+		{"action":"create","note":{"title":"Pay invoice","deadline":"` + deadline.Format(time.RFC3339) + `"}},
+		{"action":"update","id":3,"version":2,"note":{"title":"Call bank","deadline":"` + deadline.Format(time.RFC3339) + `"}},
+		{"action":"delete","id":4,"version":1}
+	]`
+	expected := []models.BulkOperation{
+		{Action: models.BulkCreate, Note: &models.Note{Title: "Pay invoice", Deadline: deadline}},
+		{Action: models.BulkUpdate, Note: &models.Note{ID: 3, Version: 2, Title: "Call bank", Deadline: deadline}},
+		{Action: models.BulkDelete, Note: &models.Note{ID: 4, Version: 1}},
+	}
+
+	t.Run("All succeed", func(t *testing.T) {
+		mockService.On("BulkNotes", expected, true, models.ChangeInfo{}).Return([]models.BulkResult{
+			{Note: &models.Note{ID: 5, Title: "Pay invoice", Deadline: deadline, Version: 1}},
+			{Note: &models.Note{ID: 3, Title: "Call bank", Deadline: deadline, Version: 3}},
+			{},
+		}, nil).Once()
+		w := bulk(`{"operations":` + operations + `}`)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response struct {
+			Succeeded int `json:"succeeded"`
+			Failed    int `json:"failed"`
+			Results   []struct {
+				Index  int    `json:"index"`
+				Action string `json:"action"`
+				Status int    `json:"status"`
+				Note   *struct {
+					ID uint `json:"id"`
+				} `json:"note"`
+			} `json:"results"`
+		}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, 3, response.Succeeded)
+		assert.Equal(t, 0, response.Failed)
+		if assert.Len(t, response.Results, 3) {
+			assert.Equal(t, http.StatusCreated, response.Results[0].Status)
+			assert.Equal(t, uint(5), response.Results[0].Note.ID)
+			assert.Equal(t, http.StatusOK, response.Results[1].Status)
+			assert.Equal(t, "delete", response.Results[2].Action)
+			assert.Equal(t, http.StatusNoContent, response.Results[2].Status)
+			assert.Nil(t, response.Results[2].Note)
+		}
+	})
+
+	t.Run("Best effort with failures", func(t *testing.T) {
+		mockService.On("BulkNotes", expected, false, models.ChangeInfo{}).Return([]models.BulkResult{
+			{Note: &models.Note{ID: 5, Title: "Pay invoice", Deadline: deadline, Version: 1}},
+			{Err: repository.ErrVersionConflict},
+			{Err: repository.ErrNoteNotFound},
+		}, nil).Once()
+		w := bulk(`{"mode":"best_effort","operations":` + operations + `}`)
+		assert.Equal(t, http.StatusMultiStatus, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), `"succeeded":1,"failed":2`)
+		assert.Contains(t, w.Body.String(), `{"index":1,"action":"update","status":412,"error":{`)
+		assert.Contains(t, w.Body.String(), `{"index":2,"action":"delete","status":404,"error":{`)
+	})
+
+	t.Run("Invalid note fails an atomic request", func(t *testing.T) {
+		w := bulk(`{"operations":[
+			{"action":"create","note":{"title":"Pay invoice","deadline":"` + deadline.Format(time.RFC3339) + `"}},
+			{"action":"create","note":{"title":"No","deadline":"2001-01-01T00:00:00Z"}}
+		]}`)
+		assert.Equal(t, http.StatusMultiStatus, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), `{"index":0,"action":"create","status":424,"error":{`)
+		assert.Contains(t, w.Body.String(), `{"field":"title","rule":"min","param":"3","message":"must be at least 3 characters"}`)
+		assert.Contains(t, w.Body.String(), `{"field":"deadline","rule":"future","message":"must be in the future"}`)
+	})
+
+	t.Run("Invalid note fails alone in best effort", func(t *testing.T) {
+		mockService.On("BulkNotes", expected[2:], false, models.ChangeInfo{}).Return([]models.BulkResult{{}}, nil).Once()
+		w := bulk(`{"mode":"best_effort","operations":[
+			{"action":"update","id":3,"version":2,"note":{"title":7}},
+			{"action":"delete","id":4,"version":1}
+		]}`)
+		assert.Equal(t, http.StatusMultiStatus, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), `"field":"title","rule":"type"`)
+		assert.Contains(t, w.Body.String(), `{"index":1,"action":"delete","status":204}`)
+	})
+
+	t.Run("Invalid operations", func(t *testing.T) {
+		w := bulk(`{"mode":"eventually","operations":[{"action":"update","note":{}},{"action":"copy"}]}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"field":"mode","rule":"oneof"`)
+		assert.Contains(t, w.Body.String(), `{"field":"operations[0].id","rule":"required_unless","param":"Action create","message":"is required"}`)
+		assert.Contains(t, w.Body.String(), `"field":"operations[1].action","rule":"oneof"`)
+
+		w = bulk(`{"operations":[]}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	mockService.AssertExpectations(t)
+}
+
+func TestExportNotesHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockNoteService)
+	controller := controllers.NewNoteController(mockService)
+
+	router := gin.New()
+	router.Use(middleware.Errors())
+	router.GET("/notes/export", controller.ExportNotesHandler)
+
+	deadline := time.Date(2026, time.November, 1, 9, 0, 0, 0, time.UTC)
+	created := time.Date(2026, time.October, 1, 8, 0, 0, 0, time.UTC)
+	notes := []*models.Note{
+		{ID: 1, Title: "Pay invoice", Description: "Rent, \"October\"", Deadline: deadline, Tags: []string{"home", "bills"}, Reminders: []int{60, 15}, CreatedAt: created, UpdatedAt: created},
+		{ID: 2, Title: "Call bank", Deadline: deadline, CreatedAt: created, UpdatedAt: created},
+	}
+	export := func(query string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/notes/export"+query, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("CSV", func(t *testing.T) {
+		mockService.On("ExportNotes", models.NoteListQuery{Sort: "title"}).Return(notes, nil).Once()
+		w := export("?format=csv&sort=title")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Equal(t, `attachment; filename="notes.csv"`, w.Header().Get("Content-Disposition"))
+		assert.Equal(t, "id,title,description,deadline,tags,reminders,created_at,updated_at\n"+
+			"1,Pay invoice,\"Rent, \"\"October\"\"\",2026-11-01T09:00:00Z,home;bills,60;15,2026-10-01T08:00:00Z,2026-10-01T08:00:00Z\n"+
+			"2,Call bank,,2026-11-01T09:00:00Z,,,2026-10-01T08:00:00Z,2026-10-01T08:00:00Z\n", w.Body.String())
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		mockService.On("ExportNotes", models.NoteListQuery{}).Return(notes, nil).Once()
+		w := export("")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var exported []map[string]interface{}
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &exported))
+		if assert.Len(t, exported, 2) {
+			assert.Equal(t, "Call bank", exported[1]["title"])
+			assert.Equal(t, []interface{}{}, exported[1]["tags"])
+		}
+	})
+
+	t.Run("Empty JSON", func(t *testing.T) {
+		mockService.On("ExportNotes", models.NoteListQuery{}).Return(nil, nil).Once()
+		w := export("?format=json")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "[]", w.Body.String())
+	})
+
+	t.Run("NDJSON", func(t *testing.T) {
+		mockService.On("ExportNotes", models.NoteListQuery{}).Return(notes, nil).Once()
+		w := export("?format=ndjson")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+		lines := strings.Split(strings.TrimSpace(w.Body.String()), "\n")
+		if assert.Len(t, lines, 2) {
+			assert.Contains(t, lines[0], `"title":"Pay invoice"`)
+		}
+	})
+
+	t.Run("Failure before any note", func(t *testing.T) {
+		mockService.On("ExportNotes", models.NoteListQuery{}).Return(nil, errors.New("database is down")).Once()
+		w := export("?format=csv")
+		assert.Equal(t, http.StatusInternalServerError, w.Code, w.Body.String())
+		assert.Empty(t, w.Header().Get("Content-Disposition"))
+	})
+
+	t.Run("Failure after some notes", func(t *testing.T) {
+		mockService.On("ExportNotes", models.NoteListQuery{}).Return(notes, errors.New("database is down")).Once()
+		// The connection is broken rather than the export ended as if complete
+		assert.PanicsWithValue(t, http.ErrAbortHandler, func() { export("?format=ndjson") })
+	})
+
+	t.Run("Invalid format", func(t *testing.T) {
+		w := export("?format=xml")
+		assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	})
+
+	mockService.AssertExpectations(t)
+}
+
+func TestImportNotesHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockNoteService)
+	controller := controllers.NewNoteController(mockService)
+
+	router := gin.New()
+	router.Use(middleware.Errors())
+	router.POST("/notes/import", controller.ImportNotesHandler)
+
+	deadline := time.Date(2026, time.November, 1, 9, 0, 0, 0, time.UTC)
+	importNotes := func(query, contentType, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/notes/import"+query, strings.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	type report struct {
+		DryRun  bool `json:"dry_run"`
+		Created int  `json:"created"`
+		Skipped int  `json:"skipped"`
+		Failed  int  `json:"failed"`
+		Rows    []struct {
+			Row         int    `json:"row"`
+			Action      string `json:"action"`
+			Title       string `json:"title"`
+			RenamedFrom string `json:"renamed_from"`
+			Reason      string `json:"reason"`
+			Note        *struct {
+				ID uint `json:"id"`
+			} `json:"note"`
+			Error *struct {
+				Status int `json:"status"`
+				Errors []struct {
+					Field string `json:"field"`
+					Rule  string `json:"rule"`
+				} `json:"errors"`
+			} `json:"error"`
+		} `json:"rows"`
+	}
+
+	t.Run("CSV with invalid rows", func(t *testing.T) {
+		expected := []*models.Note{
+			{Title: "Pay invoice", Deadline: deadline, Tags: []string{"home", "bills"}, Reminders: []int{60, 15}},
+			{Title: "Call bank", Deadline: deadline},
+		}
+		mockService.On("ImportNotes", expected, models.ConflictRename, false, models.ChangeInfo{}).Return([]models.ImportResult{
+			{Action: models.ImportCreate, Note: &models.Note{ID: 7, Title: "Pay invoice (2)", Deadline: deadline, Version: 1}, RenamedFrom: "Pay invoice"},
+			{Action: models.ImportSkip, Note: expected[1]},
+		}, nil).Once()
+
+		w := importNotes("?on_conflict=rename", "text/csv", "Title,Deadline,Tags,Reminders\n"+
+			"Pay invoice,2026-11-01T09:00:00Z,home;bills,60;15\n"+
+			"Later,tomorrow,,\n"+
+			"Call bank,2026-11-01T09:00:00Z,,\n"+
+			"Soon,2026-11-01T09:00:00Z,,often\n")
+		assert.Equal(t, http.StatusMultiStatus, w.Code, w.Body.String())
+
+		var response report
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, 1, response.Created)
+		assert.Equal(t, 1, response.Skipped)
+		assert.Equal(t, 2, response.Failed)
+		if assert.Len(t, response.Rows, 4) {
+			assert.Equal(t, "create", response.Rows[0].Action)
+			assert.Equal(t, "Pay invoice (2)", response.Rows[0].Title)
+			assert.Equal(t, "Pay invoice", response.Rows[0].RenamedFrom)
+			assert.Equal(t, uint(7), response.Rows[0].Note.ID)
+			assert.Equal(t, "fail", response.Rows[1].Action)
+			assert.Equal(t, "deadline", response.Rows[1].Error.Errors[0].Field)
+			assert.Equal(t, "skip", response.Rows[2].Action)
+			assert.Equal(t, "The title is taken", response.Rows[2].Reason)
+			assert.Nil(t, response.Rows[2].Note)
+			assert.Equal(t, 4, response.Rows[3].Row)
+			assert.Equal(t, "reminders[0]", response.Rows[3].Error.Errors[0].Field)
+		}
+	})
+
+	t.Run("JSON dry run", func(t *testing.T) {
+		expected := []*models.Note{{Title: "Pay invoice", Deadline: deadline}}
+		mockService.On("ImportNotes", expected, models.ConflictSkip, true, models.ChangeInfo{}).Return([]models.ImportResult{
+			{Action: models.ImportCreate, Note: expected[0]},
+		}, nil).Once()
+
+		w := importNotes("?dry_run=true", "application/json", `[{"id":3,"title":"Pay invoice","deadline":"2026-11-01T09:00:00Z"},{"title":"No"}]`)
+		assert.Equal(t, http.StatusMultiStatus, w.Code, w.Body.String())
+
+		var response report
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.True(t, response.DryRun)
+		if assert.Len(t, response.Rows, 2) {
+			assert.Equal(t, "create", response.Rows[0].Action)
+			assert.NotNil(t, response.Rows[0].Note, "a dry run previews the note")
+			assert.Equal(t, "fail", response.Rows[1].Action)
+			assert.Equal(t, http.StatusBadRequest, response.Rows[1].Error.Status)
+		}
+	})
+
+	t.Run("NDJSON", func(t *testing.T) {
+		expected := []*models.Note{{Title: "Pay invoice", Deadline: deadline}, {Title: "Call bank", Deadline: deadline}}
+		mockService.On("ImportNotes", expected, models.ConflictOverwrite, false, models.ChangeInfo{}).Return([]models.ImportResult{
+			{Action: models.ImportUpdate, Note: &models.Note{ID: 1, Title: "Pay invoice", Deadline: deadline, Version: 2}},
+			{Action: models.ImportCreate, Note: &models.Note{ID: 2, Title: "Call bank", Deadline: deadline, Version: 1}},
+		}, nil).Once()
+
+		w := importNotes("?on_conflict=overwrite", "application/x-ndjson", `{"title":"Pay invoice","deadline":"2026-11-01T09:00:00Z"}`+"\n\n"+
+			`{"title":"Call bank","deadline":"2026-11-01T09:00:00Z"}`+"\n")
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	})
+
+	t.Run("Unreadable body", func(t *testing.T) {
+		w := importNotes("", "application/json", `{"title":"Pay invoice"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+
+		w = importNotes("", "text/csv", "name,when\nPay invoice,2026-11-01T09:00:00Z\n")
+		assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), "no title column")
+	})
+
+	t.Run("Unsupported media type", func(t *testing.T) {
+		w := importNotes("", "application/xml", "<notes/>")
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code, w.Body.String())
+	})
+
+	t.Run("Invalid conflict policy", func(t *testing.T) {
+		w := importNotes("?on_conflict=merge", "application/json", "[]")
+		assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	})
+
+	mockService.AssertExpectations(t)
+}
+
+func TestImportICSHandler(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	mockService := new(MockNoteService)
+	controller := controllers.NewNoteController(mockService)
+
+	router := gin.New()
+	router.Use(middleware.Errors())
+	router.POST("/notes/import/ics", controller.ImportICSHandler)
+
+	importICS := func(query, contentType string, body io.Reader) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/notes/import/ics"+query, body)
+		req.Header.Set("Content-Type", contentType)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+	calendar := func(components ...string) string {
+		return "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n" + strings.Join(components, "") + "END:VCALENDAR\r\n"
+	}
+
+	type report struct {
+		DryRun  bool `json:"dry_run"`
+		Created int  `json:"created"`
+		Skipped int  `json:"skipped"`
+		Failed  int  `json:"failed"`
+		Rows    []struct {
+			Row    int    `json:"row"`
+			Action string `json:"action"`
+			Title  string `json:"title"`
+			Reason string `json:"reason"`
+			Note   *struct {
+				Deadline  time.Time `json:"deadline"`
+				Reminders []int     `json:"reminders"`
+			} `json:"note"`
+			Error *struct {
+				Detail string `json:"detail"`
+			} `json:"error"`
+		} `json:"rows"`
+	}
+
+	t.Run("Events and to-dos", func(t *testing.T) {
+		// The notes read are previewed as created, whatever they hold
+		var imported []*models.Note
+		results := make([]models.ImportResult, 3)
+		mockService.On("ImportNotes", mock.MatchedBy(func(notes []*models.Note) bool {
+			imported = notes
+			for i := 0; i < len(notes) && i < len(results); i++ {
+				results[i] = models.ImportResult{Action: models.ImportCreate, Note: notes[i]}
+			}
+			return len(notes) == len(results)
+		}), models.ConflictSkip, true, models.ChangeInfo{}).Return(results, nil).Once()
+
+		body := calendar(
+			"BEGIN:VEVENT\r\nSUMMARY:Dentist\r\nDESCRIPTION:Bring the x-rays\\, and the card\r\n"+
+				"DTSTART;TZID=America/New_York:20300115T093000\r\n"+
+				"BEGIN:VALARM\r\nTRIGGER:-PT15M\r\nEND:VALARM\r\nBEGIN:VALARM\r\nTRIGGER:-P1D\r\nEND:VALARM\r\n"+
+				"BEGIN:VALARM\r\nTRIGGER:PT5M\r\nEND:VALARM\r\nEND:VEVENT\r\n",
+			"BEGIN:VEVENT\r\nSUMMARY:Stand-up\r\nDTSTART;TZID=America/New_York:20260105T090000\r\nRRULE:FREQ=WEEKLY;BYDAY=MO\r\nEND:VEVENT\r\n",
+			"BEGIN:VTODO\r\nSUMMARY:File taxes\r\nDTSTART:20300101T090000Z\r\nDUE:20300101T170000Z\r\n"+
+				"BEGIN:VALARM\r\nTRIGGER;RELATED=END:-PT30M\r\nEND:VALARM\r\nBEGIN:VALARM\r\nTRIGGER:PT1H\r\nEND:VALARM\r\n"+
+				"BEGIN:VALARM\r\nTRIGGER;VALUE=DATE-TIME:20300101T160000Z\r\nEND:VALARM\r\nEND:VTODO\r\n",
+			"BEGIN:VEVENT\r\nSUMMARY:Party\r\nSTATUS:CANCELLED\r\nDTSTART:20300101T090000Z\r\nEND:VEVENT\r\n",
+			"BEGIN:VTODO\r\nSUMMARY:Done already\r\nCOMPLETED:20260101T090000Z\r\nDUE:20300101T090000Z\r\nEND:VTODO\r\n",
+			"BEGIN:VEVENT\r\nSUMMARY:Somewhere\r\nDTSTART;TZID=Nowhere/Special:20300101T090000\r\nEND:VEVENT\r\n",
+		)
+		w := importICS("?dry_run=true", "text/calendar", strings.NewReader(body))
+		assert.Equal(t, http.StatusMultiStatus, w.Code, w.Body.String())
+
+		var response report
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.True(t, response.DryRun)
+		assert.Equal(t, 3, response.Created)
+		assert.Equal(t, 2, response.Skipped)
+		assert.Equal(t, 1, response.Failed)
+		if assert.Len(t, response.Rows, 6) {
+			assert.Equal(t, "Dentist", response.Rows[0].Title)
+			assert.Equal(t, []int{15, 1440}, response.Rows[0].Note.Reminders)
+			assert.Equal(t, "The event is cancelled", response.Rows[3].Reason)
+			assert.Equal(t, "The to-do is completed", response.Rows[4].Reason)
+			assert.Equal(t, "fail", response.Rows[5].Action)
+			assert.Contains(t, response.Rows[5].Error.Detail, "unknown time zone")
+		}
+
+		if assert.Len(t, imported, 3) {
+			assert.Equal(t, "Bring the x-rays, and the card", imported[0].Description)
+			assert.True(t, time.Date(2030, time.January, 15, 14, 30, 0, 0, time.UTC).Equal(imported[0].Deadline), imported[0].Deadline)
+			assert.Equal(t, "Asia/Kolkata", imported[0].Deadline.Location().String())
+
+			newYork, err := time.LoadLocation("America/New_York")
+			assert.NoError(t, err)
+			standUp := imported[1].Deadline.In(newYork)
+			assert.Equal(t, time.Monday, standUp.Weekday())
+			assert.Equal(t, 9, standUp.Hour())
+			assert.True(t, standUp.After(time.Now()) && standUp.Before(time.Now().AddDate(0, 0, 8)), standUp)
+
+			assert.True(t, time.Date(2030, time.January, 1, 17, 0, 0, 0, time.UTC).Equal(imported[2].Deadline))
+			assert.Equal(t, models.Reminders{30, 60, 420}, imported[2].Reminders)
+		}
+	})
+
+	t.Run("Multipart files", func(t *testing.T) {
+		mockService.On("ImportNotes", mock.MatchedBy(func(notes []*models.Note) bool {
+			return len(notes) == 2 && notes[0].Title == "First" && notes[1].Title == "Second"
+		}), models.ConflictRename, false, models.ChangeInfo{}).Return([]models.ImportResult{
+			{Action: models.ImportCreate, Note: &models.Note{ID: 1, Title: "First"}},
+			{Action: models.ImportCreate, Note: &models.Note{ID: 2, Title: "Second"}},
+		}, nil).Once()
+
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		for _, title := range []string{"First", "Second"} {
+			part, _ := form.CreateFormFile("files", strings.ToLower(title)+".ics")
+			fmt.Fprint(part, calendar("BEGIN:VEVENT\r\nSUMMARY:"+title+"\r\nDTSTART:20300101T090000Z\r\nEND:VEVENT\r\n"))
+		}
+		form.Close()
+
+		w := importICS("?on_conflict=rename", form.FormDataContentType(), &body)
+		assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response report
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		if assert.Len(t, response.Rows, 2) {
+			assert.Equal(t, 2, response.Rows[1].Row)
+		}
+	})
+
+	t.Run("Unreadable file", func(t *testing.T) {
+		w := importICS("", "text/calendar", strings.NewReader("BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nEND:VCALENDAR\r\n"))
+		assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), "line 3")
+
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		form.WriteField("note", "nothing")
+		form.Close()
+		w = importICS("", form.FormDataContentType(), &body)
+		assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	})
+
+	t.Run("Unsupported media type", func(t *testing.T) {
+		w := importICS("", "application/json", strings.NewReader("[]"))
+		assert.Equal(t, http.StatusUnsupportedMediaType, w.Code, w.Body.String())
+	})
+
+	mockService.AssertExpectations(t)
 }
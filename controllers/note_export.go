@@ -0,0 +1,183 @@
+package controllers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sarita-growexx/note_with_alarm/models"
+)
+
+// Formats notes are exported and imported in.
+const (
+	formatCSV    = "csv"
+	formatJSON   = "json"
+	formatNDJSON = "ndjson"
+)
+
+// exportContentTypes is the media type of each export format.
+var exportContentTypes = map[string]string{
+	formatCSV:    "text/csv; charset=utf-8",
+	formatJSON:   "application/json; charset=utf-8",
+	formatNDJSON: "application/x-ndjson",
+}
+
+// csvColumns are the columns of a CSV export, in order. Imports read the
+// same columns, by name, and ignore the ID and timestamps.
+var csvColumns = []string{"id", "title", "description", "deadline", "tags", "reminders", "created_at", "updated_at"}
+
+// csvListSeparator joins the tags and reminders of a note in a CSV cell.
+const csvListSeparator = ";"
+
+// exportNotesParams are the query parameters accepted when exporting notes:
+// the format, and the filters and order of a list.
+type exportNotesParams struct {
+	Format         string     `form:"format" binding:"omitempty,oneof=csv json ndjson"`
+	Sort           string     `form:"sort" binding:"omitempty,oneof=deadline created_at updated_at title"`
+	Order          string     `form:"order" binding:"omitempty,oneof=asc desc"`
+	DeadlineBefore *time.Time `form:"deadline_before" time_format:"2006-01-02T15:04:05Z07:00"`
+	DeadlineAfter  *time.Time `form:"deadline_after" time_format:"2006-01-02T15:04:05Z07:00"`
+	Overdue        *bool      `form:"overdue"`
+}
+
+// exportedNote is a note as exported. It holds the stored fields only, so
+// that an export can be imported again.
+type exportedNote struct {
+	ID          uint      `json:"id"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Deadline    time.Time `json:"deadline"`
+	Tags        []string  `json:"tags"`
+	Reminders   []int     `json:"reminders"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+func newExportedNote(note *models.Note) exportedNote {
+	exported := exportedNote{
+		ID:          note.ID,
+		Title:       note.Title,
+		Description: note.Description,
+		Deadline:    note.Deadline,
+		Tags:        note.Tags,
+		Reminders:   note.Reminders,
+		CreatedAt:   note.CreatedAt,
+		UpdatedAt:   note.UpdatedAt,
+	}
+	if exported.Tags == nil {
+		exported.Tags = []string{}
+	}
+	if exported.Reminders == nil {
+		exported.Reminders = []int{}
+	}
+	return exported
+}
+
+// noteEncoder writes the notes of an export one at a time, so an export is
+// streamed rather than built in memory.
+type noteEncoder interface {
+	encode(note exportedNote) error
+	// close ends the export, which may hold no notes at all.
+	close() error
+}
+
+func newNoteEncoder(format string, w io.Writer) noteEncoder {
+	switch format {
+	case formatCSV:
+		return &csvNoteEncoder{writer: csv.NewWriter(w)}
+	case formatNDJSON:
+		return &ndjsonNoteEncoder{encoder: json.NewEncoder(w)}
+	default:
+		return &jsonNoteEncoder{w: w}
+	}
+}
+
+// csvNoteEncoder writes a header of csvColumns and a record per note.
+type csvNoteEncoder struct {
+	writer  *csv.Writer
+	started bool
+}
+
+func (e *csvNoteEncoder) encode(note exportedNote) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	reminders := make([]string, len(note.Reminders))
+	for i, reminder := range note.Reminders {
+		reminders[i] = strconv.Itoa(reminder)
+	}
+	return e.writer.Write([]string{
+		strconv.FormatUint(uint64(note.ID), 10),
+		note.Title,
+		note.Description,
+		note.Deadline.Format(time.RFC3339),
+		strings.Join(note.Tags, csvListSeparator),
+		strings.Join(reminders, csvListSeparator),
+		note.CreatedAt.Format(time.RFC3339),
+		note.UpdatedAt.Format(time.RFC3339),
+	})
+}
+
+func (e *csvNoteEncoder) close() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	e.writer.Flush()
+	return e.writer.Error()
+}
+
+func (e *csvNoteEncoder) writeHeader() error {
+	if e.started {
+		return nil
+	}
+	e.started = true
+	return e.writer.Write(csvColumns)
+}
+
+// jsonNoteEncoder writes a JSON array of notes, an element at a time.
+type jsonNoteEncoder struct {
+	w     io.Writer
+	count int
+}
+
+func (e *jsonNoteEncoder) encode(note exportedNote) error {
+	data, err := json.Marshal(note)
+	if err != nil {
+		return err
+	}
+	separator := ","
+	if e.count == 0 {
+		separator = "["
+	}
+	e.count++
+	if _, err := io.WriteString(e.w, separator); err != nil {
+		return err
+	}
+	_, err = e.w.Write(data)
+	return err
+}
+
+func (e *jsonNoteEncoder) close() error {
+	end := "]"
+	if e.count == 0 {
+		end = "[]"
+	}
+	_, err := io.WriteString(e.w, end)
+	return err
+}
+
+// ndjsonNoteEncoder writes a note per line, as JSON.
+type ndjsonNoteEncoder struct {
+	encoder *json.Encoder
+}
+
+func (e *ndjsonNoteEncoder) encode(note exportedNote) error {
+	return e.encoder.Encode(note)
+}
+
+func (e *ndjsonNoteEncoder) close() error {
+	return nil
+}
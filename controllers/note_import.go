@@ -0,0 +1,261 @@
+package controllers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sarita-growexx/note_with_alarm/models"
+	"github.com/sarita-growexx/note_with_alarm/problem"
+)
+
+// maxImportRows is the most notes an import may hold.
+const maxImportRows = 10000
+
+// maxNDJSONLine is the longest line of an NDJSON import.
+const maxNDJSONLine = 1 << 20
+
+// errTooManyRows stops reading an import that holds more than maxImportRows
+// notes.
+var errTooManyRows = fmt.Errorf("an import holds at most %d notes", maxImportRows)
+
+// importNotesParams are the query parameters accepted when importing notes.
+type importNotesParams struct {
+	DryRun     bool   `form:"dry_run"`
+	OnConflict string `form:"on_conflict" binding:"omitempty,oneof=skip overwrite rename"`
+}
+
+// importFormats is the import format of each accepted media type.
+var importFormats = map[string]string{
+	"text/csv":             formatCSV,
+	"application/json":     formatJSON,
+	"application/x-ndjson": formatNDJSON,
+}
+
+// importRow is a note read from an import, or the problem that makes it
+// invalid. Invalid notes fail their row only. Rows with a skip reason are
+// left out of the import, such as cancelled events of an iCalendar file.
+type importRow struct {
+	note    *models.Note
+	problem *problem.Problem
+	skip    string
+}
+
+// newImportRow validates a note read from an import as the body of an update
+// request, unless reading it already found a problem.
+func newImportRow(request *updateNoteRequest, p *problem.Problem) importRow {
+	if p == nil {
+		if err := validateRequest(request); err != nil {
+			p = problem.Validation(err)
+		}
+	}
+	if p != nil {
+		return importRow{problem: p}
+	}
+	return importRow{note: request.toModel()}
+}
+
+// readImport reads the notes of an import in the given format. Every note is
+// validated as the body of an update request, so notes that are already
+// overdue can be imported. An error means the body is not in the format at
+// all, or holds too many notes.
+func readImport(format string, r io.Reader) ([]importRow, error) {
+	var rows []importRow
+	add := func(request *updateNoteRequest, p *problem.Problem) error {
+		if len(rows) == maxImportRows {
+			return errTooManyRows
+		}
+		rows = append(rows, newImportRow(request, p))
+		return nil
+	}
+
+	var err error
+	switch format {
+	case formatCSV:
+		err = readCSVImport(r, add)
+	case formatNDJSON:
+		err = readNDJSONImport(r, add)
+	default:
+		err = readJSONImport(r, add)
+	}
+	return rows, err
+}
+
+// decodeImportedNote decodes a note of a JSON or NDJSON import.
+func decodeImportedNote(data []byte) (*updateNoteRequest, *problem.Problem) {
+	var request updateNoteRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		return nil, problem.Validation(err)
+	}
+	return &request, nil
+}
+
+// readJSONImport reads a JSON array of notes.
+func readJSONImport(r io.Reader, add func(*updateNoteRequest, *problem.Problem) error) error {
+	decoder := json.NewDecoder(r)
+	if token, err := decoder.Token(); err != nil || token != json.Delim('[') {
+		return errors.New("the body must be a JSON array of notes")
+	}
+	for decoder.More() {
+		var data json.RawMessage
+		if err := decoder.Decode(&data); err != nil {
+			return fmt.Errorf("the body is not valid JSON: %w", err)
+		}
+		if err := add(decodeImportedNote(data)); err != nil {
+			return err
+		}
+	}
+	if _, err := decoder.Token(); err != nil {
+		return fmt.Errorf("the body is not valid JSON: %w", err)
+	}
+	return nil
+}
+
+// readNDJSONImport reads a note per line. Blank lines are skipped, and a line
+// that is not JSON fails its row only.
+func readNDJSONImport(r io.Reader, add func(*updateNoteRequest, *problem.Problem) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxNDJSONLine)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if err := add(decodeImportedNote([]byte(line))); err != nil {
+			return err
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("the body could not be read: %w", err)
+	}
+	return nil
+}
+
+// readCSVImport reads a CSV file whose header names its columns, among
+// csvColumns. The title and deadline columns are required; the others may
+// be left out.
+func readCSVImport(r io.Reader, add func(*updateNoteRequest, *problem.Problem) error) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return errors.New("the CSV file has no header")
+	}
+	if err != nil {
+		return fmt.Errorf("the body is not valid CSV: %w", err)
+	}
+
+	// Header names are matched loosely, and a byte order mark left by
+	// spreadsheet applications is ignored.
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	for _, name := range []string{"title", "deadline"} {
+		if _, ok := columns[name]; !ok {
+			return fmt.Errorf("the CSV file has no %s column", name)
+		}
+	}
+
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("the body is not valid CSV: %w", err)
+		}
+		cell := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return record[i]
+			}
+			return ""
+		}
+		if err := add(parseCSVNote(cell)); err != nil {
+			return err
+		}
+	}
+}
+
+// parseCSVNote reads a note from the cells of a CSV record. Tags and
+// reminders are lists separated by csvListSeparator.
+func parseCSVNote(cell func(name string) string) (*updateNoteRequest, *problem.Problem) {
+	request := &updateNoteRequest{
+		Title:       cell("title"),
+		Description: cell("description"),
+	}
+	var violations []problem.Violation
+
+	if deadline := strings.TrimSpace(cell("deadline")); deadline != "" {
+		parsed, err := time.Parse(time.RFC3339, deadline)
+		if err != nil {
+			violations = append(violations, problem.Violation{Field: "deadline", Rule: "format", Param: "date-time"})
+		}
+		request.Deadline = parsed
+	}
+	request.Tags = splitCSVList(cell("tags"))
+	for i, raw := range splitCSVList(cell("reminders")) {
+		reminder, err := strconv.Atoi(raw)
+		if err != nil {
+			violations = append(violations, problem.Violation{Field: fmt.Sprintf("reminders[%d]", i), Rule: "type", Param: "integer", Value: "string"})
+			continue
+		}
+		request.Reminders = append(request.Reminders, reminder)
+	}
+
+	if len(violations) > 0 {
+		return nil, problem.Invalid(violations)
+	}
+	return request, nil
+}
+
+// splitCSVList splits a CSV cell holding a list, dropping empty items.
+func splitCSVList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, csvListSeparator) {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// importReportResponse reports what an import did, or in a dry run would
+// do, with each of its notes, in the order they were given.
+type importReportResponse struct {
+	DryRun  bool                `json:"dry_run"`
+	Created int                 `json:"created"`
+	Updated int                 `json:"updated"`
+	Skipped int                 `json:"skipped"`
+	Failed  int                 `json:"failed"`
+	Rows    []importRowResponse `json:"rows"`
+}
+
+// importRowResponse is the outcome of a note of an import: its action, one
+// of create, update, skip or fail, its title, the title it had when it had
+// to be renamed, why it was skipped, and the note written, or in a dry run
+// the note that would be, or the problem that stopped it. Rows count from
+// 1, not counting the header of a CSV file.
+type importRowResponse struct {
+	Row         int              `json:"row"`
+	Action      string           `json:"action"`
+	Title       string           `json:"title,omitempty"`
+	Note        *noteResponse    `json:"note,omitempty"`
+	RenamedFrom string           `json:"renamed_from,omitempty"`
+	Reason      string           `json:"reason,omitempty"`
+	Error       *problem.Problem `json:"error,omitempty"`
+}
+
+// skipReasonTitleTaken is the reason given for a note skipped because its
+// title is taken.
+const skipReasonTitleTaken = "The title is taken"
+
+// importActionFailed is the action reported for a note that was not
+// imported because of a problem.
+const importActionFailed = "fail"
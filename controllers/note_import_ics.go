@@ -0,0 +1,280 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sarita-growexx/note_with_alarm/ical"
+	"github.com/sarita-growexx/note_with_alarm/problem"
+)
+
+// icsFilesField is the multipart form field iCalendar files are uploaded in.
+const icsFilesField = "files"
+
+// icsReadTimeout bounds how long the recurrences of the files of an import
+// may take to expand, in all.
+const icsReadTimeout = 10 * time.Second
+
+// maxReminders and maxReminderMinutes are the limits on the reminders of a
+// note, read from the binding of updateNoteRequest.
+var maxReminders, maxReminderMinutes = reminderLimits()
+
+// reminderLimits returns the max of the Reminders binding of
+// updateNoteRequest, and the max of each reminder, which follows dive.
+func reminderLimits() (count, minutes int) {
+	field, _ := reflect.TypeOf(updateNoteRequest{}).FieldByName("Reminders")
+	slice, each, _ := strings.Cut(field.Tag.Get("binding"), ",dive,")
+	return bindingMax(slice), bindingMax(each)
+}
+
+func bindingMax(binding string) int {
+	for _, rule := range strings.Split(binding, ",") {
+		if value, ok := strings.CutPrefix(rule, "max="); ok {
+			if max, err := strconv.Atoi(value); err == nil {
+				return max
+			}
+		}
+	}
+	panic("binding has no max: " + binding)
+}
+
+// errNoICSFiles is returned for a multipart upload without iCalendar files.
+var errNoICSFiles = fmt.Errorf("no file was uploaded in the %s field", icsFilesField)
+
+// icsFiles returns the iCalendar files of a request, either its body or the
+// parts of its multipart form in the files field, in order.
+func icsFiles(request *http.Request, mediaType string) (func(func(name string, r io.Reader) error) error, error) {
+	if mediaType != "multipart/form-data" {
+		return func(fn func(string, io.Reader) error) error {
+			return fn("", request.Body)
+		}, nil
+	}
+
+	reader, err := request.MultipartReader()
+	if err != nil {
+		return nil, err
+	}
+	return func(fn func(string, io.Reader) error) error {
+		found := false
+		for {
+			part, err := reader.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("the form is not valid: %w", err)
+			}
+			if part.FormName() != icsFilesField {
+				continue
+			}
+			found = true
+			if err := fn(fileName(part), part); err != nil {
+				return err
+			}
+		}
+		if !found {
+			return errNoICSFiles
+		}
+		return nil
+	}, nil
+}
+
+func fileName(part *multipart.Part) string {
+	if name := part.FileName(); name != "" {
+		return name
+	}
+	return icsFilesField
+}
+
+// readICSImport reads the VEVENTs and VTODOs of iCalendar files as notes.
+// Times are resolved with the VTIMEZONEs of each file, and floating times and
+// dates are taken to be in location, the time zone of deadlines. The
+// deadline of a recurring component is its next occurrence after now.
+// Reading stops once ctx is done.
+func readICSImport(ctx context.Context, files func(func(name string, r io.Reader) error) error, location *time.Location, now time.Time) ([]importRow, error) {
+	var rows []importRow
+	err := files(func(name string, r io.Reader) error {
+		components, err := ical.Parse(r)
+		if err != nil {
+			return fileError(name, err)
+		}
+		for _, component := range components {
+			if component.Name != "VCALENDAR" {
+				return fileError(name, fmt.Errorf("%s is not a VCALENDAR", component.Name))
+			}
+			calendar, err := ical.NewCalendar(component)
+			if err != nil {
+				return fileError(name, err)
+			}
+			for _, sub := range calendar.Components {
+				if sub.Name != "VEVENT" && sub.Name != "VTODO" {
+					continue
+				}
+				if len(rows) == maxImportRows {
+					return errTooManyRows
+				}
+				rows = append(rows, readICSComponent(ctx, calendar, sub, location, now))
+				if err := ctx.Err(); err != nil {
+					return fmt.Errorf("reading the files was stopped: %w", err)
+				}
+			}
+		}
+		return nil
+	})
+	return rows, err
+}
+
+func fileError(name string, err error) error {
+	if name == "" {
+		return err
+	}
+	return fmt.Errorf("%s: %w", name, err)
+}
+
+// readICSComponent reads a note from a VEVENT or VTODO. SUMMARY is its title
+// and DESCRIPTION its description. Events are due when they start, and
+// to-dos when they are due. Cancelled events and completed or cancelled
+// to-dos are skipped.
+func readICSComponent(ctx context.Context, calendar *ical.Calendar, component *ical.Component, location *time.Location, now time.Time) importRow {
+	kind := "event"
+	if component.Name == "VTODO" {
+		kind = "to-do"
+	}
+	request := &updateNoteRequest{
+		Title:       strings.TrimSpace(component.Text("SUMMARY")),
+		Description: component.Text("DESCRIPTION"),
+	}
+
+	status := strings.ToUpper(component.Text("STATUS"))
+	switch {
+	case status == "CANCELLED":
+		return importRow{note: request.toModel(), skip: fmt.Sprintf("The %s is cancelled", kind)}
+	case kind == "to-do" && (status == "COMPLETED" || component.Get("COMPLETED") != nil):
+		return importRow{note: request.toModel(), skip: "The to-do is completed"}
+	}
+
+	start, end, err := icsOccurrence(ctx, calendar, component, location, now)
+	if err != nil {
+		return importRow{problem: problem.New(http.StatusBadRequest, fmt.Sprintf("The %s cannot be read: %s", kind, err))}
+	}
+	deadline := start
+	if kind == "to-do" {
+		deadline = end
+	}
+	request.Deadline = deadline.In(location)
+
+	request.Reminders, err = icsReminders(calendar, component, start, end, deadline, location)
+	if err != nil {
+		return importRow{problem: problem.New(http.StatusBadRequest, fmt.Sprintf("The %s cannot be read: %s", kind, err))}
+	}
+	return newImportRow(request, nil)
+}
+
+// icsOccurrence returns when the next occurrence of a component after now
+// starts and ends, or is due for to-dos. Its length is given by DTEND or DUE,
+// or by DURATION. To-dos may be due without a start, and then do not recur.
+func icsOccurrence(ctx context.Context, calendar *ical.Calendar, component *ical.Component, location *time.Location, now time.Time) (start, end time.Time, err error) {
+	endName := "DTEND"
+	if component.Name == "VTODO" {
+		endName = "DUE"
+	}
+	dtstart, dtend := component.Get("DTSTART"), component.Get(endName)
+
+	if dtstart == nil {
+		if dtend == nil || component.Name != "VTODO" {
+			return start, end, errors.New("DTSTART is missing")
+		}
+		due, err := calendar.Time(dtend, location)
+		if err != nil {
+			return start, end, fmt.Errorf("DUE: %w", err)
+		}
+		return due, due, nil
+	}
+
+	first, err := calendar.Time(dtstart, location)
+	if err != nil {
+		return start, end, fmt.Errorf("DTSTART: %w", err)
+	}
+	var length time.Duration
+	switch duration := component.Get("DURATION"); {
+	case dtend != nil:
+		firstEnd, err := calendar.Time(dtend, location)
+		if err != nil {
+			return start, end, fmt.Errorf("%s: %w", endName, err)
+		}
+		length = firstEnd.Sub(first)
+	case duration != nil:
+		if length, err = ical.ParseDuration(duration.Value); err != nil {
+			return start, end, fmt.Errorf("DURATION: %w", err)
+		}
+	}
+
+	// Recurring to-dos are due after their start, so the next occurrence is
+	// the first still due after now.
+	after := now
+	if component.Name == "VTODO" {
+		after = now.Add(-length)
+	}
+	if start, err = calendar.Next(ctx, component, after, location); err != nil {
+		return start, end, err
+	}
+	return start, start.Add(length), nil
+}
+
+// icsReminders reads the VALARMs of a component as reminders, in minutes
+// before the deadline. Triggers are durations from the start or, when
+// RELATED=END, the end of the occurrence, or absolute times. Alarms at or
+// after the deadline, or further before it than maxReminderMinutes, are
+// left out, and beyond maxReminders only the closest to the deadline kept.
+func icsReminders(calendar *ical.Calendar, component *ical.Component, start, end, deadline time.Time, location *time.Location) ([]int, error) {
+	seen := make(map[int]bool)
+	var reminders []int
+	for _, alarm := range component.Components {
+		if alarm.Name != "VALARM" {
+			continue
+		}
+		trigger := alarm.Get("TRIGGER")
+		if trigger == nil {
+			continue
+		}
+
+		var at time.Time
+		if strings.EqualFold(trigger.Param("VALUE"), "DATE-TIME") {
+			var err error
+			if at, err = calendar.Time(trigger, location); err != nil {
+				return nil, fmt.Errorf("TRIGGER: %w", err)
+			}
+		} else {
+			offset, err := ical.ParseDuration(trigger.Value)
+			if err != nil {
+				return nil, fmt.Errorf("TRIGGER: %w", err)
+			}
+			at = start.Add(offset)
+			if strings.EqualFold(trigger.Param("RELATED"), "END") {
+				at = end.Add(offset)
+			}
+		}
+
+		minutes := int(deadline.Sub(at).Round(time.Minute) / time.Minute)
+		if minutes < 1 || minutes > maxReminderMinutes || seen[minutes] {
+			continue
+		}
+		seen[minutes] = true
+		reminders = append(reminders, minutes)
+	}
+
+	sort.Ints(reminders)
+	if len(reminders) > maxReminders {
+		reminders = reminders[:maxReminders]
+	}
+	return reminders, nil
+}
@@ -0,0 +1,98 @@
+package controllers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"time"
+
+	jsonpatch "github.com/evanphx/json-patch/v5"
+	"github.com/sarita-growexx/note_with_alarm/models"
+)
+
+const (
+	mergePatchContentType = "application/merge-patch+json"
+	jsonPatchContentType  = "application/json-patch+json"
+)
+
+var errUnsupportedPatchType = errors.New("unsupported patch content type")
+
+// patchError reports a patch document that is malformed or cannot be applied
+// to the stored note.
+type patchError struct {
+	err error
+}
+
+func (e *patchError) Error() string {
+	return e.err.Error()
+}
+
+func (e *patchError) Unwrap() error {
+	return e.err
+}
+
+// patchableNote is the JSON document patches are applied to. Only fields
+// clients may edit are exposed; id, version and timestamps are read-only.
+type patchableNote struct {
+	Title       string      `json:"title"`
+	Description string      `json:"description"`
+	Deadline    *time.Time  `json:"deadline"`
+	Tags        models.Tags `json:"tags"`
+	Reminders   []int       `json:"reminders"`
+}
+
+// applyNotePatch applies an RFC 7396 merge patch or RFC 6902 JSON patch,
+// selected by content type, to the editable fields of note.
+func applyNotePatch(note *models.Note, contentType string, patch []byte) error {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return errUnsupportedPatchType
+	}
+
+	doc, err := json.Marshal(patchableNote{
+		Title:       note.Title,
+		Description: note.Description,
+		Deadline:    &note.Deadline,
+		Tags:        note.Tags,
+		Reminders:   note.Reminders,
+	})
+	if err != nil {
+		return err
+	}
+
+	var patched []byte
+	switch mediaType {
+	case mergePatchContentType:
+		patched, err = jsonpatch.MergePatch(doc, patch)
+	case jsonPatchContentType:
+		var ops jsonpatch.Patch
+		ops, err = jsonpatch.DecodePatch(patch)
+		if err == nil {
+			patched, err = ops.Apply(doc)
+		}
+	default:
+		return errUnsupportedPatchType
+	}
+	if err != nil {
+		return &patchError{err: err}
+	}
+
+	var result patchableNote
+	decoder := json.NewDecoder(bytes.NewReader(patched))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&result); err != nil {
+		return &patchError{err: fmt.Errorf("patched note is invalid: %w", err)}
+	}
+	if result.Deadline == nil {
+		return &patchError{err: errors.New("deadline cannot be removed")}
+	}
+
+	note.Title = result.Title
+	note.Description = result.Description
+	note.Deadline = *result.Deadline
+	note.Tags = result.Tags
+	note.Reminders = result.Reminders
+	return nil
+}
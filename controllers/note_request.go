@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/sarita-growexx/note_with_alarm/models"
+)
+
+// createNoteRequest is the body of a request that creates a note. Its
+// deadline must not have passed yet.
+type createNoteRequest struct {
+	Title       string    `json:"title" binding:"required,min=3,max=50"`
+	Description string    `json:"description" binding:"max=2000"`
+	Deadline    time.Time `json:"deadline" binding:"required,future"`
+	Tags        []string  `json:"tags" binding:"max=20,unique,dive,tagname"`
+	// Reminders are minutes before the deadline, up to 30 days.
+	Reminders []int `json:"reminders" binding:"max=10,unique,dive,min=1,max=43200"`
+}
+
+func (r *createNoteRequest) toModel() *models.Note {
+	return &models.Note{
+		Title:       r.Title,
+		Description: r.Description,
+		Deadline:    r.Deadline,
+		Tags:        r.Tags,
+		Reminders:   r.Reminders,
+	}
+}
+
+// updateNoteRequest is the body of a request that replaces a note, and the
+// state a patched note is checked against. Unlike on creation, the deadline
+// may be in the past, so overdue notes can still be edited.
+type updateNoteRequest struct {
+	Title       string    `json:"title" binding:"required,min=3,max=50"`
+	Description string    `json:"description" binding:"max=2000"`
+	Deadline    time.Time `json:"deadline" binding:"required"`
+	Tags        []string  `json:"tags" binding:"max=20,unique,dive,tagname"`
+	Reminders   []int     `json:"reminders" binding:"max=10,unique,dive,min=1,max=43200"`
+}
+
+func newUpdateNoteRequest(note *models.Note) *updateNoteRequest {
+	return &updateNoteRequest{
+		Title:       note.Title,
+		Description: note.Description,
+		Deadline:    note.Deadline,
+		Tags:        note.Tags,
+		Reminders:   note.Reminders,
+	}
+}
+
+func (r *updateNoteRequest) toModel() *models.Note {
+	return &models.Note{
+		Title:       r.Title,
+		Description: r.Description,
+		Deadline:    r.Deadline,
+		Tags:        r.Tags,
+		Reminders:   r.Reminders,
+	}
+}
+
+// bulkModeBestEffort applies each operation of a bulk request on its own.
+// By default, in atomic mode, they are applied all together or not at all.
+const bulkModeBestEffort = "best_effort"
+
+// bulkNotesRequest is the body of a bulk request.
+type bulkNotesRequest struct {
+	Mode       string                 `json:"mode" binding:"omitempty,oneof=atomic best_effort"`
+	Operations []bulkOperationRequest `json:"operations" binding:"required,min=1,max=1000,dive"`
+}
+
+// bulkOperationRequest is an operation of a bulk request. Updates and deletes
+// give the ID and expected version of their note, as the path and If-Match
+// header of a single request would. Its note is validated on its own, so an
+// invalid note fails only its operation.
+type bulkOperationRequest struct {
+	Action  string          `json:"action" binding:"required,oneof=create update delete"`
+	ID      uint            `json:"id" binding:"required_unless=Action create"`
+	Version uint            `json:"version" binding:"required_unless=Action create"`
+	Note    json.RawMessage `json:"note" binding:"required_unless=Action delete"`
+}
+
+// toModel validates the note of the operation as the body of a create or
+// update request.
+func (r *bulkOperationRequest) toModel() (models.BulkOperation, error) {
+	operation := models.BulkOperation{Action: models.BulkAction(r.Action)}
+	switch operation.Action {
+	case models.BulkCreate:
+		var request createNoteRequest
+		if err := decodeRequest(r.Note, &request); err != nil {
+			return operation, err
+		}
+		operation.Note = request.toModel()
+		return operation, nil
+	case models.BulkUpdate:
+		var request updateNoteRequest
+		if err := decodeRequest(r.Note, &request); err != nil {
+			return operation, err
+		}
+		operation.Note = request.toModel()
+	default:
+		operation.Note = &models.Note{}
+	}
+	operation.Note.ID = r.ID
+	operation.Note.Version = r.Version
+	return operation, nil
+}
+
+// decodeRequest decodes and validates a request embedded in another.
+func decodeRequest(data json.RawMessage, request interface{}) error {
+	if err := json.Unmarshal(data, request); err != nil {
+		return err
+	}
+	return validateRequest(request)
+}
@@ -0,0 +1,161 @@
+package controllers
+
+import (
+	"time"
+
+	"github.com/sarita-growexx/note_with_alarm/models"
+	"github.com/sarita-growexx/note_with_alarm/problem"
+)
+
+// noteResponse is a note as returned to clients. Besides the stored fields
+// it reports whether the note is overdue, how long remains until its
+// deadline and when its next alert is due.
+type noteResponse struct {
+	ID               uint       `json:"id"`
+	Title            string     `json:"title"`
+	Description      string     `json:"description"`
+	Deadline         time.Time  `json:"deadline"`
+	Tags             []string   `json:"tags"`
+	Reminders        []int      `json:"reminders"`
+	Version          uint       `json:"version"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+	Overdue          bool       `json:"overdue"`
+	RemainingSeconds int64      `json:"remaining_seconds"`
+	NextAlertAt      *time.Time `json:"next_alert_at"`
+}
+
+// newNoteResponse maps note to its response as of now. Remaining seconds are
+// zero once the deadline has passed, and next_alert_at is null.
+func newNoteResponse(note *models.Note, now time.Time) noteResponse {
+	response := noteResponse{
+		ID:          note.ID,
+		Title:       note.Title,
+		Description: note.Description,
+		Deadline:    note.Deadline,
+		Tags:        note.Tags,
+		Reminders:   note.Reminders,
+		Version:     note.Version,
+		CreatedAt:   note.CreatedAt,
+		UpdatedAt:   note.UpdatedAt,
+		Overdue:     !note.Deadline.After(now),
+	}
+	if response.Tags == nil {
+		response.Tags = []string{}
+	}
+	if response.Reminders == nil {
+		response.Reminders = []int{}
+	}
+	if !response.Overdue {
+		response.RemainingSeconds = int64(note.Deadline.Sub(now) / time.Second)
+	}
+	if next, ok := note.NextAlertAt(now); ok {
+		response.NextAlertAt = &next
+	}
+	return response
+}
+
+func newNoteResponses(notes []*models.Note, now time.Time) []noteResponse {
+	responses := make([]noteResponse, 0, len(notes))
+	for _, note := range notes {
+		responses = append(responses, newNoteResponse(note, now))
+	}
+	return responses
+}
+
+// noteSearchResultResponse is a note matching a search, with its relevance.
+type noteSearchResultResponse struct {
+	noteResponse
+	Rank                 float64 `json:"rank,omitempty"`
+	TitleHighlight       string  `json:"title_highlight,omitempty"`
+	DescriptionHighlight string  `json:"description_highlight,omitempty"`
+	Similarity           float64 `json:"similarity,omitempty"`
+}
+
+func newNoteSearchResultResponses(results []*models.NoteSearchResult, now time.Time) []noteSearchResultResponse {
+	responses := make([]noteSearchResultResponse, 0, len(results))
+	for _, result := range results {
+		responses = append(responses, noteSearchResultResponse{
+			noteResponse:         newNoteResponse(&result.Note, now),
+			Rank:                 result.Rank,
+			TitleHighlight:       result.TitleHighlight,
+			DescriptionHighlight: result.DescriptionHighlight,
+			Similarity:           result.Similarity,
+		})
+	}
+	return responses
+}
+
+// noteRevisionResponse is a revision of a note as returned to clients.
+type noteRevisionResponse struct {
+	ID            uint      `json:"id"`
+	NoteID        uint      `json:"note_id"`
+	Revision      uint      `json:"revision"`
+	Title         string    `json:"title"`
+	Description   string    `json:"description"`
+	Deadline      time.Time `json:"deadline"`
+	Tags          []string  `json:"tags"`
+	Reminders     []int     `json:"reminders"`
+	ChangedFields []string  `json:"changed_fields"`
+	ChangedBy     string    `json:"changed_by"`
+	Reason        string    `json:"reason"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func newNoteRevisionResponse(revision *models.NoteRevision) noteRevisionResponse {
+	response := noteRevisionResponse{
+		ID:            revision.ID,
+		NoteID:        revision.NoteID,
+		Revision:      revision.Revision,
+		Title:         revision.Title,
+		Description:   revision.Description,
+		Deadline:      revision.Deadline,
+		Tags:          revision.Tags,
+		Reminders:     revision.Reminders,
+		ChangedFields: revision.ChangedFields,
+		ChangedBy:     revision.ChangedBy,
+		Reason:        revision.Reason,
+		CreatedAt:     revision.CreatedAt,
+	}
+	if response.Tags == nil {
+		response.Tags = []string{}
+	}
+	if response.Reminders == nil {
+		response.Reminders = []int{}
+	}
+	return response
+}
+
+func newNoteRevisionResponses(revisions []*models.NoteRevision) []noteRevisionResponse {
+	responses := make([]noteRevisionResponse, 0, len(revisions))
+	for _, revision := range revisions {
+		responses = append(responses, newNoteRevisionResponse(revision))
+	}
+	return responses
+}
+
+// noteDiffResponse lists the fields that differ between two revisions.
+type noteDiffResponse struct {
+	From    uint64               `json:"from"`
+	To      uint64               `json:"to"`
+	Changes []models.FieldChange `json:"changes"`
+}
+
+// bulkNotesResponse reports the result of every operation of a bulk request,
+// in the order they were given.
+type bulkNotesResponse struct {
+	Succeeded int                  `json:"succeeded"`
+	Failed    int                  `json:"failed"`
+	Results   []bulkResultResponse `json:"results"`
+}
+
+// bulkResultResponse is the result of a bulk operation: the status a single
+// request would have answered with, and the note it created or updated or
+// the problem that stopped it.
+type bulkResultResponse struct {
+	Index  int               `json:"index"`
+	Action models.BulkAction `json:"action"`
+	Status int               `json:"status"`
+	Note   *noteResponse     `json:"note,omitempty"`
+	Error  *problem.Problem  `json:"error,omitempty"`
+}
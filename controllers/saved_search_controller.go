@@ -0,0 +1,185 @@
+package controllers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sarita-growexx/note_with_alarm/models"
+	"github.com/sarita-growexx/note_with_alarm/problem"
+	"github.com/sarita-growexx/note_with_alarm/services"
+)
+
+type SavedSearchController struct {
+	savedSearchService services.SavedSearchService
+	version            apiVersion
+}
+
+const invalidSavedSearchIDErr = "Invalid saved search ID"
+
+// savedSearchRequest is the body accepted when creating or replacing a
+// saved search.
+type savedSearchRequest struct {
+	Name       string `json:"name" binding:"required,max=100"`
+	Query      string `json:"query" binding:"required"`
+	Sort       string `json:"sort"`
+	Subscribed bool   `json:"subscribed"`
+	Channel    string `json:"channel"`
+	WebhookURL string `json:"webhook_url"`
+}
+
+func (r savedSearchRequest) toModel() *models.SavedSearch {
+	return &models.SavedSearch{
+		Name:       r.Name,
+		Query:      r.Query,
+		Sort:       r.Sort,
+		Subscribed: r.Subscribed,
+		Channel:    r.Channel,
+		WebhookURL: r.WebhookURL,
+	}
+}
+
+// savedSearchResponse is a saved search as returned to clients.
+type savedSearchResponse struct {
+	ID         uint      `json:"id"`
+	Name       string    `json:"name"`
+	Query      string    `json:"query"`
+	Sort       string    `json:"sort"`
+	Subscribed bool      `json:"subscribed"`
+	Channel    string    `json:"channel"`
+	WebhookURL string    `json:"webhook_url"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func newSavedSearchResponse(search *models.SavedSearch) savedSearchResponse {
+	return savedSearchResponse{
+		ID:         search.ID,
+		Name:       search.Name,
+		Query:      search.Query,
+		Sort:       search.Sort,
+		Subscribed: search.Subscribed,
+		Channel:    search.Channel,
+		WebhookURL: search.WebhookURL,
+		CreatedAt:  search.CreatedAt,
+		UpdatedAt:  search.UpdatedAt,
+	}
+}
+
+func NewSavedSearchController(savedSearchService services.SavedSearchService) *SavedSearchController {
+	return &SavedSearchController{
+		savedSearchService: savedSearchService,
+		version:            v1{},
+	}
+}
+
+// V2 returns a controller for version 2 of the API, which shares the saved
+// search service of c.
+func (c *SavedSearchController) V2() *SavedSearchController {
+	return &SavedSearchController{
+		savedSearchService: c.savedSearchService,
+		version:            v2{},
+	}
+}
+
+func (c *SavedSearchController) CreateSavedSearchHandler(ctx *gin.Context) {
+	var request savedSearchRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		problem.Write(ctx, problem.Validation(err))
+		return
+	}
+
+	search := request.toModel()
+	if err := c.savedSearchService.CreateSavedSearch(ctx.Request.Context(), search); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	c.version.one(ctx, http.StatusCreated, "saved_search", newSavedSearchResponse(search), "Saved search created successfully")
+}
+
+func (c *SavedSearchController) UpdateSavedSearchHandler(ctx *gin.Context) {
+	id, ok := savedSearchID(ctx)
+	if !ok {
+		return
+	}
+
+	var request savedSearchRequest
+	if err := ctx.ShouldBindJSON(&request); err != nil {
+		problem.Write(ctx, problem.Validation(err))
+		return
+	}
+
+	search := request.toModel()
+	search.ID = id
+	if err := c.savedSearchService.UpdateSavedSearch(ctx.Request.Context(), search); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	c.version.one(ctx, http.StatusOK, "saved_search", newSavedSearchResponse(search), "Saved search updated successfully")
+}
+
+func (c *SavedSearchController) DeleteSavedSearchHandler(ctx *gin.Context) {
+	id, ok := savedSearchID(ctx)
+	if !ok {
+		return
+	}
+
+	if err := c.savedSearchService.DeleteSavedSearch(ctx.Request.Context(), id); err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	c.version.deleted(ctx, "Saved search deleted successfully")
+}
+
+func (c *SavedSearchController) GetSavedSearchHandler(ctx *gin.Context) {
+	id, ok := savedSearchID(ctx)
+	if !ok {
+		return
+	}
+
+	search, err := c.savedSearchService.GetSavedSearchById(ctx.Request.Context(), id)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	c.version.one(ctx, http.StatusOK, "saved_search", newSavedSearchResponse(search), "")
+}
+
+func (c *SavedSearchController) GetAllSavedSearchesHandler(ctx *gin.Context) {
+	searches, err := c.savedSearchService.GetAllSavedSearches(ctx.Request.Context())
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	responses := make([]savedSearchResponse, 0, len(searches))
+	for _, search := range searches {
+		responses = append(responses, newSavedSearchResponse(search))
+	}
+	c.version.many(ctx, "", responses, pagination{Total: int64(len(searches))})
+}
+
+// RunSavedSearchHandler executes the stored query of a saved search and
+// returns the matching notes.
+func (c *SavedSearchController) RunSavedSearchHandler(ctx *gin.Context) {
+	id, ok := savedSearchID(ctx)
+	if !ok {
+		return
+	}
+
+	notes, err := c.savedSearchService.RunSavedSearch(ctx.Request.Context(), id)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+
+	c.version.many(ctx, "", newNoteSearchResultResponses(notes, time.Now()), pagination{Total: int64(len(notes))})
+}
+
+func savedSearchID(ctx *gin.Context) (uint, bool) {
+	return uintParam(ctx, "id", invalidSavedSearchIDErr)
+}
@@ -0,0 +1,207 @@
+package controllers_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sarita-growexx/note_with_alarm/controllers"
+	"github.com/sarita-growexx/note_with_alarm/middleware"
+	"github.com/sarita-growexx/note_with_alarm/models"
+	"github.com/sarita-growexx/note_with_alarm/query"
+	"github.com/sarita-growexx/note_with_alarm/repository"
+	"github.com/sarita-growexx/note_with_alarm/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// Define a mock saved search service for testing
+type MockSavedSearchService struct {
+	mock.Mock
+}
+
+func (m *MockSavedSearchService) CreateSavedSearch(ctx context.Context, search *models.SavedSearch) error {
+	args := m.Called(search)
+	return args.Error(0)
+}
+
+func (m *MockSavedSearchService) UpdateSavedSearch(ctx context.Context, search *models.SavedSearch) error {
+	args := m.Called(search)
+	return args.Error(0)
+}
+
+func (m *MockSavedSearchService) DeleteSavedSearch(ctx context.Context, id uint) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *MockSavedSearchService) GetSavedSearchById(ctx context.Context, id uint) (*models.SavedSearch, error) {
+	args := m.Called(id)
+	search, _ := args.Get(0).(*models.SavedSearch)
+	return search, args.Error(1)
+}
+
+func (m *MockSavedSearchService) GetAllSavedSearches(ctx context.Context) ([]*models.SavedSearch, error) {
+	args := m.Called()
+	searches, _ := args.Get(0).([]*models.SavedSearch)
+	return searches, args.Error(1)
+}
+
+func (m *MockSavedSearchService) RunSavedSearch(ctx context.Context, id uint) ([]*models.NoteSearchResult, error) {
+	args := m.Called(id)
+	results, _ := args.Get(0).([]*models.NoteSearchResult)
+	return results, args.Error(1)
+}
+
+func (m *MockSavedSearchService) RouteAlarms(ctx context.Context, notes []*models.Note) error {
+	args := m.Called(notes)
+	return args.Error(0)
+}
+
+func setupSavedSearchRouter(service services.SavedSearchService) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	controller := controllers.NewSavedSearchController(service)
+	router := gin.New()
+	router.Use(middleware.Errors())
+	router.POST("/saved-searches", controller.CreateSavedSearchHandler)
+	router.GET("/saved-searches", controller.GetAllSavedSearchesHandler)
+	router.GET("/saved-searches/:id", controller.GetSavedSearchHandler)
+	router.PUT("/saved-searches/:id", controller.UpdateSavedSearchHandler)
+	router.DELETE("/saved-searches/:id", controller.DeleteSavedSearchHandler)
+	router.GET("/saved-searches/:id/notes", controller.RunSavedSearchHandler)
+	return router
+}
+
+func TestCreateSavedSearchHandler(t *testing.T) {
+	mockService := new(MockSavedSearchService)
+	router := setupSavedSearchRouter(mockService)
+
+	t.Run("Created", func(t *testing.T) {
+		mockService.On("CreateSavedSearch", mock.MatchedBy(func(search *models.SavedSearch) bool {
+			return search.Name == "Overdue & urgent" && search.Query == "status:overdue tag:urgent"
+		})).Return(nil).Once()
+
+		body := `{"name":"Overdue & urgent","query":"status:overdue tag:urgent","sort":"-deadline"}`
+		req, _ := http.NewRequest("POST", "/saved-searches", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusCreated, w.Code)
+	})
+
+	t.Run("Missing query", func(t *testing.T) {
+		req, _ := http.NewRequest("POST", "/saved-searches", strings.NewReader(`{"name":"Empty"}`))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Unparsable query", func(t *testing.T) {
+		parseErr := &query.ParseError{Offset: 0, Token: "due:soon", Message: "invalid due value"}
+		mockService.On("CreateSavedSearch", mock.Anything).Return(parseErr).Once()
+
+		req, _ := http.NewRequest("POST", "/saved-searches", strings.NewReader(`{"name":"Broken","query":"due:soon"}`))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `"token":"due:soon"`)
+	})
+
+	t.Run("Duplicate name", func(t *testing.T) {
+		mockService.On("CreateSavedSearch", mock.Anything).Return(repository.ErrDuplicateSavedSearchName).Once()
+
+		req, _ := http.NewRequest("POST", "/saved-searches", strings.NewReader(`{"name":"Overdue & urgent","query":"status:overdue"}`))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusConflict, w.Code)
+	})
+
+	mockService.AssertExpectations(t)
+}
+
+func TestSavedSearchHandlers(t *testing.T) {
+	mockService := new(MockSavedSearchService)
+	router := setupSavedSearchRouter(mockService)
+
+	t.Run("Get", func(t *testing.T) {
+		mockService.On("GetSavedSearchById", uint(1)).Return(&models.SavedSearch{ID: 1, Name: "Overdue"}, nil).Once()
+		req, _ := http.NewRequest("GET", "/saved-searches/1", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Get not found", func(t *testing.T) {
+		mockService.On("GetSavedSearchById", uint(2)).Return(nil, repository.ErrSavedSearchNotFound).Once()
+		req, _ := http.NewRequest("GET", "/saved-searches/2", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Invalid ID", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/saved-searches/abc", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("List", func(t *testing.T) {
+		mockService.On("GetAllSavedSearches").Return([]*models.SavedSearch{{ID: 1, Name: "Overdue"}}, nil).Once()
+		req, _ := http.NewRequest("GET", "/saved-searches", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		mockService.On("UpdateSavedSearch", mock.MatchedBy(func(search *models.SavedSearch) bool {
+			return search.ID == 1 && search.Subscribed && search.Channel == "log"
+		})).Return(nil).Once()
+		body := `{"name":"Overdue","query":"status:overdue","subscribed":true,"channel":"log"}`
+		req, _ := http.NewRequest("PUT", "/saved-searches/1", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Update invalid channel", func(t *testing.T) {
+		mockService.On("UpdateSavedSearch", mock.Anything).Return(services.ErrInvalidSavedSearch).Once()
+		body := `{"name":"Overdue","query":"status:overdue","subscribed":true,"channel":"pager"}`
+		req, _ := http.NewRequest("PUT", "/saved-searches/1", strings.NewReader(body))
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		mockService.On("DeleteSavedSearch", uint(1)).Return(nil).Once()
+		req, _ := http.NewRequest("DELETE", "/saved-searches/1", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+	})
+
+	t.Run("Run", func(t *testing.T) {
+		mockService.On("RunSavedSearch", uint(1)).Return([]*models.NoteSearchResult{{Note: models.Note{ID: 3, Title: "Pay invoice"}}}, nil).Once()
+		req, _ := http.NewRequest("GET", "/saved-searches/1/notes", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Contains(t, w.Body.String(), `"title":"Pay invoice"`)
+	})
+
+	t.Run("Run failure", func(t *testing.T) {
+		mockService.On("RunSavedSearch", uint(4)).Return(nil, errors.New("failed to run saved search")).Once()
+		req, _ := http.NewRequest("GET", "/saved-searches/4/notes", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+	})
+
+	mockService.AssertExpectations(t)
+}
@@ -0,0 +1,30 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// streamWriteTimeout bounds each write of a streamed response. It stands in
+// for the WriteTimeout of the server, which bounds the response as a whole
+// and would cut long streams short.
+const streamWriteTimeout = 30 * time.Second
+
+// extendWriteDeadline gives the next write of a streamed response
+// streamWriteTimeout to complete. Writers that do not support deadlines,
+// such as those of tests, are left alone.
+func extendWriteDeadline(ctx *gin.Context) {
+	_ = http.NewResponseController(ctx.Writer).SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+}
+
+// abortStream ends a streamed response that failed after it started by
+// breaking the connection, so that the client sees an error rather than a
+// body that looks complete but is not.
+func abortStream(ctx *gin.Context, what string, err error) {
+	log.Printf("%s %s: %s cut short: %v", ctx.Request.Method, ctx.Request.URL.Path, what, err)
+	ctx.Abort()
+	panic(http.ErrAbortHandler)
+}
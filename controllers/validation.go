@@ -0,0 +1,76 @@
+package controllers
+
+import (
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/sarita-growexx/note_with_alarm/models"
+)
+
+// deadlineGrace is how far in the past a deadline may be and still count as
+// in the future, to allow for clock skew between clients and the server.
+var deadlineGrace = time.Minute
+
+// SetDeadlineGrace sets how far in the past the deadline of a new note may
+// be. It must be called before the server starts handling requests.
+func SetDeadlineGrace(grace time.Duration) {
+	deadlineGrace = grace
+}
+
+func init() {
+	// Request bodies and query strings are validated by gin's validator, so
+	// the custom rules are registered on it once, before any request.
+	engine, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	registerValidations(engine)
+}
+
+func registerValidations(v *validator.Validate) {
+	// Report fields by the names clients send.
+	v.RegisterTagNameFunc(fieldName)
+	must(v.RegisterValidation("future", isFuture))
+	must(v.RegisterValidation("tagname", isTagName))
+}
+
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
+// fieldName names a struct field by its json tag, or its form tag for query
+// parameters, falling back to the Go name.
+func fieldName(field reflect.StructField) string {
+	for _, key := range []string{"json", "form"} {
+		name, _, _ := strings.Cut(field.Tag.Get(key), ",")
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// isFuture validates that a time is after now, less the deadline grace.
+func isFuture(fl validator.FieldLevel) bool {
+	t, ok := fl.Field().Interface().(time.Time)
+	return ok && t.After(time.Now().Add(-deadlineGrace))
+}
+
+// isTagName validates a tag name, as accepted by tag: search filters.
+func isTagName(fl validator.FieldLevel) bool {
+	return models.ValidTagName(fl.Field().String())
+}
+
+// validateRequest checks a request that was not bound by gin, such as a note
+// produced by applying a patch.
+func validateRequest(request interface{}) error {
+	return binding.Validator.ValidateStruct(request)
+}
@@ -0,0 +1,253 @@
+package ical
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	dateFormat          = "20060102"
+	localDateTimeFormat = "20060102T150405"
+)
+
+// Calendar is a VCALENDAR, with the time zones it defines, so that the
+// times of its components can be resolved.
+type Calendar struct {
+	*Component
+	timezones map[string]*Timezone
+}
+
+// NewCalendar reads the VTIMEZONEs of a VCALENDAR.
+func NewCalendar(component *Component) (*Calendar, error) {
+	calendar := &Calendar{Component: component, timezones: map[string]*Timezone{}}
+	for _, sub := range component.Components {
+		if sub.Name != "VTIMEZONE" {
+			continue
+		}
+		tz, err := NewTimezone(sub)
+		if err != nil {
+			return nil, err
+		}
+		calendar.timezones[tz.ID] = tz
+	}
+	return calendar, nil
+}
+
+// Time returns the instant a DATE or DATE-TIME property stands for. Times in
+// UTC stand for themselves, and times with a TZID are resolved with the
+// VTIMEZONE of that ID or, failing that, the IANA time zone of that name.
+// Dates, which are taken to be at midnight, and floating times are in
+// floating.
+func (c *Calendar) Time(p *Property, floating *time.Location) (time.Time, error) {
+	values, err := c.times(p, floating)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return values[0], nil
+}
+
+// Next returns the occurrence of a component, given by its DTSTART, RRULE,
+// RDATE and EXDATE properties, that is the first at or after after; or the
+// last one when all of them are before it. The RRULE is followed from about
+// after on, and no further than that occurrence, unless it has a COUNT. It
+// fails with ErrRecurrenceTooLong when the rule cannot be followed that far,
+// and with the error of ctx once ctx is done.
+func (c *Calendar) Next(ctx context.Context, component *Component, after time.Time, floating *time.Location) (time.Time, error) {
+	dtstart := component.Get("DTSTART")
+	if dtstart == nil {
+		return time.Time{}, errors.New("DTSTART is missing")
+	}
+	start, isUTC, err := parseDateTime(dtstart.Value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("DTSTART: %w", err)
+	}
+	resolve, err := c.resolver(dtstart, isUTC, floating)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("DTSTART: %w", err)
+	}
+
+	excluded := map[int64]bool{}
+	for _, exdate := range component.All("EXDATE") {
+		times, err := c.times(exdate, floating)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("EXDATE: %w", err)
+		}
+		for _, t := range times {
+			excluded[t.Unix()] = true
+		}
+	}
+
+	var next, last time.Time
+	consider := func(t time.Time) {
+		if excluded[t.Unix()] {
+			return
+		}
+		if !t.Before(after) && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+		if t.Before(after) && t.After(last) {
+			last = t
+		}
+	}
+
+	if rrule := component.Get("RRULE"); rrule != nil {
+		rule, err := ParseRecurrence(rrule.Value)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("RRULE: %w", err)
+		}
+		// Wall clock times are within a day of the instants they stand
+		// for, so after can tell Each where to start from.
+		err = rule.Each(ctx, start, after.UTC(), resolve, func(occurrence time.Time) bool {
+			consider(resolve(occurrence))
+			return next.IsZero()
+		})
+		if err != nil {
+			return time.Time{}, fmt.Errorf("RRULE: %w", err)
+		}
+	} else {
+		consider(resolve(start))
+	}
+	for _, rdate := range component.All("RDATE") {
+		times, err := c.times(rdate, floating)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("RDATE: %w", err)
+		}
+		for _, t := range times {
+			consider(t)
+		}
+	}
+
+	if next.IsZero() {
+		if last.IsZero() {
+			return time.Time{}, errors.New("every occurrence is excluded")
+		}
+		return last, nil
+	}
+	return next, nil
+}
+
+// times resolves the comma separated values of a DATE, DATE-TIME or
+// PERIOD property, of which the start is taken.
+func (c *Calendar) times(p *Property, floating *time.Location) ([]time.Time, error) {
+	var times []time.Time
+	for _, value := range strings.Split(p.Value, ",") {
+		value, _, _ = strings.Cut(value, "/")
+		wall, isUTC, err := parseDateTime(value)
+		if err != nil {
+			return nil, err
+		}
+		resolve, err := c.resolver(p, isUTC, floating)
+		if err != nil {
+			return nil, err
+		}
+		times = append(times, resolve(wall))
+	}
+	return times, nil
+}
+
+// resolver returns the function that turns wall clock times of p into
+// instants.
+func (c *Calendar) resolver(p *Property, isUTC bool, floating *time.Location) (func(time.Time) time.Time, error) {
+	if isUTC {
+		return func(t time.Time) time.Time { return t }, nil
+	}
+	location := floating
+	if tzid := p.Param("TZID"); tzid != "" {
+		if tz, ok := c.timezones[tzid]; ok {
+			return tz.Resolve, nil
+		}
+		loaded, err := time.LoadLocation(strings.TrimPrefix(tzid, "/"))
+		if err != nil {
+			return nil, fmt.Errorf("unknown time zone %q", tzid)
+		}
+		location = loaded
+	}
+	return func(t time.Time) time.Time { return inLocation(t, location) }, nil
+}
+
+// parseDateTime parses a DATE or DATE-TIME value. The wall clock time is
+// returned in UTC, and isUTC reports whether it is a UTC time rather than
+// a local one.
+func parseDateTime(value string) (t time.Time, isUTC bool, err error) {
+	switch {
+	case len(value) == len(dateFormat):
+		t, err = time.Parse(dateFormat, value)
+	case strings.HasSuffix(value, "Z"):
+		t, err = time.Parse(localDateTimeFormat, strings.TrimSuffix(value, "Z"))
+		isUTC = true
+	default:
+		t, err = time.Parse(localDateTimeFormat, value)
+	}
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid date or time %q", value)
+	}
+	return t, isUTC, nil
+}
+
+// inLocation returns the time of location with the wall clock of t.
+func inLocation(t time.Time, location *time.Location) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, location)
+}
+
+// ParseDuration parses a DURATION value, such as -PT15M or P1W.
+func ParseDuration(value string) (time.Duration, error) {
+	invalid := fmt.Errorf("invalid duration %q", value)
+	s := value
+	sign := time.Duration(1)
+	switch {
+	case strings.HasPrefix(s, "-"):
+		sign, s = -1, s[1:]
+	case strings.HasPrefix(s, "+"):
+		s = s[1:]
+	}
+	if !strings.HasPrefix(s, "P") || len(s) < 3 {
+		return 0, invalid
+	}
+	s = s[1:]
+
+	var d time.Duration
+	inTime := false
+	for s != "" {
+		if s[0] == 'T' {
+			if inTime || len(s) == 1 {
+				return 0, invalid
+			}
+			inTime = true
+			s = s[1:]
+			continue
+		}
+		i := 0
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		if i == 0 || i == len(s) {
+			return 0, invalid
+		}
+		n, err := strconv.Atoi(s[:i])
+		if err != nil {
+			return 0, invalid
+		}
+		var unit time.Duration
+		switch designator := s[i]; {
+		case designator == 'W' && !inTime:
+			unit = 7 * 24 * time.Hour
+		case designator == 'D' && !inTime:
+			unit = 24 * time.Hour
+		case designator == 'H' && inTime:
+			unit = time.Hour
+		case designator == 'M' && inTime:
+			unit = time.Minute
+		case designator == 'S' && inTime:
+			unit = time.Second
+		default:
+			return 0, invalid
+		}
+		d += time.Duration(n) * unit
+		s = s[i+1:]
+	}
+	return sign * d, nil
+}
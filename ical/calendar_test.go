@@ -0,0 +1,192 @@
+package ical
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const newYorkTimezone = `BEGIN:VTIMEZONE
+TZID:Eastern
+BEGIN:STANDARD
+DTSTART:20071104T020000
+RRULE:FREQ=YEARLY;BYMONTH=11;BYDAY=1SU
+TZOFFSETFROM:-0400
+TZOFFSETTO:-0500
+TZNAME:EST
+END:STANDARD
+BEGIN:DAYLIGHT
+DTSTART:20070311T020000
+RRULE:FREQ=YEARLY;BYMONTH=3;BYDAY=2SU
+TZOFFSETFROM:-0500
+TZOFFSETTO:-0400
+TZNAME:EDT
+END:DAYLIGHT
+END:VTIMEZONE
+`
+
+func parseCalendar(t *testing.T, body string) *Calendar {
+	components, err := Parse(strings.NewReader("BEGIN:VCALENDAR\n" + newYorkTimezone + body + "END:VCALENDAR\n"))
+	require.NoError(t, err)
+	calendar, err := NewCalendar(components[0])
+	require.NoError(t, err)
+	return calendar
+}
+
+func TestCalendarTime(t *testing.T) {
+	calendar := parseCalendar(t, "")
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	require.NoError(t, err)
+
+	for value, expected := range map[string]time.Time{
+		"DTSTART:20260701T090000Z":                          time.Date(2026, 7, 1, 9, 0, 0, 0, time.UTC),
+		"DTSTART;TZID=Eastern:20260701T090000":              time.Date(2026, 7, 1, 13, 0, 0, 0, time.UTC),
+		"DTSTART;TZID=Eastern:20261215T090000":              time.Date(2026, 12, 15, 14, 0, 0, 0, time.UTC),
+		"DTSTART;TZID=Europe/Berlin:20260701T090000":        time.Date(2026, 7, 1, 7, 0, 0, 0, time.UTC),
+		"DTSTART:20260701T090000":                           time.Date(2026, 7, 1, 3, 30, 0, 0, time.UTC),
+		"DTSTART;VALUE=DATE:20260701":                       time.Date(2026, 6, 30, 18, 30, 0, 0, time.UTC),
+		"DTSTART;TZID=Eastern:20260308T013000":              time.Date(2026, 3, 8, 6, 30, 0, 0, time.UTC),
+		"DTSTART;TZID=Eastern:20260308T030000":              time.Date(2026, 3, 8, 7, 0, 0, 0, time.UTC),
+		"DTSTART;TZID=Eastern:19990101T090000":              time.Date(1999, 1, 1, 14, 0, 0, 0, time.UTC),
+		"DTSTART;TZID=\"/America/Chicago\":20260101T090000": time.Date(2026, 1, 1, 15, 0, 0, 0, time.UTC),
+		"DTSTART:2026-01-01T09:00:00":                       {},
+	} {
+		property, err := parseContentLine(value)
+		require.NoError(t, err)
+		got, err := calendar.Time(property, kolkata)
+		if expected.IsZero() {
+			assert.Error(t, err, value)
+			continue
+		}
+		require.NoError(t, err, value)
+		assert.True(t, expected.Equal(got), "%s: %s", value, got)
+	}
+
+	property, _ := parseContentLine("DTSTART;TZID=Nowhere/Special:20260701T090000")
+	_, err = calendar.Time(property, kolkata)
+	assert.EqualError(t, err, `unknown time zone "Nowhere/Special"`)
+}
+
+func TestCalendarNext(t *testing.T) {
+	after := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	for name, test := range map[string]struct {
+		event    string
+		expected time.Time
+	}{
+		"single in the future": {
+			"DTSTART:20261120T100000Z\n",
+			time.Date(2026, 11, 20, 10, 0, 0, 0, time.UTC),
+		},
+		"single in the past": {
+			"DTSTART:20250120T100000Z\n",
+			time.Date(2025, 1, 20, 10, 0, 0, 0, time.UTC),
+		},
+		"weekly across daylight saving": {
+			"DTSTART;TZID=Eastern:20260601T090000\nRRULE:FREQ=WEEKLY;BYDAY=MO,TH\n",
+			time.Date(2026, 10, 19, 13, 0, 0, 0, time.UTC),
+		},
+		"weekly after the clocks change": {
+			"DTSTART;TZID=Eastern:20261012T090000\nRRULE:FREQ=WEEKLY;INTERVAL=4\n",
+			time.Date(2026, 11, 9, 14, 0, 0, 0, time.UTC),
+		},
+		"monthly on the last friday": {
+			"DTSTART:20260130T120000Z\nRRULE:FREQ=MONTHLY;BYDAY=-1FR\n",
+			time.Date(2026, 10, 30, 12, 0, 0, 0, time.UTC),
+		},
+		"monthly skipping short months": {
+			"DTSTART:20260131T120000Z\nRRULE:FREQ=MONTHLY\n",
+			time.Date(2026, 10, 31, 12, 0, 0, 0, time.UTC),
+		},
+		"yearly": {
+			"DTSTART:20200229T120000Z\nRRULE:FREQ=YEARLY\n",
+			time.Date(2028, 2, 29, 12, 0, 0, 0, time.UTC),
+		},
+		"count ends the series": {
+			"DTSTART:20261001T080000Z\nRRULE:FREQ=DAILY;COUNT=5\n",
+			time.Date(2026, 10, 5, 8, 0, 0, 0, time.UTC),
+		},
+		"daily since long ago": {
+			"DTSTART:19700101T080000Z\nRRULE:FREQ=DAILY;INTERVAL=3\n",
+			time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC),
+		},
+		"ended long ago": {
+			"DTSTART:19700101T080000Z\nRRULE:FREQ=WEEKLY;UNTIL=19800101T000000Z\n",
+			time.Date(1979, 12, 27, 8, 0, 0, 0, time.UTC),
+		},
+		"until ends the series": {
+			"DTSTART:20261001T080000Z\nRRULE:FREQ=DAILY;UNTIL=20261010\n",
+			time.Date(2026, 10, 10, 8, 0, 0, 0, time.UTC),
+		},
+		"excluded and added dates": {
+			"DTSTART:20261012T080000Z\nRRULE:FREQ=WEEKLY\nEXDATE:20261019T080000Z\nRDATE:20261021T080000Z\n",
+			time.Date(2026, 10, 21, 8, 0, 0, 0, time.UTC),
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			calendar := parseCalendar(t, "BEGIN:VEVENT\n"+test.event+"END:VEVENT\n")
+			event := calendar.Components[1]
+			got, err := calendar.Next(context.Background(), event, after, time.UTC)
+			require.NoError(t, err)
+			assert.True(t, test.expected.Equal(got), got)
+		})
+	}
+}
+
+func TestCalendarNextGivesUp(t *testing.T) {
+	after := time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC)
+	calendar := parseCalendar(t, "BEGIN:VEVENT\nDTSTART:20260101T080000Z\nRRULE:FREQ=YEARLY;BYMONTH=2;BYMONTHDAY=30\nEND:VEVENT\n")
+	event := calendar.Components[1]
+
+	_, err := calendar.Next(context.Background(), event, after, time.UTC)
+	assert.ErrorIs(t, err, ErrRecurrenceTooLong)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = calendar.Next(ctx, event, after, time.UTC)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestParseRecurrence(t *testing.T) {
+	rule, err := ParseRecurrence("FREQ=MONTHLY;INTERVAL=2;BYDAY=2TU,-1FR;BYMONTH=1,7;WKST=SU")
+	require.NoError(t, err)
+	assert.Equal(t, &Recurrence{
+		Freq:      "MONTHLY",
+		Interval:  2,
+		ByDay:     []WeekdayNum{{N: 2, Day: time.Tuesday}, {N: -1, Day: time.Friday}},
+		ByMonth:   []time.Month{time.January, time.July},
+		WeekStart: time.Sunday,
+	}, rule)
+
+	for _, value := range []string{"FREQ=HOURLY", "FREQ=MONTHLY;BYSETPOS=-1", "FREQ=YEARLY;BYDAY=1MO"} {
+		_, err := ParseRecurrence(value)
+		assert.True(t, errors.Is(err, ErrUnsupportedRecurrence), value)
+	}
+	for _, value := range []string{"INTERVAL=2", "FREQ=DAILY;COUNT=0", "FREQ=DAILY;COUNT=2;UNTIL=20261010", "FREQ=WEEKLY;BYDAY=2MO", "FREQ=DAILY;BYMONTH=13"} {
+		_, err := ParseRecurrence(value)
+		assert.Error(t, err, value)
+		assert.False(t, errors.Is(err, ErrUnsupportedRecurrence), value)
+	}
+}
+
+func TestParseDuration(t *testing.T) {
+	for value, expected := range map[string]time.Duration{
+		"-PT15M":    -15 * time.Minute,
+		"PT0S":      0,
+		"+P1DT6H":   30 * time.Hour,
+		"P2W":       14 * 24 * time.Hour,
+		"-P1DT1M5S": -(24*time.Hour + time.Minute + 5*time.Second),
+	} {
+		got, err := ParseDuration(value)
+		require.NoError(t, err, value)
+		assert.Equal(t, expected, got, value)
+	}
+	for _, value := range []string{"", "P", "PT", "15M", "P1H", "PT1D", "P1DT"} {
+		_, err := ParseDuration(value)
+		assert.Error(t, err, value)
+	}
+}
@@ -0,0 +1,214 @@
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// maxContentLine is the longest content line Parse accepts, once unfolded.
+const maxContentLine = 1 << 20
+
+// textUnescaper undoes the escaping of TEXT values.
+var textUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+// Component is a component of an iCalendar stream, such as a VCALENDAR or
+// the VEVENTs it holds, with its properties and subcomponents in order.
+type Component struct {
+	Name       string
+	Properties []*Property
+	Components []*Component
+}
+
+// Property is a property of a component. Its value is as written, so TEXT
+// values are still escaped.
+type Property struct {
+	Name   string
+	Params map[string]string
+	Value  string
+}
+
+// ParseError is returned for an iCalendar stream that cannot be read.
+type ParseError struct {
+	Line    int
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// Get returns the first property named name, or nil.
+func (c *Component) Get(name string) *Property {
+	for _, property := range c.Properties {
+		if property.Name == name {
+			return property
+		}
+	}
+	return nil
+}
+
+// All returns the properties named name.
+func (c *Component) All(name string) []*Property {
+	var properties []*Property
+	for _, property := range c.Properties {
+		if property.Name == name {
+			properties = append(properties, property)
+		}
+	}
+	return properties
+}
+
+// Text returns the unescaped value of the first property named name, or ""
+// when there is none.
+func (c *Component) Text(name string) string {
+	if property := c.Get(name); property != nil {
+		return UnescapeText(property.Value)
+	}
+	return ""
+}
+
+// Param returns the value of a parameter of the property, or "".
+func (p *Property) Param(name string) string {
+	return p.Params[name]
+}
+
+// UnescapeText undoes the escaping of a TEXT value.
+func UnescapeText(value string) string {
+	return textUnescaper.Replace(value)
+}
+
+// Parse reads the components of an iCalendar stream, usually a single
+// VCALENDAR. Folded lines are unfolded, and names of components, properties
+// and parameters are upper-cased.
+func Parse(r io.Reader) ([]*Component, error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxContentLine)
+
+	var components []*Component
+	var open []*Component
+	number, start := 0, 0
+	var line strings.Builder
+
+	handle := func() error {
+		if line.Len() == 0 {
+			return nil
+		}
+		property, err := parseContentLine(line.String())
+		if err != nil {
+			return &ParseError{Line: start, Message: err.Error()}
+		}
+		line.Reset()
+
+		switch property.Name {
+		case "BEGIN":
+			component := &Component{Name: strings.ToUpper(property.Value)}
+			if len(open) == 0 {
+				components = append(components, component)
+			} else {
+				parent := open[len(open)-1]
+				parent.Components = append(parent.Components, component)
+			}
+			open = append(open, component)
+		case "END":
+			if len(open) == 0 || open[len(open)-1].Name != strings.ToUpper(property.Value) {
+				return &ParseError{Line: start, Message: fmt.Sprintf("END:%s does not close an open component", property.Value)}
+			}
+			open = open[:len(open)-1]
+		default:
+			if len(open) == 0 {
+				return &ParseError{Line: start, Message: fmt.Sprintf("%s is outside of any component", property.Name)}
+			}
+			component := open[len(open)-1]
+			component.Properties = append(component.Properties, property)
+		}
+		return nil
+	}
+
+	for scanner.Scan() {
+		number++
+		text := strings.TrimSuffix(scanner.Text(), "\r")
+		if number == 1 {
+			text = strings.TrimPrefix(text, "\ufeff")
+		}
+		// Lines starting with a space or tab continue the previous one
+		if len(text) > 0 && (text[0] == ' ' || text[0] == '\t') && line.Len() > 0 {
+			line.WriteString(text[1:])
+			continue
+		}
+		if err := handle(); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(text) != "" {
+			line.WriteString(text)
+			start = number
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, &ParseError{Line: number + 1, Message: err.Error()}
+	}
+	if err := handle(); err != nil {
+		return nil, err
+	}
+	if len(open) > 0 {
+		return nil, &ParseError{Line: number, Message: fmt.Sprintf("%s is not closed", open[len(open)-1].Name)}
+	}
+	if len(components) == 0 {
+		return nil, &ParseError{Line: number, Message: "no components found"}
+	}
+	return components, nil
+}
+
+// parseContentLine splits a content line into its name, parameters and
+// value. Parameter values may be quoted, to hold ';', ':' or ','.
+func parseContentLine(line string) (*Property, error) {
+	property := &Property{}
+	i := strings.IndexAny(line, ";:")
+	if i <= 0 {
+		return nil, fmt.Errorf("%q is not a content line", truncate(line))
+	}
+	property.Name = strings.ToUpper(line[:i])
+
+	for line[i] == ';' {
+		rest := line[i+1:]
+		equals := strings.IndexByte(rest, '=')
+		if equals <= 0 {
+			return nil, fmt.Errorf("%s has an invalid parameter", property.Name)
+		}
+		name := strings.ToUpper(rest[:equals])
+
+		var value strings.Builder
+		j := equals + 1
+		quoted := false
+		for ; j < len(rest); j++ {
+			ch := rest[j]
+			if ch == '"' {
+				quoted = !quoted
+				continue
+			}
+			if !quoted && (ch == ';' || ch == ':') {
+				break
+			}
+			value.WriteByte(ch)
+		}
+		if j == len(rest) {
+			return nil, fmt.Errorf("%s has no value", property.Name)
+		}
+		if property.Params == nil {
+			property.Params = make(map[string]string)
+		}
+		property.Params[name] = value.String()
+		i += 1 + j
+	}
+
+	property.Value = line[i+1:]
+	return property, nil
+}
+
+func truncate(line string) string {
+	if runes := []rune(line); len(runes) > 40 {
+		return string(runes[:40]) + "..."
+	}
+	return line
+}
@@ -0,0 +1,57 @@
+package ical
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	input := "\ufeffBEGIN:VCALENDAR\r\n" +
+		"VERSION:2.0\r\n" +
+		"BEGIN:VEVENT\r\n" +
+		"SUMMARY:Pay rent\\; water\\, power\\nand \r\n" +
+		" gas\r\n" +
+		"DTSTART;TZID=\"America/New_York\";VALUE=DATE-TIME:20261101T090000\r\n" +
+		"BEGIN:VALARM\r\n" +
+		"TRIGGER:-PT15M\r\n" +
+		"END:VALARM\r\n" +
+		"END:VEVENT\r\n" +
+		"END:VCALENDAR\r\n"
+
+	components, err := Parse(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Len(t, components, 1)
+	calendar := components[0]
+	assert.Equal(t, "VCALENDAR", calendar.Name)
+	require.Len(t, calendar.Components, 1)
+
+	event := calendar.Components[0]
+	assert.Equal(t, "Pay rent; water, power\nand gas", event.Text("SUMMARY"))
+	dtstart := event.Get("DTSTART")
+	require.NotNil(t, dtstart)
+	assert.Equal(t, "America/New_York", dtstart.Param("TZID"))
+	assert.Equal(t, "20261101T090000", dtstart.Value)
+	require.Len(t, event.Components, 1)
+	assert.Equal(t, "-PT15M", event.Components[0].Get("TRIGGER").Value)
+	assert.Empty(t, event.Text("DESCRIPTION"))
+}
+
+func TestParseErrors(t *testing.T) {
+	for name, input := range map[string]string{
+		"unclosed":      "BEGIN:VCALENDAR\nBEGIN:VEVENT\nEND:VCALENDAR\n",
+		"not closed":    "BEGIN:VCALENDAR\n",
+		"outside":       "SUMMARY:Rent\n",
+		"no components": "\n\n",
+		"no value":      "BEGIN:VCALENDAR\nDTSTART;TZID=UTC\nEND:VCALENDAR\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse(strings.NewReader(input))
+			var parseErr *ParseError
+			assert.True(t, errors.As(err, &parseErr), err)
+		})
+	}
+}
@@ -0,0 +1,409 @@
+package ical
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A recurrence is followed for at most maxPeriods days, weeks, months or
+// years, and maxExpansionTime, each time it is expanded, so that rules which
+// never match give up soon. The time and the context of the expansion are
+// checked every checkPeriods periods.
+const (
+	maxPeriods       = 10000
+	maxExpansionTime = 20 * time.Millisecond
+	checkPeriods     = 64
+)
+
+// ErrRecurrenceTooLong is returned for a recurrence rule that was followed
+// for as long as it may be without reaching the occurrence looked for.
+var ErrRecurrenceTooLong = errors.New("recurrence rule does not reach the occurrence looked for in time")
+
+// ErrUnsupportedRecurrence is returned for recurrence rules using parts
+// this package does not expand, such as BYSETPOS or FREQ=HOURLY.
+var ErrUnsupportedRecurrence = errors.New("unsupported recurrence rule")
+
+// Recurrence is a recurrence rule (RRULE). Daily, weekly, monthly and
+// yearly rules are expanded, by weekday, day of the month and month.
+type Recurrence struct {
+	Freq       string
+	Interval   int
+	Count      int
+	Until      time.Time
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+	WeekStart  time.Weekday
+	// untilUTC is set when Until is a UTC time, rather than a wall clock
+	// time like the occurrences.
+	untilUTC bool
+}
+
+// WeekdayNum is a BYDAY value: a weekday, and with monthly and yearly
+// rules, which of the weekdays of the month it is, from the end when
+// negative. N is zero for every such weekday.
+type WeekdayNum struct {
+	N   int
+	Day time.Weekday
+}
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday, "WE": time.Wednesday,
+	"TH": time.Thursday, "FR": time.Friday, "SA": time.Saturday,
+}
+
+// ParseRecurrence parses the value of an RRULE property.
+func ParseRecurrence(value string) (*Recurrence, error) {
+	r := &Recurrence{Interval: 1, WeekStart: time.Monday}
+	for _, part := range strings.Split(value, ";") {
+		name, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid recurrence rule part %q", part)
+		}
+		var err error
+		switch strings.ToUpper(name) {
+		case "FREQ":
+			r.Freq = strings.ToUpper(val)
+			switch r.Freq {
+			case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+			case "SECONDLY", "MINUTELY", "HOURLY":
+				return nil, fmt.Errorf("%w: FREQ=%s", ErrUnsupportedRecurrence, r.Freq)
+			default:
+				return nil, fmt.Errorf("invalid FREQ %q", val)
+			}
+		case "INTERVAL":
+			r.Interval, err = positive(val)
+		case "COUNT":
+			r.Count, err = positive(val)
+		case "UNTIL":
+			var isUTC bool
+			r.Until, isUTC, err = parseDateTime(val)
+			r.untilUTC = isUTC
+			if err == nil && len(val) == len(dateFormat) {
+				// A date includes the occurrences on that day
+				r.Until = r.Until.Add(24*time.Hour - time.Second)
+			}
+		case "BYDAY":
+			r.ByDay, err = parseByDay(val)
+		case "BYMONTHDAY":
+			r.ByMonthDay, err = parseInts(val, -31, 31)
+		case "BYMONTH":
+			var months []int
+			months, err = parseInts(val, 1, 12)
+			for _, month := range months {
+				r.ByMonth = append(r.ByMonth, time.Month(month))
+			}
+		case "WKST":
+			day, ok := weekdays[strings.ToUpper(val)]
+			if !ok {
+				err = fmt.Errorf("invalid weekday %q", val)
+			}
+			r.WeekStart = day
+		default:
+			return nil, fmt.Errorf("%w: %s", ErrUnsupportedRecurrence, strings.ToUpper(name))
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", strings.ToUpper(name), err)
+		}
+	}
+
+	if r.Freq == "" {
+		return nil, errors.New("recurrence rule has no FREQ")
+	}
+	if r.Count > 0 && !r.Until.IsZero() {
+		return nil, errors.New("recurrence rule has both COUNT and UNTIL")
+	}
+	for _, day := range r.ByDay {
+		if day.N == 0 {
+			continue
+		}
+		if r.Freq == "DAILY" || r.Freq == "WEEKLY" {
+			return nil, fmt.Errorf("BYDAY cannot number weekdays with FREQ=%s", r.Freq)
+		}
+		if r.Freq == "YEARLY" && len(r.ByMonth) == 0 {
+			return nil, fmt.Errorf("%w: numbered BYDAY without BYMONTH", ErrUnsupportedRecurrence)
+		}
+	}
+	return r, nil
+}
+
+// Each passes the occurrences of the rule for a series starting at start to
+// fn, in order, until fn returns false or the rule ends. Occurrences are
+// wall clock times, like start. When UNTIL is a UTC time, resolve converts
+// an occurrence to the instant it stands for, to compare them; it may be
+// nil when start is in UTC.
+//
+// Rules without COUNT skip the periods well before from, a wall clock time,
+// so that fn is passed the occurrences from the period before the one of
+// from on. Each returns ErrRecurrenceTooLong when it gives up, and the error
+// of ctx once ctx is done.
+func (r *Recurrence) Each(ctx context.Context, start, from time.Time, resolve func(time.Time) time.Time, fn func(time.Time) bool) error {
+	giveUp := time.Now().Add(maxExpansionTime)
+	count := 0
+	first := r.firstPeriod(start, from)
+	for period := first; period < first+maxPeriods; period++ {
+		if (period-first)%checkPeriods == checkPeriods-1 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if time.Now().After(giveUp) {
+				return ErrRecurrenceTooLong
+			}
+		}
+		for _, occurrence := range r.expand(start, period*r.Interval) {
+			if occurrence.Before(start) {
+				continue
+			}
+			if r.ended(occurrence, resolve) {
+				return nil
+			}
+			count++
+			if !fn(occurrence) {
+				return nil
+			}
+			if r.Count > 0 && count >= r.Count {
+				return nil
+			}
+		}
+	}
+	return ErrRecurrenceTooLong
+}
+
+// firstPeriod returns the period Each starts from: the one before that of
+// from, or of UNTIL when the rule ends before from. Rules with COUNT start
+// from the first period, to count their occurrences.
+func (r *Recurrence) firstPeriod(start, from time.Time) int {
+	if !r.Until.IsZero() && r.Until.Before(from) {
+		from = r.Until
+	}
+	if r.Count > 0 || !from.After(start) {
+		return 0
+	}
+	var units int
+	switch r.Freq {
+	case "DAILY":
+		units = int(from.Sub(start) / (24 * time.Hour))
+	case "WEEKLY":
+		units = int(from.Sub(start) / (7 * 24 * time.Hour))
+	case "MONTHLY":
+		units = (from.Year()-start.Year())*12 + int(from.Month()) - int(start.Month())
+	case "YEARLY":
+		units = from.Year() - start.Year()
+	}
+	return max(units/r.Interval-1, 0)
+}
+
+func (r *Recurrence) ended(occurrence time.Time, resolve func(time.Time) time.Time) bool {
+	if r.Until.IsZero() {
+		return false
+	}
+	if r.untilUTC && resolve != nil {
+		occurrence = resolve(occurrence)
+	}
+	return occurrence.After(r.Until)
+}
+
+// expand returns the occurrences, in order, of the period that is step
+// days, weeks, months or years after the one of start.
+func (r *Recurrence) expand(start time.Time, step int) []time.Time {
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+	}
+
+	var occurrences []time.Time
+	switch r.Freq {
+	case "DAILY":
+		day := start.AddDate(0, 0, step)
+		if r.matchesMonth(day.Month()) && r.matchesMonthDay(day) && r.matchesWeekday(day.Weekday()) {
+			occurrences = append(occurrences, day)
+		}
+
+	case "WEEKLY":
+		offset := (int(start.Weekday()) - int(r.WeekStart) + 7) % 7
+		weekStart := start.AddDate(0, 0, step*7-offset)
+		days := []time.Weekday{start.Weekday()}
+		if len(r.ByDay) > 0 {
+			days = days[:0]
+			for _, day := range r.ByDay {
+				days = append(days, day.Day)
+			}
+		}
+		for _, day := range days {
+			occurrence := weekStart.AddDate(0, 0, (int(day)-int(r.WeekStart)+7)%7)
+			if r.matchesMonth(occurrence.Month()) {
+				occurrences = append(occurrences, occurrence)
+			}
+		}
+
+	case "MONTHLY":
+		month := time.Date(start.Year(), start.Month()+time.Month(step), 1, 0, 0, 0, 0, time.UTC)
+		if r.matchesMonth(month.Month()) {
+			for _, day := range r.monthDays(month.Year(), month.Month(), start.Day()) {
+				occurrences = append(occurrences, at(month.Year(), month.Month(), day))
+			}
+		}
+
+	case "YEARLY":
+		year := start.Year() + step
+		months := r.ByMonth
+		if len(months) == 0 {
+			months = []time.Month{start.Month()}
+			if len(r.ByDay) > 0 || len(r.ByMonthDay) > 0 {
+				months = []time.Month{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12}
+			}
+		}
+		for _, month := range months {
+			for _, day := range r.monthDays(year, month, start.Day()) {
+				occurrences = append(occurrences, at(year, month, day))
+			}
+		}
+	}
+
+	sort.Slice(occurrences, func(i, j int) bool { return occurrences[i].Before(occurrences[j]) })
+	return occurrences
+}
+
+// monthDays returns the days of a month the rule falls on: those of
+// BYMONTHDAY and BYDAY, or the day of the start of the series.
+func (r *Recurrence) monthDays(year int, month time.Month, startDay int) []int {
+	last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+
+	var days []int
+	for _, day := range r.ByMonthDay {
+		if day < 0 {
+			day = last + 1 + day
+		}
+		if day >= 1 && day <= last {
+			days = append(days, day)
+		}
+	}
+
+	if len(r.ByDay) > 0 {
+		var byDay []int
+		for _, weekday := range r.ByDay {
+			var matching []int
+			for day := 1; day <= last; day++ {
+				if time.Date(year, month, day, 0, 0, 0, 0, time.UTC).Weekday() == weekday.Day {
+					matching = append(matching, day)
+				}
+			}
+			switch {
+			case weekday.N == 0:
+				byDay = append(byDay, matching...)
+			case weekday.N > 0 && weekday.N <= len(matching):
+				byDay = append(byDay, matching[weekday.N-1])
+			case weekday.N < 0 && -weekday.N <= len(matching):
+				byDay = append(byDay, matching[len(matching)+weekday.N])
+			}
+		}
+		if len(r.ByMonthDay) > 0 {
+			days = intersect(days, byDay)
+		} else {
+			days = byDay
+		}
+	}
+
+	if len(r.ByMonthDay) == 0 && len(r.ByDay) == 0 && startDay <= last {
+		days = append(days, startDay)
+	}
+	return days
+}
+
+func (r *Recurrence) matchesMonth(month time.Month) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, m := range r.ByMonth {
+		if m == month {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Recurrence) matchesMonthDay(day time.Time) bool {
+	if len(r.ByMonthDay) == 0 {
+		return true
+	}
+	last := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	for _, d := range r.ByMonthDay {
+		if d == day.Day() || last+1+d == day.Day() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Recurrence) matchesWeekday(weekday time.Weekday) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, day := range r.ByDay {
+		if day.Day == weekday {
+			return true
+		}
+	}
+	return false
+}
+
+func parseByDay(value string) ([]WeekdayNum, error) {
+	var days []WeekdayNum
+	for _, item := range strings.Split(value, ",") {
+		item = strings.ToUpper(strings.TrimSpace(item))
+		if len(item) < 2 {
+			return nil, fmt.Errorf("invalid weekday %q", item)
+		}
+		day, ok := weekdays[item[len(item)-2:]]
+		if !ok {
+			return nil, fmt.Errorf("invalid weekday %q", item)
+		}
+		weekday := WeekdayNum{Day: day}
+		if number := item[:len(item)-2]; number != "" {
+			n, err := strconv.Atoi(number)
+			if err != nil || n == 0 || n < -53 || n > 53 {
+				return nil, fmt.Errorf("invalid weekday %q", item)
+			}
+			weekday.N = n
+		}
+		days = append(days, weekday)
+	}
+	return days, nil
+}
+
+func parseInts(value string, min, max int) ([]int, error) {
+	var numbers []int
+	for _, item := range strings.Split(value, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(item))
+		if err != nil || n == 0 || n < min || n > max {
+			return nil, fmt.Errorf("invalid number %q", item)
+		}
+		numbers = append(numbers, n)
+	}
+	return numbers, nil
+}
+
+func positive(value string) (int, error) {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 1 {
+		return 0, fmt.Errorf("invalid number %q", value)
+	}
+	return n, nil
+}
+
+func intersect(a, b []int) []int {
+	var both []int
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				both = append(both, x)
+				break
+			}
+		}
+	}
+	return both
+}
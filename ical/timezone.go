@@ -0,0 +1,190 @@
+package ical
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Timezone is a time zone defined by a VTIMEZONE, as the offsets from UTC
+// its STANDARD and DAYLIGHT observances switch between. The onsets of the
+// observances are expanded as times are resolved and kept, so a Timezone is
+// not safe for concurrent use.
+type Timezone struct {
+	ID          string
+	observances []observance
+}
+
+// observance is a STANDARD or DAYLIGHT part of a VTIMEZONE. Its onsets are
+// wall clock times of the offset in use before them, offsetFrom.
+type observance struct {
+	name       string
+	start      time.Time
+	rule       *Recurrence
+	dates      []time.Time
+	offsetFrom int
+	offsetTo   int
+
+	// onsets are those of rule up to horizon, in order; past it the rule
+	// is only followed again when it has not ended.
+	onsets  []time.Time
+	horizon time.Time
+	ended   bool
+}
+
+// onsetHorizon is how far past the time being resolved the onsets of a rule
+// are expanded, so that the times of a calendar, which are mostly close to
+// each other, follow it once.
+const onsetHorizon = 10
+
+// NewTimezone reads a VTIMEZONE.
+func NewTimezone(component *Component) (*Timezone, error) {
+	tz := &Timezone{ID: component.Text("TZID")}
+	if tz.ID == "" {
+		return nil, errors.New("VTIMEZONE has no TZID")
+	}
+	for _, sub := range component.Components {
+		if sub.Name != "STANDARD" && sub.Name != "DAYLIGHT" {
+			continue
+		}
+		o, err := newObservance(sub)
+		if err != nil {
+			return nil, fmt.Errorf("VTIMEZONE %s: %s: %w", tz.ID, sub.Name, err)
+		}
+		tz.observances = append(tz.observances, o)
+	}
+	if len(tz.observances) == 0 {
+		return nil, fmt.Errorf("VTIMEZONE %s has neither STANDARD nor DAYLIGHT", tz.ID)
+	}
+	return tz, nil
+}
+
+func newObservance(component *Component) (observance, error) {
+	o := observance{name: component.Text("TZNAME")}
+	var err error
+	for _, name := range []string{"DTSTART", "TZOFFSETFROM", "TZOFFSETTO"} {
+		if component.Get(name) == nil {
+			return o, fmt.Errorf("%s is missing", name)
+		}
+	}
+	if o.start, _, err = parseDateTime(component.Get("DTSTART").Value); err != nil {
+		return o, fmt.Errorf("DTSTART: %w", err)
+	}
+	if o.offsetFrom, err = parseOffset(component.Get("TZOFFSETFROM").Value); err != nil {
+		return o, fmt.Errorf("TZOFFSETFROM: %w", err)
+	}
+	if o.offsetTo, err = parseOffset(component.Get("TZOFFSETTO").Value); err != nil {
+		return o, fmt.Errorf("TZOFFSETTO: %w", err)
+	}
+	if rrule := component.Get("RRULE"); rrule != nil {
+		if o.rule, err = ParseRecurrence(rrule.Value); err != nil {
+			return o, fmt.Errorf("RRULE: %w", err)
+		}
+	}
+	for _, rdate := range component.All("RDATE") {
+		for _, value := range strings.Split(rdate.Value, ",") {
+			date, _, err := parseDateTime(value)
+			if err != nil {
+				return o, fmt.Errorf("RDATE: %w", err)
+			}
+			o.dates = append(o.dates, date)
+		}
+	}
+	return o, nil
+}
+
+// Resolve returns the instant a wall clock time of the time zone stands
+// for, with the offset of the observance whose latest onset is at or before
+// it. Times before every onset take the offset the earliest observance
+// switches from.
+func (tz *Timezone) Resolve(wall time.Time) time.Time {
+	var current *observance
+	var currentOnset time.Time
+	earliest := &tz.observances[0]
+	for i := range tz.observances {
+		o := &tz.observances[i]
+		if o.start.Before(earliest.start) {
+			earliest = o
+		}
+		if onset, ok := o.lastOnset(wall); ok && (current == nil || onset.After(currentOnset)) {
+			current, currentOnset = o, onset
+		}
+	}
+
+	offset, name := earliest.offsetFrom, ""
+	if current != nil {
+		offset, name = current.offsetTo, current.name
+	}
+	return inLocation(wall, time.FixedZone(name, offset))
+}
+
+// lastOnset returns the latest onset of the observance at or before wall.
+func (o *observance) lastOnset(wall time.Time) (time.Time, bool) {
+	var last time.Time
+	found := false
+	if o.rule != nil {
+		o.expandTo(wall)
+		if i := sort.Search(len(o.onsets), func(i int) bool { return o.onsets[i].After(wall) }); i > 0 {
+			last, found = o.onsets[i-1], true
+		}
+	} else if !o.start.After(wall) {
+		last, found = o.start, true
+	}
+	for _, date := range o.dates {
+		if !date.After(wall) && (!found || date.After(last)) {
+			last, found = date, true
+		}
+	}
+	return last, found
+}
+
+// expandTo expands the onsets of the rule up to onsetHorizon years past
+// wall, unless they already reach wall. A rule that cannot be followed that
+// far is taken to have no further onsets.
+func (o *observance) expandTo(wall time.Time) {
+	if o.ended || wall.Before(o.horizon) {
+		return
+	}
+	horizon := wall.AddDate(onsetHorizon, 0, 0)
+	toUTC := func(t time.Time) time.Time { return t.Add(-time.Duration(o.offsetFrom) * time.Second) }
+	reached := false
+	err := o.rule.Each(context.Background(), o.start, o.horizon, toUTC, func(onset time.Time) bool {
+		if onset.After(horizon) {
+			reached = true
+			return false
+		}
+		if len(o.onsets) == 0 || onset.After(o.onsets[len(o.onsets)-1]) {
+			o.onsets = append(o.onsets, onset)
+		}
+		return true
+	})
+	o.horizon = horizon
+	o.ended = err != nil || !reached
+}
+
+// parseOffset parses a UTC-OFFSET value, such as +0530 or -0800, into
+// seconds east of UTC.
+func parseOffset(value string) (int, error) {
+	if (len(value) != 5 && len(value) != 7) || (value[0] != '+' && value[0] != '-') {
+		return 0, fmt.Errorf("invalid offset %q", value)
+	}
+	seconds := 0
+	for i, unit := range []int{3600, 60, 1} {
+		if 1+2*i >= len(value) {
+			break
+		}
+		n, err := strconv.Atoi(value[1+2*i : 3+2*i])
+		if err != nil {
+			return 0, fmt.Errorf("invalid offset %q", value)
+		}
+		seconds += n * unit
+	}
+	if value[0] == '-' {
+		seconds = -seconds
+	}
+	return seconds, nil
+}
@@ -0,0 +1,135 @@
+// Package ical reads and writes iCalendar (RFC 5545) data.
+package ical
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// maxLineLength is the longest a content line may be, in octets, before it
+// is folded onto the next line.
+const maxLineLength = 75
+
+// textEscaper escapes the characters RFC 5545 reserves in TEXT values.
+var textEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+
+// Writer writes the content lines of an iCalendar stream. After the first
+// write error, later writes do nothing and Err reports the error.
+type Writer struct {
+	w   io.Writer
+	err error
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+// Begin starts a component, such as VCALENDAR or VEVENT.
+func (w *Writer) Begin(component string) {
+	w.Property("BEGIN", component)
+}
+
+// End ends a component started by Begin.
+func (w *Writer) End(component string) {
+	w.Property("END", component)
+}
+
+// Property writes a property whose value is written as is, such as a date
+// or a duration. Name may carry parameters, as in "TRIGGER;RELATED=END".
+func (w *Writer) Property(name, value string) {
+	w.writeLine(name + ":" + value)
+}
+
+// Text writes a property of type TEXT, escaping its value.
+func (w *Writer) Text(name, value string) {
+	w.Property(name, EscapeText(value))
+}
+
+// TextList writes a property holding a list of TEXT values, such as
+// CATEGORIES.
+func (w *Writer) TextList(name string, values []string) {
+	escaped := make([]string, len(values))
+	for i, value := range values {
+		escaped[i] = EscapeText(value)
+	}
+	w.Property(name, strings.Join(escaped, ","))
+}
+
+// Err returns the first error met while writing.
+func (w *Writer) Err() error {
+	return w.err
+}
+
+// writeLine writes a content line, folded so that no line is longer than
+// maxLineLength octets. Lines are only folded between characters, so that
+// multi-byte characters stay whole.
+func (w *Writer) writeLine(line string) {
+	if w.err != nil {
+		return
+	}
+	var folded strings.Builder
+	limit := maxLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		folded.WriteString(line[:cut])
+		folded.WriteString("\r\n ")
+		line = line[cut:]
+		// Continuation lines start with a space, which counts towards
+		// their length
+		limit = maxLineLength - 1
+	}
+	folded.WriteString(line)
+	folded.WriteString("\r\n")
+	_, w.err = io.WriteString(w.w, folded.String())
+}
+
+// EscapeText escapes a TEXT value.
+func EscapeText(value string) string {
+	return textEscaper.Replace(value)
+}
+
+// FormatDateTime formats t as a DATE-TIME value in UTC, such as
+// 20261101T090000Z.
+func FormatDateTime(t time.Time) string {
+	return t.UTC().Format("20060102T150405Z")
+}
+
+// FormatDuration formats d as a DURATION value, such as -PT15M or P1DT6H.
+// Durations are written to the second.
+func FormatDuration(d time.Duration) string {
+	var b strings.Builder
+	if d < 0 {
+		b.WriteByte('-')
+		d = -d
+	}
+	b.WriteByte('P')
+	seconds := int64(d / time.Second)
+	days := seconds / 86400
+	seconds %= 86400
+	if days > 0 {
+		fmt.Fprintf(&b, "%dD", days)
+	}
+	if seconds == 0 {
+		if days == 0 {
+			b.WriteString("T0S")
+		}
+		return b.String()
+	}
+	b.WriteByte('T')
+	if hours := seconds / 3600; hours > 0 {
+		fmt.Fprintf(&b, "%dH", hours)
+	}
+	if minutes := seconds % 3600 / 60; minutes > 0 {
+		fmt.Fprintf(&b, "%dM", minutes)
+	}
+	if seconds%60 > 0 {
+		fmt.Fprintf(&b, "%dS", seconds%60)
+	}
+	return b.String()
+}
@@ -0,0 +1,45 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriter(t *testing.T) {
+	var b strings.Builder
+	w := NewWriter(&b)
+	w.Begin("VEVENT")
+	w.Text("SUMMARY", "Pay rent; water, power\nand gas")
+	w.TextList("CATEGORIES", []string{"home", "a,b"})
+	w.Text("DESCRIPTION", strings.Repeat("é", 40))
+	w.End("VEVENT")
+	assert.NoError(t, w.Err())
+
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\r\n"), "\r\n")
+	assert.Equal(t, []string{
+		"BEGIN:VEVENT",
+		`SUMMARY:Pay rent\; water\, power\nand gas`,
+		`CATEGORIES:home,a\,b`,
+		"DESCRIPTION:" + strings.Repeat("é", 31),
+		" " + strings.Repeat("é", 9),
+		"END:VEVENT",
+	}, lines)
+	for _, line := range lines {
+		assert.LessOrEqual(t, len(line), maxLineLength)
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	for d, expected := range map[time.Duration]string{
+		0:                             "PT0S",
+		-15 * time.Minute:             "-PT15M",
+		-24 * time.Hour:               "-P1D",
+		30*time.Hour + 90*time.Second: "P1DT6H1M30S",
+	} {
+		assert.Equal(t, expected, FormatDuration(d))
+	}
+	assert.Equal(t, "20261101T090000Z", FormatDateTime(time.Date(2026, time.November, 1, 14, 30, 0, 0, time.FixedZone("IST", 19800))))
+}
@@ -1,19 +1,21 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"strconv"
 	"time"
 
 	"github.com/sarita-growexx/note_with_alarm/config"
 	"github.com/sarita-growexx/note_with_alarm/controllers"
 	"github.com/sarita-growexx/note_with_alarm/helper"
-	"github.com/sarita-growexx/note_with_alarm/models"
+	"github.com/sarita-growexx/note_with_alarm/migrations"
 	"github.com/sarita-growexx/note_with_alarm/repository"
 	routers "github.com/sarita-growexx/note_with_alarm/router"
 	"github.com/sarita-growexx/note_with_alarm/services"
-	"github.com/sarita-growexx/note_with_alarm/utils"
 )
 
 func main() {
@@ -29,23 +31,54 @@ func main() {
 	//Database
 	db := config.ConnectionDB(&loadConfig)
 
-	db.AutoMigrate(&models.Note{})
+	migrator, err := migrations.NewMigrator(db)
+	if err != nil {
+		log.Fatal("🚀 Could not load migrations", err)
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := runMigrateCommand(migrator, os.Args[2:]); err != nil {
+			log.Fatal("🚀 Migration failed: ", err)
+		}
+		return
+	}
+
+	if _, err := migrator.Up(); err != nil {
+		log.Fatal("🚀 Could not migrate the database: ", err)
+	}
 
 	noteRepository := repository.NewNoteRepository(db)
 	noteService := services.NewNoteService(noteRepository)
 	noteController := controllers.NewNoteController(noteService)
+	controllers.SetDeadlineGrace(loadConfig.DeadlineGrace)
 
-	routes := routers.SetupRouter(noteController)
+	savedSearchRepository := repository.NewSavedSearchRepository(db)
+	savedSearchService := services.NewSavedSearchService(savedSearchRepository, noteRepository)
+	savedSearchController := controllers.NewSavedSearchController(savedSearchService)
+
+	calendarTokenRepository := repository.NewCalendarTokenRepository(db)
+	calendarService := services.NewCalendarService(calendarTokenRepository, noteRepository)
+	calendarController := controllers.NewCalendarController(calendarService)
+
+	idempotencyKeyRepository := repository.NewIdempotencyKeyRepository(db)
+
+	routes := routers.SetupRouter(noteController, savedSearchController, calendarController, idempotencyKeyRepository, loadConfig.DBQueryTimeout, loadConfig.IdempotencyTTL)
 
 	// Start the background task to check for upcoming deadlines
 	go func() {
 		for {
-			notes, err := noteService.GetAllNotes()
+			notes, err := noteService.GetAllNotes(context.Background())
 			if err != nil {
 				log.Println("Failed to retrieve notes for checking deadlines: ", err)
 			}
 
-			utils.SetAlarmForNotes(notes)
+			if err := savedSearchService.RouteAlarms(context.Background(), notes); err != nil {
+				log.Println("Failed to route alarms through saved searches: ", err)
+			}
+
+			if _, err := idempotencyKeyRepository.DeleteExpired(context.Background(), time.Now()); err != nil {
+				log.Println("Failed to delete expired idempotency keys: ", err)
+			}
 
 			// Sleep for a specified duration before checking again
 			time.Sleep(time.Minute * 5)
@@ -63,3 +96,51 @@ func main() {
 	serverErr := server.ListenAndServe()
 	helper.ErrorPanic(serverErr)
 }
+
+// runMigrateCommand handles `migrate up`, `migrate down [steps]` and
+// `migrate status`.
+func runMigrateCommand(migrator *migrations.Migrator, args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: migrate up|down [steps]|status")
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := migrator.Up()
+		for _, migration := range applied {
+			fmt.Printf("applied %04d_%s\n", migration.Version, migration.Name)
+		}
+		if err == nil && len(applied) == 0 {
+			fmt.Println("database is up to date")
+		}
+		return err
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			parsed, err := strconv.Atoi(args[1])
+			if err != nil || parsed < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+			steps = parsed
+		}
+		reverted, err := migrator.Down(steps)
+		for _, migration := range reverted {
+			fmt.Printf("reverted %04d_%s\n", migration.Version, migration.Name)
+		}
+		return err
+	case "status":
+		statuses, err := migrator.Status()
+		if err != nil {
+			return err
+		}
+		for _, status := range statuses {
+			appliedAt := "pending"
+			if status.AppliedAt != nil {
+				appliedAt = status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%04d_%s\t%s\n", status.Version, status.Name, appliedAt)
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown migrate command %q", args[0])
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Deprecated marks the responses of a deprecated API version with the
+// Deprecation (RFC 9745) and Sunset (RFC 8594) headers, and links to the
+// version that replaces it.
+func Deprecated(since, sunset time.Time, successor string) gin.HandlerFunc {
+	deprecation := "@" + strconv.FormatInt(since.Unix(), 10)
+	sunsetDate := sunset.UTC().Format(http.TimeFormat)
+	link := "<" + successor + `>; rel="successor-version"`
+
+	return func(ctx *gin.Context) {
+		header := ctx.Writer.Header()
+		header.Set("Deprecation", deprecation)
+		header.Set("Sunset", sunsetDate)
+		header.Add("Link", link)
+		ctx.Next()
+	}
+}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"log"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sarita-growexx/note_with_alarm/apperrors"
+	"github.com/sarita-growexx/note_with_alarm/problem"
+)
+
+// Errors writes the problem details response for the last error a handler
+// attached with ctx.Error, as described by problem.FromError. Internal errors
+// are logged with their cause, which is not sent to the client. Requests the
+// client canceled get no response, as nobody is left to read it, only the
+// status for the log. Handlers that already wrote a response are left alone.
+func Errors() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Next()
+
+		last := ctx.Errors.Last()
+		if last == nil || ctx.Writer.Written() {
+			return
+		}
+
+		err := last.Err
+		switch apperrors.KindOf(err) {
+		case apperrors.Internal:
+			log.Printf("%s %s: %v", ctx.Request.Method, ctx.Request.URL.Path, err)
+		case apperrors.Canceled:
+			ctx.Status(problem.StatusClientClosedRequest)
+			return
+		}
+
+		problem.Write(ctx, problem.FromError(err))
+	}
+}
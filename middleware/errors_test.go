@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sarita-growexx/note_with_alarm/apperrors"
+	"github.com/sarita-growexx/note_with_alarm/problem"
+	"github.com/sarita-growexx/note_with_alarm/query"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrors(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	notFound := apperrors.New(apperrors.NotFound, "note not found")
+	_, parseErr := query.Parse("due:soon")
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		body   string
+	}{
+		{"not found", notFound, http.StatusNotFound, `{"type":"about:blank","title":"Not Found","status":404,"detail":"Note not found","instance":"/"}`},
+		{"wrapped", fmt.Errorf("loading note: %w", notFound), http.StatusNotFound, `{"type":"about:blank","title":"Not Found","status":404,"detail":"Loading note: note not found","instance":"/"}`},
+		{"conflict", apperrors.New(apperrors.Conflict, "duplicate title"), http.StatusConflict, `{"type":"about:blank","title":"Conflict","status":409,"detail":"Duplicate title","instance":"/"}`},
+		{"precondition", apperrors.New(apperrors.PreconditionFailed, "stale version"), http.StatusPreconditionFailed, `{"type":"about:blank","title":"Precondition Failed","status":412,"detail":"Stale version","instance":"/"}`},
+		{"validation with fields", parseErr, http.StatusBadRequest, `{"type":"/problems/validation-error","title":"Bad Request","status":400,"detail":` + strconv.Quote(apperrors.MessageOf(parseErr)) + `,"instance":"/","offset":0,"token":"due:soon"}`},
+		{"internal hides cause", apperrors.Wrap(apperrors.Internal, "failed to list notes", errors.New("connection refused")), http.StatusInternalServerError, `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"Failed to list notes","instance":"/"}`},
+		{"unclassified", errors.New("connection refused"), http.StatusInternalServerError, `{"type":"about:blank","title":"Internal Server Error","status":500,"detail":"Internal server error","instance":"/"}`},
+		{"timeout hides cause", apperrors.Wrap(apperrors.Timeout, "the database did not answer in time", context.DeadlineExceeded), http.StatusGatewayTimeout, `{"type":"about:blank","title":"Gateway Timeout","status":504,"detail":"The database did not answer in time","instance":"/"}`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(Errors())
+			router.GET("/", func(ctx *gin.Context) {
+				ctx.Error(tc.err)
+			})
+
+			req, _ := http.NewRequest("GET", "/", nil)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+			assert.Equal(t, tc.status, w.Code)
+			assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+			assert.JSONEq(t, tc.body, w.Body.String())
+		})
+	}
+}
+
+func TestErrors_ResponseWritten(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(Errors())
+	router.GET("/", func(ctx *gin.Context) {
+		ctx.JSON(http.StatusUnprocessableEntity, gin.H{"error": "handled"})
+		ctx.Error(errors.New("already handled"))
+	})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.JSONEq(t, `{"error":"handled"}`, w.Body.String())
+}
+
+func TestErrors_Canceled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(Errors())
+	router.GET("/", func(ctx *gin.Context) {
+		ctx.Error(apperrors.Wrap(apperrors.Canceled, "the request was canceled", context.Canceled))
+	})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, problem.StatusClientClosedRequest, w.Code)
+	assert.Empty(t, w.Body.String())
+}
@@ -0,0 +1,196 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sarita-growexx/note_with_alarm/models"
+	"github.com/sarita-growexx/note_with_alarm/problem"
+	"github.com/sarita-growexx/note_with_alarm/repository"
+)
+
+const (
+	// IdempotencyKeyHeader carries the key a client picks for a request, and
+	// resends when it retries the request.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on responses replayed for a retry.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+)
+
+// Idempotency lets clients safely retry requests that send an
+// Idempotency-Key header. The first successful response to a key is stored
+// in keys for ttl, and replayed to retries of the same request with the
+// Idempotent-Replayed header set. Reusing a key for a different request is
+// answered with 422, and retrying while the first request is still in
+// progress with 409. Responses other than 2xx are not stored, so a request
+// that failed can be retried with its key.
+//
+// A request still in progress after lease, the longest a request may run,
+// is taken to have died with its server, and the next retry takes its key
+// over instead of being refused until the key expires. A zero lease never
+// takes keys over.
+//
+// Requests without the header are handled as usual.
+func Idempotency(keys repository.IdempotencyKeyRepository, ttl, lease time.Duration) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		key := ctx.GetHeader(IdempotencyKeyHeader)
+		if key == "" {
+			ctx.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			problem.Write(ctx, problem.New(http.StatusBadRequest, "Idempotency-Key must be at most 255 characters long"))
+			ctx.Abort()
+			return
+		}
+
+		var body []byte
+		if ctx.Request.Body != nil {
+			var err error
+			body, err = io.ReadAll(ctx.Request.Body)
+			if err != nil {
+				problem.Write(ctx, problem.New(http.StatusBadRequest, err.Error()))
+				ctx.Abort()
+				return
+			}
+			ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		token, err := newLease()
+		if err != nil {
+			ctx.Error(err)
+			ctx.Abort()
+			return
+		}
+		now := time.Now()
+		record := &models.IdempotencyKey{
+			Key:         key,
+			Lease:       token,
+			RequestHash: requestHash(ctx.Request, body),
+			CreatedAt:   now,
+			ExpiresAt:   now.Add(ttl),
+		}
+		if !reserveIdempotencyKey(ctx, keys, record, lease) {
+			ctx.Abort()
+			return
+		}
+
+		writer := &recordingWriter{ResponseWriter: ctx.Writer}
+		ctx.Writer = writer
+		ctx.Next()
+		ctx.Writer = writer.ResponseWriter
+
+		// The key must be settled even when the request context has been
+		// cancelled, or retries would be refused until it expires.
+		settleCtx := context.WithoutCancel(ctx.Request.Context())
+		status := ctx.Writer.Status()
+		if len(ctx.Errors) > 0 || status < 200 || status >= 300 {
+			if err := keys.Release(settleCtx, record); err != nil {
+				log.Printf("%s %s: failed to release idempotency key: %v", ctx.Request.Method, ctx.Request.URL.Path, err)
+			}
+			return
+		}
+
+		record.Status = status
+		record.Header = models.Header(ctx.Writer.Header().Clone())
+		record.Body = writer.body.Bytes()
+		if err := keys.Complete(settleCtx, record); err != nil {
+			log.Printf("%s %s: failed to store idempotent response: %v", ctx.Request.Method, ctx.Request.URL.Path, err)
+		}
+	}
+}
+
+// reserveIdempotencyKey claims record.Key for the request, taking it over
+// from a request in progress for longer than lease. When the key is taken,
+// it writes the stored response or the error instead and returns false.
+func reserveIdempotencyKey(ctx *gin.Context, keys repository.IdempotencyKeyRepository, record *models.IdempotencyKey, lease time.Duration) bool {
+	stored, err := keys.Get(ctx.Request.Context(), record.Key)
+	switch {
+	case errors.Is(err, repository.ErrIdempotencyKeyNotFound):
+	case err != nil:
+		ctx.Error(err)
+		return false
+	case !stored.ExpiresAt.After(record.CreatedAt):
+		if err := keys.Delete(ctx.Request.Context(), record.Key); err != nil {
+			ctx.Error(err)
+			return false
+		}
+	case stored.RequestHash != record.RequestHash:
+		problem.Write(ctx, problem.New(http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request"))
+		return false
+	case stored.Status == 0 && lease > 0 && stored.CreatedAt.Add(lease).Before(record.CreatedAt):
+		return claimIdempotencyKey(ctx, keys.TakeOver(ctx.Request.Context(), record, record.CreatedAt.Add(-lease)))
+	case stored.Status == 0:
+		problem.Write(ctx, problem.New(http.StatusConflict, "A request with this Idempotency-Key is still in progress"))
+		return false
+	default:
+		header := ctx.Writer.Header()
+		for name, values := range stored.Header {
+			header[name] = values
+		}
+		header.Set(IdempotentReplayedHeader, "true")
+		ctx.Writer.WriteHeader(stored.Status)
+		ctx.Writer.Write(stored.Body)
+		return false
+	}
+
+	return claimIdempotencyKey(ctx, keys.Reserve(ctx.Request.Context(), record))
+}
+
+// claimIdempotencyKey writes the error, if any, of reserving a key and
+// returns whether the key was reserved.
+func claimIdempotencyKey(ctx *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, repository.ErrIdempotencyKeyExists):
+		problem.Write(ctx, problem.New(http.StatusConflict, "A request with this Idempotency-Key is still in progress"))
+		return false
+	case err != nil:
+		ctx.Error(err)
+		return false
+	}
+	return true
+}
+
+// newLease returns a random token identifying the request holding a key.
+func newLease() (string, error) {
+	lease := make([]byte, 16)
+	if _, err := rand.Read(lease); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(lease), nil
+}
+
+// requestHash identifies a request by its method, path and body.
+func requestHash(req *http.Request, body []byte) string {
+	hash := sha256.New()
+	io.WriteString(hash, req.Method+" "+req.URL.Path+"\n")
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// recordingWriter keeps a copy of the response body it writes.
+type recordingWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *recordingWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *recordingWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sarita-growexx/note_with_alarm/apperrors"
+	"github.com/sarita-growexx/note_with_alarm/models"
+	"github.com/sarita-growexx/note_with_alarm/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotency(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	keys := repository.NewMemoryIdempotencyKeyRepository()
+
+	created := 0
+	router := gin.New()
+	router.Use(Errors())
+	router.POST("/notes", Idempotency(keys, time.Hour, time.Minute), func(ctx *gin.Context) {
+		if strings.Contains(ctx.GetHeader("X-Fail"), "conflict") {
+			ctx.Error(apperrors.New(apperrors.Conflict, "duplicate title"))
+			return
+		}
+		if retry := ctx.GetHeader("X-Taken-Over"); retry != "" {
+			// A retry takes the key over while this request is still running
+			now := time.Now().Add(2 * time.Minute)
+			require.NoError(t, keys.TakeOver(context.Background(), &models.IdempotencyKey{
+				Key: ctx.GetHeader(IdempotencyKeyHeader), Lease: retry, RequestHash: "retry", CreatedAt: now, ExpiresAt: now.Add(time.Hour),
+			}, now.Add(-time.Minute)))
+		}
+		created++
+		ctx.Header("ETag", `"1"`)
+		ctx.JSON(http.StatusCreated, gin.H{"id": created})
+	})
+
+	post := func(key, body string, header ...string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/notes", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	t.Run("Without a key", func(t *testing.T) {
+		post("", `{"title":"Pay invoice"}`)
+		post("", `{"title":"Pay invoice"}`)
+		assert.Equal(t, 2, created)
+	})
+
+	t.Run("Retry replays the response", func(t *testing.T) {
+		w := post("create-1", `{"title":"Pay invoice"}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Empty(t, w.Header().Get(IdempotentReplayedHeader))
+		first := w.Body.String()
+
+		w = post("create-1", `{"title":"Pay invoice"}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, first, w.Body.String())
+		assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+		assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+		assert.Equal(t, "true", w.Header().Get(IdempotentReplayedHeader))
+		assert.Equal(t, 3, created)
+	})
+
+	t.Run("Key reused for a different request", func(t *testing.T) {
+		w := post("create-1", `{"title":"Call bank"}`)
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Contains(t, w.Body.String(), "Idempotency-Key was already used for a different request")
+		assert.Equal(t, 3, created)
+	})
+
+	t.Run("Failures are not stored", func(t *testing.T) {
+		w := post("create-2", `{"title":"Taken"}`, "X-Fail", "conflict")
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		w = post("create-2", `{"title":"Taken"}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Empty(t, w.Header().Get(IdempotentReplayedHeader))
+		assert.Equal(t, 4, created)
+	})
+
+	t.Run("Request in progress", func(t *testing.T) {
+		now := time.Now()
+		require.NoError(t, keys.Reserve(context.Background(), &models.IdempotencyKey{
+			Key: "create-3", RequestHash: requestHash(httptest.NewRequest("POST", "/notes", nil), []byte(`{}`)), CreatedAt: now, ExpiresAt: now.Add(time.Hour),
+		}))
+		w := post("create-3", `{}`)
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.Contains(t, w.Body.String(), "still in progress")
+	})
+
+	t.Run("Abandoned request", func(t *testing.T) {
+		now := time.Now()
+		require.NoError(t, keys.Reserve(context.Background(), &models.IdempotencyKey{
+			Key: "create-5", RequestHash: requestHash(httptest.NewRequest("POST", "/notes", nil), []byte(`{}`)), CreatedAt: now.Add(-2 * time.Minute), ExpiresAt: now.Add(time.Hour),
+		}))
+		w := post("create-5", `{}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, `{"id":`+strconv.Itoa(created)+`}`, w.Body.String())
+
+		w = post("create-5", `{}`)
+		assert.Equal(t, "true", w.Header().Get(IdempotentReplayedHeader))
+	})
+
+	t.Run("Taken over while in progress", func(t *testing.T) {
+		w := post("create-6", `{}`, "X-Taken-Over", "retry")
+		assert.Equal(t, http.StatusCreated, w.Code)
+
+		// The response is not stored over the reservation of the retry
+		stored, err := keys.Get(context.Background(), "create-6")
+		require.NoError(t, err)
+		assert.Equal(t, "retry", stored.Lease)
+		assert.Zero(t, stored.Status)
+	})
+
+	t.Run("Expired key", func(t *testing.T) {
+		now := time.Now()
+		require.NoError(t, keys.Reserve(context.Background(), &models.IdempotencyKey{
+			Key: "create-4", RequestHash: "other", Status: http.StatusCreated, CreatedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(-time.Hour),
+		}))
+		w := post("create-4", `{"title":"Fresh"}`)
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.Equal(t, `{"id":`+strconv.Itoa(created)+`}`, w.Body.String())
+	})
+
+	t.Run("Key too long", func(t *testing.T) {
+		w := post(strings.Repeat("k", 256), `{}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// redacted stands in for the values of secret query parameters in logs.
+const redacted = "REDACTED"
+
+// Logger logs each request to out in the format of gin.Logger, with the
+// values of the secret query parameters, such as the token of a calendar
+// feed, replaced so that credentials in URLs do not end up in logs.
+func Logger(out io.Writer, secrets ...string) gin.HandlerFunc {
+	return gin.LoggerWithConfig(gin.LoggerConfig{
+		Output: out,
+		Formatter: func(param gin.LogFormatterParams) string {
+			var statusColor, methodColor, resetColor string
+			if param.IsOutputColor() {
+				statusColor = param.StatusCodeColor()
+				methodColor = param.MethodColor()
+				resetColor = param.ResetColor()
+			}
+			if param.Latency > time.Minute {
+				param.Latency = param.Latency.Truncate(time.Second)
+			}
+			return fmt.Sprintf("[GIN] %v |%s %3d %s| %13v | %15s |%s %-7s %s %#v\n%s",
+				param.TimeStamp.Format("2006/01/02 - 15:04:05"),
+				statusColor, param.StatusCode, resetColor,
+				param.Latency,
+				param.ClientIP,
+				methodColor, param.Method, resetColor,
+				redactQuery(param.Path, secrets),
+				param.ErrorMessage,
+			)
+		},
+	})
+}
+
+// redactQuery replaces the values of the secret parameters in the query of
+// path, keeping the others as they were sent.
+func redactQuery(path string, secrets []string) string {
+	base, query, ok := strings.Cut(path, "?")
+	if !ok {
+		return path
+	}
+	pairs := strings.Split(query, "&")
+	for i, pair := range pairs {
+		key, _, _ := strings.Cut(pair, "=")
+		if name, err := url.QueryUnescape(key); err == nil && isSecret(name, secrets) {
+			pairs[i] = key + "=" + redacted
+		}
+	}
+	return base + "?" + strings.Join(pairs, "&")
+}
+
+func isSecret(name string, secrets []string) bool {
+	for _, secret := range secrets {
+		if name == secret {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package middleware
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLogger(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	var out bytes.Buffer
+	router := gin.New()
+	router.Use(Logger(&out, "token"))
+	router.GET("/calendar.ics", func(ctx *gin.Context) {
+		assert.Equal(t, "s3cr3t", ctx.Query("token"))
+		ctx.Status(http.StatusOK)
+	})
+
+	req, _ := http.NewRequest("GET", "/calendar.ics?tag=home&token=s3cr3t&component=todo", nil)
+	router.ServeHTTP(httptest.NewRecorder(), req)
+
+	assert.Contains(t, out.String(), `"/calendar.ics?tag=home&token=REDACTED&component=todo"`)
+	assert.NotContains(t, out.String(), "s3cr3t")
+}
+
+func TestRedactQuery(t *testing.T) {
+	secrets := []string{"token"}
+	for path, expected := range map[string]string{
+		"/notes":                       "/notes",
+		"/notes?sort=title":            "/notes?sort=title",
+		"/calendar.ics?token=abc":      "/calendar.ics?token=REDACTED",
+		"/calendar.ics?token":          "/calendar.ics?token=REDACTED",
+		"/calendar.ics?to%6Ben=abc&a=": "/calendar.ics?to%6Ben=REDACTED&a=",
+		"/calendar.ics?token=a&token=": "/calendar.ics?token=REDACTED&token=REDACTED",
+	} {
+		assert.Equal(t, expected, redactQuery(path, secrets), path)
+	}
+}
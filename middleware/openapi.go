@@ -0,0 +1,168 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sarita-growexx/note_with_alarm/openapi"
+	"github.com/sarita-growexx/note_with_alarm/problem"
+)
+
+// OpenAPI validates requests against the operation doc describes for their
+// route before any handler runs. Invalid path or query parameters and
+// bodies that break their schema are answered with a 400 problem listing
+// each field, and bodies of a media type the operation does not accept with
+// 415. Routes missing from doc are not checked.
+//
+// Request bodies are capped as limits says, and answered with 413 beyond
+// that. Only JSON bodies are read before the handler runs, to check them
+// against their schema; bodies of other media types, such as imports, are
+// left for the handler to stream.
+//
+// With validateResponses, which is meant for tests, responses are buffered
+// and checked as well; one that does not match doc is replaced by a 500
+// problem describing the mismatch.
+func OpenAPI(doc *openapi.Document, validateResponses bool, limits BodyLimits) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if ctx.Request.Body != nil {
+			ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, limits.For(ctx.FullPath()))
+		}
+
+		op := doc.Operation(ctx.Request.Method, ctx.FullPath())
+		if op == nil {
+			ctx.Next()
+			return
+		}
+
+		if !validateRequest(ctx, op) {
+			ctx.Abort()
+			return
+		}
+
+		if !validateResponses {
+			ctx.Next()
+			return
+		}
+
+		writer := &bufferedWriter{ResponseWriter: ctx.Writer, status: http.StatusOK}
+		ctx.Writer = writer
+		ctx.Next()
+		ctx.Writer = writer.ResponseWriter
+
+		if err := op.ValidateResponse(writer.status, writer.Header().Get("Content-Type"), writer.body.Bytes()); err != nil {
+			log.Printf("%s %s: response does not match the OpenAPI document: %v", ctx.Request.Method, ctx.Request.URL.Path, err)
+			ctx.Writer.Header().Del("ETag")
+			problem.Write(ctx, problem.New(http.StatusInternalServerError, "Response does not match the OpenAPI document: "+err.Error()))
+			return
+		}
+		writer.flush()
+	}
+}
+
+// BodyLimits are the most bytes a request body may hold: Default, or the
+// limit of its route in Routes, by full path.
+type BodyLimits struct {
+	Default int64
+	Routes  map[string]int64
+}
+
+// For returns the limit on the bodies of route.
+func (l BodyLimits) For(route string) int64 {
+	if limit, ok := l.Routes[route]; ok {
+		return limit
+	}
+	return l.Default
+}
+
+// validateRequest writes the error response and returns false when the
+// request does not match op.
+func validateRequest(ctx *gin.Context, op *openapi.Operation) bool {
+	violations := op.ValidateParameters(ctx.Param, ctx.Request.URL.Query())
+
+	if op.RequestBody != nil && ctx.Request.Body != nil {
+		var body []byte
+		if mediaType, _, _ := mime.ParseMediaType(ctx.GetHeader("Content-Type")); openapi.IsJSON(mediaType) {
+			var err error
+			if body, err = io.ReadAll(ctx.Request.Body); err != nil {
+				if p := problem.TooLarge(err); p != nil {
+					problem.Write(ctx, p)
+					return false
+				}
+				problem.Write(ctx, problem.New(http.StatusBadRequest, err.Error()))
+				return false
+			}
+			ctx.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		bodyViolations, err := op.ValidateBody(ctx.GetHeader("Content-Type"), body)
+		switch {
+		case errors.Is(err, openapi.ErrUnsupportedMediaType):
+			problem.Write(ctx, problem.New(http.StatusUnsupportedMediaType, "Content-Type must be "+strings.Join(op.AcceptedMediaTypes(), " or ")))
+			return false
+		case err != nil:
+			problem.Write(ctx, problem.New(http.StatusBadRequest, "Request body is not valid JSON: "+err.Error()))
+			return false
+		}
+		violations = append(violations, bodyViolations...)
+	}
+
+	if len(violations) > 0 {
+		problem.Write(ctx, problem.Invalid(violations))
+		return false
+	}
+	return true
+}
+
+// bufferedWriter holds back a response until it has been validated.
+type bufferedWriter struct {
+	gin.ResponseWriter
+	status  int
+	written bool
+	body    bytes.Buffer
+}
+
+func (w *bufferedWriter) WriteHeader(status int) {
+	w.status = status
+}
+
+func (w *bufferedWriter) WriteHeaderNow() {
+	w.written = true
+}
+
+func (w *bufferedWriter) Write(data []byte) (int, error) {
+	w.written = true
+	return w.body.Write(data)
+}
+
+func (w *bufferedWriter) WriteString(s string) (int, error) {
+	w.written = true
+	return w.body.WriteString(s)
+}
+
+func (w *bufferedWriter) Status() int {
+	return w.status
+}
+
+func (w *bufferedWriter) Size() int {
+	if !w.written {
+		return -1
+	}
+	return w.body.Len()
+}
+
+func (w *bufferedWriter) Written() bool {
+	return w.written
+}
+
+// flush sends the buffered response.
+func (w *bufferedWriter) flush() {
+	w.ResponseWriter.WriteHeader(w.status)
+	w.ResponseWriter.WriteHeaderNow()
+	w.ResponseWriter.Write(w.body.Bytes())
+}
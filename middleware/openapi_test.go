@@ -0,0 +1,134 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sarita-growexx/note_with_alarm/openapi"
+	"github.com/sarita-growexx/note_with_alarm/problem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testDocument = `{
+	"openapi": "3.1.0",
+	"paths": {
+		"/items/{id}": {
+			"put": {
+				"parameters": [
+					{"name": "id", "in": "path", "required": true, "schema": {"type": "integer", "minimum": 1}},
+					{"name": "dry_run", "in": "query", "schema": {"type": "boolean"}}
+				],
+				"requestBody": {"required": true, "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Item"}}}},
+				"responses": {"200": {"description": "ok", "content": {"application/json": {"schema": {"$ref": "#/components/schemas/Item"}}}}}
+			}
+		},
+		"/uploads": {
+			"post": {
+				"requestBody": {"required": true, "content": {"text/csv": {"schema": {"type": "string"}}}},
+				"responses": {"200": {"description": "ok"}, "413": {"description": "too large"}}
+			}
+		}
+	},
+	"components": {
+		"schemas": {
+			"Item": {"type": "object", "required": ["name"], "properties": {"name": {"type": "string", "maxLength": 5}}}
+		}
+	}
+}`
+
+func TestOpenAPI(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	doc, err := openapi.Parse([]byte(testDocument))
+	require.NoError(t, err)
+
+	handlerRan := false
+	router := gin.New()
+	router.Use(OpenAPI(doc, true, BodyLimits{Default: 32, Routes: map[string]int64{"/uploads": 8}}))
+	router.PUT("/items/:id", func(ctx *gin.Context) {
+		handlerRan = true
+		if ctx.Query("dry_run") == "true" {
+			ctx.JSON(http.StatusOK, gin.H{"name": 42})
+			return
+		}
+		ctx.JSON(http.StatusOK, gin.H{"name": "ok"})
+	})
+	router.POST("/uploads", func(ctx *gin.Context) {
+		body, err := io.ReadAll(ctx.Request.Body)
+		if p := problem.TooLarge(err); p != nil {
+			problem.Write(ctx, p)
+			return
+		}
+		ctx.String(http.StatusOK, "%s", body)
+	})
+	router.GET("/undocumented", func(ctx *gin.Context) {
+		ctx.String(http.StatusTeapot, "short and stout")
+	})
+
+	tests := []struct {
+		name        string
+		path        string
+		contentType string
+		body        string
+		status      int
+		response    string
+		handlerRan  bool
+	}{
+		{"valid", "/items/1", "application/json", `{"name":"pen"}`, http.StatusOK, `{"name":"ok"}`, true},
+		{"invalid path parameter", "/items/0", "application/json", `{"name":"pen"}`, http.StatusBadRequest, `{"field":"id","rule":"min","param":"1","message":"must be at least 1"}`, false},
+		{"invalid query parameter", "/items/1?dry_run=maybe", "application/json", `{"name":"pen"}`, http.StatusBadRequest, `"field":"dry_run","rule":"type"`, false},
+		{"invalid body", "/items/1", "application/json", `{"name":"pencil"}`, http.StatusBadRequest, `{"field":"name","rule":"max","param":"5","message":"must be at most 5 characters"}`, false},
+		{"missing field", "/items/1", "application/json", `{}`, http.StatusBadRequest, `{"field":"name","rule":"required","message":"is required"}`, false},
+		{"malformed body", "/items/1", "application/json", `{"name":`, http.StatusBadRequest, `"detail":"Request body is not valid JSON`, false},
+		{"unsupported media type", "/items/1", "text/plain", `pen`, http.StatusUnsupportedMediaType, `"detail":"Content-Type must be application/json"`, false},
+		{"body too large", "/items/1", "application/json", `{"name":"` + strings.Repeat(" ", 32) + `"}`, http.StatusRequestEntityTooLarge, `"detail":"Request body is larger than 32 bytes"`, false},
+		{"response drift", "/items/1?dry_run=true", "application/json", `{"name":"pen"}`, http.StatusInternalServerError, `"detail":"Response does not match the OpenAPI document: status 200 body does not match the schema: name breaks type string"`, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			handlerRan = false
+			req, _ := http.NewRequest("PUT", tc.path, strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", tc.contentType)
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.status, w.Code)
+			assert.Contains(t, w.Body.String(), tc.response)
+			assert.Equal(t, tc.handlerRan, handlerRan)
+		})
+	}
+
+	// Bodies that are not JSON reach the handler unread, still capped by
+	// the limit of their route.
+	for _, tc := range []struct {
+		name     string
+		body     string
+		status   int
+		response string
+	}{
+		{"upload", "a,b\n1,2", http.StatusOK, "a,b\n1,2"},
+		{"upload too large", "a,b\n1,2\n3,4", http.StatusRequestEntityTooLarge, `"detail":"Request body is larger than 8 bytes"`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest("POST", "/uploads", strings.NewReader(tc.body))
+			req.Header.Set("Content-Type", "text/csv")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tc.status, w.Code)
+			assert.Contains(t, w.Body.String(), tc.response)
+		})
+	}
+
+	t.Run("undocumented route", func(t *testing.T) {
+		req, _ := http.NewRequest("GET", "/undocumented", nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusTeapot, w.Code)
+	})
+}
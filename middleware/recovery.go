@@ -0,0 +1,32 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Recovery answers 500 for requests whose handler panicked, as gin.Recovery
+// does. A panic with http.ErrAbortHandler is passed on to the server, which
+// breaks the connection: that is how a handler streaming a response tells
+// the client that the response was cut short, rather than let it end as if
+// it were complete.
+func Recovery() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			if err, ok := recovered.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(recovered)
+			}
+			log.Printf("%s %s: panic recovered: %v\n%s", ctx.Request.Method, ctx.Request.URL.Path, recovered, debug.Stack())
+			ctx.AbortWithStatus(http.StatusInternalServerError)
+		}()
+		ctx.Next()
+	}
+}
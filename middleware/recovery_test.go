@@ -0,0 +1,35 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecovery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(Recovery())
+	router.GET("/panic", func(ctx *gin.Context) {
+		panic("boom")
+	})
+	router.GET("/abort", func(ctx *gin.Context) {
+		ctx.String(http.StatusOK, "partial")
+		panic(http.ErrAbortHandler)
+	})
+
+	req, _ := http.NewRequest("GET", "/panic", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+
+	// Aborted streams reach the server, which breaks the connection
+	req, _ = http.NewRequest("GET", "/abort", nil)
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() {
+		router.ServeHTTP(httptest.NewRecorder(), req)
+	})
+}
@@ -0,0 +1,42 @@
+// Package middleware holds the gin middleware shared by every route.
+package middleware
+
+import (
+	"context"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sarita-growexx/note_with_alarm/repository"
+)
+
+// RequestTimeout bounds the request context by timeout, so database queries
+// started by a handler are cancelled once it elapses or the client goes away.
+// A zero timeout leaves the request context unchanged.
+//
+// Requests to the unbounded routes, given as full route paths, stream or
+// write many notes and are not bounded as a whole; each of their queries is
+// bounded by timeout instead, through repository.QueryContext.
+func RequestTimeout(timeout time.Duration, unbounded ...string) gin.HandlerFunc {
+	exempt := make(map[string]bool, len(unbounded))
+	for _, path := range unbounded {
+		exempt[path] = true
+	}
+
+	return func(ctx *gin.Context) {
+		if timeout <= 0 {
+			ctx.Next()
+			return
+		}
+		if exempt[ctx.FullPath()] {
+			ctx.Request = ctx.Request.WithContext(repository.WithQueryTimeout(ctx.Request.Context(), timeout))
+			ctx.Next()
+			return
+		}
+
+		timeoutCtx, cancel := context.WithTimeout(ctx.Request.Context(), timeout)
+		defer cancel()
+
+		ctx.Request = ctx.Request.WithContext(timeoutCtx)
+		ctx.Next()
+	}
+}
@@ -0,0 +1,72 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sarita-growexx/note_with_alarm/repository"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(RequestTimeout(50 * time.Millisecond))
+	router.GET("/slow", func(ctx *gin.Context) {
+		deadline, ok := ctx.Request.Context().Deadline()
+		assert.True(t, ok)
+		assert.WithinDuration(t, time.Now().Add(50*time.Millisecond), deadline, 50*time.Millisecond)
+
+		<-ctx.Request.Context().Done()
+		ctx.Status(http.StatusGatewayTimeout)
+	})
+
+	req, _ := http.NewRequest("GET", "/slow", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+}
+
+func TestRequestTimeout_Disabled(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(RequestTimeout(0))
+	router.GET("/", func(ctx *gin.Context) {
+		_, ok := ctx.Request.Context().Deadline()
+		assert.False(t, ok)
+		ctx.Status(http.StatusOK)
+	})
+
+	req, _ := http.NewRequest("GET", "/", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRequestTimeout_Unbounded(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(RequestTimeout(50*time.Millisecond, "/export/:format"))
+	router.GET("/export/:format", func(ctx *gin.Context) {
+		_, ok := ctx.Request.Context().Deadline()
+		assert.False(t, ok, "the request as a whole is not bounded")
+
+		queryCtx, cancel := repository.QueryContext(ctx.Request.Context())
+		defer cancel()
+		deadline, ok := queryCtx.Deadline()
+		assert.True(t, ok, "each query is")
+		assert.WithinDuration(t, time.Now().Add(50*time.Millisecond), deadline, 50*time.Millisecond)
+		ctx.Status(http.StatusOK)
+	})
+
+	req, _ := http.NewRequest("GET", "/export/csv", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+}
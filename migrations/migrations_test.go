@@ -0,0 +1,136 @@
+package migrations
+
+import (
+	"io/fs"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/glebarez/sqlite"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestLoad_Embedded(t *testing.T) {
+	byDialect := make(map[string][]Migration)
+	for _, dialect := range []string{"postgres", "sqlite"} {
+		sqlFiles, err := fs.Sub(files, "sql/"+dialect)
+		assert.NoError(t, err)
+
+		migrations, err := Load(sqlFiles)
+		assert.NoError(t, err)
+		assert.NotEmpty(t, migrations)
+
+		for i, migration := range migrations {
+			assert.Equal(t, uint(i+1), migration.Version, "%s migration versions must be contiguous", dialect)
+			assert.NotEmpty(t, migration.Up)
+			assert.NotEmpty(t, migration.Down)
+		}
+		byDialect[dialect] = migrations
+	}
+
+	// Both dialects must describe the same schema history.
+	assert.Equal(t, len(byDialect["postgres"]), len(byDialect["sqlite"]))
+	for i := range byDialect["sqlite"] {
+		assert.Equal(t, byDialect["postgres"][i].Name, byDialect["sqlite"][i].Name)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load(fstest.MapFS{
+		"0002_add_tags.up.sql":      {Data: []byte("ALTER TABLE note ADD COLUMN tags TEXT")},
+		"0002_add_tags.down.sql":    {Data: []byte("ALTER TABLE note DROP COLUMN tags")},
+		"0001_create_note.up.sql":   {Data: []byte("CREATE TABLE note (id BIGSERIAL)")},
+		"0001_create_note.down.sql": {Data: []byte("DROP TABLE note")},
+		"README.md":                 {Data: []byte("ignored")},
+	})
+	assert.NoError(t, err)
+	assert.Len(t, migrations, 2)
+	assert.Equal(t, "create_note", migrations[0].Name)
+	assert.Equal(t, uint(2), migrations[1].Version)
+	assert.Equal(t, "ALTER TABLE note DROP COLUMN tags", migrations[1].Down)
+}
+
+func TestLoad_Invalid(t *testing.T) {
+	tests := map[string]fstest.MapFS{
+		"missing down": {
+			"0001_create_note.up.sql": {Data: []byte("CREATE TABLE note (id BIGSERIAL)")},
+		},
+		"bad name": {
+			"create_note.up.sql": {Data: []byte("CREATE TABLE note (id BIGSERIAL)")},
+		},
+		"conflicting names": {
+			"0001_create_note.up.sql":    {Data: []byte("CREATE TABLE note (id BIGSERIAL)")},
+			"0001_create_notes.down.sql": {Data: []byte("DROP TABLE note")},
+		},
+		"zero version": {
+			"0000_create_note.up.sql":   {Data: []byte("CREATE TABLE note (id BIGSERIAL)")},
+			"0000_create_note.down.sql": {Data: []byte("DROP TABLE note")},
+		},
+	}
+
+	for name, fsys := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := Load(fsys)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestMigrator_SQLite(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "migrate.db")), &gorm.Config{})
+	require.NoError(t, err)
+
+	migrator, err := NewMigrator(db)
+	require.NoError(t, err)
+
+	applied, err := migrator.Up()
+	require.NoError(t, err)
+	assert.Len(t, applied, len(migrator.migrations))
+	assert.True(t, db.Migrator().HasTable("note"))
+
+	applied, err = migrator.Up()
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+
+	reverted, err := migrator.Down(1)
+	require.NoError(t, err)
+	require.Len(t, reverted, 1)
+	assert.Equal(t, migrator.migrations[len(migrator.migrations)-1].Version, reverted[0].Version)
+
+	statuses, err := migrator.Status()
+	require.NoError(t, err)
+	assert.Nil(t, statuses[len(statuses)-1].AppliedAt)
+	assert.NotNil(t, statuses[0].AppliedAt)
+
+	// Every down migration must undo its up migration.
+	_, err = migrator.Down(len(migrator.migrations))
+	require.NoError(t, err)
+	assert.False(t, db.Migrator().HasTable("note"))
+
+	_, err = migrator.Up()
+	require.NoError(t, err)
+}
+
+func TestMigrator_SQLite_UniqueNoteTitle(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "migrate.db")), &gorm.Config{})
+	require.NoError(t, err)
+	migrator, err := NewMigrator(db)
+	require.NoError(t, err)
+	_, err = migrator.Up()
+	require.NoError(t, err)
+	_, err = migrator.Down(len(migrator.migrations) - 8)
+	require.NoError(t, err)
+
+	// The renamed duplicates would collide with the notes that follow them
+	for id, title := range []string{"Pay rent", "Pay rent", "Pay rent (2)", "Pay rent (2-2)", "Pay rent"} {
+		require.NoError(t, db.Exec("INSERT INTO note (id, title) VALUES (?, ?)", id+1, title).Error)
+	}
+	_, err = migrator.Up()
+	require.NoError(t, err)
+
+	var titles []string
+	require.NoError(t, db.Raw("SELECT title FROM note ORDER BY id").Scan(&titles).Error)
+	assert.Equal(t, []string{"Pay rent", "Pay rent (2-3)", "Pay rent (2)", "Pay rent (2-2)", "Pay rent (5)"}, titles)
+}
@@ -0,0 +1,15 @@
+-- Baseline for databases created by gorm AutoMigrate: every statement is
+-- idempotent so existing deployments converge on the same schema.
+CREATE TABLE IF NOT EXISTS note (
+    id          BIGSERIAL PRIMARY KEY,
+    title       TEXT NOT NULL,
+    description TEXT,
+    deadline    TIMESTAMPTZ,
+    created_at  TIMESTAMPTZ,
+    updated_at  TIMESTAMPTZ
+);
+
+ALTER TABLE note ADD COLUMN IF NOT EXISTS description TEXT;
+ALTER TABLE note ADD COLUMN IF NOT EXISTS deadline TIMESTAMPTZ;
+ALTER TABLE note ADD COLUMN IF NOT EXISTS created_at TIMESTAMPTZ;
+ALTER TABLE note ADD COLUMN IF NOT EXISTS updated_at TIMESTAMPTZ;
@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS note_revisions (
+    id             BIGSERIAL PRIMARY KEY,
+    note_id        BIGINT NOT NULL,
+    revision       BIGINT NOT NULL,
+    title          TEXT NOT NULL,
+    description    TEXT,
+    deadline       TIMESTAMPTZ,
+    changed_fields TEXT,
+    changed_by     TEXT,
+    reason         TEXT,
+    created_at     TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_note_revision ON note_revisions (note_id, revision);
@@ -0,0 +1 @@
+ALTER TABLE note ADD COLUMN IF NOT EXISTS version BIGINT NOT NULL DEFAULT 1;
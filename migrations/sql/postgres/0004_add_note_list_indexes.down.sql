@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_note_updated_at;
+DROP INDEX IF EXISTS idx_note_created_at;
+DROP INDEX IF EXISTS idx_note_deadline;
+DROP INDEX IF EXISTS idx_note_title;
@@ -0,0 +1,4 @@
+CREATE INDEX IF NOT EXISTS idx_note_title ON note (title);
+CREATE INDEX IF NOT EXISTS idx_note_deadline ON note (deadline);
+CREATE INDEX IF NOT EXISTS idx_note_created_at ON note (created_at);
+CREATE INDEX IF NOT EXISTS idx_note_updated_at ON note (updated_at);
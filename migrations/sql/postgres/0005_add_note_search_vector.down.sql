@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_note_search_vector;
+ALTER TABLE note DROP COLUMN IF EXISTS search_vector;
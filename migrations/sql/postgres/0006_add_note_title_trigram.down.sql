@@ -0,0 +1,2 @@
+-- The pg_trgm extension is left installed; other schemas may depend on it.
+DROP INDEX IF EXISTS idx_note_title_trgm;
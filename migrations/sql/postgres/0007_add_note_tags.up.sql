@@ -0,0 +1,2 @@
+ALTER TABLE note ADD COLUMN IF NOT EXISTS tags TEXT NOT NULL DEFAULT '[]';
+ALTER TABLE note_revisions ADD COLUMN IF NOT EXISTS tags TEXT NOT NULL DEFAULT '[]';
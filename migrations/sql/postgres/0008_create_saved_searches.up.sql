@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS saved_searches (
+    id          BIGSERIAL PRIMARY KEY,
+    name        TEXT NOT NULL,
+    query       TEXT NOT NULL,
+    sort        TEXT,
+    subscribed  BOOLEAN NOT NULL DEFAULT false,
+    channel     TEXT,
+    webhook_url TEXT,
+    created_at  TIMESTAMPTZ,
+    updated_at  TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_saved_searches_name ON saved_searches (name);
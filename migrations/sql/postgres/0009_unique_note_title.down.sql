@@ -0,0 +1,3 @@
+-- Titles renamed by the up migration are not restored.
+DROP INDEX IF EXISTS idx_note_title;
+CREATE INDEX idx_note_title ON note (title);
@@ -0,0 +1,29 @@
+-- Titles were only checked for uniqueness in application code, so concurrent
+-- creates could store duplicates. Keep the oldest note's title and suffix the
+-- others with their id before making the index unique. A suffixed title that
+-- is taken already gets a counter too, "title (id-2)", "title (id-3)" and so
+-- on, until it is free. Suffixed titles cannot collide with one another, as
+-- the id in them tells them apart.
+WITH RECURSIVE duplicates AS (
+    SELECT id, title
+    FROM (
+        SELECT id, title, ROW_NUMBER() OVER (PARTITION BY title ORDER BY id) AS position
+        FROM note
+    ) AS ranked
+    WHERE ranked.position > 1
+), candidates (id, title, n, candidate) AS (
+    SELECT id, title, 1, title || ' (' || id || ')'
+    FROM duplicates
+    UNION ALL
+    SELECT id, title, n + 1, title || ' (' || id || '-' || (n + 1) || ')'
+    FROM candidates
+    WHERE candidate IN (SELECT title FROM note)
+)
+UPDATE note
+SET title = candidates.candidate
+FROM candidates
+WHERE candidates.id = note.id
+  AND candidates.candidate NOT IN (SELECT title FROM note);
+
+DROP INDEX IF EXISTS idx_note_title;
+CREATE UNIQUE INDEX idx_note_title ON note (title);
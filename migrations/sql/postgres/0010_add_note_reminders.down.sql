@@ -0,0 +1,2 @@
+ALTER TABLE note_revisions DROP COLUMN IF EXISTS reminders;
+ALTER TABLE note DROP COLUMN IF EXISTS reminders;
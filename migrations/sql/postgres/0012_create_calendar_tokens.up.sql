@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS calendar_tokens (
+    user_name  TEXT PRIMARY KEY,
+    token_hash TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_calendar_tokens_token_hash ON calendar_tokens (token_hash);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS note (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    title       TEXT NOT NULL,
+    description TEXT,
+    deadline    DATETIME,
+    created_at  DATETIME,
+    updated_at  DATETIME
+);
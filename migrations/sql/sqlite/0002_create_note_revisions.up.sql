@@ -0,0 +1,14 @@
+CREATE TABLE IF NOT EXISTS note_revisions (
+    id             INTEGER PRIMARY KEY AUTOINCREMENT,
+    note_id        INTEGER NOT NULL,
+    revision       INTEGER NOT NULL,
+    title          TEXT NOT NULL,
+    description    TEXT,
+    deadline       DATETIME,
+    changed_fields TEXT,
+    changed_by     TEXT,
+    reason         TEXT,
+    created_at     DATETIME
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_note_revision ON note_revisions (note_id, revision);
@@ -0,0 +1 @@
+ALTER TABLE note DROP COLUMN version;
@@ -0,0 +1,24 @@
+-- SQLite has no tsvector; an FTS5 index over the note text, kept in sync by
+-- triggers, serves full-text search instead.
+CREATE VIRTUAL TABLE IF NOT EXISTS note_fts USING fts5(
+    title,
+    description,
+    content = 'note',
+    content_rowid = 'id',
+    tokenize = 'porter unicode61'
+);
+
+CREATE TRIGGER IF NOT EXISTS note_fts_insert AFTER INSERT ON note BEGIN
+    INSERT INTO note_fts (rowid, title, description) VALUES (new.id, new.title, new.description);
+END;
+
+CREATE TRIGGER IF NOT EXISTS note_fts_delete AFTER DELETE ON note BEGIN
+    INSERT INTO note_fts (note_fts, rowid, title, description) VALUES ('delete', old.id, old.title, old.description);
+END;
+
+CREATE TRIGGER IF NOT EXISTS note_fts_update AFTER UPDATE OF title, description ON note BEGIN
+    INSERT INTO note_fts (note_fts, rowid, title, description) VALUES ('delete', old.id, old.title, old.description);
+    INSERT INTO note_fts (rowid, title, description) VALUES (new.id, new.title, new.description);
+END;
+
+INSERT INTO note_fts (note_fts) VALUES ('rebuild');
@@ -0,0 +1,2 @@
+-- SQLite has no pg_trgm; fuzzy title matching is computed by the repository.
+SELECT 1;
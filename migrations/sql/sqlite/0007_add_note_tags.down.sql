@@ -0,0 +1,2 @@
+ALTER TABLE note_revisions DROP COLUMN tags;
+ALTER TABLE note DROP COLUMN tags;
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS saved_searches (
+    id          INTEGER PRIMARY KEY AUTOINCREMENT,
+    name        TEXT NOT NULL,
+    query       TEXT NOT NULL,
+    sort        TEXT,
+    subscribed  BOOLEAN NOT NULL DEFAULT false,
+    channel     TEXT,
+    webhook_url TEXT,
+    created_at  DATETIME,
+    updated_at  DATETIME
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_saved_searches_name ON saved_searches (name);
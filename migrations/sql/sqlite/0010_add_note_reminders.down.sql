@@ -0,0 +1,2 @@
+ALTER TABLE note_revisions DROP COLUMN reminders;
+ALTER TABLE note DROP COLUMN reminders;
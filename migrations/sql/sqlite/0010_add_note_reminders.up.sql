@@ -0,0 +1,2 @@
+ALTER TABLE note ADD COLUMN reminders TEXT NOT NULL DEFAULT '[]';
+ALTER TABLE note_revisions ADD COLUMN reminders TEXT NOT NULL DEFAULT '[]';
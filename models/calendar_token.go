@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// CalendarToken is the secret a user puts in the URL of their calendar feed.
+// Only a hash of the token is stored, so it cannot be read back; users get
+// a new one instead.
+type CalendarToken struct {
+	UserName  string `gorm:"primaryKey"`
+	TokenHash string `gorm:"not null;uniqueIndex"`
+	CreatedAt time.Time
+}
+
+func (CalendarToken) TableName() string {
+	return "calendar_tokens"
+}
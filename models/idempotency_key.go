@@ -0,0 +1,67 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// IdempotencyKey records a request made with an Idempotency-Key header and,
+// once it succeeds, its response. Retries with the same key are answered
+// with the stored response until the key expires.
+type IdempotencyKey struct {
+	Key string `gorm:"primaryKey"`
+	// RequestHash identifies the request, so that a key cannot be reused
+	// for a different one.
+	RequestHash string `gorm:"not null"`
+	// Status is zero while the request is in progress.
+	Status int `gorm:"not null;default:0"`
+	// Lease identifies the request holding the key while it is in progress.
+	// A retry taking the key over replaces it, so that the request it was
+	// taken from can no longer complete or release the key.
+	Lease     string `gorm:"not null;default:''"`
+	Header    Header `gorm:"type:text;not null;default:'{}'"`
+	Body      []byte
+	CreatedAt time.Time
+	ExpiresAt time.Time `gorm:"index"`
+}
+
+func (IdempotencyKey) TableName() string {
+	return "idempotency_keys"
+}
+
+// Header holds the headers of a stored response, stored as a JSON object.
+type Header map[string][]string
+
+// Value implements driver.Valuer.
+func (h Header) Value() (driver.Value, error) {
+	if h == nil {
+		return "{}", nil
+	}
+	raw, err := json.Marshal(map[string][]string(h))
+	return string(raw), err
+}
+
+// Scan implements sql.Scanner.
+func (h *Header) Scan(value interface{}) error {
+	var raw []byte
+	switch v := value.(type) {
+	case nil:
+		*h = nil
+		return nil
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return fmt.Errorf("cannot scan %T into Header", value)
+	}
+
+	var header map[string][]string
+	if err := json.Unmarshal(raw, &header); err != nil {
+		return err
+	}
+	*h = header
+	return nil
+}
@@ -3,15 +3,49 @@ package models
 import (
 	"time"
 )
+
 type Note struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	Title     string    `gorm:"not null" json:"title"`
-	Description   string    `json:"description"`
-	Deadline    time.Time `json:"deadline"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Title       string    `gorm:"not null;uniqueIndex" json:"title"`
+	Description string    `json:"description"`
+	Deadline    time.Time `gorm:"index" json:"deadline"`
+	Tags        Tags      `gorm:"type:text;not null;default:'[]'" json:"tags"`
+	Reminders   Reminders `gorm:"type:text;not null;default:'[]'" json:"reminders"`
+	Version     uint      `gorm:"not null;default:1" json:"version"`
+	CreatedAt   time.Time `gorm:"index" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"index" json:"updated_at"`
 }
 
+// AnyVersion stands for whichever version a note is at, for changes made
+// with If-Match: *, which only require the note to exist.
+const AnyVersion uint = 0
+
 func (Note) TableName() string {
-    return "note"
+	return "note"
+}
+
+// EffectiveReminders returns the reminders of note, or the defaults when it
+// has none.
+func (n *Note) EffectiveReminders() Reminders {
+	if len(n.Reminders) == 0 {
+		return DefaultReminders
+	}
+	return n.Reminders
+}
+
+// NextAlertAt returns the earliest time after now at which note is due an
+// alert: one of its reminders, which the alarm loop raises in turn, or the
+// deadline itself. It returns false once the deadline has passed.
+func (n *Note) NextAlertAt(now time.Time) (time.Time, bool) {
+	if !n.Deadline.After(now) {
+		return time.Time{}, false
+	}
+
+	next := n.Deadline
+	for _, offset := range n.EffectiveReminders().Offsets() {
+		if at := n.Deadline.Add(-offset); at.After(now) && at.Before(next) {
+			next = at
+		}
+	}
+	return next, true
 }
@@ -0,0 +1,25 @@
+package models
+
+// BulkAction is what an operation of a bulk request does to a note.
+type BulkAction string
+
+const (
+	BulkCreate BulkAction = "create"
+	BulkUpdate BulkAction = "update"
+	BulkDelete BulkAction = "delete"
+)
+
+// BulkOperation is one operation of a bulk request. Note is the note to
+// create, or the new state of the note to update. Updates and deletes apply
+// to the note with Note.ID, only while it is still at Note.Version.
+type BulkOperation struct {
+	Action BulkAction
+	Note   *Note
+}
+
+// BulkResult is the outcome of a bulk operation: the note it created or
+// updated, or the error that stopped it.
+type BulkResult struct {
+	Note *Note
+	Err  error
+}
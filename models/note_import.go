@@ -0,0 +1,33 @@
+package models
+
+// ConflictPolicy is what an import does with a note whose title is taken.
+type ConflictPolicy string
+
+const (
+	// ConflictSkip leaves the existing note and skips the imported one.
+	ConflictSkip ConflictPolicy = "skip"
+	// ConflictOverwrite replaces the existing note with the imported one.
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	// ConflictRename imports the note under a free title, such as
+	// "Pay invoice (2)".
+	ConflictRename ConflictPolicy = "rename"
+)
+
+// ImportAction is what an import does, or in a dry run would do, with a
+// note.
+type ImportAction string
+
+const (
+	ImportCreate ImportAction = "create"
+	ImportUpdate ImportAction = "update"
+	ImportSkip   ImportAction = "skip"
+)
+
+// ImportResult is the outcome of importing a note. RenamedFrom is the title
+// the note was imported with when it had to be renamed.
+type ImportResult struct {
+	Action      ImportAction
+	Note        *Note
+	RenamedFrom string
+	Err         error
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+)
+
+// NoteListQuery describes a page of notes to list. Cursor is the opaque
+// next_cursor value returned with the previous page.
+type NoteListQuery struct {
+	Limit          int
+	Cursor         string
+	Sort           string
+	Order          string
+	DeadlineBefore *time.Time
+	DeadlineAfter  *time.Time
+	Overdue        *bool
+	// Tag keeps only the notes with this tag, when set.
+	Tag string
+	// Now is the time Overdue is judged at; the zero time means the time of
+	// the call.
+	Now time.Time
+	// SkipTotal leaves the Total of the page zero, sparing a count of every
+	// matching note.
+	SkipTotal bool
+}
+
+// NotePage is a single page of a note listing.
+type NotePage struct {
+	Notes      []*Note `json:"notes"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+	Total      int64   `json:"total"`
+}
+
+// NoteSearchResult is a note matching a search together with its relevance.
+// Keyword searches report a rank and highlighted snippets of the matching
+// text, fuzzy searches report the title similarity.
+type NoteSearchResult struct {
+	Note
+	Rank                 float64 `json:"rank,omitempty"`
+	TitleHighlight       string  `json:"title_highlight,omitempty"`
+	DescriptionHighlight string  `json:"description_highlight,omitempty"`
+	Similarity           float64 `json:"similarity,omitempty"`
+}
@@ -0,0 +1,38 @@
+package models
+
+import (
+	"time"
+)
+
+// NoteRevision is an immutable snapshot of a note taken every time it changes.
+type NoteRevision struct {
+	ID            uint      `gorm:"primaryKey" json:"id"`
+	NoteID        uint      `gorm:"not null;uniqueIndex:idx_note_revision" json:"note_id"`
+	Revision      uint      `gorm:"not null;uniqueIndex:idx_note_revision" json:"revision"`
+	Title         string    `gorm:"not null" json:"title"`
+	Description   string    `json:"description"`
+	Deadline      time.Time `json:"deadline"`
+	Tags          Tags      `gorm:"type:text;not null;default:'[]'" json:"tags"`
+	Reminders     Reminders `gorm:"type:text;not null;default:'[]'" json:"reminders"`
+	ChangedFields []string  `gorm:"serializer:json" json:"changed_fields"`
+	ChangedBy     string    `json:"changed_by"`
+	Reason        string    `json:"reason"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+func (NoteRevision) TableName() string {
+	return "note_revisions"
+}
+
+// ChangeInfo describes who made a change to a note and why.
+type ChangeInfo struct {
+	Author string
+	Reason string
+}
+
+// FieldChange is a single field difference between two note revisions.
+type FieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Reminders lists when to be alerted about a note, in minutes before its
+// deadline, stored as a JSON array.
+type Reminders []int
+
+// DefaultReminders are used for notes that set no reminders of their own:
+// a day, 6 hours, an hour and 30 minutes before the deadline.
+var DefaultReminders = Reminders{24 * 60, 6 * 60, 60, 30}
+
+// Offsets returns the reminders as durations before the deadline.
+func (r Reminders) Offsets() []time.Duration {
+	offsets := make([]time.Duration, len(r))
+	for i, minutes := range r {
+		offsets[i] = time.Duration(minutes) * time.Minute
+	}
+	return offsets
+}
+
+// Value implements driver.Valuer.
+func (r Reminders) Value() (driver.Value, error) {
+	if r == nil {
+		return "[]", nil
+	}
+	raw, err := json.Marshal([]int(r))
+	return string(raw), err
+}
+
+// Scan implements sql.Scanner.
+func (r *Reminders) Scan(value interface{}) error {
+	var raw []byte
+	switch v := value.(type) {
+	case nil:
+		*r = nil
+		return nil
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return fmt.Errorf("cannot scan %T into Reminders", value)
+	}
+
+	var reminders []int
+	if err := json.Unmarshal(raw, &reminders); err != nil {
+		return err
+	}
+	*r = reminders
+	return nil
+}
@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+)
+
+// SavedSearch is a named search query that can be re-run as a smart list.
+// Subscribed searches route the alarms of their matching notes to Channel.
+type SavedSearch struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	Name       string    `gorm:"not null;uniqueIndex" json:"name"`
+	Query      string    `gorm:"not null" json:"query"`
+	Sort       string    `json:"sort"`
+	Subscribed bool      `gorm:"not null;default:false" json:"subscribed"`
+	Channel    string    `json:"channel"`
+	WebhookURL string    `json:"webhook_url"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+func (SavedSearch) TableName() string {
+	return "saved_searches"
+}
@@ -0,0 +1,51 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"regexp"
+)
+
+var tagNamePattern = regexp.MustCompile(`^[\p{L}\p{N}_-]{1,32}$`)
+
+// Tags is a list of tag names, stored as a JSON array.
+type Tags []string
+
+// ValidTagName reports whether name is a valid tag: 1 to 32 letters, digits,
+// underscores or hyphens.
+func ValidTagName(name string) bool {
+	return tagNamePattern.MatchString(name)
+}
+
+// Value implements driver.Valuer.
+func (t Tags) Value() (driver.Value, error) {
+	if t == nil {
+		return "[]", nil
+	}
+	raw, err := json.Marshal([]string(t))
+	return string(raw), err
+}
+
+// Scan implements sql.Scanner.
+func (t *Tags) Scan(value interface{}) error {
+	var raw []byte
+	switch v := value.(type) {
+	case nil:
+		*t = nil
+		return nil
+	case string:
+		raw = []byte(v)
+	case []byte:
+		raw = v
+	default:
+		return fmt.Errorf("cannot scan %T into Tags", value)
+	}
+
+	var tags []string
+	if err := json.Unmarshal(raw, &tags); err != nil {
+		return err
+	}
+	*t = tags
+	return nil
+}
@@ -0,0 +1,196 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Document is the part of an OpenAPI document used to validate requests and
+// responses: operations, their parameters, bodies and schemas.
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components struct {
+		Schemas    map[string]*Schema    `json:"schemas"`
+		Parameters map[string]*Parameter `json:"parameters"`
+		Responses  map[string]*Response  `json:"responses"`
+	} `json:"components"`
+}
+
+// Operation is a single method of a path.
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Parameters  []*Parameter         `json:"parameters"`
+	RequestBody *RequestBody         `json:"requestBody"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+// Parameter is a path, query or header parameter of an operation.
+type Parameter struct {
+	Ref      string  `json:"$ref"`
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+// RequestBody lists the media types an operation accepts.
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response lists the media types of a response.
+type Response struct {
+	Ref     string               `json:"$ref"`
+	Content map[string]MediaType `json:"content"`
+}
+
+// MediaType is the schema of a body of a given media type.
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// Load parses the OpenAPI document served by SpecHandler and resolves its
+// references.
+func Load() (*Document, error) {
+	return Parse(spec)
+}
+
+// Parse parses an OpenAPI document and resolves its references to
+// components.
+func Parse(data []byte) (*Document, error) {
+	var doc Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+	if err := doc.resolve(); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI document: %w", err)
+	}
+	return &doc, nil
+}
+
+// Operation returns the operation for a request method and gin route
+// pattern, such as GET /api/notes/:id, or nil when it is not described.
+func (d *Document) Operation(method, route string) *Operation {
+	return d.Paths[PathOf(route)][strings.ToLower(method)]
+}
+
+var routeParam = regexp.MustCompile(`:(\w+)`)
+
+// PathOf converts a gin route pattern to an OpenAPI path, so that
+// /api/notes/:id becomes /api/notes/{id}.
+func PathOf(route string) string {
+	return routeParam.ReplaceAllString(route, "{$1}")
+}
+
+// resolve replaces every $ref with the component it points to.
+func (d *Document) resolve() error {
+	for name, schema := range d.Components.Schemas {
+		if err := d.resolveSchema(schema, map[*Schema]bool{}); err != nil {
+			return fmt.Errorf("schema %s: %w", name, err)
+		}
+	}
+	for _, parameter := range d.Components.Parameters {
+		if err := d.resolveSchema(parameter.Schema, map[*Schema]bool{}); err != nil {
+			return fmt.Errorf("parameter %s: %w", parameter.Name, err)
+		}
+	}
+	for name, response := range d.Components.Responses {
+		if err := d.resolveContent(response.Content); err != nil {
+			return fmt.Errorf("response %s: %w", name, err)
+		}
+	}
+
+	for path, operations := range d.Paths {
+		for method, op := range operations {
+			if err := d.resolveOperation(op); err != nil {
+				return fmt.Errorf("%s %s: %w", strings.ToUpper(method), path, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (d *Document) resolveOperation(op *Operation) error {
+	for i, parameter := range op.Parameters {
+		if parameter.Ref != "" {
+			resolved, ok := d.Components.Parameters[strings.TrimPrefix(parameter.Ref, "#/components/parameters/")]
+			if !ok {
+				return fmt.Errorf("unknown parameter %s", parameter.Ref)
+			}
+			op.Parameters[i] = resolved
+			continue
+		}
+		if err := d.resolveSchema(parameter.Schema, map[*Schema]bool{}); err != nil {
+			return err
+		}
+	}
+
+	if op.RequestBody != nil {
+		if err := d.resolveContent(op.RequestBody.Content); err != nil {
+			return err
+		}
+	}
+
+	for status, response := range op.Responses {
+		if response.Ref != "" {
+			resolved, ok := d.Components.Responses[strings.TrimPrefix(response.Ref, "#/components/responses/")]
+			if !ok {
+				return fmt.Errorf("unknown response %s", response.Ref)
+			}
+			op.Responses[status] = resolved
+			continue
+		}
+		if err := d.resolveContent(response.Content); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Document) resolveContent(content map[string]MediaType) error {
+	for mediaType, media := range content {
+		if err := d.resolveSchema(media.Schema, map[*Schema]bool{}); err != nil {
+			return fmt.Errorf("%s: %w", mediaType, err)
+		}
+	}
+	return nil
+}
+
+// resolveSchema points the ref of schema and of the schemas it contains at
+// the components they name.
+func (d *Document) resolveSchema(schema *Schema, seen map[*Schema]bool) error {
+	if schema == nil || seen[schema] {
+		return nil
+	}
+	seen[schema] = true
+
+	if schema.Ref != "" {
+		resolved, ok := d.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+		if !ok {
+			return fmt.Errorf("unknown schema %s", schema.Ref)
+		}
+		schema.resolved = resolved
+	}
+	if schema.Pattern != "" {
+		pattern, err := regexp.Compile(schema.Pattern)
+		if err != nil {
+			return err
+		}
+		schema.pattern = pattern
+	}
+
+	children := append([]*Schema{schema.Items}, schema.AllOf...)
+	for _, property := range schema.Properties {
+		children = append(children, property)
+	}
+	for _, child := range children {
+		if err := d.resolveSchema(child, seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+// Package openapi serves the OpenAPI 3.1 description of the HTTP API, and a
+// Swagger UI page for browsing it.
+package openapi
+
+import (
+	"embed"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sarita-growexx/note_with_alarm/problem"
+)
+
+//go:embed openapi.json
+var spec []byte
+
+// docsPage loads Swagger UI from the files served under it and points it at
+// the spec, which is served next to it.
+//
+//go:embed docs.html
+var docsPage []byte
+
+// swaggerUI holds the files of swagger-ui-dist the docs page loads, vendored
+// in swagger-ui, so that the page neither depends on nor runs code from a
+// third-party CDN.
+//
+//go:embed swagger-ui
+var swaggerUI embed.FS
+
+// docsAssets are the Swagger UI files served under the docs page, with their
+// Content-Type.
+var docsAssets = map[string]string{
+	"swagger-ui.css":       "text/css; charset=utf-8",
+	"swagger-ui-bundle.js": "text/javascript; charset=utf-8",
+}
+
+// Spec returns the OpenAPI document.
+func Spec() []byte {
+	return spec
+}
+
+// SpecHandler serves the OpenAPI document.
+func SpecHandler(ctx *gin.Context) {
+	ctx.Data(http.StatusOK, "application/json", spec)
+}
+
+// DocsHandler serves the Swagger UI page.
+func DocsHandler(ctx *gin.Context) {
+	ctx.Data(http.StatusOK, "text/html; charset=utf-8", docsPage)
+}
+
+// DocsAssetHandler serves the Swagger UI file named by the file parameter.
+func DocsAssetHandler(ctx *gin.Context) {
+	name := ctx.Param("file")
+	contentType, ok := docsAssets[name]
+	if !ok {
+		problem.Write(ctx, problem.New(http.StatusNotFound, "No such Swagger UI file"))
+		return
+	}
+	data, err := swaggerUI.ReadFile("swagger-ui/" + name)
+	if err != nil {
+		ctx.Error(err)
+		return
+	}
+	ctx.Header("Cache-Control", "public, max-age=86400")
+	ctx.Data(http.StatusOK, contentType, data)
+}
@@ -0,0 +1,283 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sarita-growexx/note_with_alarm/problem"
+)
+
+// Schema is the subset of JSON Schema used by the document.
+type Schema struct {
+	Ref              string             `json:"$ref"`
+	Type             Types              `json:"type"`
+	Format           string             `json:"format"`
+	Enum             []interface{}      `json:"enum"`
+	Pattern          string             `json:"pattern"`
+	MinLength        *int               `json:"minLength"`
+	MaxLength        *int               `json:"maxLength"`
+	Minimum          *float64           `json:"minimum"`
+	Maximum          *float64           `json:"maximum"`
+	ExclusiveMinimum *float64           `json:"exclusiveMinimum"`
+	MinItems         *int               `json:"minItems"`
+	MaxItems         *int               `json:"maxItems"`
+	UniqueItems      bool               `json:"uniqueItems"`
+	Items            *Schema            `json:"items"`
+	Properties       map[string]*Schema `json:"properties"`
+	Required         []string           `json:"required"`
+	AllOf            []*Schema          `json:"allOf"`
+
+	resolved *Schema
+	pattern  *regexp.Regexp
+}
+
+// Types are the JSON types a schema allows, given as a single name or, in
+// OpenAPI 3.1, a list such as ["string", "null"].
+type Types []string
+
+// UnmarshalJSON accepts a type name or a list of them.
+func (t *Types) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*t = Types{name}
+		return nil
+	}
+	var names []string
+	if err := json.Unmarshal(data, &names); err != nil {
+		return err
+	}
+	*t = names
+	return nil
+}
+
+// DecodeJSON decodes a JSON document for Validate, keeping numbers exact so
+// integers can be told from other numbers.
+func DecodeJSON(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(strings.NewReader(string(data)))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("unexpected data after the JSON value")
+	}
+	return value, nil
+}
+
+// ParseParameter converts the raw value of a path or query parameter to the
+// JSON type its schema expects. Values that cannot be converted are left as
+// strings, for Validate to report.
+func (s *Schema) ParseParameter(raw string) interface{} {
+	schema := s.target()
+	switch {
+	case schema.allows("integer"), schema.allows("number"):
+		if _, err := strconv.ParseFloat(raw, 64); err == nil {
+			return json.Number(raw)
+		}
+	case schema.allows("boolean"):
+		if value, err := strconv.ParseBool(raw); err == nil {
+			return value
+		}
+	}
+	return raw
+}
+
+// Validate checks a value decoded by DecodeJSON against the schema and
+// returns the rules it breaks. Field is the name of the value, with the
+// names of nested values joined by dots and list indexes in brackets.
+func (s *Schema) Validate(field string, value interface{}) []problem.Violation {
+	schema := s.target()
+	if schema == nil {
+		return nil
+	}
+
+	var violations []problem.Violation
+	for _, sub := range schema.AllOf {
+		violations = append(violations, sub.Validate(field, value)...)
+	}
+
+	kind := jsonType(value)
+	if len(schema.Type) > 0 && !schema.allows(kind) {
+		return append(violations, problem.Violation{Field: field, Rule: "type", Param: strings.Join(schema.Type, " or "), Value: kind})
+	}
+	if len(schema.Enum) > 0 && !schema.inEnum(value) {
+		violations = append(violations, problem.Violation{Field: field, Rule: "oneof", Param: schema.enumParam()})
+	}
+
+	switch value := value.(type) {
+	case string:
+		violations = append(violations, schema.validateString(field, value)...)
+	case json.Number:
+		violations = append(violations, schema.validateNumber(field, value)...)
+	case []interface{}:
+		violations = append(violations, schema.validateArray(field, value)...)
+	case map[string]interface{}:
+		violations = append(violations, schema.validateObject(field, value)...)
+	}
+	return violations
+}
+
+func (s *Schema) validateString(field, value string) []problem.Violation {
+	var violations []problem.Violation
+	length := utf8.RuneCountInString(value)
+	if s.MinLength != nil && length < *s.MinLength {
+		violations = append(violations, problem.Violation{Field: field, Rule: "min", Param: strconv.Itoa(*s.MinLength), Kind: reflect.String})
+	}
+	if s.MaxLength != nil && length > *s.MaxLength {
+		violations = append(violations, problem.Violation{Field: field, Rule: "max", Param: strconv.Itoa(*s.MaxLength), Kind: reflect.String})
+	}
+	if s.pattern != nil && !s.pattern.MatchString(value) {
+		violations = append(violations, problem.Violation{Field: field, Rule: "pattern", Param: s.Pattern, Kind: reflect.String})
+	}
+	if s.Format == "date-time" {
+		if _, err := time.Parse(time.RFC3339, value); err != nil {
+			violations = append(violations, problem.Violation{Field: field, Rule: "format", Param: s.Format, Kind: reflect.String})
+		}
+	}
+	return violations
+}
+
+func (s *Schema) validateNumber(field string, value json.Number) []problem.Violation {
+	number, err := value.Float64()
+	if err != nil {
+		return nil
+	}
+
+	var violations []problem.Violation
+	if s.Minimum != nil && number < *s.Minimum {
+		violations = append(violations, problem.Violation{Field: field, Rule: "min", Param: formatNumber(*s.Minimum), Kind: reflect.Float64})
+	}
+	if s.ExclusiveMinimum != nil && number <= *s.ExclusiveMinimum {
+		violations = append(violations, problem.Violation{Field: field, Rule: "gt", Param: formatNumber(*s.ExclusiveMinimum), Kind: reflect.Float64})
+	}
+	if s.Maximum != nil && number > *s.Maximum {
+		violations = append(violations, problem.Violation{Field: field, Rule: "max", Param: formatNumber(*s.Maximum), Kind: reflect.Float64})
+	}
+	return violations
+}
+
+func (s *Schema) validateArray(field string, value []interface{}) []problem.Violation {
+	var violations []problem.Violation
+	if s.MinItems != nil && len(value) < *s.MinItems {
+		violations = append(violations, problem.Violation{Field: field, Rule: "min", Param: strconv.Itoa(*s.MinItems), Kind: reflect.Slice})
+	}
+	if s.MaxItems != nil && len(value) > *s.MaxItems {
+		violations = append(violations, problem.Violation{Field: field, Rule: "max", Param: strconv.Itoa(*s.MaxItems), Kind: reflect.Slice})
+	}
+	if s.UniqueItems && hasDuplicates(value) {
+		violations = append(violations, problem.Violation{Field: field, Rule: "unique", Kind: reflect.Slice})
+	}
+	if s.Items != nil {
+		for i, item := range value {
+			violations = append(violations, s.Items.Validate(fmt.Sprintf("%s[%d]", field, i), item)...)
+		}
+	}
+	return violations
+}
+
+func (s *Schema) validateObject(field string, value map[string]interface{}) []problem.Violation {
+	var violations []problem.Violation
+	for _, name := range s.Required {
+		if _, ok := value[name]; !ok {
+			violations = append(violations, problem.Violation{Field: joinField(field, name), Rule: "required"})
+		}
+	}
+	names := make([]string, 0, len(s.Properties))
+	for name := range s.Properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if propertyValue, ok := value[name]; ok {
+			violations = append(violations, s.Properties[name].Validate(joinField(field, name), propertyValue)...)
+		}
+	}
+	return violations
+}
+
+// target follows the reference of s, if any.
+func (s *Schema) target() *Schema {
+	for s != nil && s.resolved != nil {
+		s = s.resolved
+	}
+	return s
+}
+
+func (s *Schema) allows(kind string) bool {
+	for _, allowed := range s.Type {
+		if allowed == kind || (allowed == "number" && kind == "integer") {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Schema) inEnum(value interface{}) bool {
+	for _, allowed := range s.Enum {
+		if fmt.Sprint(allowed) == fmt.Sprint(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Schema) enumParam() string {
+	values := make([]string, len(s.Enum))
+	for i, value := range s.Enum {
+		values[i] = fmt.Sprint(value)
+	}
+	return strings.Join(values, " ")
+}
+
+// jsonType names the JSON type of a value decoded by DecodeJSON. Numbers
+// without a fraction are integers.
+func jsonType(value interface{}) string {
+	switch value := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if _, err := value.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	default:
+		return "object"
+	}
+}
+
+func hasDuplicates(values []interface{}) bool {
+	seen := make(map[string]bool, len(values))
+	for _, value := range values {
+		key, _ := json.Marshal(value)
+		if seen[string(key)] {
+			return true
+		}
+		seen[string(key)] = true
+	}
+	return false
+}
+
+func joinField(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
+
+func formatNumber(number float64) string {
+	return strconv.FormatFloat(number, 'f', -1, 64)
+}
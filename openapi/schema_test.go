@@ -0,0 +1,66 @@
+package openapi
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sarita-growexx/note_with_alarm/problem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	doc, err := Load()
+	require.NoError(t, err)
+	assert.Equal(t, "3.1.0", doc.OpenAPI)
+
+	op := doc.Operation("PATCH", "/api/v2/notes/:id")
+	require.NotNil(t, op)
+	assert.Equal(t, "patchNote", op.OperationID)
+	assert.Equal(t, []string{"application/json-patch+json", "application/merge-patch+json"}, op.AcceptedMediaTypes())
+	assert.Nil(t, doc.Operation("GET", "/api/unknown"))
+}
+
+func TestSchemaValidate(t *testing.T) {
+	doc, err := Load()
+	require.NoError(t, err)
+	note := doc.Components.Schemas["NoteCreate"]
+
+	tests := []struct {
+		name       string
+		body       string
+		violations []problem.Violation
+	}{
+		{"valid", `{"title":"Pay invoice","deadline":"2030-01-01T00:00:00Z","tags":["work"],"reminders":[30,60]}`, nil},
+		{"not an object", `[]`, []problem.Violation{{Rule: "type", Param: "object", Value: "array"}}},
+		{"missing fields", `{}`, []problem.Violation{{Field: "title", Rule: "required"}, {Field: "deadline", Rule: "required"}}},
+		{"bad values", `{"title":"ab","deadline":"tomorrow","tags":["work","work"],"reminders":[1.5]}`, []problem.Violation{
+			{Field: "deadline", Rule: "format", Param: "date-time", Kind: reflect.String},
+			{Field: "reminders[0]", Rule: "type", Param: "integer", Value: "number"},
+			{Field: "tags", Rule: "unique", Kind: reflect.Slice},
+			{Field: "title", Rule: "min", Param: "3", Kind: reflect.String},
+		}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			value, err := DecodeJSON([]byte(tc.body))
+			require.NoError(t, err)
+			assert.Equal(t, tc.violations, note.Validate("", value))
+		})
+	}
+}
+
+func TestSchemaNullable(t *testing.T) {
+	doc, err := Load()
+	require.NoError(t, err)
+	patch := doc.Components.Schemas["NotePatch"]
+
+	value, err := DecodeJSON([]byte(`{"description":null,"tags":null}`))
+	require.NoError(t, err)
+	assert.Empty(t, patch.Validate("", value))
+
+	value, err = DecodeJSON([]byte(`{"description":false}`))
+	require.NoError(t, err)
+	assert.Equal(t, []problem.Violation{{Field: "description", Rule: "type", Param: "string or null", Value: "boolean"}}, patch.Validate("", value))
+}
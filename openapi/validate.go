@@ -0,0 +1,142 @@
+package openapi
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sarita-growexx/note_with_alarm/problem"
+)
+
+// ErrUnsupportedMediaType is returned for a body whose media type the
+// operation does not accept.
+var ErrUnsupportedMediaType = errors.New("unsupported media type")
+
+// ValidateParameters checks the path and query parameters of a request.
+// Header parameters are left to the handlers, which answer a missing
+// If-Match with 428 Precondition Required rather than 400.
+func (op *Operation) ValidateParameters(pathParam func(string) string, query url.Values) []problem.Violation {
+	var violations []problem.Violation
+	for _, parameter := range op.Parameters {
+		var raw string
+		var present bool
+		switch parameter.In {
+		case "path":
+			raw = pathParam(parameter.Name)
+			present = raw != ""
+		case "query":
+			present = query.Has(parameter.Name)
+			raw = query.Get(parameter.Name)
+		default:
+			continue
+		}
+
+		if !present {
+			if parameter.Required {
+				violations = append(violations, problem.Violation{Field: parameter.Name, Rule: "required"})
+			}
+			continue
+		}
+		violations = append(violations, parameter.Schema.Validate(parameter.Name, parameter.Schema.ParseParameter(raw))...)
+	}
+	return violations
+}
+
+// ValidateBody checks a request body of the given Content-Type. It returns
+// ErrUnsupportedMediaType when the operation does not accept the media type,
+// and an error when a body of a JSON media type is not JSON. Bodies of other
+// media types are only checked against the accepted types, so they need not
+// be read to be passed.
+func (op *Operation) ValidateBody(contentType string, body []byte) ([]problem.Violation, error) {
+	if op.RequestBody == nil {
+		return nil, nil
+	}
+	if len(body) == 0 && !op.RequestBody.Required {
+		return nil, nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, ErrUnsupportedMediaType
+	}
+	media, ok := op.RequestBody.Content[mediaType]
+	if !ok {
+		return nil, ErrUnsupportedMediaType
+	}
+
+	if !IsJSON(mediaType) {
+		return nil, nil
+	}
+
+	value, err := DecodeJSON(body)
+	if err != nil {
+		return nil, err
+	}
+	return media.Schema.Validate("", value), nil
+}
+
+// AcceptedMediaTypes lists the media types of request bodies the operation
+// accepts.
+func (op *Operation) AcceptedMediaTypes() []string {
+	if op.RequestBody == nil {
+		return nil
+	}
+	mediaTypes := make([]string, 0, len(op.RequestBody.Content))
+	for mediaType := range op.RequestBody.Content {
+		mediaTypes = append(mediaTypes, mediaType)
+	}
+	sort.Strings(mediaTypes)
+	return mediaTypes
+}
+
+// ValidateResponse checks that the operation documents a response with the
+// given status and Content-Type, and that its JSON body matches the schema.
+func (op *Operation) ValidateResponse(status int, contentType string, body []byte) error {
+	response, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		response, ok = op.Responses["default"]
+	}
+	if !ok {
+		return fmt.Errorf("status %d is not documented", status)
+	}
+	if len(response.Content) == 0 {
+		return nil
+	}
+
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return fmt.Errorf("status %d has no valid Content-Type", status)
+	}
+	media, ok := response.Content[mediaType]
+	if !ok {
+		return fmt.Errorf("status %d is not documented as %s", status, mediaType)
+	}
+	if !IsJSON(mediaType) {
+		return nil
+	}
+
+	value, err := DecodeJSON(body)
+	if err != nil {
+		return fmt.Errorf("status %d body is not JSON: %w", status, err)
+	}
+	violations := media.Schema.Validate("", value)
+	if len(violations) == 0 {
+		return nil
+	}
+	descriptions := make([]string, len(violations))
+	for i, v := range violations {
+		descriptions[i] = strings.TrimSpace(fmt.Sprintf("%s breaks %s %s", v.Field, v.Rule, v.Param))
+	}
+	return fmt.Errorf("status %d body does not match the schema: %s", status, strings.Join(descriptions, "; "))
+}
+
+// IsJSON reports whether a media type holds a single JSON document, as
+// application/json and the +json types such as application/problem+json do.
+// Streams of JSON values, such as application/x-ndjson, do not.
+func IsJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
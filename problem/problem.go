@@ -0,0 +1,243 @@
+// Package problem writes error responses as RFC 7807 problem details, so
+// clients can tell errors apart and point at the request fields at fault.
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/sarita-growexx/note_with_alarm/apperrors"
+)
+
+// ContentType is the media type of problem detail responses.
+const ContentType = "application/problem+json"
+
+// TypeValidation identifies problems caused by invalid request fields. Other
+// problems use about:blank, whose meaning is given by the HTTP status alone.
+const TypeValidation = "/problems/validation-error"
+
+// Problem is an RFC 7807 problem details object.
+type Problem struct {
+	Type     string
+	Title    string
+	Status   int
+	Detail   string
+	Instance string
+	// Errors lists every invalid request field.
+	Errors []FieldError
+	// Extensions are additional members, such as the offset of a query
+	// syntax error.
+	Extensions map[string]interface{}
+
+	// translations holds the message of each field error for translation.
+	translations []translation
+}
+
+// FieldError is a request field that failed a validation rule.
+type FieldError struct {
+	// Field is the JSON or query parameter name of the field, with the
+	// index for elements of a list, such as tags[1].
+	Field string `json:"field"`
+	// Rule is the validator/v10 tag that failed, such as required or max.
+	Rule    string `json:"rule"`
+	Param   string `json:"param,omitempty"`
+	Message string `json:"message"`
+}
+
+// Violation is a request field that broke a rule, found without
+// validator/v10, such as when checking a request against the OpenAPI
+// document. Rules share the names and messages of the validator tags.
+type Violation struct {
+	Field string
+	Rule  string
+	Param string
+	// Kind is the kind of the field value, which words min and max rules.
+	Kind reflect.Kind
+	// Value is the type of the value that broke a type rule.
+	Value string
+}
+
+// translation selects the message of a field error in each locale.
+type translation struct {
+	key    string
+	params []string
+}
+
+// New returns a problem with the given status, described by detail.
+func New(status int, detail string) *Problem {
+	return &Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(status),
+		Status: status,
+		Detail: detail,
+	}
+}
+
+// StatusClientClosedRequest is the status, borrowed from nginx, of requests
+// the client gave up on before they were answered.
+const StatusClientClosedRequest = 499
+
+// statusByKind is the HTTP status reported for each kind of error.
+var statusByKind = map[apperrors.Kind]int{
+	apperrors.Internal:           http.StatusInternalServerError,
+	apperrors.NotFound:           http.StatusNotFound,
+	apperrors.Conflict:           http.StatusConflict,
+	apperrors.Validation:         http.StatusBadRequest,
+	apperrors.PreconditionFailed: http.StatusPreconditionFailed,
+	apperrors.Timeout:            http.StatusGatewayTimeout,
+	apperrors.Canceled:           StatusClientClosedRequest,
+}
+
+// FromError returns the problem describing err. The status follows the
+// error's apperrors kind, the detail is its message and any fields the error
+// carries become extension members.
+func FromError(err error) *Problem {
+	kind := apperrors.KindOf(err)
+	p := New(statusByKind[kind], apperrors.MessageOf(err))
+	if kind == apperrors.Validation {
+		p.Type = TypeValidation
+	}
+	p.Extensions = apperrors.FieldsOf(err)
+	return p
+}
+
+// Validation returns the 400 problem for a request that failed binding or
+// validation, listing each invalid field when err identifies them.
+func Validation(err error) *Problem {
+	p := New(http.StatusBadRequest, "The request is invalid")
+	p.Type = TypeValidation
+
+	var validationErrs validator.ValidationErrors
+	var typeErr *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &validationErrs):
+		for _, fieldErr := range validationErrs {
+			p.Errors = append(p.Errors, FieldError{
+				Field: fieldPath(fieldErr),
+				Rule:  fieldErr.Tag(),
+				Param: fieldErr.Param(),
+			})
+			p.translations = append(p.translations, messageOf(fieldErr.Tag(), fieldErr.Param(), fieldErr.Kind()))
+		}
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		p.Errors = []FieldError{{
+			Field: typeErr.Field,
+			Rule:  "type",
+			Param: typeErr.Type.String(),
+		}}
+		p.translations = []translation{{key: "type", params: []string{typeErr.Type.String(), typeErr.Value}}}
+	default:
+		p.Detail = err.Error()
+	}
+	p.Translate("")
+	return p
+}
+
+// TooLarge returns the 413 problem for a request whose body was cut short by
+// http.MaxBytesReader, or nil when err is not about the limit.
+func TooLarge(err error) *Problem {
+	var tooLarge *http.MaxBytesError
+	if !errors.As(err, &tooLarge) {
+		return nil
+	}
+	return New(http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body is larger than %d bytes", tooLarge.Limit))
+}
+
+// Invalid returns the 400 problem for a request with the given violations.
+func Invalid(violations []Violation) *Problem {
+	p := New(http.StatusBadRequest, "The request is invalid")
+	p.Type = TypeValidation
+	for _, v := range violations {
+		p.Errors = append(p.Errors, FieldError{Field: v.Field, Rule: v.Rule, Param: v.Param})
+		t := messageOf(v.Rule, v.Param, v.Kind)
+		if v.Rule == "type" {
+			t.params = []string{v.Param, v.Value}
+		}
+		p.translations = append(p.translations, t)
+	}
+	p.Translate("")
+	return p
+}
+
+// Translate rewrites the field error messages in the first language of an
+// Accept-Language header that has translations, or in English.
+func (p *Problem) Translate(acceptLanguage string) {
+	trans := translatorFor(acceptLanguage)
+	for i, t := range p.translations {
+		p.Errors[i].Message = translate(trans, t.key, t.params)
+	}
+}
+
+// MarshalJSON writes the standard members alongside the extensions, which
+// cannot override them.
+func (p *Problem) MarshalJSON() ([]byte, error) {
+	members := make(map[string]interface{}, len(p.Extensions)+6)
+	for name, value := range p.Extensions {
+		members[name] = value
+	}
+	members["type"] = p.Type
+	members["title"] = p.Title
+	members["status"] = p.Status
+	if p.Detail != "" {
+		members["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		members["instance"] = p.Instance
+	}
+	if len(p.Errors) > 0 {
+		members["errors"] = p.Errors
+	}
+	return json.Marshal(members)
+}
+
+// Write sends p as the response, using the request path as its instance
+// unless one is set. Field error messages follow the Accept-Language header.
+func Write(ctx *gin.Context, p *Problem) {
+	if p.Instance == "" {
+		p.Instance = ctx.Request.URL.Path
+	}
+	if acceptLanguage := ctx.GetHeader("Accept-Language"); acceptLanguage != "" {
+		p.Translate(acceptLanguage)
+	}
+	ctx.Header("Content-Type", ContentType)
+	ctx.JSON(p.Status, p)
+}
+
+// fieldPath drops the name of the validated struct from the namespace of a
+// field error, so that Note.tags[1] becomes tags[1].
+func fieldPath(fieldErr validator.FieldError) string {
+	namespace := fieldErr.Namespace()
+	if i := strings.Index(namespace, "."); i >= 0 {
+		return namespace[i+1:]
+	}
+	return fieldErr.Field()
+}
+
+// messageOf returns the translation describing a broken rule.
+func messageOf(rule, param string, kind reflect.Kind) translation {
+	switch rule {
+	case "required", "future", "tagname", "unique":
+		return translation{key: rule}
+	case "required_unless", "required_if":
+		return translation{key: "required"}
+	case "min", "max":
+		switch kind {
+		case reflect.String:
+			return translation{key: rule + "-string", params: []string{param}}
+		case reflect.Slice, reflect.Array, reflect.Map:
+			return translation{key: rule + "-items", params: []string{param}}
+		default:
+			return translation{key: rule, params: []string{param}}
+		}
+	case "gt", "oneof", "pattern", "format", "type":
+		return translation{key: rule, params: []string{param}}
+	default:
+		return translation{key: "rule", params: []string{rule}}
+	}
+}
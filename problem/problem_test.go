@@ -0,0 +1,44 @@
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidation(t *testing.T) {
+	type request struct {
+		Title string   `validate:"required,min=3"`
+		Tags  []string `validate:"max=2,dive,max=5"`
+		Limit int      `validate:"max=100"`
+	}
+	err := validator.New().Struct(request{Title: "ab", Tags: []string{"ok", "too long"}, Limit: 500})
+	require.Error(t, err)
+
+	p := Validation(err)
+	assert.Equal(t, 400, p.Status)
+	assert.Equal(t, TypeValidation, p.Type)
+	assert.Equal(t, []FieldError{
+		{Field: "Title", Rule: "min", Param: "3", Message: "must be at least 3 characters"},
+		{Field: "Tags[1]", Rule: "max", Param: "5", Message: "must be at most 5 characters"},
+		{Field: "Limit", Rule: "max", Param: "100", Message: "must be at most 100"},
+	}, p.Errors)
+
+	p = Validation(errors.New("unexpected EOF"))
+	assert.Empty(t, p.Errors)
+	assert.Equal(t, "unexpected EOF", p.Detail)
+}
+
+func TestProblem_MarshalJSON(t *testing.T) {
+	p := New(404, "Note not found")
+	p.Instance = "/api/notes/7"
+	p.Extensions = map[string]interface{}{"status": "ignored", "note_id": 7}
+
+	body, err := json.Marshal(p)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"type":"about:blank","title":"Not Found","status":404,"detail":"Note not found","instance":"/api/notes/7","note_id":7}`, string(body))
+}
@@ -0,0 +1,96 @@
+package problem
+
+import (
+	"strings"
+
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/es"
+	ut "github.com/go-playground/universal-translator"
+)
+
+// messages are the field error messages of each supported locale, by key.
+// Placeholders such as {0} are filled with the parameters of the failed rule.
+var messages = map[string]map[string]string{
+	"en": {
+		"required":   "is required",
+		"min-string": "must be at least {0} characters",
+		"min-items":  "must have at least {0} items",
+		"min":        "must be at least {0}",
+		"max-string": "must be at most {0} characters",
+		"max-items":  "must have at most {0} items",
+		"max":        "must be at most {0}",
+		"gt":         "must be greater than {0}",
+		"oneof":      "must be one of {0}",
+		"pattern":    "must match {0}",
+		"format":     "must be a valid {0}",
+		"type":       "must be of type {0}, not {1}",
+		"future":     "must be in the future",
+		"tagname":    "must be 1 to 32 letters, digits, underscores or hyphens",
+		"unique":     "must not contain duplicates",
+		"rule":       "failed the {0} rule",
+	},
+	"es": {
+		"required":   "es obligatorio",
+		"min-string": "debe tener al menos {0} caracteres",
+		"min-items":  "debe tener al menos {0} elementos",
+		"min":        "debe ser como mínimo {0}",
+		"max-string": "debe tener como máximo {0} caracteres",
+		"max-items":  "debe tener como máximo {0} elementos",
+		"max":        "debe ser como máximo {0}",
+		"gt":         "debe ser mayor que {0}",
+		"oneof":      "debe ser uno de {0}",
+		"pattern":    "debe coincidir con {0}",
+		"format":     "debe ser un {0} válido",
+		"type":       "debe ser de tipo {0}, no {1}",
+		"future":     "debe estar en el futuro",
+		"tagname":    "debe tener de 1 a 32 letras, dígitos, guiones bajos o guiones",
+		"unique":     "no debe contener duplicados",
+		"rule":       "no cumple la regla {0}",
+	},
+}
+
+// translators holds a translator for every locale in messages. English is
+// the fallback for clients that accept none of them.
+var translators = newTranslators()
+
+func newTranslators() *ut.UniversalTranslator {
+	english := en.New()
+	uni := ut.New(english, english, es.New())
+	for locale, texts := range messages {
+		trans, _ := uni.GetTranslator(locale)
+		for key, text := range texts {
+			if err := trans.Add(key, text, false); err != nil {
+				panic(err)
+			}
+		}
+	}
+	return uni
+}
+
+// translate returns the message for key in the locale of trans.
+func translate(trans ut.Translator, key string, params []string) string {
+	message, err := trans.T(key, params...)
+	if err != nil {
+		return key
+	}
+	return message
+}
+
+// translatorFor picks the translator for the first language of an
+// Accept-Language header that has messages, such as es for "es-MX,en;q=0.5".
+// Quality values are not weighed; clients list their preferred language
+// first.
+func translatorFor(acceptLanguage string) ut.Translator {
+	var candidates []string
+	for _, tag := range strings.Split(acceptLanguage, ",") {
+		tag, _, _ = strings.Cut(tag, ";")
+		tag = strings.ReplaceAll(strings.TrimSpace(tag), "-", "_")
+		if tag == "" {
+			continue
+		}
+		base, _, _ := strings.Cut(tag, "_")
+		candidates = append(candidates, tag, base)
+	}
+	trans, _ := translators.FindTranslator(candidates...)
+	return trans
+}
@@ -0,0 +1,170 @@
+package query
+
+import (
+	"strings"
+	"time"
+)
+
+// Query is the parsed form of a search box query such as
+// `tag:work due:<7d status:open "design review" -draft`.
+type Query struct {
+	// Terms are the free text parts of the query, in order.
+	Terms []Term
+	// Filters restrict results on note fields and are ANDed together.
+	Filters []Filter
+	// Sort orders the results. It is not part of the query syntax but set
+	// by callers; see IsValidSort.
+	Sort string
+	// Location is the time zone whose calendar days the dates of due
+	// filters are. It is set by callers; nil means UTC.
+	Location *time.Location
+}
+
+// Now returns the current time in the Location of q, which its filters are
+// resolved against.
+func (q *Query) Now() time.Time {
+	if q.Location == nil {
+		return time.Now().UTC()
+	}
+	return time.Now().In(q.Location)
+}
+
+// Term is a word or quoted phrase to match against note text.
+type Term struct {
+	Text    string
+	Phrase  bool
+	Negated bool
+	// Or joins the term to the previous one with OR instead of AND.
+	Or  bool
+	Pos int
+}
+
+type Field string
+
+const (
+	FieldTag    Field = "tag"
+	FieldDue    Field = "due"
+	FieldStatus Field = "status"
+)
+
+type Op string
+
+const (
+	OpEq  Op = "="
+	OpLt  Op = "<"
+	OpLte Op = "<="
+	OpGt  Op = ">"
+	OpGte Op = ">="
+)
+
+const (
+	StatusOpen    = "open"
+	StatusOverdue = "overdue"
+)
+
+// Filter is a field:value qualifier.
+type Filter struct {
+	Field   Field
+	Op      Op
+	Negated bool
+	Pos     int
+
+	// Value is the tag name or status for tag: and status: filters.
+	Value string
+
+	// Due filters compare the deadline either to now plus Offset, when
+	// Relative is set, or to the calendar day Date, whose time and zone are
+	// meaningless.
+	Relative bool
+	Offset   time.Duration
+	Date     time.Time
+}
+
+// SortRelevance orders results by text rank when the query has free text
+// and by deadline otherwise. It is the default.
+const SortRelevance = "relevance"
+
+// sortFields are the note fields results can be sorted on, ascending or,
+// with a leading -, descending.
+var sortFields = map[string]bool{
+	"deadline":   true,
+	"created_at": true,
+	"updated_at": true,
+	"title":      true,
+}
+
+// IsValidSort reports whether sort is an accepted value for Query.Sort.
+func IsValidSort(sort string) bool {
+	return sort == "" || sort == SortRelevance || sortFields[strings.TrimPrefix(sort, "-")]
+}
+
+// SortField splits a sort into the note field and whether it is descending.
+// It returns an empty field for relevance ordering.
+func (q *Query) SortField() (string, bool) {
+	if q.Sort == "" || q.Sort == SortRelevance {
+		return "", false
+	}
+	return strings.TrimPrefix(q.Sort, "-"), strings.HasPrefix(q.Sort, "-")
+}
+
+// HasText reports whether the query contains free text terms.
+func (q *Query) HasText() bool {
+	return len(q.Terms) > 0
+}
+
+// Text renders the free text terms in web search syntax: quoted phrases,
+// OR between alternatives and a leading - for negation.
+func (q *Query) Text() string {
+	parts := make([]string, 0, len(q.Terms))
+	for _, term := range q.Terms {
+		var b strings.Builder
+		if term.Or {
+			b.WriteString("OR ")
+		}
+		if term.Negated {
+			b.WriteString("-")
+		}
+		if term.Phrase {
+			b.WriteString(`"` + term.Text + `"`)
+		} else {
+			b.WriteString(term.Text)
+		}
+		parts = append(parts, b.String())
+	}
+	return strings.Join(parts, " ")
+}
+
+// DueBounds resolves a due filter to the deadline range it matches relative
+// to now, taking dates to be calendar days in the location of now. A zero
+// bound is unbounded; from is inclusive and to exclusive.
+func (f Filter) DueBounds(now time.Time) (from, to time.Time) {
+	if f.Relative {
+		at := now.Add(f.Offset)
+		switch f.Op {
+		case OpLt:
+			return time.Time{}, at
+		case OpLte:
+			return time.Time{}, at.Add(time.Nanosecond)
+		case OpGt:
+			return at.Add(time.Nanosecond), time.Time{}
+		default:
+			return at, time.Time{}
+		}
+	}
+
+	year, month, day := f.Date.Date()
+	start := time.Date(year, month, day, 0, 0, 0, 0, now.Location())
+	end := time.Date(year, month, day+1, 0, 0, 0, 0, now.Location())
+	switch f.Op {
+	case OpLt:
+		return time.Time{}, start
+	case OpLte:
+		return time.Time{}, end
+	case OpGt:
+		return end, time.Time{}
+	case OpGte:
+		return start, time.Time{}
+	default:
+		return start, end
+	}
+}
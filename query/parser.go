@@ -0,0 +1,213 @@
+package query
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/sarita-growexx/note_with_alarm/apperrors"
+	"github.com/sarita-growexx/note_with_alarm/models"
+)
+
+var (
+	offsetPattern = regexp.MustCompile(`^(\d{1,4})([hdw])$`)
+)
+
+// ParseError points at the token of a query that could not be parsed.
+type ParseError struct {
+	// Offset is the byte offset of the token in the query.
+	Offset  int
+	Token   string
+	Message string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("%s at offset %d near %q", e.Message, e.Offset, e.Token)
+}
+
+// Is reports parse errors as validation failures.
+func (e *ParseError) Is(target error) bool {
+	return target == apperrors.Validation
+}
+
+// Fields points clients at the offending token.
+func (e *ParseError) Fields() map[string]interface{} {
+	return map[string]interface{}{"offset": e.Offset, "token": e.Token}
+}
+
+// token is a single whitespace separated unit of the query. Quotes are kept
+// in raw so phrases can be told apart from words.
+type token struct {
+	raw string
+	pos int
+}
+
+// Parse parses a search query. Free text words and "quoted phrases" can be
+// negated with a leading - and joined with OR. The qualifiers tag:<name>,
+// status:open|overdue and due:<op><value> filter on note fields, where the
+// due value is an offset from now such as 7d, 12h or 2w, or a date such as
+// 2024-06-01. Dates are calendar days in the Location of the query. Words
+// with a colon that do not start with one of those fields are free text.
+func Parse(input string) (*Query, error) {
+	tokens, err := tokenize(input)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &Query{}
+	var pendingOr *token
+	for i := range tokens {
+		tok := tokens[i]
+		if tok.raw == "OR" {
+			if pendingOr != nil || len(q.Terms) == 0 {
+				return nil, &ParseError{Offset: tok.pos, Token: tok.raw, Message: "OR must join two search terms"}
+			}
+			pendingOr = &tokens[i]
+			continue
+		}
+
+		negated := false
+		body := tok.raw
+		if strings.HasPrefix(body, "-") && len(body) > 1 {
+			negated = true
+			body = body[1:]
+		}
+
+		if field, value, ok := splitField(body); ok {
+			if pendingOr != nil {
+				return nil, &ParseError{Offset: pendingOr.pos, Token: pendingOr.raw, Message: "OR must join two search terms"}
+			}
+			filter, err := parseFilter(field, value, tok)
+			if err != nil {
+				return nil, err
+			}
+			filter.Negated = negated
+			q.Filters = append(q.Filters, filter)
+			continue
+		}
+
+		term := Term{Text: body, Negated: negated, Or: pendingOr != nil, Pos: tok.pos}
+		if strings.HasPrefix(body, `"`) {
+			term.Phrase = true
+			term.Text = strings.Join(strings.Fields(strings.Trim(body, `"`)), " ")
+			if term.Text == "" {
+				return nil, &ParseError{Offset: tok.pos, Token: tok.raw, Message: "empty phrase"}
+			}
+		}
+		q.Terms = append(q.Terms, term)
+		pendingOr = nil
+	}
+
+	if pendingOr != nil {
+		return nil, &ParseError{Offset: pendingOr.pos, Token: pendingOr.raw, Message: "OR must join two search terms"}
+	}
+	return q, nil
+}
+
+// tokenize splits the query on white space, decoding it as UTF-8 so that
+// multi-byte characters stay whole.
+func tokenize(input string) ([]token, error) {
+	var tokens []token
+	i := 0
+	for i < len(input) {
+		r, size := utf8.DecodeRuneInString(input[i:])
+		if unicode.IsSpace(r) {
+			i += size
+			continue
+		}
+
+		start := i
+		for i < len(input) {
+			r, size := utf8.DecodeRuneInString(input[i:])
+			if unicode.IsSpace(r) {
+				break
+			}
+			if r == '"' {
+				end := strings.IndexByte(input[i+1:], '"')
+				if end < 0 {
+					return nil, &ParseError{Offset: i, Token: input[start:], Message: "unterminated quote"}
+				}
+				i += end + 2
+				continue
+			}
+			i += size
+		}
+		tokens = append(tokens, token{raw: input[start:i], pos: start})
+	}
+	return tokens, nil
+}
+
+// splitField splits a field:value token. Quoted phrases are never fields,
+// and neither are words whose prefix is not a known field, such as 10:30 or
+// a URL; those are free text.
+func splitField(s string) (string, string, bool) {
+	colon := strings.IndexByte(s, ':')
+	if colon <= 0 || strings.IndexByte(s[:colon], '"') >= 0 {
+		return "", "", false
+	}
+	field := strings.ToLower(s[:colon])
+	switch Field(field) {
+	case FieldTag, FieldStatus, FieldDue:
+		return field, strings.Trim(s[colon+1:], `"`), true
+	}
+	return "", "", false
+}
+
+func parseFilter(field, value string, tok token) (Filter, error) {
+	fail := func(message string) (Filter, error) {
+		return Filter{}, &ParseError{Offset: tok.pos, Token: tok.raw, Message: message}
+	}
+	if value == "" {
+		return fail("missing value for " + field)
+	}
+
+	switch Field(field) {
+	case FieldTag:
+		if !models.ValidTagName(value) {
+			return fail("invalid tag name")
+		}
+		return Filter{Field: FieldTag, Op: OpEq, Value: value, Pos: tok.pos}, nil
+
+	case FieldStatus:
+		status := strings.ToLower(value)
+		if status != StatusOpen && status != StatusOverdue {
+			return fail("status must be open or overdue")
+		}
+		return Filter{Field: FieldStatus, Op: OpEq, Value: status, Pos: tok.pos}, nil
+
+	case FieldDue:
+		op, rest := splitOp(value)
+		filter := Filter{Field: FieldDue, Op: op, Pos: tok.pos}
+		if m := offsetPattern.FindStringSubmatch(strings.ToLower(rest)); m != nil {
+			if op == OpEq {
+				return fail("relative due dates need one of <, <=, > or >=")
+			}
+			n, _ := strconv.Atoi(m[1])
+			unit := map[string]time.Duration{"h": time.Hour, "d": 24 * time.Hour, "w": 7 * 24 * time.Hour}[m[2]]
+			filter.Relative = true
+			filter.Offset = time.Duration(n) * unit
+			return filter, nil
+		}
+		date, err := time.Parse("2006-01-02", rest)
+		if err != nil {
+			return fail("due must be an offset such as 7d or a date such as 2024-06-01")
+		}
+		filter.Date = date
+		return filter, nil
+	}
+
+	return fail("unknown field " + field)
+}
+
+func splitOp(value string) (Op, string) {
+	for _, op := range []Op{OpLte, OpGte, OpLt, OpGt, OpEq} {
+		if strings.HasPrefix(value, string(op)) {
+			return op, value[len(op):]
+		}
+	}
+	return OpEq, value
+}
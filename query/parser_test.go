@@ -0,0 +1,129 @@
+package query
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	q, err := Parse(`tag:work due:<7d status:open "design review" -draft`)
+	require.NoError(t, err)
+
+	assert.Equal(t, []Term{
+		{Text: "design review", Phrase: true, Pos: 29},
+		{Text: "draft", Negated: true, Pos: 45},
+	}, q.Terms)
+	assert.Equal(t, []Filter{
+		{Field: FieldTag, Op: OpEq, Value: "work", Pos: 0},
+		{Field: FieldDue, Op: OpLt, Relative: true, Offset: 7 * 24 * time.Hour, Pos: 9},
+		{Field: FieldStatus, Op: OpEq, Value: StatusOpen, Pos: 17},
+	}, q.Filters)
+	assert.Equal(t, `"design review" -draft`, q.Text())
+}
+
+func TestParse_OrAndNegatedFilters(t *testing.T) {
+	q, err := Parse(`budget OR  "cost plan" -tag:archived due:>=2024-06-01`)
+	require.NoError(t, err)
+
+	assert.Equal(t, `budget OR "cost plan"`, q.Text())
+	require.Len(t, q.Filters, 2)
+	assert.True(t, q.Filters[0].Negated)
+	assert.Equal(t, "archived", q.Filters[0].Value)
+	assert.Equal(t, OpGte, q.Filters[1].Op)
+	assert.Equal(t, time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC), q.Filters[1].Date)
+}
+
+func TestParse_FiltersOnly(t *testing.T) {
+	q, err := Parse("status:overdue")
+	require.NoError(t, err)
+	assert.False(t, q.HasText())
+	assert.Len(t, q.Filters, 1)
+}
+
+func TestParse_NonASCII(t *testing.T) {
+	q, err := Parse("déjà\u00a0vu tag:voilà -café")
+	require.NoError(t, err)
+
+	assert.Equal(t, []Term{
+		{Text: "déjà", Pos: 0},
+		{Text: "vu", Pos: 8},
+		{Text: "café", Negated: true, Pos: 22},
+	}, q.Terms)
+	assert.Equal(t, []Filter{{Field: FieldTag, Op: OpEq, Value: "voilà", Pos: 11}}, q.Filters)
+}
+
+func TestParse_UnknownFieldsAreText(t *testing.T) {
+	q, err := Parse(`standup 10:30 https://example.com/agenda color:red`)
+	require.NoError(t, err)
+	assert.Empty(t, q.Filters)
+	assert.Equal(t, `standup 10:30 https://example.com/agenda color:red`, q.Text())
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		input  string
+		offset int
+		token  string
+	}{
+		{input: `notes "design review`, offset: 6, token: `"design review`},
+		{input: `due:<soon`, offset: 0, token: "due:<soon"},
+		{input: `due:7d`, offset: 0, token: "due:7d"},
+		{input: `status:done`, offset: 0, token: "status:done"},
+		{input: `tag:`, offset: 0, token: "tag:"},
+		{input: `tag:a+b`, offset: 0, token: "tag:a+b"},
+		{input: `OR budget`, offset: 0, token: "OR"},
+		{input: `budget OR`, offset: 7, token: "OR"},
+		{input: `budget OR tag:work`, offset: 7, token: "OR"},
+		{input: `budget ""`, offset: 7, token: `""`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			_, err := Parse(tt.input)
+			var parseErr *ParseError
+			require.ErrorAs(t, err, &parseErr)
+			assert.Equal(t, tt.offset, parseErr.Offset)
+			assert.Equal(t, tt.token, parseErr.Token)
+		})
+	}
+}
+
+func TestFilter_DueBounds(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	from, to := Filter{Field: FieldDue, Op: OpLt, Relative: true, Offset: 48 * time.Hour}.DueBounds(now)
+	assert.True(t, from.IsZero())
+	assert.Equal(t, now.Add(48*time.Hour), to)
+
+	day := time.Date(2024, 6, 3, 0, 0, 0, 0, time.UTC)
+	from, to = Filter{Field: FieldDue, Op: OpEq, Date: day}.DueBounds(now)
+	assert.Equal(t, day, from)
+	assert.Equal(t, day.AddDate(0, 0, 1), to)
+
+	from, to = Filter{Field: FieldDue, Op: OpGt, Date: day}.DueBounds(now)
+	assert.Equal(t, day.AddDate(0, 0, 1), from)
+	assert.True(t, to.IsZero())
+
+	// Dates are days in the location of now
+	ist := time.FixedZone("IST", 5*3600+1800)
+	from, to = Filter{Field: FieldDue, Op: OpEq, Date: day}.DueBounds(now.In(ist))
+	assert.Equal(t, time.Date(2024, 6, 2, 18, 30, 0, 0, time.UTC), from.UTC())
+	assert.Equal(t, time.Date(2024, 6, 3, 18, 30, 0, 0, time.UTC), to.UTC())
+}
+
+func TestQuery_Sort(t *testing.T) {
+	assert.True(t, IsValidSort(""))
+	assert.True(t, IsValidSort("relevance"))
+	assert.True(t, IsValidSort("-deadline"))
+	assert.False(t, IsValidSort("color"))
+
+	field, desc := (&Query{Sort: "-updated_at"}).SortField()
+	assert.Equal(t, "updated_at", field)
+	assert.True(t, desc)
+
+	field, _ = (&Query{Sort: "relevance"}).SortField()
+	assert.Empty(t, field)
+}
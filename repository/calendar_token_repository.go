@@ -0,0 +1,17 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/sarita-growexx/note_with_alarm/models"
+)
+
+// CalendarTokenRepository stores the tokens of calendar feeds, one per user.
+type CalendarTokenRepository interface {
+	// Save stores token, replacing the previous token of its user.
+	Save(ctx context.Context, token *models.CalendarToken) error
+	GetByHash(ctx context.Context, tokenHash string) (*models.CalendarToken, error)
+	// Delete deletes the token of a user. It returns
+	// ErrCalendarTokenNotFound when the user has none.
+	Delete(ctx context.Context, userName string) error
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sarita-growexx/note_with_alarm/apperrors"
+	"github.com/sarita-growexx/note_with_alarm/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var ErrCalendarTokenNotFound = apperrors.New(apperrors.NotFound, "calendar token not found")
+
+type CalendarTokenRepositoryImpl struct {
+	db *gorm.DB
+}
+
+func NewCalendarTokenRepository(db *gorm.DB) CalendarTokenRepository {
+	return &CalendarTokenRepositoryImpl{db: db}
+}
+
+// Save implements CalendarTokenRepository.
+func (r *CalendarTokenRepositoryImpl) Save(ctx context.Context, token *models.CalendarToken) error {
+	err := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_name"}},
+		DoUpdates: clause.AssignmentColumns([]string{"token_hash", "created_at"}),
+	}).Create(token).Error
+	return dbError(err, nil)
+}
+
+// GetByHash implements CalendarTokenRepository.
+func (r *CalendarTokenRepositoryImpl) GetByHash(ctx context.Context, tokenHash string) (*models.CalendarToken, error) {
+	var token models.CalendarToken
+	err := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&token).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrCalendarTokenNotFound
+	}
+	if err != nil {
+		return nil, dbError(err, nil)
+	}
+	return &token, nil
+}
+
+// Delete implements CalendarTokenRepository.
+func (r *CalendarTokenRepositoryImpl) Delete(ctx context.Context, userName string) error {
+	result := r.db.WithContext(ctx).Where("user_name = ?", userName).Delete(&models.CalendarToken{})
+	if result.Error != nil {
+		return dbError(result.Error, nil)
+	}
+	if result.RowsAffected == 0 {
+		return ErrCalendarTokenNotFound
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sarita-growexx/note_with_alarm/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalendarTokenRepository(t *testing.T) {
+	implementations := map[string]func(t *testing.T) CalendarTokenRepository{
+		"sql": func(t *testing.T) CalendarTokenRepository {
+			db, cleanup := setupTestDB()
+			t.Cleanup(cleanup)
+			return NewCalendarTokenRepository(db)
+		},
+		"memory": func(t *testing.T) CalendarTokenRepository {
+			return NewMemoryCalendarTokenRepository()
+		},
+	}
+
+	for implementation, newRepo := range implementations {
+		t.Run(implementation, func(t *testing.T) {
+			ctx := context.Background()
+			repo := newRepo(t)
+			now := time.Now()
+
+			require.NoError(t, repo.Save(ctx, &models.CalendarToken{UserName: "asha", TokenHash: "first", CreatedAt: now}))
+			token, err := repo.GetByHash(ctx, "first")
+			require.NoError(t, err)
+			assert.Equal(t, "asha", token.UserName)
+
+			// A new token replaces the previous one
+			require.NoError(t, repo.Save(ctx, &models.CalendarToken{UserName: "asha", TokenHash: "second", CreatedAt: now}))
+			_, err = repo.GetByHash(ctx, "first")
+			assert.ErrorIs(t, err, ErrCalendarTokenNotFound)
+			token, err = repo.GetByHash(ctx, "second")
+			require.NoError(t, err)
+			assert.Equal(t, "asha", token.UserName)
+
+			require.NoError(t, repo.Delete(ctx, "asha"))
+			_, err = repo.GetByHash(ctx, "second")
+			assert.ErrorIs(t, err, ErrCalendarTokenNotFound)
+			assert.ErrorIs(t, repo.Delete(ctx, "asha"), ErrCalendarTokenNotFound)
+		})
+	}
+}
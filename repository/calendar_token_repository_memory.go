@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"sync"
+
+	"github.com/sarita-growexx/note_with_alarm/models"
+)
+
+// MemoryCalendarTokenRepository is a CalendarTokenRepository that keeps
+// tokens in memory, for tests and single instance deployments. It is safe
+// for concurrent use.
+type MemoryCalendarTokenRepository struct {
+	mu     sync.Mutex
+	tokens map[string]models.CalendarToken
+}
+
+func NewMemoryCalendarTokenRepository() CalendarTokenRepository {
+	return &MemoryCalendarTokenRepository{tokens: make(map[string]models.CalendarToken)}
+}
+
+// Save implements CalendarTokenRepository.
+func (r *MemoryCalendarTokenRepository) Save(ctx context.Context, token *models.CalendarToken) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.tokens[token.UserName] = *token
+	return nil
+}
+
+// GetByHash implements CalendarTokenRepository.
+func (r *MemoryCalendarTokenRepository) GetByHash(ctx context.Context, tokenHash string) (*models.CalendarToken, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, token := range r.tokens {
+		if token.TokenHash == tokenHash {
+			return &token, nil
+		}
+	}
+	return nil, ErrCalendarTokenNotFound
+}
+
+// Delete implements CalendarTokenRepository.
+func (r *MemoryCalendarTokenRepository) Delete(ctx context.Context, userName string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.tokens[userName]; !ok {
+		return ErrCalendarTokenNotFound
+	}
+	delete(r.tokens, userName)
+	return nil
+}
@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/sarita-growexx/note_with_alarm/apperrors"
+	"gorm.io/gorm"
+)
+
+const (
+	// pgUniqueViolation is the Postgres SQLSTATE for a unique constraint violation.
+	pgUniqueViolation = "23505"
+	// sqliteConstraintUnique and sqliteConstraintPrimaryKey are SQLite's
+	// extended result codes for the same, and for a duplicate primary key,
+	// which Postgres reports as a unique violation too.
+	sqliteConstraintUnique     = 2067
+	sqliteConstraintPrimaryKey = 1555
+)
+
+// isSQLite reports whether db talks to SQLite rather than Postgres.
+func isSQLite(db *gorm.DB) bool {
+	return db.Dialector.Name() == "sqlite"
+}
+
+// timeExpr wraps a time column or placeholder so that it compares and sorts
+// chronologically. SQLite stores times as text carrying their UTC offset,
+// which only orders correctly once converted to a Julian day number.
+func timeExpr(db *gorm.DB, expr string) string {
+	if isSQLite(db) {
+		return "julianday(" + expr + ")"
+	}
+	return expr
+}
+
+// isUniqueViolation reports whether err was raised by a unique index, such as
+// the one on note titles, or a primary key.
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == pgUniqueViolation
+	}
+
+	var sqliteErr interface{ Code() int }
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+	return sqliteErr.Code() == sqliteConstraintUnique || sqliteErr.Code() == sqliteConstraintPrimaryKey
+}
+
+// dbError translates an error from gorm or the database driver. Queries cut
+// short by their context are timeouts or cancellations, and unique violations
+// are reported as unique, the repository's error for the only unique index
+// of its table; anything else is an internal error.
+func dbError(err error, unique error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, context.DeadlineExceeded):
+		return apperrors.Wrap(apperrors.Timeout, "the database did not answer in time", err)
+	case errors.Is(err, context.Canceled):
+		return apperrors.Wrap(apperrors.Canceled, "the request was canceled", err)
+	case unique != nil && isUniqueViolation(err):
+		return unique
+	default:
+		return apperrors.Wrap(apperrors.Internal, "database error", err)
+	}
+}
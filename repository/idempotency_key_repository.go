@@ -0,0 +1,34 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/sarita-growexx/note_with_alarm/models"
+)
+
+// IdempotencyKeyRepository stores the requests made with an Idempotency-Key
+// header and their responses.
+type IdempotencyKeyRepository interface {
+	// Reserve records a request that is starting. It returns
+	// ErrIdempotencyKeyExists when the key is already recorded.
+	Reserve(ctx context.Context, key *models.IdempotencyKey) error
+	// TakeOver reserves key.Key for a new request in place of a request
+	// that reserved it before staleBefore and never completed. It returns
+	// ErrIdempotencyKeyExists when there is no such reservation, such as
+	// when another request took the key over first.
+	TakeOver(ctx context.Context, key *models.IdempotencyKey, staleBefore time.Time) error
+	// Complete stores the response of the request that reserved key.Key
+	// under key.Lease. It returns ErrIdempotencyKeyLost when the request no
+	// longer holds the key, such as when a retry took it over.
+	Complete(ctx context.Context, key *models.IdempotencyKey) error
+	// Release deletes the reservation of key.Key made under key.Lease, so
+	// that the request can be retried. A request that no longer holds the
+	// key leaves it alone.
+	Release(ctx context.Context, key *models.IdempotencyKey) error
+	Get(ctx context.Context, key string) (*models.IdempotencyKey, error)
+	Delete(ctx context.Context, key string) error
+	// DeleteExpired deletes the keys that expired before now and returns
+	// how many there were.
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
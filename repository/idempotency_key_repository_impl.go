@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sarita-growexx/note_with_alarm/apperrors"
+	"github.com/sarita-growexx/note_with_alarm/models"
+	"gorm.io/gorm"
+)
+
+var ErrIdempotencyKeyNotFound = apperrors.New(apperrors.NotFound, "idempotency key not found")
+var ErrIdempotencyKeyExists = apperrors.New(apperrors.Conflict, "a request with this idempotency key is already in progress")
+var ErrIdempotencyKeyLost = apperrors.New(apperrors.Conflict, "the idempotency key is no longer held by this request")
+
+type IdempotencyKeyRepositoryImpl struct {
+	db *gorm.DB
+}
+
+func NewIdempotencyKeyRepository(db *gorm.DB) IdempotencyKeyRepository {
+	return &IdempotencyKeyRepositoryImpl{db: db}
+}
+
+// Reserve implements IdempotencyKeyRepository.
+func (r *IdempotencyKeyRepositoryImpl) Reserve(ctx context.Context, key *models.IdempotencyKey) error {
+	return dbError(r.db.WithContext(ctx).Create(key).Error, ErrIdempotencyKeyExists)
+}
+
+// TakeOver implements IdempotencyKeyRepository.
+func (r *IdempotencyKeyRepositoryImpl) TakeOver(ctx context.Context, key *models.IdempotencyKey, staleBefore time.Time) error {
+	result := r.db.WithContext(ctx).Model(&models.IdempotencyKey{}).
+		Where("key = ? AND status = 0", key.Key).
+		Where(timeExpr(r.db, "created_at")+" < "+timeExpr(r.db, "?"), staleBefore).
+		Updates(map[string]interface{}{
+			"lease":        key.Lease,
+			"request_hash": key.RequestHash,
+			"created_at":   key.CreatedAt,
+			"expires_at":   key.ExpiresAt,
+		})
+	if result.Error != nil {
+		return dbError(result.Error, nil)
+	}
+	if result.RowsAffected == 0 {
+		return ErrIdempotencyKeyExists
+	}
+	return nil
+}
+
+// Complete implements IdempotencyKeyRepository.
+func (r *IdempotencyKeyRepositoryImpl) Complete(ctx context.Context, key *models.IdempotencyKey) error {
+	result := r.db.WithContext(ctx).Model(&models.IdempotencyKey{}).
+		Where("key = ? AND lease = ? AND status = 0", key.Key, key.Lease).
+		Updates(map[string]interface{}{
+			"status": key.Status,
+			"header": key.Header,
+			"body":   key.Body,
+		})
+	if result.Error != nil {
+		return dbError(result.Error, nil)
+	}
+	if result.RowsAffected == 0 {
+		return ErrIdempotencyKeyLost
+	}
+	return nil
+}
+
+// Release implements IdempotencyKeyRepository.
+func (r *IdempotencyKeyRepositoryImpl) Release(ctx context.Context, key *models.IdempotencyKey) error {
+	return dbError(r.db.WithContext(ctx).Where("key = ? AND lease = ? AND status = 0", key.Key, key.Lease).Delete(&models.IdempotencyKey{}).Error, nil)
+}
+
+// Get implements IdempotencyKeyRepository.
+func (r *IdempotencyKeyRepositoryImpl) Get(ctx context.Context, key string) (*models.IdempotencyKey, error) {
+	var stored models.IdempotencyKey
+	err := r.db.WithContext(ctx).Where("key = ?", key).First(&stored).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrIdempotencyKeyNotFound
+	}
+	if err != nil {
+		return nil, dbError(err, nil)
+	}
+	return &stored, nil
+}
+
+// Delete implements IdempotencyKeyRepository. Deleting a key that does not
+// exist is not an error.
+func (r *IdempotencyKeyRepositoryImpl) Delete(ctx context.Context, key string) error {
+	return dbError(r.db.WithContext(ctx).Where("key = ?", key).Delete(&models.IdempotencyKey{}).Error, nil)
+}
+
+// DeleteExpired implements IdempotencyKeyRepository.
+func (r *IdempotencyKeyRepositoryImpl) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where(timeExpr(r.db, "expires_at")+" < "+timeExpr(r.db, "?"), now).
+		Delete(&models.IdempotencyKey{})
+	if result.Error != nil {
+		return 0, dbError(result.Error, nil)
+	}
+	return result.RowsAffected, nil
+}
@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sarita-growexx/note_with_alarm/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotencyKeyRepository(t *testing.T) {
+	implementations := map[string]func(t *testing.T) IdempotencyKeyRepository{
+		"sql": func(t *testing.T) IdempotencyKeyRepository {
+			db, cleanup := setupTestDB()
+			t.Cleanup(cleanup)
+			return NewIdempotencyKeyRepository(db)
+		},
+		"memory": func(t *testing.T) IdempotencyKeyRepository {
+			return NewMemoryIdempotencyKeyRepository()
+		},
+	}
+
+	for implementation, newRepo := range implementations {
+		t.Run(implementation, func(t *testing.T) {
+			ctx := context.Background()
+			repo := newRepo(t)
+			now := time.Now()
+
+			key := &models.IdempotencyKey{Key: "retry-1", Lease: "first", RequestHash: "abc", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}
+			require.NoError(t, repo.Reserve(ctx, key))
+			assert.ErrorIs(t, repo.Reserve(ctx, &models.IdempotencyKey{Key: "retry-1", RequestHash: "def", CreatedAt: now, ExpiresAt: now.Add(time.Hour)}), ErrIdempotencyKeyExists)
+
+			stored, err := repo.Get(ctx, "retry-1")
+			require.NoError(t, err)
+			assert.Equal(t, "abc", stored.RequestHash)
+			assert.Zero(t, stored.Status)
+
+			// Only a reservation made before staleBefore is taken over, and
+			// only once
+			later := &models.IdempotencyKey{Key: "retry-1", Lease: "second", RequestHash: "abc", CreatedAt: now.Add(time.Minute), ExpiresAt: now.Add(time.Hour)}
+			assert.ErrorIs(t, repo.TakeOver(ctx, later, now), ErrIdempotencyKeyExists)
+			require.NoError(t, repo.TakeOver(ctx, later, now.Add(time.Second)))
+			assert.ErrorIs(t, repo.TakeOver(ctx, later, now.Add(time.Second)), ErrIdempotencyKeyExists)
+			assert.ErrorIs(t, repo.TakeOver(ctx, &models.IdempotencyKey{Key: "missing"}, now), ErrIdempotencyKeyExists)
+
+			// The request the key was taken from can neither complete nor
+			// release it
+			key.Status = 201
+			assert.ErrorIs(t, repo.Complete(ctx, key), ErrIdempotencyKeyLost)
+			require.NoError(t, repo.Release(ctx, key))
+			stored, err = repo.Get(ctx, "retry-1")
+			require.NoError(t, err)
+			assert.Equal(t, "second", stored.Lease)
+			assert.Zero(t, stored.Status)
+			key = later
+
+			key.Status = 201
+			key.Header = models.Header{"Content-Type": {"application/json"}, "Etag": {`"1"`}}
+			key.Body = []byte(`{"id":1}`)
+			require.NoError(t, repo.Complete(ctx, key))
+			stored, err = repo.Get(ctx, "retry-1")
+			require.NoError(t, err)
+			assert.Equal(t, 201, stored.Status)
+			assert.Equal(t, key.Header, stored.Header)
+			assert.Equal(t, []byte(`{"id":1}`), stored.Body)
+			assert.ErrorIs(t, repo.Complete(ctx, &models.IdempotencyKey{Key: "missing"}), ErrIdempotencyKeyLost)
+			assert.ErrorIs(t, repo.Complete(ctx, key), ErrIdempotencyKeyLost, "a response is stored once")
+
+			require.NoError(t, repo.Reserve(ctx, &models.IdempotencyKey{Key: "expired", RequestHash: "abc", CreatedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(-time.Hour)}))
+			deleted, err := repo.DeleteExpired(ctx, now)
+			require.NoError(t, err)
+			assert.Equal(t, int64(1), deleted)
+			_, err = repo.Get(ctx, "expired")
+			assert.ErrorIs(t, err, ErrIdempotencyKeyNotFound)
+
+			require.NoError(t, repo.Delete(ctx, "retry-1"))
+			_, err = repo.Get(ctx, "retry-1")
+			assert.ErrorIs(t, err, ErrIdempotencyKeyNotFound)
+			assert.NoError(t, repo.Delete(ctx, "retry-1"))
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/sarita-growexx/note_with_alarm/models"
+)
+
+// MemoryIdempotencyKeyRepository is an IdempotencyKeyRepository that keeps
+// keys in memory, for tests and single instance deployments. It is safe for
+// concurrent use.
+type MemoryIdempotencyKeyRepository struct {
+	mu   sync.Mutex
+	keys map[string]models.IdempotencyKey
+}
+
+func NewMemoryIdempotencyKeyRepository() IdempotencyKeyRepository {
+	return &MemoryIdempotencyKeyRepository{keys: make(map[string]models.IdempotencyKey)}
+}
+
+// Reserve implements IdempotencyKeyRepository.
+func (r *MemoryIdempotencyKeyRepository) Reserve(ctx context.Context, key *models.IdempotencyKey) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.keys[key.Key]; ok {
+		return ErrIdempotencyKeyExists
+	}
+	r.keys[key.Key] = copyIdempotencyKey(*key)
+	return nil
+}
+
+// TakeOver implements IdempotencyKeyRepository.
+func (r *MemoryIdempotencyKeyRepository) TakeOver(ctx context.Context, key *models.IdempotencyKey, staleBefore time.Time) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.keys[key.Key]
+	if !ok || stored.Status != 0 || !stored.CreatedAt.Before(staleBefore) {
+		return ErrIdempotencyKeyExists
+	}
+	r.keys[key.Key] = copyIdempotencyKey(*key)
+	return nil
+}
+
+// Complete implements IdempotencyKeyRepository.
+func (r *MemoryIdempotencyKeyRepository) Complete(ctx context.Context, key *models.IdempotencyKey) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.keys[key.Key]
+	if !ok || stored.Lease != key.Lease || stored.Status != 0 {
+		return ErrIdempotencyKeyLost
+	}
+	stored.Status = key.Status
+	stored.Header = key.Header
+	stored.Body = key.Body
+	r.keys[key.Key] = copyIdempotencyKey(stored)
+	return nil
+}
+
+// Release implements IdempotencyKeyRepository.
+func (r *MemoryIdempotencyKeyRepository) Release(ctx context.Context, key *models.IdempotencyKey) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if stored, ok := r.keys[key.Key]; ok && stored.Lease == key.Lease && stored.Status == 0 {
+		delete(r.keys, key.Key)
+	}
+	return nil
+}
+
+// Get implements IdempotencyKeyRepository.
+func (r *MemoryIdempotencyKeyRepository) Get(ctx context.Context, key string) (*models.IdempotencyKey, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.keys[key]
+	if !ok {
+		return nil, ErrIdempotencyKeyNotFound
+	}
+	stored = copyIdempotencyKey(stored)
+	return &stored, nil
+}
+
+// Delete implements IdempotencyKeyRepository.
+func (r *MemoryIdempotencyKeyRepository) Delete(ctx context.Context, key string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.keys, key)
+	return nil
+}
+
+// DeleteExpired implements IdempotencyKeyRepository.
+func (r *MemoryIdempotencyKeyRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var deleted int64
+	for key, stored := range r.keys {
+		if stored.ExpiresAt.Before(now) {
+			delete(r.keys, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// copyIdempotencyKey returns a copy of key that shares no memory with it.
+func copyIdempotencyKey(key models.IdempotencyKey) models.IdempotencyKey {
+	key.Body = slices.Clone(key.Body)
+	if key.Header != nil {
+		header := make(models.Header, len(key.Header))
+		for name, values := range key.Header {
+			header[name] = slices.Clone(values)
+		}
+		key.Header = header
+	}
+	return key
+}
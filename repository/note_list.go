@@ -0,0 +1,210 @@
+package repository
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/sarita-growexx/note_with_alarm/apperrors"
+	"github.com/sarita-growexx/note_with_alarm/models"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultListLimit = 20
+	MaxListLimit     = 100
+)
+
+var ErrInvalidCursor = apperrors.New(apperrors.Validation, "invalid cursor")
+
+// sortColumns maps the sort keys accepted by List to their columns.
+var sortColumns = map[string]string{
+	"deadline":   "deadline",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+	"title":      "title",
+}
+
+// listCursor is the position of the last note on a page. It is bound to the
+// sort it was produced with so it cannot be replayed against another one.
+// Listings filtered on Overdue carry the time of their first page, so that
+// later pages judge overdue notes at the same time and skip or repeat none.
+type listCursor struct {
+	Sort  string `json:"s"`
+	Order string `json:"o"`
+	Value string `json:"v"`
+	ID    uint   `json:"id"`
+	Now   string `json:"n,omitempty"`
+}
+
+// List implements NoteRepository using keyset pagination on (sort column, id).
+func (r *NoteRepositoryImpl) List(ctx context.Context, query models.NoteListQuery) (*models.NotePage, error) {
+	sort, order, limit, err := normalizeListQuery(query)
+	if err != nil {
+		return nil, err
+	}
+	var cursor *listCursor
+	if query.Cursor != "" {
+		if cursor, err = decodeCursor(query.Cursor, sort, order); err != nil {
+			return nil, err
+		}
+	}
+	now, err := listNow(query, cursor)
+	if err != nil {
+		return nil, err
+	}
+	column, placeholder := sortColumns[sort], "?"
+	if sort != "title" {
+		column, placeholder = timeExpr(r.db, column), timeExpr(r.db, "?")
+	}
+
+	deadline, at := timeExpr(r.db, "deadline"), timeExpr(r.db, "?")
+	filtered := r.db.WithContext(ctx).Model(&models.Note{})
+	if query.DeadlineBefore != nil {
+		filtered = filtered.Where(deadline+" < "+at, *query.DeadlineBefore)
+	}
+	if query.DeadlineAfter != nil {
+		filtered = filtered.Where(deadline+" > "+at, *query.DeadlineAfter)
+	}
+	if query.Overdue != nil {
+		if *query.Overdue {
+			filtered = filtered.Where(deadline+" < "+at, now)
+		} else {
+			filtered = filtered.Where(deadline+" >= "+at, now)
+		}
+	}
+	if query.Tag != "" {
+		condition, args := tagCondition(r.db, query.Tag)
+		filtered = filtered.Where(condition, args...)
+	}
+
+	var total int64
+	if !query.SkipTotal {
+		if err := filtered.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+			return nil, dbError(err, nil)
+		}
+	}
+
+	page := filtered.Session(&gorm.Session{})
+	if cursor != nil {
+		value, err := cursorValue(sort, cursor.Value)
+		if err != nil {
+			return nil, err
+		}
+
+		cmp := ">"
+		if order == "desc" {
+			cmp = "<"
+		}
+		page = page.Where(fmt.Sprintf("(%[1]s %[2]s %[3]s OR (%[1]s = %[3]s AND id %[2]s ?))", column, cmp, placeholder), value, value, cursor.ID)
+	}
+
+	var notes []*models.Note
+	err = page.Order(fmt.Sprintf("%s %s, id %s", column, order, order)).Limit(limit + 1).Find(&notes).Error
+	if err != nil {
+		return nil, dbError(err, nil)
+	}
+
+	result := &models.NotePage{Notes: notes, Total: total}
+	if len(notes) > limit {
+		result.Notes = notes[:limit]
+		result.NextCursor = encodeCursor(sort, order, result.Notes[limit-1], query, now)
+	}
+	return result, nil
+}
+
+// listNow returns the time the Overdue filter of query is judged at: the
+// time carried by its cursor, or else query.Now or the time of the call.
+func listNow(query models.NoteListQuery, cursor *listCursor) (time.Time, error) {
+	switch {
+	case cursor != nil && cursor.Now != "":
+		now, err := time.Parse(time.RFC3339Nano, cursor.Now)
+		if err != nil {
+			return time.Time{}, ErrInvalidCursor
+		}
+		return now, nil
+	case query.Now.IsZero():
+		return time.Now(), nil
+	default:
+		return query.Now, nil
+	}
+}
+
+// normalizeListQuery applies defaults to, and validates, the sort and limit.
+func normalizeListQuery(query models.NoteListQuery) (string, string, int, error) {
+	sort := query.Sort
+	if sort == "" {
+		sort = "created_at"
+	}
+	if _, ok := sortColumns[sort]; !ok {
+		return "", "", 0, apperrors.New(apperrors.Validation, fmt.Sprintf("unsupported sort %q", sort))
+	}
+
+	order := query.Order
+	if order == "" {
+		order = "asc"
+	}
+	if order != "asc" && order != "desc" {
+		return "", "", 0, apperrors.New(apperrors.Validation, fmt.Sprintf("unsupported order %q", order))
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultListLimit
+	}
+	if limit > MaxListLimit {
+		limit = MaxListLimit
+	}
+
+	return sort, order, limit, nil
+}
+
+func encodeCursor(sort, order string, last *models.Note, query models.NoteListQuery, now time.Time) string {
+	cursor := listCursor{Sort: sort, Order: order, ID: last.ID}
+	if query.Overdue != nil {
+		cursor.Now = now.Format(time.RFC3339Nano)
+	}
+	switch sort {
+	case "deadline":
+		cursor.Value = last.Deadline.Format(time.RFC3339Nano)
+	case "created_at":
+		cursor.Value = last.CreatedAt.Format(time.RFC3339Nano)
+	case "updated_at":
+		cursor.Value = last.UpdatedAt.Format(time.RFC3339Nano)
+	case "title":
+		cursor.Value = last.Title
+	}
+
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeCursor(encoded, sort, order string) (*listCursor, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var cursor listCursor
+	if err := json.Unmarshal(raw, &cursor); err != nil {
+		return nil, ErrInvalidCursor
+	}
+	if cursor.Sort != sort || cursor.Order != order {
+		return nil, ErrInvalidCursor
+	}
+	return &cursor, nil
+}
+
+func cursorValue(sort, value string) (interface{}, error) {
+	if sort == "title" {
+		return value, nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return t, nil
+}
@@ -1,15 +1,37 @@
 package repository
 
 import (
+	"context"
 	"github.com/sarita-growexx/note_with_alarm/models"
+	"github.com/sarita-growexx/note_with_alarm/query"
 )
 
 type NoteRepository interface {
-	Create(note *models.Note) error
-	Update(note *models.Note) error
-	Delete(id uint) error
-	GetById(id uint) (*models.Note, error)
-	GetAll() ([]*models.Note, error)
-	Search(query string) ([]*models.Note, error)
-	GetNoteByTitle(title string) (*models.Note, error)
+	// WithinTx runs fn in a database transaction. The repository passed to fn
+	// is bound to the transaction, which commits when fn returns nil and rolls
+	// back otherwise.
+	WithinTx(ctx context.Context, fn func(repo NoteRepository) error) error
+	Create(ctx context.Context, note *models.Note) error
+	// CreateInBatches creates notes, inserting up to batchSize of them per
+	// statement. Either all of them are created or none are.
+	CreateInBatches(ctx context.Context, notes []*models.Note, batchSize int) error
+	Update(ctx context.Context, note *models.Note) error
+	UpdateFields(ctx context.Context, id uint, version uint, fields map[string]interface{}) error
+	Delete(ctx context.Context, id uint, version uint) error
+	GetById(ctx context.Context, id uint) (*models.Note, error)
+	GetAll(ctx context.Context) ([]*models.Note, error)
+	List(ctx context.Context, query models.NoteListQuery) (*models.NotePage, error)
+	Search(ctx context.Context, q *query.Query) ([]*models.NoteSearchResult, error)
+	FuzzySearch(ctx context.Context, query string, threshold float64) ([]*models.NoteSearchResult, error)
+	SuggestTitles(ctx context.Context, prefix string, limit int) ([]string, error)
+	GetNoteByTitle(ctx context.Context, title string) (*models.Note, error)
+	// GetNotesByTitles returns the notes with the given titles, by title, in
+	// one query. Titles no note has are left out.
+	GetNotesByTitles(ctx context.Context, titles []string) (map[string]*models.Note, error)
+	CreateRevision(ctx context.Context, revision *models.NoteRevision) error
+	// CreateRevisionsInBatches records revisions as CreateRevision does,
+	// inserting up to batchSize of them per statement.
+	CreateRevisionsInBatches(ctx context.Context, revisions []*models.NoteRevision, batchSize int) error
+	GetRevisions(ctx context.Context, noteID uint) ([]*models.NoteRevision, error)
+	GetRevision(ctx context.Context, noteID uint, revision uint) (*models.NoteRevision, error)
 }
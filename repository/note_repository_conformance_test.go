@@ -0,0 +1,476 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/sarita-growexx/note_with_alarm/apperrors"
+	"github.com/sarita-growexx/note_with_alarm/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// noteRepositoryFactories build an empty repository for each NoteRepository
+// implementation. Every conformance case runs against all of them.
+var noteRepositoryFactories = map[string]func(t *testing.T) NoteRepository{
+	"sql": func(t *testing.T) NoteRepository {
+		db, cleanup := setupTestDB()
+		t.Cleanup(cleanup)
+		return NewNoteRepository(db)
+	},
+	"memory": func(t *testing.T) NoteRepository {
+		return NewMemoryNoteRepository()
+	},
+}
+
+var noteRepositoryConformance = []struct {
+	name string
+	run  func(t *testing.T, repo NoteRepository)
+}{
+	{"Create", conformCreate},
+	{"CreateInBatches", conformCreateInBatches},
+	{"NotFound", conformNotFound},
+	{"DuplicateTitle", conformDuplicateTitle},
+	{"ConditionalWrites", conformConditionalWrites},
+	{"List", conformList},
+	{"Search", conformSearch},
+	{"FuzzySearch", conformFuzzySearch},
+	{"Revisions", conformRevisions},
+	{"WithinTx", conformWithinTx},
+	{"ContextDone", conformContextDone},
+}
+
+func TestNoteRepositoryConformance(t *testing.T) {
+	for implementation, newRepo := range noteRepositoryFactories {
+		t.Run(implementation, func(t *testing.T) {
+			for _, tc := range noteRepositoryConformance {
+				t.Run(tc.name, func(t *testing.T) {
+					tc.run(t, newRepo(t))
+				})
+			}
+		})
+	}
+}
+
+func conformCreate(t *testing.T, repo NoteRepository) {
+	ctx := context.Background()
+
+	first := &models.Note{Title: "First", Deadline: parseTime(testDateTimeString)}
+	require.NoError(t, repo.Create(ctx, first))
+	second := &models.Note{Title: "Second", Deadline: parseTime(testDateTimeString), Tags: models.Tags{"work"}, Reminders: models.Reminders{60}}
+	require.NoError(t, repo.Create(ctx, second))
+
+	assert.NotZero(t, first.ID)
+	assert.Greater(t, second.ID, first.ID)
+	assert.Equal(t, uint(1), first.Version)
+	assert.WithinDuration(t, time.Now(), first.CreatedAt, time.Minute)
+	assert.WithinDuration(t, time.Now(), first.UpdatedAt, time.Minute)
+
+	stored, err := repo.GetById(ctx, first.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "First", stored.Title)
+	assert.Equal(t, models.Tags{}, stored.Tags)
+	assert.Equal(t, models.Reminders{}, stored.Reminders)
+	assert.True(t, first.Deadline.Equal(stored.Deadline))
+
+	// Callers cannot change stored notes through the values they hold
+	second.Tags[0] = "home"
+	stored, err = repo.GetNoteByTitle(ctx, "Second")
+	require.NoError(t, err)
+	assert.Equal(t, models.Tags{"work"}, stored.Tags)
+	assert.Equal(t, models.Reminders{60}, stored.Reminders)
+
+	all, err := repo.GetAll(ctx)
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+}
+
+func conformCreateInBatches(t *testing.T, repo NoteRepository) {
+	ctx := context.Background()
+
+	existing := &models.Note{Title: "Existing", Deadline: parseTime(testDateTimeString)}
+	require.NoError(t, repo.Create(ctx, existing))
+	require.NoError(t, repo.CreateRevision(ctx, &models.NoteRevision{NoteID: existing.ID, Title: existing.Title, Deadline: existing.Deadline}))
+
+	notes := make([]*models.Note, 5)
+	for i := range notes {
+		notes[i] = &models.Note{Title: fmt.Sprintf("Batch %d", i), Deadline: parseTime(testDateTimeString), Tags: models.Tags{"batch"}}
+	}
+	require.NoError(t, repo.CreateInBatches(ctx, notes, 2))
+	for i, note := range notes {
+		assert.NotZero(t, note.ID)
+		if i > 0 {
+			assert.Greater(t, note.ID, notes[i-1].ID)
+		}
+		stored, err := repo.GetById(ctx, note.ID)
+		require.NoError(t, err)
+		assert.Equal(t, note.Title, stored.Title)
+		assert.Equal(t, models.Tags{"batch"}, stored.Tags)
+	}
+
+	// Revisions are numbered after the latest of each note
+	revisions := []*models.NoteRevision{
+		{NoteID: existing.ID, Title: "Renamed", Deadline: existing.Deadline},
+		{NoteID: notes[0].ID, Title: notes[0].Title, Deadline: notes[0].Deadline},
+		{NoteID: notes[0].ID, Title: "Renamed again", Deadline: notes[0].Deadline},
+	}
+	require.NoError(t, repo.CreateRevisionsInBatches(ctx, revisions, 2))
+	assert.Equal(t, uint(2), revisions[0].Revision)
+	assert.Equal(t, uint(1), revisions[1].Revision)
+	assert.Equal(t, uint(2), revisions[2].Revision)
+	stored, err := repo.GetRevisions(ctx, notes[0].ID)
+	require.NoError(t, err)
+	assert.Len(t, stored, 2)
+
+	// A duplicate title creates none of the notes
+	err = repo.CreateInBatches(ctx, []*models.Note{
+		{Title: "Fresh", Deadline: parseTime(testDateTimeString)},
+		{Title: "Batch 3", Deadline: parseTime(testDateTimeString)},
+	}, 10)
+	assert.ErrorIs(t, err, ErrDuplicateTitle)
+	_, err = repo.GetNoteByTitle(ctx, "Fresh")
+	assert.ErrorIs(t, err, ErrNoteNotFound)
+}
+
+func conformNotFound(t *testing.T, repo NoteRepository) {
+	ctx := context.Background()
+
+	note, err := repo.GetById(ctx, 42)
+	assert.ErrorIs(t, err, ErrNoteNotFound)
+	assert.Nil(t, note)
+
+	note, err = repo.GetNoteByTitle(ctx, "Missing")
+	assert.ErrorIs(t, err, ErrNoteNotFound)
+	assert.Nil(t, note)
+
+	err = repo.UpdateFields(ctx, 42, 1, map[string]interface{}{"description": "gone"})
+	assert.ErrorIs(t, err, ErrNoteNotFound)
+
+	err = repo.Delete(ctx, 42, 1)
+	assert.ErrorIs(t, err, ErrNoteNotFound)
+}
+
+func conformDuplicateTitle(t *testing.T, repo NoteRepository) {
+	ctx := context.Background()
+
+	first := &models.Note{Title: "Taken", Deadline: parseTime(testDateTimeString)}
+	require.NoError(t, repo.Create(ctx, first))
+
+	err := repo.Create(ctx, &models.Note{Title: "Taken", Deadline: parseTime(testDateTimeString)})
+	assert.ErrorIs(t, err, ErrDuplicateTitle)
+
+	second := &models.Note{Title: "Free", Deadline: parseTime(testDateTimeString)}
+	require.NoError(t, repo.Create(ctx, second))
+
+	err = repo.UpdateFields(ctx, second.ID, second.Version, map[string]interface{}{"title": "Taken"})
+	assert.ErrorIs(t, err, ErrDuplicateTitle)
+
+	// Keeping a note's own title is not a conflict
+	err = repo.UpdateFields(ctx, first.ID, first.Version, map[string]interface{}{"title": "Taken", "description": "same title"})
+	assert.NoError(t, err)
+
+	byTitle, err := repo.GetNotesByTitles(ctx, []string{"Taken", "Free", "Missing"})
+	require.NoError(t, err)
+	assert.Len(t, byTitle, 2)
+	assert.Equal(t, first.ID, byTitle["Taken"].ID)
+	assert.Equal(t, second.ID, byTitle["Free"].ID)
+
+	byTitle, err = repo.GetNotesByTitles(ctx, nil)
+	require.NoError(t, err)
+	assert.Empty(t, byTitle)
+}
+
+func conformConditionalWrites(t *testing.T, repo NoteRepository) {
+	ctx := context.Background()
+
+	note := &models.Note{Title: "Versioned", Description: "untouched", Deadline: parseTime(testDateTimeString)}
+	require.NoError(t, repo.Create(ctx, note))
+
+	update := &models.Note{ID: note.ID, Title: "Versioned", Description: "updated", Deadline: note.Deadline, Version: note.Version}
+	require.NoError(t, repo.Update(ctx, update))
+	assert.Equal(t, uint(2), update.Version)
+
+	stale := &models.Note{ID: note.ID, Title: "Versioned", Description: "stale", Deadline: note.Deadline, Version: note.Version}
+	assert.ErrorIs(t, repo.Update(ctx, stale), ErrVersionConflict)
+
+	newDeadline := note.Deadline.Add(24 * time.Hour)
+	require.NoError(t, repo.UpdateFields(ctx, note.ID, 2, map[string]interface{}{"deadline": newDeadline}))
+
+	stored, err := repo.GetById(ctx, note.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "updated", stored.Description)
+	assert.True(t, newDeadline.Equal(stored.Deadline))
+	assert.Equal(t, uint(3), stored.Version)
+	assert.False(t, stored.UpdatedAt.Before(note.UpdatedAt))
+
+	assert.ErrorIs(t, repo.Delete(ctx, note.ID, 2), ErrVersionConflict)
+	require.NoError(t, repo.Delete(ctx, note.ID, 3))
+
+	_, err = repo.GetById(ctx, note.ID)
+	assert.ErrorIs(t, err, ErrNoteNotFound)
+}
+
+func conformList(t *testing.T, repo NoteRepository) {
+	ctx := context.Background()
+
+	base := time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC)
+	overdue := &models.Note{Title: "Overdue", Deadline: time.Now().Add(-time.Hour), Tags: models.Tags{"home"}}
+	require.NoError(t, repo.Create(ctx, overdue))
+	for i := 0; i < 5; i++ {
+		// Pairs of notes share a deadline, so ties are broken by ID
+		note := &models.Note{Title: fmt.Sprintf("Note %d", i), Deadline: base.Add(time.Duration(i/2) * time.Minute)}
+		require.NoError(t, repo.Create(ctx, note))
+	}
+
+	after := base.Add(-time.Second)
+	query := models.NoteListQuery{Limit: 2, Sort: "deadline", Order: "desc", DeadlineAfter: &after}
+
+	var titles []string
+	for {
+		page, err := repo.List(ctx, query)
+		require.NoError(t, err)
+		assert.Equal(t, int64(5), page.Total)
+		assert.LessOrEqual(t, len(page.Notes), 2)
+		for _, note := range page.Notes {
+			titles = append(titles, note.Title)
+		}
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+	assert.Equal(t, []string{"Note 4", "Note 3", "Note 2", "Note 1", "Note 0"}, titles)
+
+	overdueOnly := true
+	page, err := repo.List(ctx, models.NoteListQuery{Overdue: &overdueOnly})
+	require.NoError(t, err)
+	require.Len(t, page.Notes, 1)
+	assert.Equal(t, overdue.ID, page.Notes[0].ID)
+
+	// Later pages judge overdue notes at the time of the first, even when
+	// notes have fallen due since
+	upcoming := false
+	page, err = repo.List(ctx, models.NoteListQuery{Limit: 2, Sort: "deadline", Overdue: &upcoming, Now: base})
+	require.NoError(t, err)
+	require.NotEmpty(t, page.NextCursor)
+	page, err = repo.List(ctx, models.NoteListQuery{Limit: 2, Sort: "deadline", Overdue: &upcoming, Now: base.Add(time.Hour), Cursor: page.NextCursor})
+	require.NoError(t, err)
+	assert.Len(t, page.Notes, 2)
+
+	// Overdue is judged at Now, and SkipTotal spares the count
+	page, err = repo.List(ctx, models.NoteListQuery{Overdue: &overdueOnly, Now: base.Add(time.Minute), SkipTotal: true})
+	require.NoError(t, err)
+	assert.Len(t, page.Notes, 3)
+	assert.Zero(t, page.Total)
+
+	page, err = repo.List(ctx, models.NoteListQuery{Tag: "home"})
+	require.NoError(t, err)
+	require.Len(t, page.Notes, 1)
+	assert.Equal(t, overdue.ID, page.Notes[0].ID)
+	assert.Equal(t, int64(1), page.Total)
+
+	_, err = repo.List(ctx, models.NoteListQuery{Sort: "title", Cursor: query.Cursor})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func conformSearch(t *testing.T, repo NoteRepository) {
+	ctx := context.Background()
+
+	invoice := &models.Note{Title: "Pay invoice", Description: "Electricity bill", Deadline: time.Now().Add(48 * time.Hour), Tags: models.Tags{"home"}}
+	require.NoError(t, repo.Create(ctx, invoice))
+	receipt := &models.Note{Title: "File receipt", Description: "Electricity bill", Deadline: time.Now().Add(-time.Hour), Tags: models.Tags{"work"}}
+	require.NoError(t, repo.Create(ctx, receipt))
+	meeting := &models.Note{Title: "Billing meetings", Description: "Review the invoice process", Deadline: time.Now().Add(30 * 24 * time.Hour), Tags: models.Tags{"work"}}
+	require.NoError(t, repo.Create(ctx, meeting))
+
+	ids := func(results []*models.NoteSearchResult) []uint {
+		var ids []uint
+		for _, result := range results {
+			ids = append(ids, result.ID)
+		}
+		return ids
+	}
+
+	results, err := repo.Search(ctx, mustParse("invoice"))
+	require.NoError(t, err)
+	assert.Equal(t, []uint{invoice.ID, meeting.ID}, ids(results), "title matches rank first")
+	assert.Contains(t, results[0].TitleHighlight, "<b>invoice</b>")
+	assert.Contains(t, results[1].DescriptionHighlight, "<b>invoice</b>")
+
+	results, err = repo.Search(ctx, mustParse("meeting"))
+	require.NoError(t, err)
+	assert.Equal(t, []uint{meeting.ID}, ids(results), "words match regardless of plural")
+
+	results, err = repo.Search(ctx, mustParse(`"electricity bill" invoice OR receipt`))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uint{invoice.ID, receipt.ID}, ids(results))
+
+	results, err = repo.Search(ctx, mustParse("bill -receipt"))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uint{invoice.ID, meeting.ID}, ids(results), "billing stems to bill")
+
+	results, err = repo.Search(ctx, mustParse("tag:work status:open"))
+	require.NoError(t, err)
+	assert.Equal(t, []uint{meeting.ID}, ids(results))
+
+	results, err = repo.Search(ctx, mustParse("-tag:work due:<7d"))
+	require.NoError(t, err)
+	assert.Equal(t, []uint{invoice.ID}, ids(results))
+
+	// Dates are calendar days in the location of the query: a note due at
+	// 02:00 IST on a day is due on that day, not the one before in UTC
+	ist := time.FixedZone("IST", 5*3600+1800)
+	early := &models.Note{Title: "Early call", Deadline: time.Date(2099, 10, 18, 2, 0, 0, 0, ist)}
+	require.NoError(t, repo.Create(ctx, early))
+	q := mustParse("due:2099-10-18")
+	q.Location = ist
+	results, err = repo.Search(ctx, q)
+	require.NoError(t, err)
+	assert.Equal(t, []uint{early.ID}, ids(results))
+	q = mustParse("due:2099-10-17")
+	q.Location = ist
+	results, err = repo.Search(ctx, q)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+	require.NoError(t, repo.Delete(ctx, early.ID, early.Version))
+
+	q = mustParse("")
+	q.Sort = "-deadline"
+	results, err = repo.Search(ctx, q)
+	require.NoError(t, err)
+	assert.Equal(t, []uint{meeting.ID, invoice.ID, receipt.ID}, ids(results))
+
+	q.Sort = "title"
+	results, err = repo.Search(ctx, q)
+	require.NoError(t, err)
+	assert.Equal(t, []uint{meeting.ID, receipt.ID, invoice.ID}, ids(results))
+
+	// Highlights are HTML, in which only the marks of matches are markup
+	markup := &models.Note{Title: `Fish & <i>chips</i>`, Description: `<script>alert("chips")</script>`, Deadline: time.Now().Add(time.Hour)}
+	require.NoError(t, repo.Create(ctx, markup))
+	results, err = repo.Search(ctx, mustParse("chips"))
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, `Fish &amp; &lt;i&gt;<b>chips</b>&lt;/i&gt;`, results[0].TitleHighlight)
+	assert.Equal(t, `&lt;script&gt;alert(&#34;<b>chips</b>&#34;)&lt;/script&gt;`, results[0].DescriptionHighlight)
+}
+
+func conformFuzzySearch(t *testing.T, repo NoteRepository) {
+	ctx := context.Background()
+
+	review := &models.Note{Title: "Architecture review", Deadline: parseTime(testDateTimeString)}
+	require.NoError(t, repo.Create(ctx, review))
+	require.NoError(t, repo.Create(ctx, &models.Note{Title: "Architecture board", Deadline: parseTime(testDateTimeString)}))
+	require.NoError(t, repo.Create(ctx, &models.Note{Title: "Grocery list", Deadline: parseTime(testDateTimeString)}))
+
+	results, err := repo.FuzzySearch(ctx, "Architecure reveiw", 0.3)
+	require.NoError(t, err)
+	require.NotEmpty(t, results)
+	assert.Equal(t, review.ID, results[0].ID)
+	assert.Greater(t, results[0].Similarity, 0.3)
+
+	results, err = repo.FuzzySearch(ctx, "Architecure reveiw", 0.99)
+	require.NoError(t, err)
+	assert.Empty(t, results)
+
+	titles, err := repo.SuggestTitles(ctx, "architecture", 10)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"Architecture review", "Architecture board"}, titles)
+
+	titles, err = repo.SuggestTitles(ctx, "architecture", 1)
+	require.NoError(t, err)
+	assert.Len(t, titles, 1)
+
+	titles, err = repo.SuggestTitles(ctx, "%list", 10)
+	require.NoError(t, err)
+	assert.Empty(t, titles)
+}
+
+func conformRevisions(t *testing.T, repo NoteRepository) {
+	ctx := context.Background()
+
+	note := &models.Note{Title: "Revised", Deadline: parseTime(testDateTimeString)}
+	require.NoError(t, repo.Create(ctx, note))
+	other := &models.Note{Title: "Other", Deadline: parseTime(testDateTimeString)}
+	require.NoError(t, repo.Create(ctx, other))
+
+	first := &models.NoteRevision{NoteID: note.ID, Title: note.Title, Deadline: note.Deadline, ChangedFields: []string{"title", "deadline"}}
+	require.NoError(t, repo.CreateRevision(ctx, first))
+	otherFirst := &models.NoteRevision{NoteID: other.ID, Title: other.Title, Deadline: other.Deadline}
+	require.NoError(t, repo.CreateRevision(ctx, otherFirst))
+	second := &models.NoteRevision{NoteID: note.ID, Title: "Renamed", Deadline: note.Deadline, ChangedFields: []string{"title"}, ChangedBy: "alice"}
+	require.NoError(t, repo.CreateRevision(ctx, second))
+
+	// Revisions are numbered per note
+	assert.Equal(t, uint(1), first.Revision)
+	assert.Equal(t, uint(1), otherFirst.Revision)
+	assert.Equal(t, uint(2), second.Revision)
+
+	revisions, err := repo.GetRevisions(ctx, note.ID)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, []string{"title"}, revisions[1].ChangedFields)
+
+	revision, err := repo.GetRevision(ctx, note.ID, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", revision.ChangedBy)
+
+	_, err = repo.GetRevision(ctx, note.ID, 3)
+	assert.ErrorIs(t, err, ErrRevisionNotFound)
+
+	revisions, err = repo.GetRevisions(ctx, 42)
+	require.NoError(t, err)
+	assert.Empty(t, revisions)
+}
+
+func conformWithinTx(t *testing.T, repo NoteRepository) {
+	ctx := context.Background()
+
+	existing := &models.Note{Title: "Existing", Deadline: parseTime(testDateTimeString)}
+	require.NoError(t, repo.Create(ctx, existing))
+
+	rollback := errors.New("rollback")
+	err := repo.WithinTx(ctx, func(tx NoteRepository) error {
+		require.NoError(t, tx.Create(ctx, &models.Note{Title: "Rolled back", Deadline: parseTime(testDateTimeString)}))
+		require.NoError(t, tx.UpdateFields(ctx, existing.ID, existing.Version, map[string]interface{}{"description": "rolled back"}))
+		return rollback
+	})
+	assert.ErrorIs(t, err, rollback)
+
+	_, err = repo.GetNoteByTitle(ctx, "Rolled back")
+	assert.ErrorIs(t, err, ErrNoteNotFound)
+	stored, err := repo.GetById(ctx, existing.ID)
+	require.NoError(t, err)
+	assert.Empty(t, stored.Description)
+	assert.Equal(t, existing.Version, stored.Version)
+
+	note := &models.Note{Title: "Committed", Deadline: parseTime(testDateTimeString)}
+	err = repo.WithinTx(ctx, func(tx NoteRepository) error {
+		if err := tx.Create(ctx, note); err != nil {
+			return err
+		}
+		return tx.CreateRevision(ctx, &models.NoteRevision{NoteID: note.ID, Title: note.Title, Deadline: note.Deadline})
+	})
+	require.NoError(t, err)
+
+	_, err = repo.GetNoteByTitle(ctx, "Committed")
+	assert.NoError(t, err)
+	revisions, err := repo.GetRevisions(ctx, note.ID)
+	require.NoError(t, err)
+	assert.Len(t, revisions, 1)
+}
+
+func conformContextDone(t *testing.T, repo NoteRepository) {
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	_, err := repo.List(expired, models.NoteListQuery{})
+	assert.Equal(t, apperrors.Timeout, apperrors.KindOf(err))
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = repo.GetAll(canceled)
+	assert.Equal(t, apperrors.Canceled, apperrors.KindOf(err))
+}
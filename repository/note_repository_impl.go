@@ -1,14 +1,18 @@
 package repository
 
 import (
+	"context"
 	"errors"
-	"fmt"
 
+	"github.com/sarita-growexx/note_with_alarm/apperrors"
 	"github.com/sarita-growexx/note_with_alarm/models"
 	"gorm.io/gorm"
 )
 
-var ErrNoteNotFound = errors.New("note not found")
+var ErrNoteNotFound = apperrors.New(apperrors.NotFound, "note not found")
+var ErrRevisionNotFound = apperrors.New(apperrors.NotFound, "revision not found")
+var ErrVersionConflict = apperrors.New(apperrors.PreconditionFailed, "note has been modified, fetch the latest version and retry")
+var ErrDuplicateTitle = apperrors.New(apperrors.Conflict, "duplicate title, please choose a different title")
 
 type NoteRepositoryImpl struct {
 	db *gorm.DB
@@ -18,50 +22,210 @@ func NewNoteRepository(db *gorm.DB) NoteRepository {
 	return &NoteRepositoryImpl{db: db}
 }
 
+// WithinTx implements NoteRepository.
+func (n *NoteRepositoryImpl) WithinTx(ctx context.Context, fn func(repo NoteRepository) error) error {
+	return n.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(&NoteRepositoryImpl{db: tx})
+	})
+}
+
 // Create implements NoteRepository.
-func (n *NoteRepositoryImpl) Create(note *models.Note) error {
-	return n.db.Create(note).Error
+func (n *NoteRepositoryImpl) Create(ctx context.Context, note *models.Note) error {
+	return dbError(n.db.WithContext(ctx).Create(note).Error, ErrDuplicateTitle)
 }
 
-// Delete implements NoteRepository.
-func (n *NoteRepositoryImpl) Delete(id uint) error {
-	return n.db.Delete(&models.Note{}, id).Error
+// CreateInBatches implements NoteRepository.
+func (n *NoteRepositoryImpl) CreateInBatches(ctx context.Context, notes []*models.Note, batchSize int) error {
+	if len(notes) == 0 {
+		return nil
+	}
+	return dbError(n.db.WithContext(ctx).CreateInBatches(notes, batchSize).Error, ErrDuplicateTitle)
+}
+
+// Delete implements NoteRepository. The note is only deleted while it is
+// still at the given version.
+func (n *NoteRepositoryImpl) Delete(ctx context.Context, id uint, version uint) error {
+	result := n.db.WithContext(ctx).Where("version = ?", version).Delete(&models.Note{}, id)
+	if result.Error != nil {
+		return dbError(result.Error, nil)
+	}
+	if result.RowsAffected == 0 {
+		return n.missingOrConflict(ctx, id)
+	}
+	return nil
 }
 
 // GetAll implements NoteRepository.
-func (r *NoteRepositoryImpl) GetAll() ([]*models.Note, error) {
+func (r *NoteRepositoryImpl) GetAll(ctx context.Context) ([]*models.Note, error) {
 	var notes []*models.Note
-	if err := r.db.Find(&notes).Error; err != nil {
-		return nil, err
+	if err := r.db.WithContext(ctx).Find(&notes).Error; err != nil {
+		return nil, dbError(err, nil)
 	}
 	return notes, nil
 }
 
 // GetById implements NoteRepository.
-func (n *NoteRepositoryImpl) GetById(id uint) (*models.Note, error) {
+func (n *NoteRepositoryImpl) GetById(ctx context.Context, id uint) (*models.Note, error) {
 	var note models.Note
-	err := n.db.First(&note, id).Error
-	return &note, err
+	err := n.db.WithContext(ctx).First(&note, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNoteNotFound
+	}
+	if err != nil {
+		return nil, dbError(err, nil)
+	}
+	return &note, nil
 }
 
-// Search implements NoteRepository.
-func (n *NoteRepositoryImpl) Search(query string) ([]*models.Note, error) {
-	var notes []*models.Note
-	err := n.db.Where("title LIKE ? OR description LIKE ?", "%"+query+"%", "%"+query+"%").Find(&notes).Error
-	fmt.Println("Notes :: ", notes)
-	return notes, err
+// Update implements NoteRepository. The update only applies while the stored
+// note is still at note.Version, which is incremented on success.
+func (n *NoteRepositoryImpl) Update(ctx context.Context, note *models.Note) error {
+	err := n.UpdateFields(ctx, note.ID, note.Version, map[string]interface{}{
+		"title":       note.Title,
+		"description": note.Description,
+		"deadline":    note.Deadline,
+		"tags":        note.Tags,
+		"reminders":   note.Reminders,
+		"updated_at":  note.UpdatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	note.Version++
+	return nil
 }
 
-// Update implements NoteRepository.
-func (n *NoteRepositoryImpl) Update(note *models.Note) error {
-	return n.db.Save(note).Error
+// UpdateFields implements NoteRepository. Only the given columns are written,
+// and only while the stored note is still at version.
+func (n *NoteRepositoryImpl) UpdateFields(ctx context.Context, id uint, version uint, fields map[string]interface{}) error {
+	columns := make(map[string]interface{}, len(fields)+1)
+	for column, value := range fields {
+		columns[column] = value
+	}
+	columns["version"] = gorm.Expr("version + 1")
+
+	result := n.db.WithContext(ctx).Model(&models.Note{}).
+		Where("id = ? AND version = ?", id, version).
+		Updates(columns)
+	if result.Error != nil {
+		return dbError(result.Error, ErrDuplicateTitle)
+	}
+	if result.RowsAffected == 0 {
+		return n.missingOrConflict(ctx, id)
+	}
+	return nil
 }
 
-func (r *NoteRepositoryImpl) GetNoteByTitle(title string) (*models.Note, error) {
+// missingOrConflict explains why a conditional write matched no rows.
+func (n *NoteRepositoryImpl) missingOrConflict(ctx context.Context, id uint) error {
+	var count int64
+	if err := n.db.WithContext(ctx).Model(&models.Note{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return dbError(err, nil)
+	}
+	if count == 0 {
+		return ErrNoteNotFound
+	}
+	return ErrVersionConflict
+}
+
+func (r *NoteRepositoryImpl) GetNoteByTitle(ctx context.Context, title string) (*models.Note, error) {
 	var note models.Note
-	err := r.db.Where("title = ?", title).First(&note).Error
+	err := r.db.WithContext(ctx).Where("title = ?", title).First(&note).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNoteNotFound
+	}
 	if err != nil {
-		return nil, err
+		return nil, dbError(err, nil)
 	}
 	return &note, nil
 }
+
+// GetNotesByTitles implements NoteRepository.
+func (r *NoteRepositoryImpl) GetNotesByTitles(ctx context.Context, titles []string) (map[string]*models.Note, error) {
+	notes := make(map[string]*models.Note)
+	if len(titles) == 0 {
+		return notes, nil
+	}
+	var found []*models.Note
+	if err := r.db.WithContext(ctx).Where("title IN ?", titles).Find(&found).Error; err != nil {
+		return nil, dbError(err, nil)
+	}
+	for _, note := range found {
+		notes[note.Title] = note
+	}
+	return notes, nil
+}
+
+// CreateRevision implements NoteRepository. The revision number is assigned
+// as the next number in sequence for the note.
+func (r *NoteRepositoryImpl) CreateRevision(ctx context.Context, revision *models.NoteRevision) error {
+	var latest uint
+	err := r.db.WithContext(ctx).Model(&models.NoteRevision{}).
+		Where("note_id = ?", revision.NoteID).
+		Select("COALESCE(MAX(revision), 0)").
+		Scan(&latest).Error
+	if err != nil {
+		return dbError(err, nil)
+	}
+
+	revision.Revision = latest + 1
+	return dbError(r.db.WithContext(ctx).Create(revision).Error, nil)
+}
+
+// CreateRevisionsInBatches implements NoteRepository. The latest revision
+// number of every note involved is read in one query.
+func (r *NoteRepositoryImpl) CreateRevisionsInBatches(ctx context.Context, revisions []*models.NoteRevision, batchSize int) error {
+	if len(revisions) == 0 {
+		return nil
+	}
+
+	noteIDs := make([]uint, 0, len(revisions))
+	for _, revision := range revisions {
+		noteIDs = append(noteIDs, revision.NoteID)
+	}
+	var latest []struct {
+		NoteID   uint
+		Revision uint
+	}
+	err := r.db.WithContext(ctx).Model(&models.NoteRevision{}).
+		Where("note_id IN ?", noteIDs).
+		Group("note_id").
+		Select("note_id, MAX(revision) AS revision").
+		Scan(&latest).Error
+	if err != nil {
+		return dbError(err, nil)
+	}
+
+	next := make(map[uint]uint, len(latest))
+	for _, l := range latest {
+		next[l.NoteID] = l.Revision
+	}
+	for _, revision := range revisions {
+		next[revision.NoteID]++
+		revision.Revision = next[revision.NoteID]
+	}
+	return dbError(r.db.WithContext(ctx).CreateInBatches(revisions, batchSize).Error, nil)
+}
+
+// GetRevisions implements NoteRepository.
+func (r *NoteRepositoryImpl) GetRevisions(ctx context.Context, noteID uint) ([]*models.NoteRevision, error) {
+	var revisions []*models.NoteRevision
+	if err := r.db.WithContext(ctx).Where("note_id = ?", noteID).Order("revision").Find(&revisions).Error; err != nil {
+		return nil, dbError(err, nil)
+	}
+	return revisions, nil
+}
+
+// GetRevision implements NoteRepository.
+func (r *NoteRepositoryImpl) GetRevision(ctx context.Context, noteID uint, revision uint) (*models.NoteRevision, error) {
+	var rev models.NoteRevision
+	err := r.db.WithContext(ctx).Where("note_id = ? AND revision = ?", noteID, revision).First(&rev).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrRevisionNotFound
+	}
+	if err != nil {
+		return nil, dbError(err, nil)
+	}
+	return &rev, nil
+}
@@ -1,11 +1,18 @@
 package repository
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/sarita-growexx/note_with_alarm/config"
+	"github.com/sarita-growexx/note_with_alarm/migrations"
 	"github.com/sarita-growexx/note_with_alarm/models"
+	"github.com/sarita-growexx/note_with_alarm/query"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"gorm.io/driver/postgres"
@@ -14,17 +21,25 @@ import (
 
 const testDateTimeString = "2024-03-13T12:00:00Z"
 
+// setupTestDB opens a migrated database for a test. It is a fresh SQLite
+// file unless TEST_DB_DRIVER=postgres, in which case TEST_POSTGRES_DSN (or a
+// local default) is used.
 func setupTestDB() (*gorm.DB, func()) {
+	dialector, removeFile := testDialector()
 
-	dsn := "host=127.0.0.1 port=5432 user=postgres password=1234 dbname=postgres sslmode=disable"
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(dialector, &gorm.Config{})
 	if err != nil {
 		panic("Failed to connect to the database: " + err.Error())
 	}
 
-	err = db.AutoMigrate(&models.Note{})
+	migrator, err := migrations.NewMigrator(db)
 	if err != nil {
-		panic("Failed to auto migrate: " + err.Error())
+		panic("Failed to load migrations: " + err.Error())
+	}
+
+	_, err = migrator.Up()
+	if err != nil {
+		panic("Failed to migrate: " + err.Error())
 	}
 
 	return db, func() {
@@ -36,7 +51,24 @@ func setupTestDB() (*gorm.DB, func()) {
 		if err != nil {
 			panic("Failed to close the database: " + err.Error())
 		}
+		removeFile()
+	}
+}
+
+func testDialector() (gorm.Dialector, func()) {
+	if os.Getenv("TEST_DB_DRIVER") == config.DriverPostgres {
+		dsn := os.Getenv("TEST_POSTGRES_DSN")
+		if dsn == "" {
+			dsn = "host=127.0.0.1 port=5432 user=postgres password=1234 dbname=postgres sslmode=disable"
+		}
+		return postgres.Open(dsn), func() {}
+	}
+
+	dir, err := os.MkdirTemp("", "note_with_alarm_test")
+	if err != nil {
+		panic("Failed to create a temp dir: " + err.Error())
 	}
+	return config.OpenSQLite(filepath.Join(dir, "test.db")), func() { os.RemoveAll(dir) }
 }
 
 func parseTime(timeStr string) time.Time {
@@ -47,6 +79,14 @@ func parseTime(timeStr string) time.Time {
 	return parsedTime
 }
 
+func mustParse(search string) *query.Query {
+	q, err := query.Parse(search)
+	if err != nil {
+		panic("Failed to parse query: " + err.Error())
+	}
+	return q
+}
+
 func TestNoteRepositoryImpl_Create(t *testing.T) {
 	db, cleanup := setupTestDB()
 	defer cleanup()
@@ -59,7 +99,7 @@ func TestNoteRepositoryImpl_Create(t *testing.T) {
 		Title:    testTitle,
 		Deadline: parseTime(testDateTimeString),
 	}
-	err := repo.Create(newNote)
+	err := repo.Create(context.Background(), newNote)
 	assert.NoError(t, err)
 }
 
@@ -75,7 +115,7 @@ func TestNoteRepositoryImpl_Update(t *testing.T) {
 		Title:    testTitle,
 		Deadline: parseTime(testDateTimeString),
 	}
-	err := repo.Create(newNote)
+	err := repo.Create(context.Background(), newNote)
 	assert.NoError(t, err)
 
 	defer cleanup()
@@ -84,9 +124,21 @@ func TestNoteRepositoryImpl_Update(t *testing.T) {
 		ID:       newNote.ID,
 		Title:    "Updated Test Note",
 		Deadline: parseTime(testDateTimeString),
+		Version:  newNote.Version,
 	}
-	err = repo.Update(updatedNote)
+	err = repo.Update(context.Background(), updatedNote)
 	assert.NoError(t, err)
+	assert.Equal(t, newNote.Version+1, updatedNote.Version)
+
+	// A second writer still holding the old version loses the race
+	staleNote := &models.Note{
+		ID:       newNote.ID,
+		Title:    "Stale Test Note",
+		Deadline: parseTime(testDateTimeString),
+		Version:  newNote.Version,
+	}
+	err = repo.Update(context.Background(), staleNote)
+	assert.ErrorIs(t, err, ErrVersionConflict)
 }
 
 func TestNoteRepositoryImpl_Delete(t *testing.T) {
@@ -100,13 +152,19 @@ func TestNoteRepositoryImpl_Delete(t *testing.T) {
 		Title:    testTitle,
 		Deadline: parseTime(testDateTimeString),
 	}
-	err := repo.Create(newNote)
+	err := repo.Create(context.Background(), newNote)
 	assert.NoError(t, err)
 
 	defer cleanup()
 
-	err = repo.Delete(newNote.ID)
+	err = repo.Delete(context.Background(), newNote.ID, newNote.Version+1)
+	assert.ErrorIs(t, err, ErrVersionConflict)
+
+	err = repo.Delete(context.Background(), newNote.ID, newNote.Version)
 	assert.NoError(t, err)
+
+	err = repo.Delete(context.Background(), newNote.ID, newNote.Version)
+	assert.ErrorIs(t, err, ErrNoteNotFound)
 }
 
 func TestNoteRepositoryImpl_GetAll(t *testing.T) {
@@ -122,12 +180,12 @@ func TestNoteRepositoryImpl_GetAll(t *testing.T) {
 			Title:    testTitle,
 			Deadline: parseTime(testDateTimeString),
 		}
-		err := repo.Create(newNote)
+		err := repo.Create(context.Background(), newNote)
 		assert.NoError(t, err)
 	}
 
 	// Retrieve all notes
-	notes, err := repo.GetAll()
+	notes, err := repo.GetAll(context.Background())
 	assert.NoError(t, err)
 	assert.NotNil(t, notes)
 
@@ -152,26 +210,21 @@ func TestNoteRepositoryImpl_GetById(t *testing.T) {
 		Title:    "Test Note get by id",
 		Deadline: parseTime(testDateTimeString),
 	}
-	err := repo.Create(createdNote)
+	err := repo.Create(context.Background(), createdNote)
 	assert.NoError(t, err)
 
-	retrievedNote, err := repo.GetById(createdNote.ID)
+	retrievedNote, err := repo.GetById(context.Background(), createdNote.ID)
 	assert.NoError(t, err)
 
-	createdNote.CreatedAt = createdNote.CreatedAt.Round(time.Second)
-	createdNote.UpdatedAt = createdNote.UpdatedAt.Round(time.Second)
-	retrievedNote.CreatedAt = retrievedNote.CreatedAt.Round(time.Second)
-	retrievedNote.UpdatedAt = retrievedNote.UpdatedAt.Round(time.Second)
-
-	expectedNote := &models.Note{
-		ID:        createdNote.ID,
-		Title:     "Test Note get by id",
-		Deadline:  createdNote.Deadline.In(retrievedNote.Deadline.Location()),
-		CreatedAt: createdNote.CreatedAt,
-		UpdatedAt: createdNote.UpdatedAt,
-	}
-
-	assert.Equal(t, expectedNote, retrievedNote)
+	// Times come back in the location the database reports them in, so they
+	// are compared as instants.
+	assert.Equal(t, createdNote.ID, retrievedNote.ID)
+	assert.Equal(t, "Test Note get by id", retrievedNote.Title)
+	assert.Equal(t, uint(1), retrievedNote.Version)
+	assert.Empty(t, retrievedNote.Tags)
+	assert.True(t, createdNote.Deadline.Equal(retrievedNote.Deadline))
+	assert.WithinDuration(t, createdNote.CreatedAt, retrievedNote.CreatedAt, time.Second)
+	assert.WithinDuration(t, createdNote.UpdatedAt, retrievedNote.UpdatedAt, time.Second)
 }
 
 func TestNoteRepositoryImpl_Search(t *testing.T) {
@@ -184,25 +237,133 @@ func TestNoteRepositoryImpl_Search(t *testing.T) {
 		Title:    "Important Note",
 		Deadline: parseTime(testDateTimeString),
 	}
-	err := repo.Create(createdNote)
+	err := repo.Create(context.Background(), createdNote)
 	assert.NoError(t, err)
 
-	searchResults, err := repo.Search("Important Note")
+	searchResults, err := repo.Search(context.Background(), mustParse("Important Note"))
 	assert.NoError(t, err)
 
 	assert.Len(t, searchResults, 1)
 
-	createdNote.CreatedAt = createdNote.CreatedAt.Round(time.Second)
-	createdNote.UpdatedAt = createdNote.UpdatedAt.Round(time.Second)
-	searchResults[0].CreatedAt = searchResults[0].CreatedAt.Round(time.Second)
-	searchResults[0].UpdatedAt = searchResults[0].UpdatedAt.Round(time.Second)
+	assert.Equal(t, createdNote.ID, searchResults[0].ID)
+	assert.Equal(t, createdNote.Title, searchResults[0].Title)
+	assert.True(t, createdNote.Deadline.Equal(searchResults[0].Deadline))
+	assert.WithinDuration(t, createdNote.CreatedAt, searchResults[0].CreatedAt, time.Second)
+}
+
+func TestNoteRepositoryImpl_Search_Ranking(t *testing.T) {
+	db, cleanup := setupTestDB()
+	defer cleanup()
+
+	repo := NewNoteRepository(db)
+
+	suffix := time.Now().Format("20060102150405")
+	inDescription := &models.Note{
+		Title:       "Weekly sync " + suffix,
+		Description: "Agenda for the quarterly planning meeting",
+		Deadline:    parseTime(testDateTimeString),
+	}
+	require.NoError(t, repo.Create(context.Background(), inDescription))
+	inTitle := &models.Note{
+		Title:       "Meeting with the quarterly planning group " + suffix,
+		Description: "Bring the roadmap",
+		Deadline:    parseTime(testDateTimeString),
+	}
+	require.NoError(t, repo.Create(context.Background(), inTitle))
+
+	// Matching is case-insensitive and title matches rank first
+	searchResults, err := repo.Search(context.Background(), mustParse(`"quarterly planning" meeting`))
+	require.NoError(t, err)
+	require.GreaterOrEqual(t, len(searchResults), 2)
+	assert.Equal(t, inTitle.ID, searchResults[0].ID)
+	assert.Contains(t, searchResults[0].TitleHighlight, "<b>Meeting</b>")
+
+	// Negated terms exclude notes
+	searchResults, err = repo.Search(context.Background(), mustParse("quarterly -roadmap"))
+	require.NoError(t, err)
+	for _, result := range searchResults {
+		assert.NotEqual(t, inTitle.ID, result.ID)
+	}
+}
+
+func TestNoteRepositoryImpl_Search_Filters(t *testing.T) {
+	db, cleanup := setupTestDB()
+	defer cleanup()
+
+	repo := NewNoteRepository(db)
+
+	suffix := time.Now().Format("20060102150405")
+	soon := &models.Note{
+		Title:    "Design review soon " + suffix,
+		Deadline: time.Now().Add(48 * time.Hour),
+		Tags:     models.Tags{"work", "review"},
+	}
+	require.NoError(t, repo.Create(context.Background(), soon))
+	later := &models.Note{
+		Title:    "Design review later " + suffix,
+		Deadline: time.Now().Add(30 * 24 * time.Hour),
+		Tags:     models.Tags{"work"},
+	}
+	require.NoError(t, repo.Create(context.Background(), later))
+	overdue := &models.Note{
+		Title:    "Design review draft " + suffix,
+		Deadline: time.Now().Add(-time.Hour),
+		Tags:     models.Tags{"work", "draft"},
+	}
+	require.NoError(t, repo.Create(context.Background(), overdue))
+
+	ids := func(results []*models.NoteSearchResult) []uint {
+		var ids []uint
+		for _, result := range results {
+			ids = append(ids, result.ID)
+		}
+		return ids
+	}
 
-	expectedDeadline := createdNote.Deadline.In(searchResults[0].Deadline.Location())
+	results, err := repo.Search(context.Background(), mustParse(`tag:work due:<7d status:open "design review" `+suffix))
+	require.NoError(t, err)
+	assert.Equal(t, []uint{soon.ID}, ids(results))
 
-	createdNote.Deadline = expectedDeadline
-	searchResults[0].Deadline = expectedDeadline
+	results, err = repo.Search(context.Background(), mustParse(`tag:work -tag:draft `+suffix))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uint{soon.ID, later.ID}, ids(results))
 
-	assert.Equal(t, createdNote, searchResults[0])
+	results, err = repo.Search(context.Background(), mustParse(`status:overdue tag:draft `+suffix))
+	require.NoError(t, err)
+	assert.Equal(t, []uint{overdue.ID}, ids(results))
+}
+
+func TestNoteRepositoryImpl_FuzzySearch(t *testing.T) {
+	db, cleanup := setupTestDB()
+	defer cleanup()
+
+	repo := NewNoteRepository(db)
+
+	suffix := time.Now().Format("20060102150405")
+	createdNote := &models.Note{
+		Title:    "Architecture review " + suffix,
+		Deadline: parseTime(testDateTimeString),
+	}
+	require.NoError(t, repo.Create(context.Background(), createdNote))
+
+	searchResults, err := repo.FuzzySearch(context.Background(), "Architecure reveiw "+suffix, 0.3)
+	require.NoError(t, err)
+	require.NotEmpty(t, searchResults)
+	assert.Equal(t, createdNote.ID, searchResults[0].ID)
+	assert.Greater(t, searchResults[0].Similarity, 0.3)
+
+	searchResults, err = repo.FuzzySearch(context.Background(), "Architecure reveiw "+suffix, 0.99)
+	require.NoError(t, err)
+	assert.Empty(t, searchResults)
+
+	titles, err := repo.SuggestTitles(context.Background(), "architecture rev", 10)
+	require.NoError(t, err)
+	assert.Contains(t, titles, createdNote.Title)
+
+	// LIKE wildcards in the prefix are matched literally
+	titles, err = repo.SuggestTitles(context.Background(), "%"+suffix, 10)
+	require.NoError(t, err)
+	assert.Empty(t, titles)
 }
 
 func TestNoteRepositoryImpl_GetNoteByTitle(t *testing.T) {
@@ -216,11 +377,11 @@ func TestNoteRepositoryImpl_GetNoteByTitle(t *testing.T) {
 		Title:    "Test Note get by title",
 		Deadline: parseTime(testDateTimeString).UTC(),
 	}
-	err := repo.Create(newNote)
+	err := repo.Create(context.Background(), newNote)
 	require.NoError(t, err, "failed to create a sample note for testing")
 
 	// Test case: Retrieve existing note by its title
-	retrievedNote, err := repo.GetNoteByTitle(newNote.Title)
+	retrievedNote, err := repo.GetNoteByTitle(context.Background(), newNote.Title)
 	require.NoError(t, err, "error while retrieving note by title")
 	require.NotNil(t, retrievedNote, "retrieved note is nil")
 	assert.Equal(t, newNote.ID, retrievedNote.ID, "IDs do not match")
@@ -233,15 +394,237 @@ func TestNoteRepositoryImpl_GetNoteByTitle(t *testing.T) {
 	assert.Equal(t, expectedDeadline, actualDeadline, "deadlines do not match")
 	// Test case: Retrieve non-existent note by its title
 	nonExistentTitle := "Non-existent Title"
-	retrievedNote, err = repo.GetNoteByTitle(nonExistentTitle)
+	retrievedNote, err = repo.GetNoteByTitle(context.Background(), nonExistentTitle)
 	require.Error(t, err, "expected error while retrieving non-existent note by title")
 	assert.Nil(t, retrievedNote, "retrieved note should be nil for non-existent title")
 
 	// Test case: Database error
 	// Simulate a database error by closing the database connection
 	// db.Close()
-	_, err = repo.GetNoteByTitle("Any Title")
+	_, err = repo.GetNoteByTitle(context.Background(), "Any Title")
 	require.Error(t, err, "expected error due to database connection closure")
 	assert.Nil(t, retrievedNote, "retrieved note should be nil due to database error")
 
 }
+
+func TestNoteRepositoryImpl_Revisions(t *testing.T) {
+	db, cleanup := setupTestDB()
+	defer cleanup()
+
+	repo := NewNoteRepository(db)
+
+	newNote := &models.Note{
+		Title:    "Test Note revisions_" + time.Now().Format("20060102150405"),
+		Deadline: parseTime(testDateTimeString),
+	}
+	require.NoError(t, repo.Create(context.Background(), newNote))
+
+	first := &models.NoteRevision{NoteID: newNote.ID, Title: newNote.Title, Deadline: newNote.Deadline, ChangedFields: []string{"title", "deadline"}}
+	require.NoError(t, repo.CreateRevision(context.Background(), first))
+	assert.Equal(t, uint(1), first.Revision)
+
+	second := &models.NoteRevision{NoteID: newNote.ID, Title: "Renamed", Deadline: newNote.Deadline, ChangedFields: []string{"title"}, ChangedBy: "alice"}
+	require.NoError(t, repo.CreateRevision(context.Background(), second))
+	assert.Equal(t, uint(2), second.Revision)
+
+	revisions, err := repo.GetRevisions(context.Background(), newNote.ID)
+	require.NoError(t, err)
+	require.Len(t, revisions, 2)
+	assert.Equal(t, []string{"title"}, revisions[1].ChangedFields)
+
+	revision, err := repo.GetRevision(context.Background(), newNote.ID, 2)
+	require.NoError(t, err)
+	assert.Equal(t, "alice", revision.ChangedBy)
+
+	_, err = repo.GetRevision(context.Background(), newNote.ID, 3)
+	assert.ErrorIs(t, err, ErrRevisionNotFound)
+}
+
+func TestNoteRepositoryImpl_UpdateFields(t *testing.T) {
+	db, cleanup := setupTestDB()
+	defer cleanup()
+
+	repo := NewNoteRepository(db)
+
+	newNote := &models.Note{
+		Title:       "Test Note update fields_" + time.Now().Format("20060102150405"),
+		Description: "untouched",
+		Deadline:    parseTime(testDateTimeString),
+	}
+	require.NoError(t, repo.Create(context.Background(), newNote))
+
+	newDeadline := parseTime(testDateTimeString).Add(24 * time.Hour)
+	err := repo.UpdateFields(context.Background(), newNote.ID, newNote.Version, map[string]interface{}{"deadline": newDeadline})
+	require.NoError(t, err)
+
+	retrievedNote, err := repo.GetById(context.Background(), newNote.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "untouched", retrievedNote.Description)
+	assert.True(t, newDeadline.Equal(retrievedNote.Deadline))
+	assert.Equal(t, newNote.Version+1, retrievedNote.Version)
+
+	err = repo.UpdateFields(context.Background(), newNote.ID, newNote.Version, map[string]interface{}{"description": "stale"})
+	assert.ErrorIs(t, err, ErrVersionConflict)
+}
+
+func TestNoteRepositoryImpl_List(t *testing.T) {
+	db, cleanup := setupTestDB()
+	defer cleanup()
+
+	repo := NewNoteRepository(db)
+
+	// Deadlines far in the future keep this listing isolated from other notes
+	base := time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC).Add(time.Duration(time.Now().UnixNano()%1000) * time.Hour)
+	for i := 0; i < 5; i++ {
+		newNote := &models.Note{
+			Title:    fmt.Sprintf("Test Note list %d_%d", i, time.Now().UnixNano()),
+			Deadline: base.Add(time.Duration(5-i) * time.Minute),
+		}
+		require.NoError(t, repo.Create(context.Background(), newNote))
+	}
+
+	after := base
+	before := base.Add(time.Hour)
+	query := models.NoteListQuery{Limit: 2, Sort: "deadline", DeadlineAfter: &after, DeadlineBefore: &before}
+
+	var seen []*models.Note
+	for {
+		page, err := repo.List(context.Background(), query)
+		require.NoError(t, err)
+		assert.Equal(t, int64(5), page.Total)
+		seen = append(seen, page.Notes...)
+		if page.NextCursor == "" {
+			break
+		}
+		query.Cursor = page.NextCursor
+	}
+
+	require.Len(t, seen, 5)
+	for i := 1; i < len(seen); i++ {
+		assert.True(t, seen[i-1].Deadline.Before(seen[i].Deadline), "notes should be sorted by deadline")
+	}
+
+	// A cursor cannot be replayed against a different sort
+	_, err := repo.List(context.Background(), models.NoteListQuery{Limit: 2, Sort: "title", Cursor: query.Cursor})
+	assert.ErrorIs(t, err, ErrInvalidCursor)
+}
+
+func TestNoteRepositoryImpl_WithinTx(t *testing.T) {
+	db, cleanup := setupTestDB()
+	defer cleanup()
+
+	repo := NewNoteRepository(db)
+	title := "Test Note tx_" + time.Now().Format("20060102150405")
+
+	rollback := errors.New("rollback")
+	err := repo.WithinTx(context.Background(), func(tx NoteRepository) error {
+		require.NoError(t, tx.Create(context.Background(), &models.Note{Title: title, Deadline: parseTime(testDateTimeString)}))
+		return rollback
+	})
+	assert.ErrorIs(t, err, rollback)
+
+	_, err = repo.GetNoteByTitle(context.Background(), title)
+	assert.ErrorIs(t, err, ErrNoteNotFound)
+
+	note := &models.Note{Title: title, Deadline: parseTime(testDateTimeString)}
+	err = repo.WithinTx(context.Background(), func(tx NoteRepository) error {
+		if err := tx.Create(context.Background(), note); err != nil {
+			return err
+		}
+		return tx.CreateRevision(context.Background(), &models.NoteRevision{NoteID: note.ID, Title: note.Title, Deadline: note.Deadline})
+	})
+	require.NoError(t, err)
+
+	revisions, err := repo.GetRevisions(context.Background(), note.ID)
+	require.NoError(t, err)
+	assert.Len(t, revisions, 1)
+}
+
+func TestNoteRepositoryImpl_DuplicateTitle(t *testing.T) {
+	db, cleanup := setupTestDB()
+	defer cleanup()
+
+	repo := NewNoteRepository(db)
+	title := "Test Note duplicate_" + time.Now().Format("20060102150405")
+
+	first := &models.Note{Title: title, Deadline: parseTime(testDateTimeString)}
+	require.NoError(t, repo.Create(context.Background(), first))
+
+	err := repo.Create(context.Background(), &models.Note{Title: title, Deadline: parseTime(testDateTimeString)})
+	assert.ErrorIs(t, err, ErrDuplicateTitle)
+
+	second := &models.Note{Title: title + " other", Deadline: parseTime(testDateTimeString)}
+	require.NoError(t, repo.Create(context.Background(), second))
+
+	err = repo.UpdateFields(context.Background(), second.ID, second.Version, map[string]interface{}{"title": title})
+	assert.ErrorIs(t, err, ErrDuplicateTitle)
+}
+
+func TestNoteRepositoryImpl_Search_Syntax(t *testing.T) {
+	db, cleanup := setupTestDB()
+	defer cleanup()
+
+	repo := NewNoteRepository(db)
+
+	invoice := &models.Note{Title: "Pay invoice", Description: "Electricity bill", Deadline: parseTime(testDateTimeString)}
+	require.NoError(t, repo.Create(context.Background(), invoice))
+	receipt := &models.Note{Title: "File receipt", Description: "Electricity bill", Deadline: parseTime(testDateTimeString)}
+	require.NoError(t, repo.Create(context.Background(), receipt))
+	plants := &models.Note{Title: "Water plants", Deadline: parseTime(testDateTimeString)}
+	require.NoError(t, repo.Create(context.Background(), plants))
+
+	ids := func(results []*models.NoteSearchResult) []uint {
+		var ids []uint
+		for _, result := range results {
+			ids = append(ids, result.ID)
+		}
+		return ids
+	}
+
+	results, err := repo.Search(context.Background(), mustParse("bill invoice OR receipt"))
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uint{invoice.ID, receipt.ID}, ids(results))
+
+	results, err = repo.Search(context.Background(), mustParse(`"electricity bill" -receipt`))
+	require.NoError(t, err)
+	assert.Equal(t, []uint{invoice.ID}, ids(results))
+
+	results, err = repo.Search(context.Background(), mustParse("-bill"))
+	require.NoError(t, err)
+	assert.Equal(t, []uint{plants.ID}, ids(results))
+
+	// Query syntax characters in terms are matched literally.
+	_, err = repo.Search(context.Background(), mustParse(`invoice* NEAR(bill)`))
+	assert.NoError(t, err)
+}
+
+func TestNoteRepositoryImpl_List_MixedOffsets(t *testing.T) {
+	db, cleanup := setupTestDB()
+	defer cleanup()
+
+	repo := NewNoteRepository(db)
+
+	// 12:00 IST is 06:30 UTC, so it is due before 10:00 UTC even though its
+	// wall clock time is later.
+	ist := time.FixedZone("IST", 5*60*60+30*60)
+	later := &models.Note{Title: "Later", Deadline: time.Date(2024, 3, 13, 10, 0, 0, 0, time.UTC)}
+	require.NoError(t, repo.Create(context.Background(), later))
+	earlier := &models.Note{Title: "Earlier", Deadline: time.Date(2024, 3, 13, 12, 0, 0, 0, ist)}
+	require.NoError(t, repo.Create(context.Background(), earlier))
+
+	page, err := repo.List(context.Background(), models.NoteListQuery{Sort: "deadline", Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, page.Notes, 1)
+	assert.Equal(t, earlier.ID, page.Notes[0].ID)
+
+	page, err = repo.List(context.Background(), models.NoteListQuery{Sort: "deadline", Limit: 1, Cursor: page.NextCursor})
+	require.NoError(t, err)
+	require.Len(t, page.Notes, 1)
+	assert.Equal(t, later.ID, page.Notes[0].ID)
+
+	before := time.Date(2024, 3, 13, 8, 0, 0, 0, time.UTC)
+	page, err = repo.List(context.Background(), models.NoteListQuery{DeadlineBefore: &before})
+	require.NoError(t, err)
+	require.Len(t, page.Notes, 1)
+	assert.Equal(t, earlier.ID, page.Notes[0].ID)
+}
@@ -0,0 +1,769 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sarita-growexx/note_with_alarm/apperrors"
+	"github.com/sarita-growexx/note_with_alarm/models"
+	"github.com/sarita-growexx/note_with_alarm/query"
+)
+
+// MemoryNoteRepository is a NoteRepository that keeps notes in memory. It is
+// safe for concurrent use and follows the same rules as the database
+// implementation: IDs and timestamps are assigned on create, titles are
+// unique, writes are conditional on the version and missing notes are
+// reported with ErrNoteNotFound.
+type MemoryNoteRepository struct {
+	store *memoryStore
+	// inTx is set on the repository handed to a WithinTx callback, which
+	// runs while the store lock is already held.
+	inTx bool
+}
+
+type memoryStore struct {
+	mu             sync.Mutex
+	notes          map[uint]models.Note
+	revisions      map[uint][]models.NoteRevision
+	nextNoteID     uint
+	nextRevisionID uint
+}
+
+func NewMemoryNoteRepository() NoteRepository {
+	return &MemoryNoteRepository{store: &memoryStore{
+		notes:     make(map[uint]models.Note),
+		revisions: make(map[uint][]models.NoteRevision),
+	}}
+}
+
+func (r *MemoryNoteRepository) lock() func() {
+	if r.inTx {
+		return func() {}
+	}
+	r.store.mu.Lock()
+	return r.store.mu.Unlock
+}
+
+// WithinTx implements NoteRepository. Transactions hold the store lock for
+// their whole duration, so they are serialized with every other call, and
+// the notes and revisions are restored if fn fails. As with database
+// sequences, IDs handed out inside a failed transaction are not reused.
+func (r *MemoryNoteRepository) WithinTx(ctx context.Context, fn func(repo NoteRepository) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	unlock := r.lock()
+	defer unlock()
+
+	notes := make(map[uint]models.Note, len(r.store.notes))
+	for id, note := range r.store.notes {
+		notes[id] = note
+	}
+	revisions := make(map[uint][]models.NoteRevision, len(r.store.revisions))
+	for id, noteRevisions := range r.store.revisions {
+		revisions[id] = slices.Clone(noteRevisions)
+	}
+
+	committed := false
+	defer func() {
+		if !committed {
+			r.store.notes, r.store.revisions = notes, revisions
+		}
+	}()
+
+	if err := fn(&MemoryNoteRepository{store: r.store, inTx: true}); err != nil {
+		return err
+	}
+	committed = true
+	return nil
+}
+
+// Create implements NoteRepository.
+func (r *MemoryNoteRepository) Create(ctx context.Context, note *models.Note) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	unlock := r.lock()
+	defer unlock()
+
+	if r.titleTaken(note.Title, 0) {
+		return ErrDuplicateTitle
+	}
+
+	now := time.Now()
+	if note.ID == 0 {
+		r.store.nextNoteID++
+		note.ID = r.store.nextNoteID
+	} else if _, ok := r.store.notes[note.ID]; ok {
+		return fmt.Errorf("note %d already exists", note.ID)
+	}
+	if note.CreatedAt.IsZero() {
+		note.CreatedAt = now
+	}
+	if note.UpdatedAt.IsZero() {
+		note.UpdatedAt = now
+	}
+	if note.Version == 0 {
+		note.Version = 1
+	}
+
+	r.store.notes[note.ID] = copyNote(*note)
+	return nil
+}
+
+// CreateInBatches implements NoteRepository. Notes are checked before any
+// is stored, so a duplicate title leaves the store unchanged.
+func (r *MemoryNoteRepository) CreateInBatches(ctx context.Context, notes []*models.Note, batchSize int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	unlock := r.lock()
+	defer unlock()
+
+	titles := make(map[string]bool, len(notes))
+	for _, note := range notes {
+		if titles[note.Title] || r.titleTaken(note.Title, 0) {
+			return ErrDuplicateTitle
+		}
+		titles[note.Title] = true
+	}
+
+	return (&MemoryNoteRepository{store: r.store, inTx: true}).WithinTx(ctx, func(repo NoteRepository) error {
+		for _, note := range notes {
+			if err := repo.Create(ctx, note); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Update implements NoteRepository.
+func (r *MemoryNoteRepository) Update(ctx context.Context, note *models.Note) error {
+	err := r.UpdateFields(ctx, note.ID, note.Version, map[string]interface{}{
+		"title":       note.Title,
+		"description": note.Description,
+		"deadline":    note.Deadline,
+		"tags":        note.Tags,
+		"reminders":   note.Reminders,
+		"updated_at":  note.UpdatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	note.Version++
+	return nil
+}
+
+// UpdateFields implements NoteRepository. As with gorm, updated_at is set to
+// the current time unless it is among the fields.
+func (r *MemoryNoteRepository) UpdateFields(ctx context.Context, id uint, version uint, fields map[string]interface{}) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	unlock := r.lock()
+	defer unlock()
+
+	note, ok := r.store.notes[id]
+	if !ok {
+		return ErrNoteNotFound
+	}
+	if note.Version != version {
+		return ErrVersionConflict
+	}
+
+	note.UpdatedAt = time.Now()
+	for column, value := range fields {
+		var ok bool
+		switch column {
+		case "title":
+			note.Title, ok = value.(string)
+		case "description":
+			note.Description, ok = value.(string)
+		case "deadline":
+			note.Deadline, ok = value.(time.Time)
+		case "tags":
+			note.Tags, ok = value.(models.Tags)
+		case "reminders":
+			note.Reminders, ok = value.(models.Reminders)
+		case "updated_at":
+			note.UpdatedAt, ok = value.(time.Time)
+		}
+		if !ok {
+			return fmt.Errorf("cannot update column %q with %T", column, value)
+		}
+	}
+
+	if r.titleTaken(note.Title, id) {
+		return ErrDuplicateTitle
+	}
+
+	note.Version++
+	r.store.notes[id] = copyNote(note)
+	return nil
+}
+
+// Delete implements NoteRepository.
+func (r *MemoryNoteRepository) Delete(ctx context.Context, id uint, version uint) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	unlock := r.lock()
+	defer unlock()
+
+	note, ok := r.store.notes[id]
+	if !ok {
+		return ErrNoteNotFound
+	}
+	if note.Version != version {
+		return ErrVersionConflict
+	}
+
+	delete(r.store.notes, id)
+	return nil
+}
+
+// GetById implements NoteRepository.
+func (r *MemoryNoteRepository) GetById(ctx context.Context, id uint) (*models.Note, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	unlock := r.lock()
+	defer unlock()
+
+	note, ok := r.store.notes[id]
+	if !ok {
+		return nil, ErrNoteNotFound
+	}
+	copied := copyNote(note)
+	return &copied, nil
+}
+
+// GetAll implements NoteRepository. Notes are returned in ID order.
+func (r *MemoryNoteRepository) GetAll(ctx context.Context) ([]*models.Note, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	unlock := r.lock()
+	defer unlock()
+
+	return r.sortedNotes(), nil
+}
+
+// List implements NoteRepository.
+func (r *MemoryNoteRepository) List(ctx context.Context, query models.NoteListQuery) (*models.NotePage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	sort, order, limit, err := normalizeListQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor *listCursor
+	var after func(note *models.Note) bool
+	if query.Cursor != "" {
+		if cursor, err = decodeCursor(query.Cursor, sort, order); err != nil {
+			return nil, err
+		}
+		value, err := cursorValue(sort, cursor.Value)
+		if err != nil {
+			return nil, err
+		}
+		after = func(note *models.Note) bool {
+			cmp := compareNoteField(note, sort, value)
+			if cmp == 0 {
+				cmp = compareIDs(note.ID, cursor.ID)
+			}
+			if order == "desc" {
+				return cmp < 0
+			}
+			return cmp > 0
+		}
+	}
+
+	now, err := listNow(query, cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	unlock := r.lock()
+	defer unlock()
+
+	var filtered []*models.Note
+	for _, note := range r.sortedNotes() {
+		if query.DeadlineBefore != nil && !note.Deadline.Before(*query.DeadlineBefore) {
+			continue
+		}
+		if query.DeadlineAfter != nil && !note.Deadline.After(*query.DeadlineAfter) {
+			continue
+		}
+		if query.Overdue != nil && note.Deadline.Before(now) != *query.Overdue {
+			continue
+		}
+		if query.Tag != "" && !slices.Contains(note.Tags, query.Tag) {
+			continue
+		}
+		filtered = append(filtered, note)
+	}
+
+	sortNotes(filtered, sort, order == "desc")
+
+	var notes []*models.Note
+	for _, note := range filtered {
+		if after != nil && !after(note) {
+			continue
+		}
+		notes = append(notes, note)
+		if len(notes) > limit {
+			break
+		}
+	}
+
+	result := &models.NotePage{Notes: notes}
+	if !query.SkipTotal {
+		result.Total = int64(len(filtered))
+	}
+	if len(notes) > limit {
+		result.Notes = notes[:limit]
+		result.NextCursor = encodeCursor(sort, order, result.Notes[limit-1], query, now)
+	}
+	return result, nil
+}
+
+// Search implements NoteRepository. Terms match words of the title or
+// description case-insensitively, ignoring common English suffixes, and
+// title matches rank above description matches.
+func (r *MemoryNoteRepository) Search(ctx context.Context, q *query.Query) ([]*models.NoteSearchResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	field, desc := q.SortField()
+	if _, ok := sortColumns[field]; field != "" && !ok {
+		return nil, apperrors.New(apperrors.Validation, fmt.Sprintf("unsupported sort %q", q.Sort))
+	}
+
+	unlock := r.lock()
+	defer unlock()
+
+	now := q.Now()
+	var results []*models.NoteSearchResult
+	for _, note := range r.sortedNotes() {
+		rank, ok := matchTerms(note, q.Terms)
+		if !ok || !matchFilters(note, q.Filters, now) {
+			continue
+		}
+
+		result := &models.NoteSearchResult{Note: *note}
+		if q.HasText() {
+			words := termStems(q.Terms)
+			result.Rank = rank
+			result.TitleHighlight = highlight(note.Title, words)
+			result.DescriptionHighlight = highlight(note.Description, words)
+		}
+		results = append(results, result)
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		a, b := &results[i].Note, &results[j].Note
+		var cmp int
+		switch {
+		case field != "":
+			cmp = compareNotes(a, b, field)
+			if cmp == 0 {
+				cmp = compareIDs(a.ID, b.ID)
+			}
+			if desc {
+				cmp = -cmp
+			}
+		case q.HasText() && results[i].Rank != results[j].Rank:
+			return results[i].Rank > results[j].Rank
+		case q.HasText():
+			cmp = compareIDs(a.ID, b.ID)
+		default:
+			cmp = compareNotes(a, b, "deadline")
+			if cmp == 0 {
+				cmp = compareIDs(a.ID, b.ID)
+			}
+		}
+		return cmp < 0
+	})
+	return results, nil
+}
+
+// FuzzySearch implements NoteRepository.
+func (r *MemoryNoteRepository) FuzzySearch(ctx context.Context, query string, threshold float64) ([]*models.NoteSearchResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	unlock := r.lock()
+	defer unlock()
+
+	return rankBySimilarity(r.sortedNotes(), query, threshold), nil
+}
+
+// SuggestTitles implements NoteRepository.
+func (r *MemoryNoteRepository) SuggestTitles(ctx context.Context, prefix string, limit int) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	unlock := r.lock()
+	defer unlock()
+
+	var titles []string
+	for _, note := range r.store.notes {
+		if strings.HasPrefix(strings.ToLower(note.Title), strings.ToLower(prefix)) {
+			titles = append(titles, note.Title)
+		}
+	}
+	return closestTitles(titles, prefix, limit), nil
+}
+
+// GetNoteByTitle implements NoteRepository.
+func (r *MemoryNoteRepository) GetNoteByTitle(ctx context.Context, title string) (*models.Note, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	unlock := r.lock()
+	defer unlock()
+
+	for _, note := range r.store.notes {
+		if note.Title == title {
+			copied := copyNote(note)
+			return &copied, nil
+		}
+	}
+	return nil, ErrNoteNotFound
+}
+
+// GetNotesByTitles implements NoteRepository.
+func (r *MemoryNoteRepository) GetNotesByTitles(ctx context.Context, titles []string) (map[string]*models.Note, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	unlock := r.lock()
+	defer unlock()
+
+	wanted := make(map[string]bool, len(titles))
+	for _, title := range titles {
+		wanted[title] = true
+	}
+	notes := make(map[string]*models.Note)
+	for _, note := range r.store.notes {
+		if wanted[note.Title] {
+			copied := copyNote(note)
+			notes[note.Title] = &copied
+		}
+	}
+	return notes, nil
+}
+
+// CreateRevision implements NoteRepository.
+func (r *MemoryNoteRepository) CreateRevision(ctx context.Context, revision *models.NoteRevision) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	unlock := r.lock()
+	defer unlock()
+
+	revisions := r.store.revisions[revision.NoteID]
+	revision.Revision = uint(len(revisions)) + 1
+	if len(revisions) > 0 {
+		revision.Revision = revisions[len(revisions)-1].Revision + 1
+	}
+	r.store.nextRevisionID++
+	revision.ID = r.store.nextRevisionID
+	if revision.CreatedAt.IsZero() {
+		revision.CreatedAt = time.Now()
+	}
+
+	r.store.revisions[revision.NoteID] = append(revisions, copyRevision(*revision))
+	return nil
+}
+
+// CreateRevisionsInBatches implements NoteRepository.
+func (r *MemoryNoteRepository) CreateRevisionsInBatches(ctx context.Context, revisions []*models.NoteRevision, batchSize int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	unlock := r.lock()
+	defer unlock()
+
+	repo := &MemoryNoteRepository{store: r.store, inTx: true}
+	for _, revision := range revisions {
+		if err := repo.CreateRevision(ctx, revision); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetRevisions implements NoteRepository.
+func (r *MemoryNoteRepository) GetRevisions(ctx context.Context, noteID uint) ([]*models.NoteRevision, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	unlock := r.lock()
+	defer unlock()
+
+	revisions := make([]*models.NoteRevision, 0, len(r.store.revisions[noteID]))
+	for _, revision := range r.store.revisions[noteID] {
+		copied := copyRevision(revision)
+		revisions = append(revisions, &copied)
+	}
+	return revisions, nil
+}
+
+// GetRevision implements NoteRepository.
+func (r *MemoryNoteRepository) GetRevision(ctx context.Context, noteID uint, revision uint) (*models.NoteRevision, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	unlock := r.lock()
+	defer unlock()
+
+	for _, stored := range r.store.revisions[noteID] {
+		if stored.Revision == revision {
+			copied := copyRevision(stored)
+			return &copied, nil
+		}
+	}
+	return nil, ErrRevisionNotFound
+}
+
+// titleTaken reports whether a note other than id already has title.
+func (r *MemoryNoteRepository) titleTaken(title string, id uint) bool {
+	for _, note := range r.store.notes {
+		if note.Title == title && note.ID != id {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedNotes returns copies of every note in ID order.
+func (r *MemoryNoteRepository) sortedNotes() []*models.Note {
+	notes := make([]*models.Note, 0, len(r.store.notes))
+	for _, note := range r.store.notes {
+		copied := copyNote(note)
+		notes = append(notes, &copied)
+	}
+	sortNotes(notes, "", false)
+	return notes
+}
+
+// copyNote returns note with its tags and reminders copied, so stored notes
+// never share memory with callers. Missing lists are stored empty, like the
+// column defaults.
+func copyNote(note models.Note) models.Note {
+	note.Tags = slices.Clone(note.Tags)
+	if note.Tags == nil {
+		note.Tags = models.Tags{}
+	}
+	note.Reminders = slices.Clone(note.Reminders)
+	if note.Reminders == nil {
+		note.Reminders = models.Reminders{}
+	}
+	return note
+}
+
+func copyRevision(revision models.NoteRevision) models.NoteRevision {
+	revision.Tags = slices.Clone(revision.Tags)
+	if revision.Tags == nil {
+		revision.Tags = models.Tags{}
+	}
+	revision.Reminders = slices.Clone(revision.Reminders)
+	if revision.Reminders == nil {
+		revision.Reminders = models.Reminders{}
+	}
+	revision.ChangedFields = slices.Clone(revision.ChangedFields)
+	return revision
+}
+
+// sortNotes orders notes by field and then ID, or by ID alone when field is
+// empty.
+func sortNotes(notes []*models.Note, field string, desc bool) {
+	sort.SliceStable(notes, func(i, j int) bool {
+		cmp := 0
+		if field != "" {
+			cmp = compareNotes(notes[i], notes[j], field)
+		}
+		if cmp == 0 {
+			cmp = compareIDs(notes[i].ID, notes[j].ID)
+		}
+		if desc {
+			return cmp > 0
+		}
+		return cmp < 0
+	})
+}
+
+func compareNotes(a, b *models.Note, field string) int {
+	switch field {
+	case "title":
+		return compareNoteField(a, field, b.Title)
+	case "created_at":
+		return compareNoteField(a, field, b.CreatedAt)
+	case "updated_at":
+		return compareNoteField(a, field, b.UpdatedAt)
+	default:
+		return compareNoteField(a, field, b.Deadline)
+	}
+}
+
+// compareNoteField compares a sortable field of note with value, which is a
+// string for titles and a time.Time otherwise.
+func compareNoteField(note *models.Note, field string, value interface{}) int {
+	switch field {
+	case "title":
+		return strings.Compare(note.Title, value.(string))
+	case "created_at":
+		return note.CreatedAt.Compare(value.(time.Time))
+	case "updated_at":
+		return note.UpdatedAt.Compare(value.(time.Time))
+	default:
+		return note.Deadline.Compare(value.(time.Time))
+	}
+}
+
+func compareIDs(a, b uint) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+var wordPattern = regexp.MustCompile(`[\p{L}\p{N}]+`)
+
+// stem lower-cases a word and strips common English plural and verb
+// suffixes, so that "meetings", "meeting" and "meet" match.
+func stem(word string) string {
+	word = strings.ToLower(word)
+	for _, suffixes := range [][]string{{"es", "s"}, {"ing", "ed"}} {
+		for _, suffix := range suffixes {
+			if trimmed := strings.TrimSuffix(word, suffix); trimmed != word && len(trimmed) >= 3 {
+				word = trimmed
+				break
+			}
+		}
+	}
+	return word
+}
+
+func stems(text string) []string {
+	words := wordPattern.FindAllString(text, -1)
+	for i, word := range words {
+		words[i] = stem(word)
+	}
+	return words
+}
+
+// containsSequence reports whether words contains the words of phrase
+// consecutively.
+func containsSequence(words, phrase []string) bool {
+	if len(phrase) == 0 {
+		return false
+	}
+	for i := 0; i+len(phrase) <= len(words); i++ {
+		if slices.Equal(words[i:i+len(phrase)], phrase) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchTerms reports whether note matches the free text terms and ranks the
+// match. Terms joined by OR are alternatives; every other term is required
+// and negated terms must not match.
+func matchTerms(note *models.Note, terms []query.Term) (float64, bool) {
+	title, description := stems(note.Title), stems(note.Description)
+
+	rank := 0.0
+	var groups []bool
+	for _, term := range terms {
+		phrase := stems(term.Text)
+		inTitle, inDescription := containsSequence(title, phrase), containsSequence(description, phrase)
+		matched := inTitle || inDescription
+
+		if term.Negated {
+			if matched {
+				return 0, false
+			}
+			continue
+		}
+
+		if term.Or && len(groups) > 0 {
+			groups[len(groups)-1] = groups[len(groups)-1] || matched
+		} else {
+			groups = append(groups, matched)
+		}
+		if inTitle {
+			rank += 1.0
+		}
+		if inDescription {
+			rank += 0.4
+		}
+	}
+
+	for _, matched := range groups {
+		if !matched {
+			return 0, false
+		}
+	}
+	return rank, true
+}
+
+func termStems(terms []query.Term) map[string]bool {
+	set := make(map[string]bool)
+	for _, term := range terms {
+		if term.Negated {
+			continue
+		}
+		for _, word := range stems(term.Text) {
+			set[word] = true
+		}
+	}
+	return set
+}
+
+// highlight escapes text as HTML and wraps its words whose stem is in words
+// in <b> tags.
+func highlight(text string, words map[string]bool) string {
+	return renderHighlight(wordPattern.ReplaceAllStringFunc(text, func(word string) string {
+		if words[stem(word)] {
+			return highlightStart + word + highlightStop
+		}
+		return word
+	}))
+}
+
+// matchFilters reports whether note satisfies every filter.
+func matchFilters(note *models.Note, filters []query.Filter, now time.Time) bool {
+	for _, filter := range filters {
+		var matched bool
+		switch filter.Field {
+		case query.FieldTag:
+			matched = slices.Contains(note.Tags, filter.Value)
+		case query.FieldStatus:
+			overdue := note.Deadline.Before(now)
+			matched = overdue == (filter.Value == query.StatusOverdue)
+		default:
+			from, to := filter.DueBounds(now)
+			matched = (from.IsZero() || !note.Deadline.Before(from)) && (to.IsZero() || note.Deadline.Before(to))
+		}
+		if matched == filter.Negated {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,226 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sarita-growexx/note_with_alarm/apperrors"
+	"github.com/sarita-growexx/note_with_alarm/models"
+	"github.com/sarita-growexx/note_with_alarm/query"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Search implements NoteRepository. Free text terms are matched in web
+// search syntax (quoted phrases, OR, -negation) and ranked with title matches
+// weighted above description matches. Filters are compiled to parameterized
+// conditions on the note columns.
+func (n *NoteRepositoryImpl) Search(ctx context.Context, q *query.Query) ([]*models.NoteSearchResult, error) {
+	db := n.db.WithContext(ctx)
+	switch {
+	case !q.HasText():
+		db = db.Model(&models.Note{}).Select("note.*").Order(timeExpr(n.db, "note.deadline") + ", note.id")
+	case isSQLite(n.db):
+		db = searchFTS5(db, q)
+	default:
+		db = db.Table("note, websearch_to_tsquery('english', ?) AS query", q.Text()).
+			Select("note.*, " +
+				"ts_rank(note.search_vector, query) AS rank, " +
+				"ts_headline('english', note.title, query, 'HighlightAll=true, StartSel=' || chr(2) || ', StopSel=' || chr(3)) AS title_highlight, " +
+				"ts_headline('english', note.description, query, 'MaxFragments=2, MinWords=5, MaxWords=20, StartSel=' || chr(2) || ', StopSel=' || chr(3)) AS description_highlight").
+			Where("note.search_vector @@ query").
+			Order("rank DESC, note.id")
+	}
+
+	now := q.Now()
+	for _, filter := range q.Filters {
+		condition, args := compileFilter(n.db, filter, now)
+		if filter.Negated {
+			condition = "NOT (" + condition + ")"
+		}
+		db = db.Where(condition, args...)
+	}
+
+	if field, desc := q.SortField(); field != "" {
+		column, ok := sortColumns[field]
+		if !ok {
+			return nil, apperrors.New(apperrors.Validation, fmt.Sprintf("unsupported sort %q", q.Sort))
+		}
+		if field != "title" {
+			column = timeExpr(n.db, "note."+column)
+		} else {
+			column = "note." + column
+		}
+		db = db.Order(clause.OrderByColumn{Column: clause.Column{Name: column, Raw: true}, Desc: desc, Reorder: true}).
+			Order(clause.OrderByColumn{Column: clause.Column{Table: "note", Name: "id"}, Desc: desc})
+	}
+
+	var results []*models.NoteSearchResult
+	if err := db.Scan(&results).Error; err != nil {
+		return nil, dbError(err, nil)
+	}
+	for _, result := range results {
+		result.TitleHighlight = renderHighlight(result.TitleHighlight)
+		result.DescriptionHighlight = renderHighlight(result.DescriptionHighlight)
+	}
+	return results, nil
+}
+
+// Matches are marked with highlightStart and highlightStop, control
+// characters that are left alone by HTML escaping, and only become <b> tags
+// once the text has been escaped, so that notes cannot inject markup into
+// the highlights clients render.
+const (
+	highlightStart = "\x02"
+	highlightStop  = "\x03"
+)
+
+var highlightTags = strings.NewReplacer(highlightStart, "<b>", highlightStop, "</b>")
+
+// renderHighlight turns text with marked matches into HTML.
+func renderHighlight(marked string) string {
+	return highlightTags.Replace(html.EscapeString(marked))
+}
+
+// searchFTS5 matches the free text of q against the SQLite FTS5 index. bm25
+// scores are negated so that, as with ts_rank, a higher rank is a better match.
+func searchFTS5(db *gorm.DB, q *query.Query) *gorm.DB {
+	include, exclude := fts5Match(q)
+	if include == "" {
+		// FTS5 cannot match on negated terms alone.
+		return db.Model(&models.Note{}).Select("note.*").
+			Where("note.id NOT IN (SELECT rowid FROM note_fts WHERE note_fts MATCH ?)", exclude).
+			Order("julianday(note.deadline), note.id")
+	}
+	if exclude != "" {
+		include += " NOT (" + exclude + ")"
+	}
+
+	return db.Table("note").
+		Joins("JOIN note_fts ON note_fts.rowid = note.id").
+		Select("note.*, "+
+			"-bm25(note_fts, 10.0, 4.0) AS rank, "+
+			"highlight(note_fts, 0, char(2), char(3)) AS title_highlight, "+
+			"snippet(note_fts, 1, char(2), char(3), ' ... ', 20) AS description_highlight").
+		Where("note_fts MATCH ?", include).
+		Order("rank DESC, note.id")
+}
+
+// fts5Match renders the free text terms of q as FTS5 query expressions: one
+// for the terms that must match, with OR alternatives grouped, and one for
+// the negated terms. Every term is quoted so its text is never parsed as
+// FTS5 syntax.
+func fts5Match(q *query.Query) (include string, exclude string) {
+	var groups [][]string
+	var negated []string
+	for _, term := range q.Terms {
+		quoted := `"` + strings.ReplaceAll(term.Text, `"`, `""`) + `"`
+		switch {
+		case term.Negated:
+			negated = append(negated, quoted)
+		case term.Or && len(groups) > 0:
+			groups[len(groups)-1] = append(groups[len(groups)-1], quoted)
+		default:
+			groups = append(groups, []string{quoted})
+		}
+	}
+
+	required := make([]string, 0, len(groups))
+	for _, group := range groups {
+		required = append(required, "("+strings.Join(group, " OR ")+")")
+	}
+	return strings.Join(required, " AND "), strings.Join(negated, " OR ")
+}
+
+// tagCondition is the SQL condition of the notes with the given tag.
+func tagCondition(db *gorm.DB, tag string) (string, []interface{}) {
+	if isSQLite(db) {
+		return "EXISTS (SELECT 1 FROM json_each(note.tags) WHERE json_each.value = ?)", []interface{}{tag}
+	}
+	tags, _ := json.Marshal([]string{tag})
+	return "note.tags::jsonb @> ?::jsonb", []interface{}{string(tags)}
+}
+
+// compileFilter turns a query filter into a SQL condition and its arguments.
+func compileFilter(db *gorm.DB, filter query.Filter, now time.Time) (string, []interface{}) {
+	deadline, at := timeExpr(db, "note.deadline"), timeExpr(db, "?")
+	switch filter.Field {
+	case query.FieldTag:
+		return tagCondition(db, filter.Value)
+
+	case query.FieldStatus:
+		if filter.Value == query.StatusOverdue {
+			return deadline + " < " + at, []interface{}{now}
+		}
+		return deadline + " >= " + at, []interface{}{now}
+
+	default:
+		from, to := filter.DueBounds(now)
+		var conditions []string
+		var args []interface{}
+		if !from.IsZero() {
+			conditions = append(conditions, deadline+" >= "+at)
+			args = append(args, from)
+		}
+		if !to.IsZero() {
+			conditions = append(conditions, deadline+" < "+at)
+			args = append(args, to)
+		}
+		return strings.Join(conditions, " AND "), args
+	}
+}
+
+// FuzzySearch implements NoteRepository. Notes are matched on trigram
+// similarity of their title, so misspelled queries still find them.
+func (n *NoteRepositoryImpl) FuzzySearch(ctx context.Context, query string, threshold float64) ([]*models.NoteSearchResult, error) {
+	if isSQLite(n.db) {
+		return n.fuzzySearchTitles(ctx, query, threshold)
+	}
+
+	var results []*models.NoteSearchResult
+	err := n.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// The % operator only uses the trigram index with the session threshold
+		err := tx.Exec("SELECT set_config('pg_trgm.similarity_threshold', ?, true)", strconv.FormatFloat(threshold, 'f', -1, 64)).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&models.Note{}).
+			Select("note.*, similarity(note.title, ?) AS similarity", query).
+			Where("note.title % ?", query).
+			Order("similarity DESC, note.id").
+			Scan(&results).Error
+	})
+	if err != nil {
+		return nil, dbError(err, nil)
+	}
+	return results, nil
+}
+
+// SuggestTitles implements NoteRepository.
+func (n *NoteRepositoryImpl) SuggestTitles(ctx context.Context, prefix string, limit int) ([]string, error) {
+	if isSQLite(n.db) {
+		return n.suggestTitlesByPrefix(ctx, prefix, limit)
+	}
+
+	var titles []string
+	err := n.db.WithContext(ctx).Model(&models.Note{}).
+		Where(`title ILIKE ? ESCAPE '\'`, escapeLike(prefix)+"%").
+		Order(clause.OrderBy{Expression: clause.Expr{SQL: "similarity(title, ?) DESC, title", Vars: []interface{}{prefix}}}).
+		Limit(limit).
+		Pluck("title", &titles).Error
+	if err != nil {
+		return nil, dbError(err, nil)
+	}
+	return titles, nil
+}
+
+// escapeLike escapes the LIKE wildcards in s so it is matched literally.
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,26 @@
+package repository
+
+import (
+	"context"
+	"time"
+)
+
+// queryTimeoutKey holds the timeout of each query of a long-running request.
+type queryTimeoutKey struct{}
+
+// WithQueryTimeout marks ctx as that of a request, such as an export, that
+// runs too many queries to be bounded as a whole. Each of its queries is
+// bounded by timeout instead, through QueryContext.
+func WithQueryTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, queryTimeoutKey{}, timeout)
+}
+
+// QueryContext returns the context to run a query, or a transaction, of ctx
+// in: bounded by the timeout of WithQueryTimeout when ctx has one, and ctx
+// itself otherwise.
+func QueryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if timeout, ok := ctx.Value(queryTimeoutKey{}).(time.Duration); ok && timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return ctx, func() {}
+}
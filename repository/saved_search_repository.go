@@ -0,0 +1,15 @@
+package repository
+
+import (
+	"context"
+	"github.com/sarita-growexx/note_with_alarm/models"
+)
+
+type SavedSearchRepository interface {
+	Create(ctx context.Context, search *models.SavedSearch) error
+	Update(ctx context.Context, search *models.SavedSearch) error
+	Delete(ctx context.Context, id uint) error
+	GetById(ctx context.Context, id uint) (*models.SavedSearch, error)
+	GetAll(ctx context.Context) ([]*models.SavedSearch, error)
+	GetSubscribed(ctx context.Context) ([]*models.SavedSearch, error)
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/sarita-growexx/note_with_alarm/apperrors"
+	"github.com/sarita-growexx/note_with_alarm/models"
+	"gorm.io/gorm"
+)
+
+var ErrSavedSearchNotFound = apperrors.New(apperrors.NotFound, "saved search not found")
+var ErrDuplicateSavedSearchName = apperrors.New(apperrors.Conflict, "duplicate name, please choose a different name")
+
+type SavedSearchRepositoryImpl struct {
+	db *gorm.DB
+}
+
+func NewSavedSearchRepository(db *gorm.DB) SavedSearchRepository {
+	return &SavedSearchRepositoryImpl{db: db}
+}
+
+// Create implements SavedSearchRepository.
+func (r *SavedSearchRepositoryImpl) Create(ctx context.Context, search *models.SavedSearch) error {
+	return dbError(r.db.WithContext(ctx).Create(search).Error, ErrDuplicateSavedSearchName)
+}
+
+// Update implements SavedSearchRepository.
+func (r *SavedSearchRepositoryImpl) Update(ctx context.Context, search *models.SavedSearch) error {
+	result := r.db.WithContext(ctx).Model(&models.SavedSearch{}).Where("id = ?", search.ID).Updates(map[string]interface{}{
+		"name":        search.Name,
+		"query":       search.Query,
+		"sort":        search.Sort,
+		"subscribed":  search.Subscribed,
+		"channel":     search.Channel,
+		"webhook_url": search.WebhookURL,
+		"updated_at":  search.UpdatedAt,
+	})
+	if result.Error != nil {
+		return dbError(result.Error, ErrDuplicateSavedSearchName)
+	}
+	if result.RowsAffected == 0 {
+		return ErrSavedSearchNotFound
+	}
+	return nil
+}
+
+// Delete implements SavedSearchRepository.
+func (r *SavedSearchRepositoryImpl) Delete(ctx context.Context, id uint) error {
+	result := r.db.WithContext(ctx).Delete(&models.SavedSearch{}, id)
+	if result.Error != nil {
+		return dbError(result.Error, nil)
+	}
+	if result.RowsAffected == 0 {
+		return ErrSavedSearchNotFound
+	}
+	return nil
+}
+
+// GetById implements SavedSearchRepository.
+func (r *SavedSearchRepositoryImpl) GetById(ctx context.Context, id uint) (*models.SavedSearch, error) {
+	var search models.SavedSearch
+	err := r.db.WithContext(ctx).First(&search, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSavedSearchNotFound
+	}
+	if err != nil {
+		return nil, dbError(err, nil)
+	}
+	return &search, nil
+}
+
+// GetAll implements SavedSearchRepository.
+func (r *SavedSearchRepositoryImpl) GetAll(ctx context.Context) ([]*models.SavedSearch, error) {
+	var searches []*models.SavedSearch
+	if err := r.db.WithContext(ctx).Order("name").Find(&searches).Error; err != nil {
+		return nil, dbError(err, nil)
+	}
+	return searches, nil
+}
+
+// GetSubscribed implements SavedSearchRepository.
+func (r *SavedSearchRepositoryImpl) GetSubscribed(ctx context.Context) ([]*models.SavedSearch, error) {
+	var searches []*models.SavedSearch
+	if err := r.db.WithContext(ctx).Where("subscribed = ?", true).Order("id").Find(&searches).Error; err != nil {
+		return nil, dbError(err, nil)
+	}
+	return searches, nil
+}
@@ -0,0 +1,49 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sarita-growexx/note_with_alarm/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSavedSearchRepositoryImpl_CRUD(t *testing.T) {
+	db, cleanup := setupTestDB()
+	defer cleanup()
+
+	repo := NewSavedSearchRepository(db)
+
+	search := &models.SavedSearch{
+		Name:  "Overdue_" + time.Now().Format("20060102150405"),
+		Query: "status:overdue tag:urgent",
+		Sort:  "-deadline",
+	}
+	assert.NoError(t, repo.Create(context.Background(), search))
+	defer repo.Delete(context.Background(), search.ID)
+
+	stored, err := repo.GetById(context.Background(), search.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, search.Query, stored.Query)
+	assert.False(t, stored.Subscribed)
+
+	search.Subscribed = true
+	search.Channel = "log"
+	assert.NoError(t, repo.Update(context.Background(), search))
+
+	subscribed, err := repo.GetSubscribed(context.Background())
+	assert.NoError(t, err)
+	var found bool
+	for _, s := range subscribed {
+		if s.ID == search.ID {
+			found = true
+		}
+	}
+	assert.True(t, found)
+
+	assert.NoError(t, repo.Delete(context.Background(), search.ID))
+	_, err = repo.GetById(context.Background(), search.ID)
+	assert.ErrorIs(t, err, ErrSavedSearchNotFound)
+	assert.ErrorIs(t, repo.Delete(context.Background(), search.ID), ErrSavedSearchNotFound)
+}
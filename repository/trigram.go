@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/sarita-growexx/note_with_alarm/models"
+)
+
+// SQLite has no pg_trgm, so fuzzy title matching is computed here with the
+// same trigram similarity. It scans every title, which is fine for the local
+// and test databases SQLite is used for.
+
+// fuzzySearchTitles is FuzzySearch for SQLite.
+func (n *NoteRepositoryImpl) fuzzySearchTitles(ctx context.Context, query string, threshold float64) ([]*models.NoteSearchResult, error) {
+	var notes []*models.Note
+	if err := n.db.WithContext(ctx).Find(&notes).Error; err != nil {
+		return nil, dbError(err, nil)
+	}
+
+	return rankBySimilarity(notes, query, threshold), nil
+}
+
+// suggestTitlesByPrefix is SuggestTitles for SQLite, whose LIKE is already
+// case-insensitive for ASCII.
+func (n *NoteRepositoryImpl) suggestTitlesByPrefix(ctx context.Context, prefix string, limit int) ([]string, error) {
+	var titles []string
+	err := n.db.WithContext(ctx).Model(&models.Note{}).
+		Where(`title LIKE ? ESCAPE '\'`, escapeLike(prefix)+"%").
+		Pluck("title", &titles).Error
+	if err != nil {
+		return nil, dbError(err, nil)
+	}
+
+	return closestTitles(titles, prefix, limit), nil
+}
+
+// rankBySimilarity returns the notes whose title is at least threshold
+// similar to query, most similar first.
+func rankBySimilarity(notes []*models.Note, query string, threshold float64) []*models.NoteSearchResult {
+	var results []*models.NoteSearchResult
+	for _, note := range notes {
+		if score := similarity(note.Title, query); score >= threshold {
+			results = append(results, &models.NoteSearchResult{Note: *note, Similarity: score})
+		}
+	}
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Similarity != results[j].Similarity {
+			return results[i].Similarity > results[j].Similarity
+		}
+		return results[i].ID < results[j].ID
+	})
+	return results
+}
+
+// closestTitles orders titles by similarity to prefix and keeps the first
+// limit of them.
+func closestTitles(titles []string, prefix string, limit int) []string {
+	sort.SliceStable(titles, func(i, j int) bool {
+		si, sj := similarity(titles[i], prefix), similarity(titles[j], prefix)
+		if si != sj {
+			return si > sj
+		}
+		return titles[i] < titles[j]
+	})
+	if len(titles) > limit {
+		titles = titles[:limit]
+	}
+	return titles
+}
+
+// similarity is pg_trgm's similarity: the number of trigrams two strings
+// share divided by the number of distinct trigrams in either.
+func similarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	if len(ta) == 0 || len(tb) == 0 {
+		return 0
+	}
+
+	shared := 0
+	for trigram := range ta {
+		if _, ok := tb[trigram]; ok {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(ta)+len(tb)-shared)
+}
+
+// trigrams returns the set of trigrams of s as pg_trgm extracts them: each
+// lower-cased word is padded with two spaces in front and one behind.
+func trigrams(s string) map[string]struct{} {
+	set := make(map[string]struct{})
+	words := strings.FieldsFunc(strings.ToLower(s), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		padded := []rune("  " + word + " ")
+		for i := 0; i+3 <= len(padded); i++ {
+			set[string(padded[i:i+3])] = struct{}{}
+		}
+	}
+	return set
+}
@@ -0,0 +1,16 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimilarity(t *testing.T) {
+	// Values match pg_trgm's similarity() for the same inputs.
+	assert.Equal(t, 1.0, similarity("Quarterly review", "quarterly REVIEW"))
+	assert.InDelta(t, 0.363636, similarity("word", "two words"), 0.001)
+	assert.InDelta(t, 0.0, similarity("invoice", "plants"), 0.001)
+	assert.Equal(t, 0.0, similarity("", "invoice"))
+	assert.Greater(t, similarity("Pay invoice", "invioce"), similarity("Water plants", "invioce"))
+}
@@ -1,27 +1,129 @@
 package routers
 
 import (
+	"time"
+
 	"github.com/gin-gonic/gin"
 	"github.com/sarita-growexx/note_with_alarm/controllers"
+	"github.com/sarita-growexx/note_with_alarm/helper"
+	"github.com/sarita-growexx/note_with_alarm/middleware"
+	"github.com/sarita-growexx/note_with_alarm/openapi"
+	"github.com/sarita-growexx/note_with_alarm/repository"
 )
 
-func SetupRouter(noteController *controllers.NoteController) *gin.Engine {
-	router := gin.Default()
+// v1 of the API, and the unversioned routes that preceded it, are
+// deprecated in favour of v2 and will be removed after the sunset.
+var (
+	v1DeprecatedAt = time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)
+	v1Sunset       = time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC)
+)
 
-	// Initialize routes
-	api := router.Group("/api")
-	{
-		notes := api.Group("/notes")
-		{
-			notes.POST("/", noteController.CreateNoteHandler)
-			notes.PUT("/:id", noteController.UpdateNoteHandler)
-			notes.DELETE("/:id", noteController.DeleteNoteHandler)
-			notes.GET("/:id", noteController.GetNoteByIDHandler)
-			notes.GET("/", noteController.GetAllNotesHandler)
-			notes.GET("/search", noteController.SearchNotesHandler)
+// apiPrefixes are the prefixes each version of the API is served under.
+var apiPrefixes = []string{"/api", "/api/v1", "/api/v2"}
+
+// unboundedRoutes export, import or feed many notes, so each of their
+// queries is bounded by the query timeout rather than the request as a
+// whole.
+var unboundedRoutes = []string{"/notes/export", "/notes/import", "/notes/import/ics", "/calendar.ics"}
+
+// Request bodies hold at most maxBodyBytes, or maxBulkBodyBytes for bulk
+// requests of up to 1000 operations and maxImportBodyBytes for imports of up
+// to 10000 notes.
+const (
+	maxBodyBytes       = 1 << 20
+	maxBulkBodyBytes   = 8 << 20
+	maxImportBodyBytes = 32 << 20
+)
+
+// bodyLimits returns the limits on request bodies of every route.
+func bodyLimits() middleware.BodyLimits {
+	limits := middleware.BodyLimits{Default: maxBodyBytes, Routes: make(map[string]int64)}
+	for _, path := range apiPaths("/notes/bulk") {
+		limits.Routes[path] = maxBulkBodyBytes
+	}
+	for _, path := range apiPaths("/notes/import", "/notes/import/ics") {
+		limits.Routes[path] = maxImportBodyBytes
+	}
+	return limits
+}
+
+// apiPaths returns the full paths of routes under every prefix of the API.
+func apiPaths(routes ...string) []string {
+	paths := make([]string, 0, len(apiPrefixes)*len(routes))
+	for _, prefix := range apiPrefixes {
+		for _, route := range routes {
+			paths = append(paths, prefix+route)
 		}
 	}
+	return paths
+}
 
-	router.GET("/", noteController.GetAllNotesHandler)
+func SetupRouter(noteController *controllers.NoteController, savedSearchController *controllers.SavedSearchController, calendarController *controllers.CalendarController, idempotencyKeys repository.IdempotencyKeyRepository, queryTimeout, idempotencyTTL time.Duration) *gin.Engine {
+	spec, err := openapi.Load()
+	helper.ErrorPanic(err)
+
+	router := gin.New()
+	// Calendar apps open the feed with the token in its URL, so it is kept
+	// out of the logs.
+	router.Use(middleware.Logger(gin.DefaultWriter, "token"), middleware.Recovery())
+	// Responses are checked against the OpenAPI document in tests, so that
+	// handlers cannot drift from it unnoticed.
+	router.Use(middleware.RequestTimeout(queryTimeout, apiPaths(unboundedRoutes...)...), middleware.OpenAPI(spec, gin.Mode() == gin.TestMode, bodyLimits()), middleware.Errors())
+
+	api := router.Group("/api")
+	api.GET("/openapi.json", openapi.SpecHandler)
+	api.GET("/docs", openapi.DocsHandler)
+	api.GET("/docs/:file", openapi.DocsAssetHandler)
+
+	// v1 answers as the API did before it was versioned, as do the
+	// unversioned routes it replaced; both point clients to v2.
+	deprecated := middleware.Deprecated(v1DeprecatedAt, v1Sunset, "/api/v2")
+	// Clients retrying a create with the same Idempotency-Key get the note
+	// created by their first attempt. An attempt still in progress after the
+	// request timeout has died with its server, and a retry takes its place.
+	idempotent := middleware.Idempotency(idempotencyKeys, idempotencyTTL, queryTimeout)
+	registerRoutes(api.Group("", deprecated), idempotent, noteController, savedSearchController, calendarController)
+	registerRoutes(api.Group("/v1", deprecated), idempotent, noteController, savedSearchController, calendarController)
+	registerRoutes(api.Group("/v2"), idempotent, noteController.V2(), savedSearchController.V2(), calendarController.V2())
+
+	router.GET("/", deprecated, noteController.GetAllNotesHandler)
 	return router
 }
+
+// registerRoutes adds the note, saved search and calendar routes of a
+// version of the API to group. Note creation goes through idempotent.
+func registerRoutes(group *gin.RouterGroup, idempotent gin.HandlerFunc, noteController *controllers.NoteController, savedSearchController *controllers.SavedSearchController, calendarController *controllers.CalendarController) {
+	notes := group.Group("/notes")
+	{
+		notes.POST("/", idempotent, noteController.CreateNoteHandler)
+		notes.POST("/bulk", noteController.BulkNotesHandler)
+		notes.POST("/import", noteController.ImportNotesHandler)
+		notes.POST("/import/ics", noteController.ImportICSHandler)
+		notes.GET("/export", noteController.ExportNotesHandler)
+		notes.PUT("/:id", noteController.UpdateNoteHandler)
+		notes.PATCH("/:id", noteController.PatchNoteHandler)
+		notes.DELETE("/:id", noteController.DeleteNoteHandler)
+		notes.GET("/:id", noteController.GetNoteByIDHandler)
+		notes.GET("/", noteController.GetAllNotesHandler)
+		notes.GET("/search", noteController.SearchNotesHandler)
+		notes.GET("/suggest", noteController.SuggestTitlesHandler)
+		notes.GET("/:id/revisions", noteController.GetNoteRevisionsHandler)
+		notes.GET("/:id/revisions/diff", noteController.DiffNoteRevisionsHandler)
+		notes.GET("/:id/revisions/:rev", noteController.GetNoteRevisionHandler)
+		notes.POST("/:id/revisions/:rev/restore", noteController.RestoreNoteRevisionHandler)
+	}
+
+	savedSearches := group.Group("/saved-searches")
+	{
+		savedSearches.POST("/", savedSearchController.CreateSavedSearchHandler)
+		savedSearches.GET("/", savedSearchController.GetAllSavedSearchesHandler)
+		savedSearches.GET("/:id", savedSearchController.GetSavedSearchHandler)
+		savedSearches.PUT("/:id", savedSearchController.UpdateSavedSearchHandler)
+		savedSearches.DELETE("/:id", savedSearchController.DeleteSavedSearchHandler)
+		savedSearches.GET("/:id/notes", savedSearchController.RunSavedSearchHandler)
+	}
+
+	group.GET("/calendar.ics", calendarController.CalendarFeedHandler)
+	group.POST("/calendar/tokens", calendarController.CreateCalendarTokenHandler)
+	group.DELETE("/calendar/tokens", calendarController.RevokeCalendarTokenHandler)
+}
@@ -0,0 +1,376 @@
+package routers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sarita-growexx/note_with_alarm/controllers"
+	"github.com/sarita-growexx/note_with_alarm/openapi"
+	"github.com/sarita-growexx/note_with_alarm/repository"
+	"github.com/sarita-growexx/note_with_alarm/services"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRouter sets up the router in test mode over a memory repository.
+// Saved searches have no repository, so only their routes can be listed.
+func newTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	noteRepository := repository.NewMemoryNoteRepository()
+	noteController := controllers.NewNoteController(services.NewNoteService(noteRepository))
+	savedSearchController := controllers.NewSavedSearchController(services.NewSavedSearchService(nil, noteRepository))
+	calendarController := controllers.NewCalendarController(services.NewCalendarService(repository.NewMemoryCalendarTokenRepository(), noteRepository))
+	return SetupRouter(noteController, savedSearchController, calendarController, repository.NewMemoryIdempotencyKeyRepository(), time.Second, time.Hour)
+}
+
+// TestOpenAPICoversRoutes fails when a route is registered without being
+// described in the OpenAPI document.
+func TestOpenAPICoversRoutes(t *testing.T) {
+	router := newTestRouter()
+
+	var spec struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	require.NoError(t, json.Unmarshal(openapi.Spec(), &spec))
+	assert.Equal(t, "3.1.0", spec.OpenAPI)
+
+	for _, route := range router.Routes() {
+		path := openapi.PathOf(route.Path)
+		_, ok := spec.Paths[path][strings.ToLower(route.Method)]
+		assert.True(t, ok, "%s %s is missing from openapi.json", route.Method, path)
+	}
+}
+
+func TestOpenAPIRoutes(t *testing.T) {
+	router := newTestRouter()
+
+	req, _ := http.NewRequest("GET", "/api/openapi.json", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.Equal(t, openapi.Spec(), w.Body.Bytes())
+
+	req, _ = http.NewRequest("GET", "/api/docs", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `url: "openapi.json"`)
+	// Swagger UI is served by the API itself rather than a CDN
+	assert.NotContains(t, w.Body.String(), "https://")
+	assert.Contains(t, w.Body.String(), `src="docs/swagger-ui-bundle.js"`)
+
+	req, _ = http.NewRequest("GET", "/api/docs/swagger-ui-bundle.js", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/javascript; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), "SwaggerUIBundle")
+
+	req, _ = http.NewRequest("GET", "/api/docs/swagger-ui.css", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/css; charset=utf-8", w.Header().Get("Content-Type"))
+
+	req, _ = http.NewRequest("GET", "/api/docs/openapi.go", nil)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+// TestRoutesMatchOpenAPI drives the API through the router in test mode, so
+// every response is checked against the OpenAPI document.
+func TestRoutesMatchOpenAPI(t *testing.T) {
+	send := sender(newTestRouter())
+	deadline := time.Now().Add(48 * time.Hour).UTC().Format(time.RFC3339)
+
+	w := send("POST", "/api/notes/", "application/json", `{"title":"Pay invoice","description":"Billing for March","deadline":"`+deadline+`","tags":["work"],"reminders":[60]}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Equal(t, `"1"`, w.Header().Get("ETag"))
+
+	for _, path := range []string{"/api/notes/1", "/api/notes/?limit=10&sort=title", "/", "/api/notes/search?query=invoice", "/api/notes/search?query=invoce&mode=fuzzy", "/api/notes/suggest?prefix=Pay", "/api/notes/1/revisions", "/api/notes/1/revisions/1"} {
+		w = send("GET", path, "", "")
+		assert.Equal(t, http.StatusOK, w.Code, "%s: %s", path, w.Body.String())
+	}
+
+	w = send("PATCH", "/api/notes/1", "application/merge-patch+json", `{"description":null}`, "If-Match", `"1"`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = send("GET", "/api/notes/1/revisions/diff?from=1&to=2", "", "")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = send("POST", "/api/notes/1/revisions/1/restore", "", "")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = send("GET", "/api/notes/2", "", "")
+	assert.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+	w = send("PUT", "/api/notes/1", "application/json", `{"title":"Pay invoice","deadline":"`+deadline+`"}`, "If-Match", `"1"`)
+	assert.Equal(t, http.StatusPreconditionFailed, w.Code, w.Body.String())
+	w = send("DELETE", "/api/notes/1", "", "", "If-Match", `"3"`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = send("POST", "/api/notes/bulk", "application/json", `{"mode":"best_effort","operations":[{"action":"create","note":{"title":"Bulk note","deadline":"`+deadline+`"}},{"action":"delete","id":1,"version":4}]}`)
+	assert.Equal(t, http.StatusMultiStatus, w.Code, w.Body.String())
+
+	// Requests that break the document are rejected before any handler runs
+	w = send("GET", "/api/notes/abc", "", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `{"field":"id","rule":"type","param":"integer","message":"must be of type integer, not string"}`)
+	w = send("GET", "/api/notes/?limit=0&overdue=maybe", "", "")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `{"field":"limit","rule":"min","param":"1","message":"must be at least 1"}`)
+	assert.Contains(t, w.Body.String(), `{"field":"overdue","rule":"type","param":"boolean","message":"must be of type boolean, not string"}`)
+	w = send("GET", "/api/notes/search", "", "")
+	assert.Contains(t, w.Body.String(), `{"field":"query","rule":"required","message":"is required"}`)
+	w = send("POST", "/api/notes/", "application/json", `{"title":7,"tags":["a b"]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `{"field":"title","rule":"type","param":"string","message":"must be of type string, not integer"}`)
+	assert.Contains(t, w.Body.String(), `{"field":"deadline","rule":"required","message":"is required"}`)
+	assert.Contains(t, w.Body.String(), `"field":"tags[0]","rule":"pattern"`)
+	w = send("POST", "/api/notes/", "text/plain", `title`)
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	w = send("POST", "/api/notes/", "application/json", `{"title":`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// TestV2RoutesMatchOpenAPI drives v2 through the router in test mode, so its
+// envelopes are checked against the OpenAPI document.
+func TestV2RoutesMatchOpenAPI(t *testing.T) {
+	send := sender(newTestRouter())
+	deadline := time.Now().Add(48 * time.Hour).UTC().Format(time.RFC3339)
+
+	w := send("POST", "/api/v2/notes/", "application/json", `{"title":"Pay invoice","deadline":"`+deadline+`","tags":["work"]}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	assert.Empty(t, w.Header().Get("Deprecation"))
+
+	var created struct {
+		Data struct {
+			ID    uint   `json:"id"`
+			Title string `json:"title"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, uint(1), created.Data.ID)
+	assert.Equal(t, "Pay invoice", created.Data.Title)
+
+	send("POST", "/api/v2/notes/", "application/json", `{"title":"Call bank","deadline":"`+deadline+`"}`)
+
+	w = send("GET", "/api/v2/notes/?limit=1&sort=title", "", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var page struct {
+		Data       []map[string]interface{} `json:"data"`
+		Pagination struct {
+			Total      int64  `json:"total"`
+			Limit      int    `json:"limit"`
+			NextCursor string `json:"next_cursor"`
+		} `json:"pagination"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &page))
+	assert.Len(t, page.Data, 1)
+	assert.Equal(t, "Call bank", page.Data[0]["title"])
+	assert.Equal(t, int64(2), page.Pagination.Total)
+	assert.Equal(t, 1, page.Pagination.Limit)
+	assert.NotEmpty(t, page.Pagination.NextCursor)
+
+	for _, path := range []string{"/api/v2/notes/1", "/api/v2/notes/search?query=invoice", "/api/v2/notes/suggest?prefix=Pay", "/api/v2/notes/1/revisions", "/api/v2/notes/1/revisions/1"} {
+		w = send("GET", path, "", "")
+		assert.Equal(t, http.StatusOK, w.Code, "%s: %s", path, w.Body.String())
+		assert.True(t, strings.HasPrefix(w.Body.String(), `{"data":`), "%s: %s", path, w.Body.String())
+	}
+
+	w = send("PATCH", "/api/v2/notes/1", "application/merge-patch+json", `{"description":"Billing for March"}`, "If-Match", `"1"`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = send("GET", "/api/v2/notes/1/revisions/diff?from=1&to=2", "", "")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = send("POST", "/api/v2/notes/1/revisions/1/restore", "", "")
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = send("DELETE", "/api/v2/notes/1", "", "", "If-Match", `"3"`)
+	assert.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+	assert.Empty(t, w.Body.String())
+
+	w = send("POST", "/api/v2/notes/bulk", "application/json", `{"operations":[{"action":"create","note":{"title":"Bulk note","deadline":"`+deadline+`"}},{"action":"delete","id":2,"version":1}]}`)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `{"data":{"succeeded":2,"failed":0,`)
+
+	// Errors are the same problem details in every version
+	w = send("GET", "/api/v2/notes/1", "", "")
+	assert.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+}
+
+func TestCreateIsIdempotent(t *testing.T) {
+	send := sender(newTestRouter())
+	deadline := time.Now().Add(48 * time.Hour).UTC().Format(time.RFC3339)
+	body := `{"title":"Pay invoice","deadline":"` + deadline + `"}`
+
+	first := send("POST", "/api/v2/notes/", "application/json", body, "Idempotency-Key", "8e0f3b1c")
+	require.Equal(t, http.StatusCreated, first.Code, first.Body.String())
+
+	// The retry gets the first note rather than a duplicate title error
+	retry := send("POST", "/api/v2/notes/", "application/json", body, "Idempotency-Key", "8e0f3b1c")
+	assert.Equal(t, http.StatusCreated, retry.Code, retry.Body.String())
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, `"1"`, retry.Header().Get("ETag"))
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+
+	w := send("POST", "/api/v2/notes/", "application/json", `{"title":"Call bank","deadline":"`+deadline+`"}`, "Idempotency-Key", "8e0f3b1c")
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code, w.Body.String())
+
+	// Without a key, the retry is a new request
+	w = send("POST", "/api/v2/notes/", "application/json", body)
+	assert.Equal(t, http.StatusConflict, w.Code, w.Body.String())
+}
+
+func TestImportAndExport(t *testing.T) {
+	send := sender(newTestRouter())
+	deadline := time.Now().Add(48 * time.Hour).UTC().Format(time.RFC3339)
+	csv := "title,deadline,tags,reminders\nPay invoice," + deadline + ",home,30\nCall bank," + deadline + ",,\n"
+
+	w := send("POST", "/api/v2/notes/import?dry_run=true", "text/csv", csv)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"created":2`)
+
+	w = send("POST", "/api/v2/notes/import", "text/csv", csv)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = send("GET", "/api/v2/notes/export?format=ndjson&sort=title", "", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	export := w.Body.String()
+	assert.Equal(t, 2, strings.Count(export, "\n"))
+
+	// Importing an export again renames the notes it holds
+	w = send("POST", "/api/v1/notes/import?on_conflict=rename", "application/x-ndjson", export)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"title":"Call bank (2)"`)
+
+	w = send("GET", "/api/notes/export?format=csv", "", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, 5, strings.Count(w.Body.String(), "\n"))
+
+	w = send("POST", "/api/v2/notes/import", "application/xml", "<notes/>")
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code, w.Body.String())
+}
+
+func TestCalendarFeed(t *testing.T) {
+	send := sender(newTestRouter())
+	deadline := time.Now().Add(48 * time.Hour).UTC().Format(time.RFC3339)
+	w := send("POST", "/api/v2/notes/", "application/json", `{"title":"Pay invoice","deadline":"`+deadline+`","tags":["home"]}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	w = send("POST", "/api/v2/calendar/tokens", "", "", "X-User", "asha")
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created struct {
+		Data struct {
+			FeedURL string `json:"feed_url"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	feed, err := url.Parse(created.Data.FeedURL)
+	require.NoError(t, err)
+	assert.Equal(t, "/api/v2/calendar.ics", feed.Path)
+
+	w = send("GET", feed.RequestURI()+"&tag=home", "", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), "SUMMARY:Pay invoice\r\n")
+
+	w = send("GET", "/api/v1/calendar.ics?token=guess", "", "")
+	assert.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+
+	w = send("DELETE", "/api/v1/calendar/tokens", "", "", "X-User", "asha")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = send("GET", feed.RequestURI(), "", "")
+	assert.Equal(t, http.StatusNotFound, w.Code, w.Body.String())
+}
+
+func TestImportICS(t *testing.T) {
+	send := sender(newTestRouter())
+	deadline := time.Now().Add(48 * time.Hour).UTC().Format(time.RFC3339)
+	w := send("POST", "/api/v2/notes/", "application/json", `{"title":"Pay invoice","deadline":"`+deadline+`","reminders":[30]}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var note struct {
+		Data struct {
+			Deadline time.Time `json:"deadline"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &note))
+
+	// The calendar feed imports back as the notes it was made of
+	w = send("POST", "/api/v2/calendar/tokens", "", "", "X-User", "asha")
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created struct {
+		Data struct {
+			FeedURL string `json:"feed_url"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	feed, err := url.Parse(created.Data.FeedURL)
+	require.NoError(t, err)
+	w = send("GET", feed.RequestURI(), "", "")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	ics := w.Body.String()
+
+	w = send("POST", "/api/v2/notes/import/ics?dry_run=true", "text/calendar", ics)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"reason":"The title is taken"`)
+
+	w = send("POST", "/api/v1/notes/import/ics?on_conflict=rename", "text/calendar", ics)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var report struct {
+		Created int `json:"created"`
+		Rows    []struct {
+			Note struct {
+				Title     string    `json:"title"`
+				Deadline  time.Time `json:"deadline"`
+				Reminders []int     `json:"reminders"`
+			} `json:"note"`
+		} `json:"rows"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(t, 1, report.Created)
+	if assert.Len(t, report.Rows, 1) {
+		imported := report.Rows[0].Note
+		assert.Equal(t, "Pay invoice (2)", imported.Title)
+		assert.True(t, note.Data.Deadline.Equal(imported.Deadline), imported.Deadline)
+		assert.Equal(t, []int{30}, imported.Reminders)
+	}
+
+	w = send("POST", "/api/v2/notes/import/ics", "text/plain", ics)
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code, w.Body.String())
+}
+
+func TestV1IsDeprecated(t *testing.T) {
+	send := sender(newTestRouter())
+
+	for _, path := range []string{"/api/v1/notes/", "/api/notes/", "/"} {
+		w := send("GET", path, "", "")
+		assert.Equal(t, http.StatusOK, w.Code, "%s: %s", path, w.Body.String())
+		assert.Equal(t, "@1792281600", w.Header().Get("Deprecation"), path)
+		assert.Equal(t, "Fri, 30 Apr 2027 00:00:00 GMT", w.Header().Get("Sunset"), path)
+		assert.Equal(t, `</api/v2>; rel="successor-version"`, w.Header().Get("Link"), path)
+		assert.JSONEq(t, `{"notes":[],"total":0}`, w.Body.String(), path)
+	}
+
+	w := send("GET", "/api/docs", "", "")
+	assert.Empty(t, w.Header().Get("Deprecation"))
+}
+
+// sender returns a function that sends a request through router, with
+// header given as name and value pairs.
+func sender(router *gin.Engine) func(method, path, contentType, body string, header ...string) *httptest.ResponseRecorder {
+	return func(method, path, contentType, body string, header ...string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest(method, path, strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+}
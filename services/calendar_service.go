@@ -0,0 +1,13 @@
+package services
+
+import (
+	"context"
+
+	"github.com/sarita-growexx/note_with_alarm/models"
+)
+
+type CalendarService interface {
+	CreateToken(ctx context.Context, userName string) (string, error)
+	RevokeToken(ctx context.Context, userName string) error
+	Feed(ctx context.Context, token string, tag string, fn func(note *models.Note) error) error
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/sarita-growexx/note_with_alarm/apperrors"
+	"github.com/sarita-growexx/note_with_alarm/models"
+	"github.com/sarita-growexx/note_with_alarm/repository"
+)
+
+// calendarTokenBytes is how many random bytes make a calendar token.
+const calendarTokenBytes = 32
+
+// ErrCalendarFeedNotFound is returned for a calendar token that does not
+// exist, or was replaced or revoked.
+var ErrCalendarFeedNotFound = apperrors.New(apperrors.NotFound, "calendar feed not found")
+
+type CalendarServiceImpl struct {
+	calendarTokenRepository repository.CalendarTokenRepository
+	noteRepository          repository.NoteRepository
+}
+
+func NewCalendarService(calendarTokenRepository repository.CalendarTokenRepository, noteRepository repository.NoteRepository) *CalendarServiceImpl {
+	return &CalendarServiceImpl{
+		calendarTokenRepository: calendarTokenRepository,
+		noteRepository:          noteRepository,
+	}
+}
+
+// CreateToken gives a user a new token for their calendar feed, which
+// replaces the token they had, if any.
+func (s *CalendarServiceImpl) CreateToken(ctx context.Context, userName string) (string, error) {
+	secret := make([]byte, calendarTokenBytes)
+	if _, err := rand.Read(secret); err != nil {
+		return "", failed("failed to create calendar token", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(secret)
+
+	err := s.calendarTokenRepository.Save(ctx, &models.CalendarToken{
+		UserName:  userName,
+		TokenHash: hashCalendarToken(token),
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		return "", failed("failed to save calendar token", err)
+	}
+	return token, nil
+}
+
+// RevokeToken deletes the token of a user, so their feed stops answering.
+func (s *CalendarServiceImpl) RevokeToken(ctx context.Context, userName string) error {
+	return failed("failed to revoke calendar token", s.calendarTokenRepository.Delete(ctx, userName))
+}
+
+// Feed passes the notes of the calendar feed of token to fn, by deadline:
+// all notes, or those with the given tag. It returns ErrCalendarFeedNotFound
+// for an unknown token.
+func (s *CalendarServiceImpl) Feed(ctx context.Context, token string, tag string, fn func(note *models.Note) error) error {
+	queryCtx, cancel := repository.QueryContext(ctx)
+	_, err := s.calendarTokenRepository.GetByHash(queryCtx, hashCalendarToken(token))
+	cancel()
+	if errors.Is(err, repository.ErrCalendarTokenNotFound) {
+		return ErrCalendarFeedNotFound
+	}
+	if err != nil {
+		return failed("failed to check calendar token", err)
+	}
+
+	return eachNote(ctx, s.noteRepository, models.NoteListQuery{Sort: "deadline", Tag: tag}, fn)
+}
+
+// hashCalendarToken is the hash a token is stored and looked up by.
+func hashCalendarToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
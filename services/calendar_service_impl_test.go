@@ -0,0 +1,55 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sarita-growexx/note_with_alarm/models"
+	"github.com/sarita-growexx/note_with_alarm/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCalendarServiceImpl(t *testing.T) {
+	ctx := context.Background()
+	noteRepository := repository.NewMemoryNoteRepository()
+	service := NewCalendarService(repository.NewMemoryCalendarTokenRepository(), noteRepository)
+
+	later := &models.Note{Title: "Call bank", Deadline: time.Now().Add(48 * time.Hour), Tags: models.Tags{"work"}}
+	require.NoError(t, noteRepository.Create(ctx, later))
+	sooner := &models.Note{Title: "Pay invoice", Deadline: time.Now().Add(time.Hour), Tags: models.Tags{"home"}}
+	require.NoError(t, noteRepository.Create(ctx, sooner))
+
+	feed := func(token, tag string) ([]string, error) {
+		var titles []string
+		err := service.Feed(ctx, token, tag, func(note *models.Note) error {
+			titles = append(titles, note.Title)
+			return nil
+		})
+		return titles, err
+	}
+
+	token, err := service.CreateToken(ctx, "asha")
+	require.NoError(t, err)
+	assert.Len(t, token, 43)
+
+	titles, err := feed(token, "")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Pay invoice", "Call bank"}, titles)
+	titles, err = feed(token, "work")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Call bank"}, titles)
+
+	// A new token replaces the old one
+	newToken, err := service.CreateToken(ctx, "asha")
+	require.NoError(t, err)
+	assert.NotEqual(t, token, newToken)
+	_, err = feed(token, "")
+	assert.ErrorIs(t, err, ErrCalendarFeedNotFound)
+
+	require.NoError(t, service.RevokeToken(ctx, "asha"))
+	_, err = feed(newToken, "")
+	assert.ErrorIs(t, err, ErrCalendarFeedNotFound)
+	assert.ErrorIs(t, service.RevokeToken(ctx, "asha"), repository.ErrCalendarTokenNotFound)
+}
@@ -0,0 +1,13 @@
+package services
+
+import "github.com/sarita-growexx/note_with_alarm/apperrors"
+
+// failed reports an error from a lower layer. Domain errors the client can
+// act on, such as a missing note or a duplicate title, pass through as they
+// are; anything else becomes an internal error described by message.
+func failed(message string, err error) error {
+	if err == nil || apperrors.KindOf(err) != apperrors.Internal {
+		return err
+	}
+	return apperrors.Wrap(apperrors.Internal, message, err)
+}
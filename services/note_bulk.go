@@ -0,0 +1,203 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/sarita-growexx/note_with_alarm/models"
+	"github.com/sarita-growexx/note_with_alarm/repository"
+	"github.com/sarita-growexx/note_with_alarm/utils"
+)
+
+// bulkBatchSize is how many notes, or revisions, a bulk request inserts per
+// statement.
+const bulkBatchSize = 100
+
+// bulkStepSize is the most creates applied together, so that the steps of a
+// large import stay short.
+const bulkStepSize = 1000
+
+// ErrBulkAborted is the result of the operations of an atomic bulk request
+// that were rolled back, or never tried, because another operation failed.
+var ErrBulkAborted = errors.New("not applied, another operation of the request failed")
+
+// errBulkFailed rolls back an atomic bulk request once an operation failed.
+var errBulkFailed = errors.New("bulk operation failed")
+
+// BulkNotes applies operations in order and reports the result of each.
+// Consecutive creates are inserted together, in batches.
+//
+// When atomic, all operations run in one transaction, which is rolled back
+// by the first failure; the other operations then report ErrBulkAborted.
+// Otherwise every run of creates, and every update or delete, commits on its
+// own, and failures do not stop the operations after them.
+//
+// Alarms are set for every note created or updated.
+func (s *NoteServiceImpl) BulkNotes(ctx context.Context, operations []models.BulkOperation, atomic bool, change models.ChangeInfo) ([]models.BulkResult, error) {
+	results := make([]models.BulkResult, len(operations))
+	steps := bulkSteps(operations)
+
+	if atomic {
+		txCtx, cancel := repository.QueryContext(ctx)
+		defer cancel()
+		err := s.noteRepository.WithinTx(txCtx, func(repo repository.NoteRepository) error {
+			for _, step := range steps {
+				if err := applyBulkStep(txCtx, repo, operations, step, results, change); err != nil {
+					return err
+				}
+				if bulkFailed(results[step.start:step.end]) {
+					return errBulkFailed
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			if !bulkFailed(results) {
+				return nil, failed("failed to apply bulk operations", err)
+			}
+			for i := range results {
+				if results[i].Err == nil {
+					results[i] = models.BulkResult{Err: ErrBulkAborted}
+				}
+			}
+			return results, nil
+		}
+	} else {
+		// Each step commits on its own, and is bounded on its own in requests,
+		// such as imports, that bound their queries one by one
+		for _, step := range steps {
+			txCtx, cancel := repository.QueryContext(ctx)
+			err := s.noteRepository.WithinTx(txCtx, func(repo repository.NoteRepository) error {
+				return applyBulkStep(txCtx, repo, operations, step, results, change)
+			})
+			cancel()
+			if err != nil {
+				failStep(results[step.start:step.end], err)
+			}
+		}
+	}
+
+	var changed []*models.Note
+	for i, result := range results {
+		if result.Err == nil && operations[i].Action != models.BulkDelete {
+			changed = append(changed, result.Note)
+		}
+	}
+	utils.SetAlarmForNotes(changed)
+
+	return results, nil
+}
+
+// bulkStep is a range of bulk operations applied together: a run of up to
+// bulkStepSize creates, or a single update or delete.
+type bulkStep struct {
+	start, end int
+}
+
+func bulkSteps(operations []models.BulkOperation) []bulkStep {
+	var steps []bulkStep
+	for i, operation := range operations {
+		if operation.Action == models.BulkCreate && len(steps) > 0 {
+			last := &steps[len(steps)-1]
+			if last.end == i && operations[last.start].Action == models.BulkCreate && last.end-last.start < bulkStepSize {
+				last.end++
+				continue
+			}
+		}
+		steps = append(steps, bulkStep{start: i, end: i + 1})
+	}
+	return steps
+}
+
+// applyBulkStep applies a step through repo, recording the result of each of
+// its operations. It returns an error when the step must be rolled back.
+func applyBulkStep(ctx context.Context, repo repository.NoteRepository, operations []models.BulkOperation, step bulkStep, results []models.BulkResult, change models.ChangeInfo) error {
+	operation := operations[step.start]
+	switch operation.Action {
+	case models.BulkCreate:
+		notes := make([]*models.Note, 0, step.end-step.start)
+		for _, operation := range operations[step.start:step.end] {
+			notes = append(notes, operation.Note)
+		}
+		return createNotes(ctx, repo, notes, results[step.start:step.end], change)
+	case models.BulkUpdate:
+		results[step.start] = models.BulkResult{Note: operation.Note, Err: updateNote(ctx, repo, operation.Note, change)}
+		return results[step.start].Err
+	case models.BulkDelete:
+		results[step.start].Err = failed("failed to delete note", repo.Delete(ctx, operation.Note.ID, operation.Note.Version))
+		return results[step.start].Err
+	}
+	return nil
+}
+
+// createNotes creates notes in batches and records their first revisions.
+// The titles already taken are looked up together. Notes that cannot be
+// created, such as those with a title already taken, fail on their own; the
+// error of a batch fails all of them.
+func createNotes(ctx context.Context, repo repository.NoteRepository, notes []*models.Note, results []models.BulkResult, change models.ChangeInfo) error {
+	now := time.Now()
+	wanted := make([]string, len(notes))
+	for i, note := range notes {
+		wanted[i] = note.Title
+	}
+	taken, err := repo.GetNotesByTitles(ctx, wanted)
+	if err != nil {
+		return failed("failed to check duplicate titles", err)
+	}
+
+	titles := make(map[string]bool, len(notes))
+	valid := make([]*models.Note, 0, len(notes))
+	for i, note := range notes {
+		results[i].Note = note
+
+		deadline, err := normalizeDeadline(note.Deadline)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+
+		if taken[note.Title] != nil || titles[note.Title] {
+			results[i].Err = repository.ErrDuplicateTitle
+			continue
+		}
+		titles[note.Title] = true
+
+		note.Deadline = deadline
+		note.Version = 1
+		note.CreatedAt = now
+		note.UpdatedAt = now
+		valid = append(valid, note)
+	}
+
+	if err := repo.CreateInBatches(ctx, valid, bulkBatchSize); err != nil {
+		return failed("failed to create notes", err)
+	}
+
+	revisions := make([]*models.NoteRevision, 0, len(valid))
+	for _, note := range valid {
+		revisions = append(revisions, newRevision(note, changedFields(&models.Note{}, note), change))
+	}
+	if err := repo.CreateRevisionsInBatches(ctx, revisions, bulkBatchSize); err != nil {
+		return failed("failed to record note revisions", err)
+	}
+	return nil
+}
+
+// failStep records err for the operations of a step that was rolled back.
+func failStep(results []models.BulkResult, err error) {
+	for i := range results {
+		if results[i].Err == nil {
+			results[i].Err = err
+		}
+	}
+}
+
+func bulkFailed(results []models.BulkResult) bool {
+	for _, result := range results {
+		if result.Err != nil {
+			return true
+		}
+	}
+	return false
+}
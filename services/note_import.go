@@ -0,0 +1,205 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sarita-growexx/note_with_alarm/apperrors"
+	"github.com/sarita-growexx/note_with_alarm/models"
+	"github.com/sarita-growexx/note_with_alarm/repository"
+)
+
+const (
+	// exportPageSize is how many notes an export reads at a time.
+	exportPageSize = repository.MaxListLimit
+	// maxTitleLength is the longest title a note may have, which renamed
+	// notes must keep to.
+	maxTitleLength = 50
+	// maxRenameAttempts bounds the search for a free title.
+	maxRenameAttempts = 1000
+	// renameBatchSize is how many numbered titles are looked up at a time.
+	renameBatchSize = 20
+)
+
+// ErrDuplicateImportTitle is the result of a note that would overwrite a note
+// already overwritten, or created, by an earlier note of the same import.
+var ErrDuplicateImportTitle = apperrors.New(apperrors.Conflict, "title appears more than once in the import")
+
+// ExportNotes passes every note matching query to fn, in the order of the
+// query, reading them a page at a time so that they are never all held in
+// memory. The limit and cursor of query are ignored. It stops at the first
+// error fn returns.
+func (s *NoteServiceImpl) ExportNotes(ctx context.Context, query models.NoteListQuery, fn func(note *models.Note) error) error {
+	return eachNote(ctx, s.noteRepository, query, fn)
+}
+
+// eachNote lists the notes matching query a page at a time, passing each to
+// fn, and stops at the first error fn returns. Each page is a query of its
+// own, bounded by repository.QueryContext. The pages are not counted, and
+// all judge Overdue at the time the listing starts.
+func eachNote(ctx context.Context, noteRepository repository.NoteRepository, query models.NoteListQuery, fn func(note *models.Note) error) error {
+	query.Limit = exportPageSize
+	query.Cursor = ""
+	query.SkipTotal = true
+	if query.Now.IsZero() {
+		query.Now = time.Now()
+	}
+	for {
+		queryCtx, cancel := repository.QueryContext(ctx)
+		page, err := noteRepository.List(queryCtx, query)
+		cancel()
+		if err != nil {
+			return failed("failed to list notes", err)
+		}
+		for _, note := range page.Notes {
+			if err := fn(note); err != nil {
+				return err
+			}
+		}
+		if page.NextCursor == "" {
+			return nil
+		}
+		query.Cursor = page.NextCursor
+	}
+}
+
+// ImportNotes creates notes from an import and reports the result of each,
+// in order. A note whose title is taken, by a stored note or an earlier note
+// of the import, is handled according to policy: skipped, imported under a
+// free title, or written over the stored note it collides with.
+//
+// The notes are written as a best-effort bulk request, so a failing note
+// does not stop the others, and alarms are set for every note written. In a
+// dry run nothing is written and the results say what would have been done.
+func (s *NoteServiceImpl) ImportNotes(ctx context.Context, notes []*models.Note, policy models.ConflictPolicy, dryRun bool, change models.ChangeInfo) ([]models.ImportResult, error) {
+	results := make([]models.ImportResult, len(notes))
+	var operations []models.BulkOperation
+	var imported []int
+	// claimed holds the titles taken by earlier notes of the import.
+	claimed := make(map[string]bool, len(notes))
+	taken, err := s.takenTitles(ctx, notes)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, note := range notes {
+		results[i].Note = note
+		// Deadlines are normalized up front so that dry runs preview them as
+		// they would be stored.
+		deadline, err := normalizeDeadline(note.Deadline)
+		if err != nil {
+			return nil, err
+		}
+		note.Deadline = deadline
+
+		existingNote := taken[note.Title]
+		operation := models.BulkOperation{Action: models.BulkCreate, Note: note}
+		switch {
+		case existingNote == nil && !claimed[note.Title]:
+		case policy == models.ConflictRename:
+			title, err := s.freeTitle(ctx, note.Title, claimed)
+			if err != nil {
+				return nil, err
+			}
+			results[i].RenamedFrom = note.Title
+			note.Title = title
+		case policy == models.ConflictOverwrite && !claimed[note.Title]:
+			operation.Action = models.BulkUpdate
+			note.ID = existingNote.ID
+			note.Version = existingNote.Version
+		case policy == models.ConflictOverwrite:
+			results[i].Err = ErrDuplicateImportTitle
+			continue
+		default:
+			results[i].Action = models.ImportSkip
+			continue
+		}
+
+		claimed[note.Title] = true
+		results[i].Action = importAction(operation.Action)
+		operations = append(operations, operation)
+		imported = append(imported, i)
+	}
+
+	if dryRun || len(operations) == 0 {
+		return results, nil
+	}
+
+	bulkResults, err := s.BulkNotes(ctx, operations, false, change)
+	if err != nil {
+		return nil, err
+	}
+	for j, result := range bulkResults {
+		i := imported[j]
+		if result.Err != nil {
+			results[i].Err = result.Err
+			continue
+		}
+		results[i].Note = result.Note
+	}
+	return results, nil
+}
+
+// takenTitles returns the stored notes with the titles of notes, by title,
+// looking up bulkStepSize titles per query.
+func (s *NoteServiceImpl) takenTitles(ctx context.Context, notes []*models.Note) (map[string]*models.Note, error) {
+	taken := make(map[string]*models.Note)
+	for start := 0; start < len(notes); start += bulkStepSize {
+		batch := notes[start:min(start+bulkStepSize, len(notes))]
+		titles := make([]string, len(batch))
+		for i, note := range batch {
+			titles[i] = note.Title
+		}
+		queryCtx, cancel := repository.QueryContext(ctx)
+		found, err := s.noteRepository.GetNotesByTitles(queryCtx, titles)
+		cancel()
+		if err != nil {
+			return nil, failed("failed to check duplicate titles", err)
+		}
+		maps.Copy(taken, found)
+	}
+	return taken, nil
+}
+
+// freeTitle finds a title for a note whose title is taken, numbering it as
+// "title (2)", "title (3)" and so on, and shortening it where the number
+// would not fit. Numbered titles are looked up renameBatchSize at a time.
+func (s *NoteServiceImpl) freeTitle(ctx context.Context, title string, claimed map[string]bool) (string, error) {
+	for first := 2; first < maxRenameAttempts; first += renameBatchSize {
+		var candidates []string
+		for n := first; n < min(first+renameBatchSize, maxRenameAttempts); n++ {
+			suffix := fmt.Sprintf(" (%d)", n)
+			base := title
+			for utf8.RuneCountInString(base)+len(suffix) > maxTitleLength {
+				_, size := utf8.DecodeLastRuneInString(base)
+				base = base[:len(base)-size]
+			}
+			if candidate := base + suffix; !claimed[candidate] {
+				candidates = append(candidates, candidate)
+			}
+		}
+
+		queryCtx, cancel := repository.QueryContext(ctx)
+		taken, err := s.noteRepository.GetNotesByTitles(queryCtx, candidates)
+		cancel()
+		if err != nil {
+			return "", failed("failed to check duplicate title", err)
+		}
+		for _, candidate := range candidates {
+			if taken[candidate] == nil {
+				return candidate, nil
+			}
+		}
+	}
+	return "", apperrors.New(apperrors.Conflict, fmt.Sprintf("no free title for %q", title))
+}
+
+func importAction(action models.BulkAction) models.ImportAction {
+	if action == models.BulkUpdate {
+		return models.ImportUpdate
+	}
+	return models.ImportCreate
+}
@@ -0,0 +1,58 @@
+package services
+
+import (
+	"slices"
+	"time"
+
+	"github.com/sarita-growexx/note_with_alarm/models"
+)
+
+// newRevision snapshots the current state of a note together with the list
+// of fields that changed to produce it.
+func newRevision(note *models.Note, changed []string, change models.ChangeInfo) *models.NoteRevision {
+	return &models.NoteRevision{
+		NoteID:        note.ID,
+		Title:         note.Title,
+		Description:   note.Description,
+		Deadline:      note.Deadline,
+		Tags:          note.Tags,
+		Reminders:     note.Reminders,
+		ChangedFields: changed,
+		ChangedBy:     change.Author,
+		Reason:        change.Reason,
+		CreatedAt:     time.Now(),
+	}
+}
+
+// changedFields lists the user editable fields that differ between two
+// versions of a note.
+func changedFields(before, after *models.Note) []string {
+	changes := diffRevisions(newRevision(before, nil, models.ChangeInfo{}), newRevision(after, nil, models.ChangeInfo{}))
+
+	fields := make([]string, 0, len(changes))
+	for _, c := range changes {
+		fields = append(fields, c.Field)
+	}
+	return fields
+}
+
+// diffRevisions returns a field level diff going from one revision to another.
+func diffRevisions(from, to *models.NoteRevision) []models.FieldChange {
+	changes := []models.FieldChange{}
+	if from.Title != to.Title {
+		changes = append(changes, models.FieldChange{Field: "title", From: from.Title, To: to.Title})
+	}
+	if from.Description != to.Description {
+		changes = append(changes, models.FieldChange{Field: "description", From: from.Description, To: to.Description})
+	}
+	if !from.Deadline.Equal(to.Deadline) {
+		changes = append(changes, models.FieldChange{Field: "deadline", From: from.Deadline, To: to.Deadline})
+	}
+	if !slices.Equal(from.Tags, to.Tags) {
+		changes = append(changes, models.FieldChange{Field: "tags", From: from.Tags, To: to.Tags})
+	}
+	if !slices.Equal(from.Reminders, to.Reminders) {
+		changes = append(changes, models.FieldChange{Field: "reminders", From: from.Reminders, To: to.Reminders})
+	}
+	return changes
+}
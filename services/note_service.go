@@ -1,14 +1,26 @@
 package services
 
 import (
+	"context"
 	"github.com/sarita-growexx/note_with_alarm/models"
 )
 
 type NoteService interface {
-	CreateNote(note *models.Note) error
-	UpdateNote(note *models.Note) error
-	DeleteNote(id uint) error
-	GetNoteById(id uint) (*models.Note, error)
-	GetAllNotes() ([]*models.Note, error)
-	SearchNotes(query string) ([]*models.Note, error)
+	CreateNote(ctx context.Context, note *models.Note, change models.ChangeInfo) error
+	UpdateNote(ctx context.Context, note *models.Note, change models.ChangeInfo) error
+	PatchNote(ctx context.Context, id uint, version uint, apply func(note *models.Note) error, change models.ChangeInfo) (*models.Note, error)
+	DeleteNote(ctx context.Context, id uint, version uint) error
+	BulkNotes(ctx context.Context, operations []models.BulkOperation, atomic bool, change models.ChangeInfo) ([]models.BulkResult, error)
+	GetNoteById(ctx context.Context, id uint) (*models.Note, error)
+	GetAllNotes(ctx context.Context) ([]*models.Note, error)
+	ListNotes(ctx context.Context, query models.NoteListQuery) (*models.NotePage, error)
+	ExportNotes(ctx context.Context, query models.NoteListQuery, fn func(note *models.Note) error) error
+	ImportNotes(ctx context.Context, notes []*models.Note, policy models.ConflictPolicy, dryRun bool, change models.ChangeInfo) ([]models.ImportResult, error)
+	SearchNotes(ctx context.Context, query string) ([]*models.NoteSearchResult, error)
+	FuzzySearchNotes(ctx context.Context, query string, threshold float64) ([]*models.NoteSearchResult, error)
+	SuggestTitles(ctx context.Context, prefix string, limit int) ([]string, error)
+	GetNoteRevisions(ctx context.Context, id uint) ([]*models.NoteRevision, error)
+	GetNoteRevision(ctx context.Context, id uint, revision uint) (*models.NoteRevision, error)
+	DiffNoteRevisions(ctx context.Context, id uint, from uint, to uint) ([]models.FieldChange, error)
+	RestoreNoteRevision(ctx context.Context, id uint, revision uint, change models.ChangeInfo) (*models.Note, error)
 }
@@ -1,21 +1,20 @@
 package services
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"strings"
+	"slices"
 	"time"
 
 	"github.com/sarita-growexx/note_with_alarm/models"
+	"github.com/sarita-growexx/note_with_alarm/query"
 	"github.com/sarita-growexx/note_with_alarm/repository"
 	"github.com/sarita-growexx/note_with_alarm/utils"
-	"gorm.io/gorm"
 )
 
 const standardTime = "2006-01-02T15:04:05Z"
 
-var ErrNoteNotFound = errors.New("note not found")
-
 type NoteServiceImpl struct {
 	noteRepository repository.NoteRepository
 }
@@ -26,120 +25,371 @@ func NewNoteService(noteRepository repository.NoteRepository) *NoteServiceImpl {
 	}
 }
 
-func (s *NoteServiceImpl) CreateNote(note *models.Note) error {
-
-	existingNote, err := s.noteRepository.GetNoteByTitle(note.Title)
-	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-		return errors.New("failed to check duplicate title")
+func (s *NoteServiceImpl) CreateNote(ctx context.Context, note *models.Note, change models.ChangeInfo) error {
+	deadline, err := normalizeDeadline(note.Deadline)
+	if err != nil {
+		return err
 	}
 
-	if existingNote != nil {
-		fmt.Println("Existing note=", existingNote)
-		return errors.New("duplicate title, please choose a different title")
+	note.Deadline = deadline
+
+	note.Version = 1
+	note.CreatedAt = time.Now()
+	note.UpdatedAt = time.Now()
+
+	// The title check gives a friendly error up front; the unique index on
+	// title catches creates racing with this one.
+	err = s.noteRepository.WithinTx(ctx, func(repo repository.NoteRepository) error {
+		existingNote, err := repo.GetNoteByTitle(ctx, note.Title)
+		if err != nil && !errors.Is(err, repository.ErrNoteNotFound) {
+			return failed("failed to check duplicate title", err)
+		}
+
+		if existingNote != nil {
+			return repository.ErrDuplicateTitle
+		}
+
+		if err := repo.Create(ctx, note); err != nil {
+			return failed("failed to create note", err)
+		}
 
+		if err := repo.CreateRevision(ctx, newRevision(note, changedFields(&models.Note{}, note), change)); err != nil {
+			return failed("failed to record note revision", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	deadlineLocation, err := time.LoadLocation("Asia/Kolkata")
+	// Set the alarm for the note
+	utils.SetAlarmForNotes([]*models.Note{note})
+
+	return nil
+}
+
+func (s *NoteServiceImpl) UpdateNote(ctx context.Context, note *models.Note, change models.ChangeInfo) error {
+	err := s.noteRepository.WithinTx(ctx, func(repo repository.NoteRepository) error {
+		return updateNote(ctx, repo, note, change)
+	})
 	if err != nil {
 		return err
 	}
-	deadline, err := time.ParseInLocation(standardTime, note.Deadline.Format(standardTime), deadlineLocation)
+
+	utils.SetAlarmForNotes([]*models.Note{note})
+
+	return nil
+}
+
+// updateNote replaces a note through repo, which should be bound to a
+// transaction, and records the revision.
+func updateNote(ctx context.Context, repo repository.NoteRepository, note *models.Note, change models.ChangeInfo) error {
+	// Parse deadline
+	deadline, err := normalizeDeadline(note.Deadline)
 	if err != nil {
 		return err
 	}
 
 	note.Deadline = deadline
-
-	note.CreatedAt = time.Now()
 	note.UpdatedAt = time.Now()
 
-	err = s.noteRepository.Create(note)
+	existingNote, err := getExistingNote(ctx, repo, note.ID)
 	if err != nil {
-		fmt.Println("Error creating note:", err)
+		return err
+	}
+
+	if note.Version == models.AnyVersion {
+		note.Version = existingNote.Version
+	}
+	if existingNote.Version != note.Version {
+		return repository.ErrVersionConflict
+	}
+
+	note.CreatedAt = existingNote.CreatedAt
+
+	changed := changedFields(existingNote, note)
+
+	if err := repo.Update(ctx, note); err != nil {
+		return failed("failed to update note", err)
+	}
 
-		if strings.Contains(err.Error(), "duplicate key value") {
-			return errors.New("duplicate title, please choose a different title")
+	if len(changed) > 0 {
+		if err := repo.CreateRevision(ctx, newRevision(note, changed, change)); err != nil {
+			return failed("failed to record note revision", err)
 		}
+	}
+	return nil
+}
 
-		return fmt.Errorf("failed to create note: %w", err)
+func (s *NoteServiceImpl) DeleteNote(ctx context.Context, id uint, version uint) error {
+	if version == models.AnyVersion {
+		note, err := getExistingNote(ctx, s.noteRepository, id)
+		if err != nil {
+			return err
+		}
+		version = note.Version
 	}
+	return failed("failed to delete note", s.noteRepository.Delete(ctx, id, version))
+}
 
-	// Set the alarm for the note
-	utils.SetAlarmForNotes([]*models.Note{note})
+func (s *NoteServiceImpl) GetAllNotes(ctx context.Context) ([]*models.Note, error) {
+	notes, err := s.noteRepository.GetAll(ctx)
+	if err != nil {
+		return nil, failed("failed to get all notes", err)
+	}
 
-	return nil
+	return notes, nil
 }
 
-func (s *NoteServiceImpl) UpdateNote(note *models.Note) error {
-	existingNote, err := s.noteRepository.GetById(note.ID)
+func (s *NoteServiceImpl) ListNotes(ctx context.Context, query models.NoteListQuery) (*models.NotePage, error) {
+	page, err := s.noteRepository.List(ctx, query)
 	if err != nil {
-		return fmt.Errorf("failed to retrieve existing note: %w", err)
+		return nil, failed("failed to list notes", err)
 	}
 
-	if existingNote == nil {
-		return errors.New("note not found")
+	return page, nil
+}
+
+func (s *NoteServiceImpl) GetNoteById(ctx context.Context, id uint) (*models.Note, error) {
+	return getExistingNote(ctx, s.noteRepository, id)
+}
+
+func (s *NoteServiceImpl) SearchNotes(ctx context.Context, search string) ([]*models.NoteSearchResult, error) {
+	q, err := parseSearch(search)
+	if err != nil {
+		return nil, err
 	}
 
-	// existingNote, err := s.noteRepository.GetNoteByTitle(note.Title)
-	// if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-	// 	return errors.New("failed to check duplicate title")
-	// }
+	results, err := s.noteRepository.Search(ctx, q)
+	if err != nil {
+		return nil, failed("failed to search notes", err)
+	}
+	return results, nil
+}
 
-	if existingNote.ID != 0 && existingNote.ID != note.ID {
-		return errors.New("duplicate title, please choose a different title")
+func (s *NoteServiceImpl) FuzzySearchNotes(ctx context.Context, query string, threshold float64) ([]*models.NoteSearchResult, error) {
+	results, err := s.noteRepository.FuzzySearch(ctx, query, threshold)
+	if err != nil {
+		return nil, failed("failed to search notes", err)
 	}
+	return results, nil
+}
 
-	// Parse deadline
-	deadlineLocation, err := time.LoadLocation("Asia/Kolkata")
+func (s *NoteServiceImpl) SuggestTitles(ctx context.Context, prefix string, limit int) ([]string, error) {
+	titles, err := s.noteRepository.SuggestTitles(ctx, prefix, limit)
 	if err != nil {
-		return err
+		return nil, failed("failed to suggest titles", err)
+	}
+	return titles, nil
+}
+
+func (s *NoteServiceImpl) GetNoteRevisions(ctx context.Context, id uint) ([]*models.NoteRevision, error) {
+	if _, err := getExistingNote(ctx, s.noteRepository, id); err != nil {
+		return nil, err
 	}
-	deadline, err := time.ParseInLocation(standardTime, note.Deadline.Format(standardTime), deadlineLocation)
+
+	revisions, err := s.noteRepository.GetRevisions(ctx, id)
 	if err != nil {
-		return err
+		return nil, failed("failed to get note revisions", err)
 	}
 
-	note.Deadline = deadline
+	return revisions, nil
+}
 
-	note.UpdatedAt = time.Now()
+func (s *NoteServiceImpl) GetNoteRevision(ctx context.Context, id uint, revision uint) (*models.NoteRevision, error) {
+	if _, err := getExistingNote(ctx, s.noteRepository, id); err != nil {
+		return nil, err
+	}
 
-	err = s.noteRepository.Update(note)
+	rev, err := s.noteRepository.GetRevision(ctx, id, revision)
 	if err != nil {
-		return errors.New("failed to update note")
+		return nil, failed("failed to get note revision", err)
 	}
+	return rev, nil
+}
 
-	utils.SetAlarmForNotes([]*models.Note{note})
+func (s *NoteServiceImpl) DiffNoteRevisions(ctx context.Context, id uint, from uint, to uint) ([]models.FieldChange, error) {
+	fromRevision, err := s.GetNoteRevision(ctx, id, from)
+	if err != nil {
+		return nil, err
+	}
 
-	return nil
+	toRevision, err := s.noteRepository.GetRevision(ctx, id, to)
+	if err != nil {
+		return nil, failed("failed to get note revision", err)
+	}
+
+	return diffRevisions(fromRevision, toRevision), nil
 }
 
-func (s *NoteServiceImpl) DeleteNote(id uint) error {
-	err := s.noteRepository.Delete(id)
+func (s *NoteServiceImpl) RestoreNoteRevision(ctx context.Context, id uint, revision uint, change models.ChangeInfo) (*models.Note, error) {
+	var restored models.Note
+	var changed []string
+	err := s.noteRepository.WithinTx(ctx, func(repo repository.NoteRepository) error {
+		note, err := getExistingNote(ctx, repo, id)
+		if err != nil {
+			return err
+		}
+
+		rev, err := repo.GetRevision(ctx, id, revision)
+		if err != nil {
+			return failed("failed to get note revision", err)
+		}
+
+		restored = *note
+		restored.Title = rev.Title
+		restored.Description = rev.Description
+		restored.Deadline = rev.Deadline
+		restored.Tags = rev.Tags
+		restored.Reminders = rev.Reminders
+		restored.UpdatedAt = time.Now()
+
+		changed = changedFields(note, &restored)
+		if len(changed) == 0 {
+			restored = *note
+			return nil
+		}
+
+		if err := repo.Update(ctx, &restored); err != nil {
+			return failed("failed to restore note", err)
+		}
+
+		if change.Reason == "" {
+			change.Reason = fmt.Sprintf("restored from revision %d", revision)
+		}
+		if err := repo.CreateRevision(ctx, newRevision(&restored, changed, change)); err != nil {
+			return failed("failed to record note revision", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return errors.New("failed to delete note")
+		return nil, err
 	}
 
-	return nil
+	if len(changed) > 0 {
+		utils.SetAlarmForNotes([]*models.Note{&restored})
+	}
+
+	return &restored, nil
 }
 
-func (s *NoteServiceImpl) GetAllNotes() ([]*models.Note, error) {
-	notes, err := s.noteRepository.GetAll()
+func (s *NoteServiceImpl) PatchNote(ctx context.Context, id uint, version uint, apply func(note *models.Note) error, change models.ChangeInfo) (*models.Note, error) {
+	var patched models.Note
+	var changed []string
+	err := s.noteRepository.WithinTx(ctx, func(repo repository.NoteRepository) error {
+		note, err := getExistingNote(ctx, repo, id)
+		if err != nil {
+			return err
+		}
+
+		if version != models.AnyVersion && note.Version != version {
+			return repository.ErrVersionConflict
+		}
+
+		patched = *note
+		if err := apply(&patched); err != nil {
+			return err
+		}
+
+		if !patched.Deadline.Equal(note.Deadline) {
+			deadline, err := normalizeDeadline(patched.Deadline)
+			if err != nil {
+				return err
+			}
+			patched.Deadline = deadline
+		}
+
+		changed = changedFields(note, &patched)
+		if len(changed) == 0 {
+			patched = *note
+			return nil
+		}
+
+		if slices.Contains(changed, "title") {
+			existingNote, err := repo.GetNoteByTitle(ctx, patched.Title)
+			if err != nil && !errors.Is(err, repository.ErrNoteNotFound) {
+				return failed("failed to check duplicate title", err)
+			}
+			if existingNote != nil && existingNote.ID != patched.ID {
+				return repository.ErrDuplicateTitle
+			}
+		}
+
+		patched.UpdatedAt = time.Now()
+
+		fields := map[string]interface{}{"updated_at": patched.UpdatedAt}
+		for _, field := range changed {
+			switch field {
+			case "title":
+				fields[field] = patched.Title
+			case "description":
+				fields[field] = patched.Description
+			case "deadline":
+				fields[field] = patched.Deadline
+			case "tags":
+				fields[field] = patched.Tags
+			case "reminders":
+				fields[field] = patched.Reminders
+			}
+		}
+
+		if err := repo.UpdateFields(ctx, patched.ID, patched.Version, fields); err != nil {
+			return failed("failed to patch note", err)
+		}
+		patched.Version++
+
+		if err := repo.CreateRevision(ctx, newRevision(&patched, changed, change)); err != nil {
+			return failed("failed to record note revision", err)
+		}
+		return nil
+	})
 	if err != nil {
-		return nil, errors.New("failed to get all notes")
+		return nil, err
 	}
 
-	return notes, nil
+	if len(changed) > 0 {
+		utils.SetAlarmForNotes([]*models.Note{&patched})
+	}
+
+	return &patched, nil
 }
 
-func (s *NoteServiceImpl) GetNoteById(id uint) (*models.Note, error) {
-	note, err := s.noteRepository.GetById(id)
+// DeadlineLocation is the time zone, IST, in which the wall clock times of
+// deadlines are read. Callers holding an instant convert it to this zone
+// first, so that it survives normalization.
+func DeadlineLocation() (*time.Location, error) {
+	return time.LoadLocation("Asia/Kolkata")
+}
+
+// parseSearch parses a search query, whose dates are calendar days in the
+// time zone of deadlines.
+func parseSearch(search string) (*query.Query, error) {
+	q, err := query.Parse(search)
 	if err != nil {
-		return nil, errors.New("failed to get note by ID")
+		return nil, err
 	}
+	if q.Location, err = DeadlineLocation(); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
 
-	return note, nil
+// normalizeDeadline interprets the wall clock time of a deadline in IST.
+func normalizeDeadline(deadline time.Time) (time.Time, error) {
+	deadlineLocation, err := DeadlineLocation()
+	if err != nil {
+		return time.Time{}, err
+	}
+	return time.ParseInLocation(standardTime, deadline.Format(standardTime), deadlineLocation)
 }
 
-func (s *NoteServiceImpl) SearchNotes(query string) ([]*models.Note, error) {
-	return s.noteRepository.Search(query)
+// getExistingNote loads a note, reporting repository.ErrNoteNotFound when it
+// does not exist.
+func getExistingNote(ctx context.Context, repo repository.NoteRepository, id uint) (*models.Note, error) {
+	note, err := repo.GetById(ctx, id)
+	if err != nil {
+		return nil, failed("failed to get note by ID", err)
+	}
+
+	return note, nil
 }
@@ -1,10 +1,14 @@
 package services
 
 import (
+	"context"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/sarita-growexx/note_with_alarm/models"
+	"github.com/sarita-growexx/note_with_alarm/query"
+	"github.com/sarita-growexx/note_with_alarm/repository"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 )
@@ -13,39 +17,104 @@ type mockNoteRepository struct {
 	mock.Mock
 }
 
-func (m *mockNoteRepository) Create(note *models.Note) error {
+// WithinTx runs fn against the mock itself, so expectations set on the mock
+// apply inside the transaction.
+func (m *mockNoteRepository) WithinTx(ctx context.Context, fn func(repo repository.NoteRepository) error) error {
+	return fn(m)
+}
+
+func (m *mockNoteRepository) Create(ctx context.Context, note *models.Note) error {
 	args := m.Called(note)
 	return args.Error(0)
 }
 
-func (m *mockNoteRepository) Update(note *models.Note) error {
+func (m *mockNoteRepository) CreateInBatches(ctx context.Context, notes []*models.Note, batchSize int) error {
+	args := m.Called(notes, batchSize)
+	return args.Error(0)
+}
+
+func (m *mockNoteRepository) Update(ctx context.Context, note *models.Note) error {
 	args := m.Called(note)
 	return args.Error(0)
 }
 
-func (m *mockNoteRepository) Delete(id uint) error {
-	args := m.Called(id)
+func (m *mockNoteRepository) UpdateFields(ctx context.Context, id uint, version uint, fields map[string]interface{}) error {
+	args := m.Called(id, version, fields)
 	return args.Error(0)
 }
 
-func (m *mockNoteRepository) GetAll() ([]*models.Note, error) {
+func (m *mockNoteRepository) Delete(ctx context.Context, id uint, version uint) error {
+	args := m.Called(id, version)
+	return args.Error(0)
+}
+
+func (m *mockNoteRepository) GetAll(ctx context.Context) ([]*models.Note, error) {
 	args := m.Called()
 	return args.Get(0).([]*models.Note), args.Error(1)
 }
 
-func (m *mockNoteRepository) GetById(id uint) (*models.Note, error) {
+func (m *mockNoteRepository) List(ctx context.Context, query models.NoteListQuery) (*models.NotePage, error) {
+	args := m.Called(query)
+	page, _ := args.Get(0).(*models.NotePage)
+	return page, args.Error(1)
+}
+
+func (m *mockNoteRepository) GetById(ctx context.Context, id uint) (*models.Note, error) {
 	args := m.Called(id)
-	return args.Get(0).(*models.Note), args.Error(1)
+	note, _ := args.Get(0).(*models.Note)
+	return note, args.Error(1)
 }
 
-func (m *mockNoteRepository) Search(query string) ([]*models.Note, error) {
-	args := m.Called(query)
-	return args.Get(0).([]*models.Note), args.Error(1)
+func (m *mockNoteRepository) Search(ctx context.Context, q *query.Query) ([]*models.NoteSearchResult, error) {
+	args := m.Called(q)
+	results, _ := args.Get(0).([]*models.NoteSearchResult)
+	return results, args.Error(1)
+}
+
+func (m *mockNoteRepository) FuzzySearch(ctx context.Context, query string, threshold float64) ([]*models.NoteSearchResult, error) {
+	args := m.Called(query, threshold)
+	results, _ := args.Get(0).([]*models.NoteSearchResult)
+	return results, args.Error(1)
+}
+
+func (m *mockNoteRepository) SuggestTitles(ctx context.Context, prefix string, limit int) ([]string, error) {
+	args := m.Called(prefix, limit)
+	titles, _ := args.Get(0).([]string)
+	return titles, args.Error(1)
 }
 
-func (m *mockNoteRepository) GetNoteByTitle(title string) (*models.Note, error) {
+func (m *mockNoteRepository) GetNoteByTitle(ctx context.Context, title string) (*models.Note, error) {
 	args := m.Called(title)
-	return args.Get(0).(*models.Note), args.Error(1)
+	note, _ := args.Get(0).(*models.Note)
+	return note, args.Error(1)
+}
+
+func (m *mockNoteRepository) GetNotesByTitles(ctx context.Context, titles []string) (map[string]*models.Note, error) {
+	args := m.Called(titles)
+	notes, _ := args.Get(0).(map[string]*models.Note)
+	return notes, args.Error(1)
+}
+
+func (m *mockNoteRepository) CreateRevision(ctx context.Context, revision *models.NoteRevision) error {
+	args := m.Called(revision)
+	return args.Error(0)
+}
+
+func (m *mockNoteRepository) CreateRevisionsInBatches(ctx context.Context, revisions []*models.NoteRevision, batchSize int) error {
+	args := m.Called(revisions, batchSize)
+	return args.Error(0)
+}
+
+func (m *mockNoteRepository) GetRevisions(ctx context.Context, noteID uint) ([]*models.NoteRevision, error) {
+	args := m.Called(noteID)
+	revisions, _ := args.Get(0).([]*models.NoteRevision)
+	return revisions, args.Error(1)
+}
+
+func (m *mockNoteRepository) GetRevision(ctx context.Context, noteID uint, revision uint) (*models.NoteRevision, error) {
+	args := m.Called(noteID, revision)
+	rev, _ := args.Get(0).(*models.NoteRevision)
+	return rev, args.Error(1)
 }
 
 func TestNoteServiceImpl_CreateNote(t *testing.T) {
@@ -58,15 +127,34 @@ func TestNoteServiceImpl_CreateNote(t *testing.T) {
 		Deadline: time.Now().Add(time.Hour),
 	}
 
+	mockRepo.On("GetNoteByTitle", "Test Note").Return(nil, repository.ErrNoteNotFound).Once()
 	mockRepo.On("Create", mock.AnythingOfType("*models.Note")).Return(nil).Once()
+	mockRepo.On("CreateRevision", mock.MatchedBy(func(rev *models.NoteRevision) bool {
+		return rev.Title == "Test Note" && rev.ChangedBy == "alice"
+	})).Return(nil).Once()
 
-	err := service.CreateNote(newNote)
+	err := service.CreateNote(context.Background(), newNote, models.ChangeInfo{Author: "alice"})
 
 	assert.NoError(t, err)
 
 	mockRepo.AssertExpectations(t)
 }
 
+func TestNoteServiceImpl_CreateNote_ConcurrentDuplicate(t *testing.T) {
+	mockRepo := new(mockNoteRepository)
+	service := NewNoteService(mockRepo)
+
+	// Another create with the same title committed after the title check.
+	mockRepo.On("GetNoteByTitle", "Test Note").Return(nil, repository.ErrNoteNotFound).Once()
+	mockRepo.On("Create", mock.AnythingOfType("*models.Note")).Return(repository.ErrDuplicateTitle).Once()
+
+	err := service.CreateNote(context.Background(), &models.Note{Title: "Test Note", Deadline: time.Now().Add(time.Hour)}, models.ChangeInfo{})
+
+	assert.ErrorIs(t, err, repository.ErrDuplicateTitle)
+	mockRepo.AssertNotCalled(t, "CreateRevision", mock.Anything)
+	mockRepo.AssertExpectations(t)
+}
+
 func TestNoteServiceImpl_UpdateNote(t *testing.T) {
 
 	mockRepo := new(mockNoteRepository)
@@ -81,26 +169,110 @@ func TestNoteServiceImpl_UpdateNote(t *testing.T) {
 	mockRepo.On("GetById", uint(1)).Return(&models.Note{}, nil).Once()
 
 	mockRepo.On("Update", updatedNote).Return(nil).Once()
+	mockRepo.On("CreateRevision", mock.MatchedBy(func(rev *models.NoteRevision) bool {
+		return rev.NoteID == 1 && assert.ObjectsAreEqual([]string{"title", "deadline"}, rev.ChangedFields)
+	})).Return(nil).Once()
 
-	err := service.UpdateNote(updatedNote)
+	err := service.UpdateNote(context.Background(), updatedNote, models.ChangeInfo{})
 
 	assert.NoError(t, err)
 
 	mockRepo.AssertExpectations(t)
 }
 
+func TestNoteServiceImpl_UpdateNote_VersionConflict(t *testing.T) {
+	mockRepo := new(mockNoteRepository)
+	service := NewNoteService(mockRepo)
+
+	staleNote := &models.Note{
+		ID:       1,
+		Title:    "Updated Test Note",
+		Deadline: time.Now().Add(time.Hour),
+		Version:  1,
+	}
+
+	mockRepo.On("GetById", uint(1)).Return(&models.Note{ID: 1, Version: 2}, nil).Once()
+
+	err := service.UpdateNote(context.Background(), staleNote, models.ChangeInfo{})
+
+	assert.ErrorIs(t, err, repository.ErrVersionConflict)
+	mockRepo.AssertNotCalled(t, "Update", mock.Anything)
+}
+
+func TestNoteServiceImpl_PatchNote(t *testing.T) {
+	mockRepo := new(mockNoteRepository)
+	service := NewNoteService(mockRepo)
+
+	stored := &models.Note{ID: 1, Title: "Stored Note", Description: "keep me", Deadline: time.Now().Add(time.Hour), Version: 2}
+
+	mockRepo.On("GetById", uint(1)).Return(stored, nil)
+	mockRepo.On("UpdateFields", uint(1), uint(2), mock.MatchedBy(func(fields map[string]interface{}) bool {
+		_, hasDescription := fields["description"]
+		_, hasTitle := fields["title"]
+		return fields["deadline"] != nil && !hasDescription && !hasTitle
+	})).Return(nil).Once()
+	mockRepo.On("CreateRevision", mock.MatchedBy(func(rev *models.NoteRevision) bool {
+		return assert.ObjectsAreEqual([]string{"deadline"}, rev.ChangedFields)
+	})).Return(nil).Once()
+
+	patched, err := service.PatchNote(context.Background(), 1, 2, func(note *models.Note) error {
+		note.Deadline = note.Deadline.Add(24 * time.Hour)
+		return nil
+	}, models.ChangeInfo{})
+
+	assert.NoError(t, err)
+	assert.Equal(t, uint(3), patched.Version)
+	assert.Equal(t, "keep me", patched.Description)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestNoteServiceImpl_PatchNote_VersionConflict(t *testing.T) {
+	mockRepo := new(mockNoteRepository)
+	service := NewNoteService(mockRepo)
+
+	mockRepo.On("GetById", uint(1)).Return(&models.Note{ID: 1, Version: 3}, nil)
+
+	_, err := service.PatchNote(context.Background(), 1, 2, func(note *models.Note) error { return nil }, models.ChangeInfo{})
+
+	assert.ErrorIs(t, err, repository.ErrVersionConflict)
+	mockRepo.AssertNotCalled(t, "UpdateFields", mock.Anything, mock.Anything, mock.Anything)
+}
+
 func TestNoteServiceImpl_DeleteNote(t *testing.T) {
 	mockRepo := new(mockNoteRepository)
 	service := NewNoteService(mockRepo)
 
-	mockRepo.On("Delete", uint(1)).Return(nil)
+	mockRepo.On("Delete", uint(1), uint(1)).Return(nil)
 
-	err := service.DeleteNote(1)
+	err := service.DeleteNote(context.Background(), 1, 1)
 
 	assert.NoError(t, err)
 	mockRepo.AssertExpectations(t)
 }
 
+func TestNoteServiceImpl_AnyVersion(t *testing.T) {
+	ctx := context.Background()
+	service := NewNoteService(repository.NewMemoryNoteRepository())
+	deadline := time.Now().Add(time.Hour)
+
+	note := &models.Note{Title: "Any version", Deadline: deadline}
+	assert.NoError(t, service.CreateNote(ctx, note, models.ChangeInfo{}))
+
+	update := &models.Note{ID: note.ID, Title: "Any version, updated", Deadline: deadline, Version: models.AnyVersion}
+	assert.NoError(t, service.UpdateNote(ctx, update, models.ChangeInfo{}))
+	assert.Equal(t, uint(2), update.Version)
+
+	patched, err := service.PatchNote(ctx, note.ID, models.AnyVersion, func(note *models.Note) error {
+		note.Description = "patched"
+		return nil
+	}, models.ChangeInfo{})
+	assert.NoError(t, err)
+	assert.Equal(t, uint(3), patched.Version)
+
+	assert.NoError(t, service.DeleteNote(ctx, note.ID, models.AnyVersion))
+	assert.ErrorIs(t, service.DeleteNote(ctx, note.ID, models.AnyVersion), repository.ErrNoteNotFound)
+}
+
 func TestNoteServiceImpl_GetAllNotes(t *testing.T) {
 	mockRepo := new(mockNoteRepository)
 	service := NewNoteService(mockRepo)
@@ -112,13 +284,32 @@ func TestNoteServiceImpl_GetAllNotes(t *testing.T) {
 
 	mockRepo.On("GetAll").Return(notes, nil)
 
-	resultNotes, err := service.GetAllNotes()
+	resultNotes, err := service.GetAllNotes(context.Background())
 
 	assert.NoError(t, err)
 	assert.Equal(t, notes, resultNotes)
 	mockRepo.AssertExpectations(t)
 }
 
+func TestNoteServiceImpl_ListNotes(t *testing.T) {
+	mockRepo := new(mockNoteRepository)
+	service := NewNoteService(mockRepo)
+
+	query := models.NoteListQuery{Limit: 1, Sort: "deadline"}
+	page := &models.NotePage{Notes: []*models.Note{{ID: 1, Title: "Note 1"}}, NextCursor: "next", Total: 2}
+
+	mockRepo.On("List", query).Return(page, nil).Once()
+	mockRepo.On("List", models.NoteListQuery{Cursor: "bogus"}).Return(nil, repository.ErrInvalidCursor).Once()
+
+	resultPage, err := service.ListNotes(context.Background(), query)
+	assert.NoError(t, err)
+	assert.Equal(t, page, resultPage)
+
+	_, err = service.ListNotes(context.Background(), models.NoteListQuery{Cursor: "bogus"})
+	assert.ErrorIs(t, err, repository.ErrInvalidCursor)
+	mockRepo.AssertExpectations(t)
+}
+
 func TestNoteServiceImpl_GetNoteById(t *testing.T) {
 	mockRepo := new(mockNoteRepository)
 	service := NewNoteService(mockRepo)
@@ -127,7 +318,7 @@ func TestNoteServiceImpl_GetNoteById(t *testing.T) {
 
 	mockRepo.On("GetById", uint(1)).Return(testNote, nil)
 
-	resultNote, err := service.GetNoteById(1)
+	resultNote, err := service.GetNoteById(context.Background(), 1)
 
 	assert.NoError(t, err)
 	assert.Equal(t, testNote, resultNote)
@@ -138,16 +329,302 @@ func TestNoteServiceImpl_SearchNotes(t *testing.T) {
 	mockRepo := new(mockNoteRepository)
 	service := NewNoteService(mockRepo)
 
-	notes := []*models.Note{
-		{ID: 1, Title: "Important Note", Deadline: time.Now().Add(1 * time.Hour)},
-		{ID: 2, Title: "Random Note", Deadline: time.Now().Add(2 * time.Hour)},
+	notes := []*models.NoteSearchResult{
+		{Note: models.Note{ID: 1, Title: "Important Note", Deadline: time.Now().Add(1 * time.Hour)}, Rank: 0.6},
+		{Note: models.Note{ID: 2, Title: "Random Note", Deadline: time.Now().Add(2 * time.Hour)}, Rank: 0.1},
 	}
 
-	mockRepo.On("Search", "Important").Return(notes[:1], nil)
+	// Dates in the query are days in the time zone of deadlines
+	location, err := DeadlineLocation()
+	assert.NoError(t, err)
+	mockRepo.On("Search", &query.Query{Terms: []query.Term{{Text: "Important"}}, Location: location}).Return(notes[:1], nil)
 
-	resultNotes, err := service.SearchNotes("Important")
+	resultNotes, err := service.SearchNotes(context.Background(), "Important")
 
 	assert.NoError(t, err)
 	assert.Equal(t, notes[:1], resultNotes)
 	mockRepo.AssertExpectations(t)
 }
+
+func TestNoteServiceImpl_SearchNotes_Structured(t *testing.T) {
+	mockRepo := new(mockNoteRepository)
+	service := NewNoteService(mockRepo)
+
+	mockRepo.On("Search", mock.MatchedBy(func(q *query.Query) bool {
+		return q.Text() == `"design review"` && len(q.Filters) == 2 && q.Filters[0].Field == query.FieldTag
+	})).Return([]*models.NoteSearchResult{}, nil).Once()
+
+	_, err := service.SearchNotes(context.Background(), `tag:work "design review" status:open`)
+	assert.NoError(t, err)
+
+	_, err = service.SearchNotes(context.Background(), `tag:work due:soon`)
+	var parseErr *query.ParseError
+	assert.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, "due:soon", parseErr.Token)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestNoteServiceImpl_DiffNoteRevisions(t *testing.T) {
+	mockRepo := new(mockNoteRepository)
+	service := NewNoteService(mockRepo)
+
+	deadline := time.Now().Add(time.Hour)
+	first := &models.NoteRevision{NoteID: 1, Revision: 1, Title: "Note", Description: "same", Deadline: deadline}
+	second := &models.NoteRevision{NoteID: 1, Revision: 2, Title: "Note", Description: "same", Deadline: deadline.Add(24 * time.Hour)}
+
+	mockRepo.On("GetById", uint(1)).Return(&models.Note{ID: 1}, nil)
+	mockRepo.On("GetRevision", uint(1), uint(1)).Return(first, nil)
+	mockRepo.On("GetRevision", uint(1), uint(2)).Return(second, nil)
+
+	changes, err := service.DiffNoteRevisions(context.Background(), 1, 1, 2)
+
+	assert.NoError(t, err)
+	assert.Equal(t, []models.FieldChange{{Field: "deadline", From: first.Deadline, To: second.Deadline}}, changes)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestNoteServiceImpl_GetNoteRevisions_NotFound(t *testing.T) {
+	mockRepo := new(mockNoteRepository)
+	service := NewNoteService(mockRepo)
+
+	mockRepo.On("GetById", uint(7)).Return(nil, repository.ErrNoteNotFound)
+
+	revisions, err := service.GetNoteRevisions(context.Background(), 7)
+
+	assert.ErrorIs(t, err, repository.ErrNoteNotFound)
+	assert.Nil(t, revisions)
+	mockRepo.AssertNotCalled(t, "GetRevisions", uint(7))
+}
+
+func TestNoteServiceImpl_RestoreNoteRevision(t *testing.T) {
+	mockRepo := new(mockNoteRepository)
+	service := NewNoteService(mockRepo)
+
+	current := &models.Note{ID: 1, Title: "Renamed", Description: "desc", Deadline: time.Now().Add(48 * time.Hour)}
+	original := &models.NoteRevision{NoteID: 1, Revision: 1, Title: "Original", Description: "desc", Deadline: current.Deadline}
+
+	mockRepo.On("GetById", uint(1)).Return(current, nil)
+	mockRepo.On("GetRevision", uint(1), uint(1)).Return(original, nil)
+	mockRepo.On("Update", mock.MatchedBy(func(note *models.Note) bool {
+		return note.ID == 1 && note.Title == "Original"
+	})).Return(nil).Once()
+	mockRepo.On("CreateRevision", mock.MatchedBy(func(rev *models.NoteRevision) bool {
+		return rev.Title == "Original" && rev.Reason == "restored from revision 1" &&
+			assert.ObjectsAreEqual([]string{"title"}, rev.ChangedFields)
+	})).Return(nil).Once()
+
+	restored, err := service.RestoreNoteRevision(context.Background(), 1, 1, models.ChangeInfo{Author: "bob"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "Original", restored.Title)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestNoteServiceImpl_FuzzySearchNotes(t *testing.T) {
+	mockRepo := new(mockNoteRepository)
+	service := NewNoteService(mockRepo)
+
+	results := []*models.NoteSearchResult{{Note: models.Note{ID: 1, Title: "Important Note"}, Similarity: 0.5}}
+	mockRepo.On("FuzzySearch", "Imprtant", 0.3).Return(results, nil)
+	mockRepo.On("SuggestTitles", "Imp", 5).Return([]string{"Important Note"}, nil)
+
+	resultNotes, err := service.FuzzySearchNotes(context.Background(), "Imprtant", 0.3)
+	assert.NoError(t, err)
+	assert.Equal(t, results, resultNotes)
+
+	titles, err := service.SuggestTitles(context.Background(), "Imp", 5)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Important Note"}, titles)
+	mockRepo.AssertExpectations(t)
+}
+
+// TestNoteServiceImpl_MemoryRepository runs a note's life cycle against the
+// in-memory repository, which behaves like the database rather than
+// replaying canned expectations.
+func TestNoteServiceImpl_MemoryRepository(t *testing.T) {
+	ctx := context.Background()
+	service := NewNoteService(repository.NewMemoryNoteRepository())
+
+	note := &models.Note{Title: "Test Note", Deadline: time.Now().Add(time.Hour)}
+	assert.NoError(t, service.CreateNote(ctx, note, models.ChangeInfo{Author: "alice"}))
+
+	err := service.CreateNote(ctx, &models.Note{Title: "Test Note", Deadline: time.Now().Add(time.Hour)}, models.ChangeInfo{})
+	assert.ErrorIs(t, err, repository.ErrDuplicateTitle)
+
+	update := &models.Note{ID: note.ID, Title: "Renamed Note", Deadline: note.Deadline, Version: note.Version}
+	assert.NoError(t, service.UpdateNote(ctx, update, models.ChangeInfo{Author: "bob"}))
+
+	stale := &models.Note{ID: note.ID, Title: "Stale Note", Deadline: note.Deadline, Version: note.Version}
+	assert.ErrorIs(t, service.UpdateNote(ctx, stale, models.ChangeInfo{}), repository.ErrVersionConflict)
+
+	revisions, err := service.GetNoteRevisions(ctx, note.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, revisions, 2) {
+		assert.Equal(t, "bob", revisions[1].ChangedBy)
+		assert.Equal(t, []string{"title"}, revisions[1].ChangedFields)
+	}
+
+	assert.ErrorIs(t, service.DeleteNote(ctx, note.ID, note.Version), repository.ErrVersionConflict)
+	assert.NoError(t, service.DeleteNote(ctx, note.ID, update.Version))
+	assert.ErrorIs(t, service.DeleteNote(ctx, note.ID, update.Version), repository.ErrNoteNotFound)
+}
+
+func TestNoteServiceImpl_BulkNotes(t *testing.T) {
+	ctx := context.Background()
+	service := NewNoteService(repository.NewMemoryNoteRepository())
+	deadline := time.Now().Add(time.Hour)
+
+	existing := &models.Note{Title: "Existing", Deadline: deadline}
+	assert.NoError(t, service.CreateNote(ctx, existing, models.ChangeInfo{}))
+
+	operations := func() []models.BulkOperation {
+		return []models.BulkOperation{
+			{Action: models.BulkCreate, Note: &models.Note{Title: "First", Deadline: deadline}},
+			{Action: models.BulkCreate, Note: &models.Note{Title: "Existing", Deadline: deadline}},
+			{Action: models.BulkCreate, Note: &models.Note{Title: "Second", Deadline: deadline}},
+			{Action: models.BulkUpdate, Note: &models.Note{ID: existing.ID, Version: existing.Version, Title: "Renamed", Deadline: deadline}},
+			{Action: models.BulkDelete, Note: &models.Note{ID: 42, Version: 1}},
+		}
+	}
+
+	// An atomic request fails as a whole
+	results, err := service.BulkNotes(ctx, operations(), true, models.ChangeInfo{Author: "importer"})
+	assert.NoError(t, err)
+	if assert.Len(t, results, 5) {
+		assert.ErrorIs(t, results[0].Err, ErrBulkAborted)
+		assert.ErrorIs(t, results[1].Err, repository.ErrDuplicateTitle)
+		assert.ErrorIs(t, results[2].Err, ErrBulkAborted)
+		assert.ErrorIs(t, results[3].Err, ErrBulkAborted)
+		assert.ErrorIs(t, results[4].Err, ErrBulkAborted)
+	}
+	_, err = service.GetNoteById(ctx, existing.ID+1)
+	assert.ErrorIs(t, err, repository.ErrNoteNotFound)
+
+	// A best effort request applies every operation that can be applied
+	results, err = service.BulkNotes(ctx, operations(), false, models.ChangeInfo{Author: "importer"})
+	assert.NoError(t, err)
+	if assert.Len(t, results, 5) {
+		assert.NoError(t, results[0].Err)
+		assert.ErrorIs(t, results[1].Err, repository.ErrDuplicateTitle)
+		assert.NoError(t, results[2].Err)
+		assert.NoError(t, results[3].Err)
+		assert.ErrorIs(t, results[4].Err, repository.ErrNoteNotFound)
+	}
+
+	created, err := service.GetNoteById(ctx, results[2].Note.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Second", created.Title)
+	revisions, err := service.GetNoteRevisions(ctx, created.ID)
+	assert.NoError(t, err)
+	if assert.Len(t, revisions, 1) {
+		assert.Equal(t, "importer", revisions[0].ChangedBy)
+	}
+	updated, err := service.GetNoteById(ctx, existing.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "Renamed", updated.Title)
+	assert.Equal(t, uint(2), updated.Version)
+}
+
+func TestBulkSteps(t *testing.T) {
+	operations := make([]models.BulkOperation, 2*bulkStepSize+2)
+	for i := range operations {
+		operations[i].Action = models.BulkCreate
+	}
+	operations[1].Action = models.BulkUpdate
+
+	assert.Equal(t, []bulkStep{
+		{start: 0, end: 1},
+		{start: 1, end: 2},
+		{start: 2, end: 2 + bulkStepSize},
+		{start: 2 + bulkStepSize, end: 2*bulkStepSize + 2},
+	}, bulkSteps(operations))
+}
+
+func TestNoteServiceImpl_ImportNotes(t *testing.T) {
+	ctx := context.Background()
+	service := NewNoteService(repository.NewMemoryNoteRepository())
+	deadline := time.Now().Add(time.Hour)
+
+	existing := &models.Note{Title: "Existing", Deadline: deadline}
+	assert.NoError(t, service.CreateNote(ctx, existing, models.ChangeInfo{}))
+	taken := &models.Note{Title: "Existing (2)", Deadline: deadline}
+	assert.NoError(t, service.CreateNote(ctx, taken, models.ChangeInfo{}))
+
+	notes := func() []*models.Note {
+		return []*models.Note{
+			{Title: "Fresh", Deadline: deadline},
+			{Title: "Existing", Description: "imported", Deadline: deadline},
+			{Title: "Fresh", Deadline: deadline},
+		}
+	}
+
+	results, err := service.ImportNotes(ctx, notes(), models.ConflictSkip, true, models.ChangeInfo{})
+	assert.NoError(t, err)
+	if assert.Len(t, results, 3) {
+		assert.Equal(t, models.ImportCreate, results[0].Action)
+		assert.Equal(t, models.ImportSkip, results[1].Action)
+		assert.Equal(t, models.ImportSkip, results[2].Action)
+	}
+	// A dry run writes nothing
+	_, err = service.GetNoteById(ctx, taken.ID+1)
+	assert.ErrorIs(t, err, repository.ErrNoteNotFound)
+
+	results, err = service.ImportNotes(ctx, notes(), models.ConflictRename, true, models.ChangeInfo{})
+	assert.NoError(t, err)
+	if assert.Len(t, results, 3) {
+		assert.Equal(t, models.ImportCreate, results[1].Action)
+		assert.Equal(t, "Existing (3)", results[1].Note.Title)
+		assert.Equal(t, "Existing", results[1].RenamedFrom)
+		assert.Equal(t, "Fresh (2)", results[2].Note.Title)
+		// Previews hold deadlines as they would be stored
+		assert.Equal(t, "Asia/Kolkata", results[0].Note.Deadline.Location().String())
+	}
+
+	results, err = service.ImportNotes(ctx, notes(), models.ConflictOverwrite, false, models.ChangeInfo{})
+	assert.NoError(t, err)
+	if assert.Len(t, results, 3) {
+		assert.NoError(t, results[0].Err)
+		assert.Equal(t, models.ImportUpdate, results[1].Action)
+		assert.NoError(t, results[1].Err)
+		assert.ErrorIs(t, results[2].Err, ErrDuplicateImportTitle)
+	}
+	overwritten, err := service.GetNoteById(ctx, existing.ID)
+	assert.NoError(t, err)
+	assert.Equal(t, "imported", overwritten.Description)
+
+	var exported []string
+	err = service.ExportNotes(ctx, models.NoteListQuery{Sort: "title"}, func(note *models.Note) error {
+		exported = append(exported, note.Title)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"Existing", "Existing (2)", "Fresh"}, exported)
+}
+
+func TestFreeTitleKeepsToMaxLength(t *testing.T) {
+	service := NewNoteService(repository.NewMemoryNoteRepository())
+	title := strings.Repeat("é", maxTitleLength)
+
+	renamed, err := service.freeTitle(context.Background(), title, map[string]bool{})
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Repeat("é", maxTitleLength-4)+" (2)", renamed)
+}
+
+func TestNoteServiceImpl_ImportNotes_OneTitleQuery(t *testing.T) {
+	mockRepo := new(mockNoteRepository)
+	service := NewNoteService(mockRepo)
+	deadline := time.Now().Add(time.Hour)
+
+	existing := &models.Note{ID: 1, Title: "B", Deadline: deadline}
+	mockRepo.On("GetNotesByTitles", []string{"A", "B", "C"}).Return(map[string]*models.Note{"B": existing}, nil).Once()
+
+	notes := []*models.Note{{Title: "A", Deadline: deadline}, {Title: "B", Deadline: deadline}, {Title: "C", Deadline: deadline}}
+	results, err := service.ImportNotes(context.Background(), notes, models.ConflictSkip, true, models.ChangeInfo{})
+	assert.NoError(t, err)
+	if assert.Len(t, results, 3) {
+		assert.Equal(t, models.ImportCreate, results[0].Action)
+		assert.Equal(t, models.ImportSkip, results[1].Action)
+		assert.Equal(t, models.ImportCreate, results[2].Action)
+	}
+	mockRepo.AssertExpectations(t)
+}
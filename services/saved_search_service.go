@@ -0,0 +1,16 @@
+package services
+
+import (
+	"context"
+	"github.com/sarita-growexx/note_with_alarm/models"
+)
+
+type SavedSearchService interface {
+	CreateSavedSearch(ctx context.Context, search *models.SavedSearch) error
+	UpdateSavedSearch(ctx context.Context, search *models.SavedSearch) error
+	DeleteSavedSearch(ctx context.Context, id uint) error
+	GetSavedSearchById(ctx context.Context, id uint) (*models.SavedSearch, error)
+	GetAllSavedSearches(ctx context.Context) ([]*models.SavedSearch, error)
+	RunSavedSearch(ctx context.Context, id uint) ([]*models.NoteSearchResult, error)
+	RouteAlarms(ctx context.Context, notes []*models.Note) error
+}
@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/sarita-growexx/note_with_alarm/apperrors"
+	"github.com/sarita-growexx/note_with_alarm/models"
+	"github.com/sarita-growexx/note_with_alarm/query"
+	"github.com/sarita-growexx/note_with_alarm/repository"
+	"github.com/sarita-growexx/note_with_alarm/utils"
+)
+
+var ErrInvalidSavedSearch = apperrors.New(apperrors.Validation, "invalid saved search")
+
+type SavedSearchServiceImpl struct {
+	savedSearchRepository repository.SavedSearchRepository
+	noteRepository        repository.NoteRepository
+}
+
+func NewSavedSearchService(savedSearchRepository repository.SavedSearchRepository, noteRepository repository.NoteRepository) *SavedSearchServiceImpl {
+	return &SavedSearchServiceImpl{
+		savedSearchRepository: savedSearchRepository,
+		noteRepository:        noteRepository,
+	}
+}
+
+func (s *SavedSearchServiceImpl) CreateSavedSearch(ctx context.Context, search *models.SavedSearch) error {
+	if err := validateSavedSearch(search); err != nil {
+		return err
+	}
+
+	search.CreatedAt = time.Now()
+	search.UpdatedAt = time.Now()
+
+	return failed("failed to create saved search", s.savedSearchRepository.Create(ctx, search))
+}
+
+func (s *SavedSearchServiceImpl) UpdateSavedSearch(ctx context.Context, search *models.SavedSearch) error {
+	if err := validateSavedSearch(search); err != nil {
+		return err
+	}
+
+	search.UpdatedAt = time.Now()
+
+	if err := s.savedSearchRepository.Update(ctx, search); err != nil {
+		return failed("failed to update saved search", err)
+	}
+
+	return s.reload(ctx, search)
+}
+
+func (s *SavedSearchServiceImpl) DeleteSavedSearch(ctx context.Context, id uint) error {
+	return failed("failed to delete saved search", s.savedSearchRepository.Delete(ctx, id))
+}
+
+func (s *SavedSearchServiceImpl) GetSavedSearchById(ctx context.Context, id uint) (*models.SavedSearch, error) {
+	search, err := s.savedSearchRepository.GetById(ctx, id)
+	if err != nil {
+		return nil, failed("failed to get saved search", err)
+	}
+	return search, nil
+}
+
+func (s *SavedSearchServiceImpl) GetAllSavedSearches(ctx context.Context) ([]*models.SavedSearch, error) {
+	searches, err := s.savedSearchRepository.GetAll(ctx)
+	if err != nil {
+		return nil, failed("failed to get saved searches", err)
+	}
+	return searches, nil
+}
+
+// RunSavedSearch executes the stored query of a saved search.
+func (s *SavedSearchServiceImpl) RunSavedSearch(ctx context.Context, id uint) ([]*models.NoteSearchResult, error) {
+	search, err := s.savedSearchRepository.GetById(ctx, id)
+	if err != nil {
+		return nil, failed("failed to run saved search", err)
+	}
+
+	return s.run(ctx, search)
+}
+
+// RouteAlarms raises alarms for notes, sending the notes matched by each
+// subscribed saved search to that search's channel. Notes matched by several
+// subscriptions go to the first one; all other notes use the default channel.
+func (s *SavedSearchServiceImpl) RouteAlarms(ctx context.Context, notes []*models.Note) error {
+	subscriptions, err := s.savedSearchRepository.GetSubscribed(ctx)
+	if err != nil {
+		utils.SetAlarmForNotes(notes)
+		return fmt.Errorf("failed to get subscribed saved searches: %w", err)
+	}
+
+	pending := make(map[uint]*models.Note, len(notes))
+	for _, note := range notes {
+		pending[note.ID] = note
+	}
+
+	for _, subscription := range subscriptions {
+		channel, err := utils.NotifierForChannel(subscription.Channel, subscription.WebhookURL)
+		if err != nil {
+			log.Printf("Skipping saved search %d: %v", subscription.ID, err)
+			continue
+		}
+
+		results, err := s.run(ctx, subscription)
+		if err != nil {
+			log.Printf("Skipping saved search %d: %v", subscription.ID, err)
+			continue
+		}
+
+		var routed []*models.Note
+		for _, result := range results {
+			if note, ok := pending[result.ID]; ok {
+				routed = append(routed, note)
+				delete(pending, result.ID)
+			}
+		}
+		utils.SetAlarmForNotesVia(routed, channel)
+	}
+
+	remaining := make([]*models.Note, 0, len(pending))
+	for _, note := range notes {
+		if _, ok := pending[note.ID]; ok {
+			remaining = append(remaining, note)
+		}
+	}
+	utils.SetAlarmForNotes(remaining)
+
+	return nil
+}
+
+func (s *SavedSearchServiceImpl) run(ctx context.Context, search *models.SavedSearch) ([]*models.NoteSearchResult, error) {
+	q, err := parseSearch(search.Query)
+	if err != nil {
+		return nil, err
+	}
+	q.Sort = search.Sort
+
+	results, err := s.noteRepository.Search(ctx, q)
+	if err != nil {
+		return nil, failed("failed to run saved search", err)
+	}
+	return results, nil
+}
+
+func (s *SavedSearchServiceImpl) reload(ctx context.Context, search *models.SavedSearch) error {
+	stored, err := s.savedSearchRepository.GetById(ctx, search.ID)
+	if err != nil {
+		return failed("failed to get saved search", err)
+	}
+	*search = *stored
+	return nil
+}
+
+// validateSavedSearch checks that the stored query parses and that the sort
+// and subscription channel are usable.
+func validateSavedSearch(search *models.SavedSearch) error {
+	if _, err := query.Parse(search.Query); err != nil {
+		return err
+	}
+	if !query.IsValidSort(search.Sort) {
+		return fmt.Errorf("%w: unsupported sort %q", ErrInvalidSavedSearch, search.Sort)
+	}
+	if search.Subscribed {
+		if _, err := utils.NotifierForChannel(search.Channel, search.WebhookURL); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSavedSearch, err)
+		}
+	}
+	// Webhook URLs are checked even when unused, so none is ever stored that
+	// points at the server itself or at link-local services
+	if search.WebhookURL != "" {
+		if err := utils.ValidateWebhookURL(search.WebhookURL); err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidSavedSearch, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,145 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/sarita-growexx/note_with_alarm/models"
+	"github.com/sarita-growexx/note_with_alarm/query"
+	"github.com/sarita-growexx/note_with_alarm/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type mockSavedSearchRepository struct {
+	mock.Mock
+}
+
+func (m *mockSavedSearchRepository) Create(ctx context.Context, search *models.SavedSearch) error {
+	args := m.Called(search)
+	return args.Error(0)
+}
+
+func (m *mockSavedSearchRepository) Update(ctx context.Context, search *models.SavedSearch) error {
+	args := m.Called(search)
+	return args.Error(0)
+}
+
+func (m *mockSavedSearchRepository) Delete(ctx context.Context, id uint) error {
+	args := m.Called(id)
+	return args.Error(0)
+}
+
+func (m *mockSavedSearchRepository) GetById(ctx context.Context, id uint) (*models.SavedSearch, error) {
+	args := m.Called(id)
+	search, _ := args.Get(0).(*models.SavedSearch)
+	return search, args.Error(1)
+}
+
+func (m *mockSavedSearchRepository) GetAll(ctx context.Context) ([]*models.SavedSearch, error) {
+	args := m.Called()
+	searches, _ := args.Get(0).([]*models.SavedSearch)
+	return searches, args.Error(1)
+}
+
+func (m *mockSavedSearchRepository) GetSubscribed(ctx context.Context) ([]*models.SavedSearch, error) {
+	args := m.Called()
+	searches, _ := args.Get(0).([]*models.SavedSearch)
+	return searches, args.Error(1)
+}
+
+func TestSavedSearchServiceImpl_CreateSavedSearch(t *testing.T) {
+	mockRepo := new(mockSavedSearchRepository)
+	service := NewSavedSearchService(mockRepo, new(mockNoteRepository))
+
+	search := &models.SavedSearch{Name: "Overdue & urgent", Query: "status:overdue tag:urgent", Sort: "-deadline"}
+	mockRepo.On("Create", search).Return(nil).Once()
+
+	err := service.CreateSavedSearch(context.Background(), search)
+	assert.NoError(t, err)
+	assert.False(t, search.CreatedAt.IsZero())
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSavedSearchServiceImpl_CreateSavedSearch_Invalid(t *testing.T) {
+	service := NewSavedSearchService(new(mockSavedSearchRepository), new(mockNoteRepository))
+
+	err := service.CreateSavedSearch(context.Background(), &models.SavedSearch{Name: "Broken", Query: "due:soon"})
+	var parseErr *query.ParseError
+	assert.ErrorAs(t, err, &parseErr)
+
+	err = service.CreateSavedSearch(context.Background(), &models.SavedSearch{Name: "Bad sort", Query: "report", Sort: "priority"})
+	assert.ErrorIs(t, err, ErrInvalidSavedSearch)
+
+	err = service.CreateSavedSearch(context.Background(), &models.SavedSearch{Name: "Unknown channel", Query: "report", Subscribed: true, Channel: "pager"})
+	assert.ErrorIs(t, err, ErrInvalidSavedSearch)
+
+	err = service.CreateSavedSearch(context.Background(), &models.SavedSearch{Name: "No URL", Query: "report", Subscribed: true, Channel: "webhook"})
+	assert.ErrorIs(t, err, ErrInvalidSavedSearch)
+	err = service.CreateSavedSearch(context.Background(), &models.SavedSearch{Name: "Metadata", Query: "report", Subscribed: true, Channel: "webhook", WebhookURL: "http://169.254.169.254/latest"})
+	assert.ErrorIs(t, err, ErrInvalidSavedSearch)
+
+	err = service.CreateSavedSearch(context.Background(), &models.SavedSearch{Name: "Loopback", Query: "report", WebhookURL: "http://127.0.0.1:8080/"})
+	assert.ErrorIs(t, err, ErrInvalidSavedSearch)
+}
+
+func TestSavedSearchServiceImpl_UpdateSavedSearch_NotFound(t *testing.T) {
+	mockRepo := new(mockSavedSearchRepository)
+	service := NewSavedSearchService(mockRepo, new(mockNoteRepository))
+
+	search := &models.SavedSearch{ID: 9, Name: "Missing", Query: "report"}
+	mockRepo.On("Update", search).Return(repository.ErrSavedSearchNotFound).Once()
+
+	err := service.UpdateSavedSearch(context.Background(), search)
+	assert.ErrorIs(t, err, repository.ErrSavedSearchNotFound)
+	mockRepo.AssertExpectations(t)
+}
+
+func TestSavedSearchServiceImpl_RunSavedSearch(t *testing.T) {
+	mockRepo := new(mockSavedSearchRepository)
+	mockNoteRepo := new(mockNoteRepository)
+	service := NewSavedSearchService(mockRepo, mockNoteRepo)
+
+	mockRepo.On("GetById", uint(1)).Return(&models.SavedSearch{ID: 1, Query: "status:overdue tag:urgent", Sort: "-deadline"}, nil).Once()
+	expected := []*models.NoteSearchResult{{Note: models.Note{ID: 3, Title: "Pay invoice"}}}
+	mockNoteRepo.On("Search", mock.MatchedBy(func(q *query.Query) bool {
+		return q.Sort == "-deadline" && len(q.Filters) == 2
+	})).Return(expected, nil).Once()
+
+	results, err := service.RunSavedSearch(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, results)
+
+	mockRepo.On("GetById", uint(2)).Return(nil, repository.ErrSavedSearchNotFound).Once()
+	_, err = service.RunSavedSearch(context.Background(), 2)
+	assert.ErrorIs(t, err, repository.ErrSavedSearchNotFound)
+
+	mockRepo.AssertExpectations(t)
+	mockNoteRepo.AssertExpectations(t)
+}
+
+func TestSavedSearchServiceImpl_RouteAlarms(t *testing.T) {
+	mockRepo := new(mockSavedSearchRepository)
+	mockNoteRepo := new(mockNoteRepository)
+	service := NewSavedSearchService(mockRepo, mockNoteRepo)
+
+	deadline := time.Now().Add(30 * 24 * time.Hour)
+	notes := []*models.Note{
+		{ID: 101, Title: "Pay invoice", Deadline: deadline},
+		{ID: 102, Title: "Water plants", Deadline: deadline},
+	}
+
+	mockRepo.On("GetSubscribed").Return([]*models.SavedSearch{
+		{ID: 1, Query: "invoice", Subscribed: true, Channel: "log"},
+		{ID: 2, Query: "plants", Subscribed: true, Channel: "pager"},
+	}, nil).Once()
+	mockNoteRepo.On("Search", mock.MatchedBy(func(q *query.Query) bool {
+		return q.Text() == "invoice"
+	})).Return([]*models.NoteSearchResult{{Note: *notes[0]}}, nil).Once()
+
+	err := service.RouteAlarms(context.Background(), notes)
+	assert.NoError(t, err)
+	mockRepo.AssertExpectations(t)
+	mockNoteRepo.AssertExpectations(t)
+}
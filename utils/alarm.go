@@ -10,7 +10,16 @@ import (
 	"github.com/sarita-growexx/note_with_alarm/models"
 )
 
-var alertTriggered = make(map[uint]bool)
+// alertKey identifies an alert of a note: one of its reminders, by its offset
+// before the deadline, or the overdue alert, at offset 0.
+type alertKey struct {
+	noteID uint
+	offset time.Duration
+}
+
+// alertTriggered records the alerts already raised, so that each reminder of
+// a note fires once however often its notes are checked.
+var alertTriggered = make(map[alertKey]bool)
 var alertTriggeredLock sync.Mutex // Mutex for concurrent map access
 
 var notifier *notificator.Notificator
@@ -22,18 +31,15 @@ func init() {
 	})
 }
 
+// SetAlarmForNotes raises due alarms for notes as desktop notifications.
 func SetAlarmForNotes(notes []*models.Note) {
+	SetAlarmForNotesVia(notes, DesktopNotifier{})
+}
+
+// SetAlarmForNotesVia raises due alarms for notes on the given channel.
+func SetAlarmForNotesVia(notes []*models.Note, channel Notifier) {
 	for _, note := range notes {
 		go func(note *models.Note) {
-
-			alertTriggeredLock.Lock()
-			defer alertTriggeredLock.Unlock()
-
-			// Check if the alert has already been triggered for this note
-			if alertTriggered[note.ID] {
-				return
-			}
-
 			deadline := note.Deadline
 			deadline = deadline.Truncate(time.Second)
 
@@ -47,34 +53,77 @@ func SetAlarmForNotes(notes []*models.Note) {
 			fmt.Println("Remaining Time:", remainingTime)
 			fmt.Println("6 hrs Time:", 6*time.Hour)
 
-			switch {
-			case remainingTime <= 0:
+			if remainingTime <= 0 {
 				// Already overdue
-				displayNotification(fmt.Sprintf("ALERT: Note '%s' is overdue!", note.Title))
-				// fmt.Printf("ALERT: Note '%s' is overdue!\n", note.Title)
-				alertTriggered[note.ID] = true
-			case remainingTime <= 30*time.Minute:
-				// 30 minutes remaining
-				displayNotification(fmt.Sprintf("ALERT: Note '%s' has 30 minutes remaining.\n", note.Title))
-				alertTriggered[note.ID] = true
-			case remainingTime <= 1*time.Hour:
-				// 1 hour remaining
-				displayNotification(fmt.Sprintf("ALERT: Note '%s' has 1 hour remaining.\n", note.Title))
-				alertTriggered[note.ID] = true
-			case remainingTime <= 6*time.Hour:
-				// 6 hours remaining
-				displayNotification(fmt.Sprintf("ALERT: Note '%s' has 6 hours remaining.\n", note.Title))
-				alertTriggered[note.ID] = true
-			case remainingTime <= 24*time.Hour:
-				// 1 day remaining
-				displayNotification(fmt.Sprintf("ALERT: Note '%s' has 1 day remaining.\n", note.Title))
-				alertTriggered[note.ID] = true
+				if claimAlert(alertKey{noteID: note.ID}) {
+					notify(channel, fmt.Sprintf("ALERT: Note '%s' is overdue!", note.Title))
+				}
+				return
+			}
+
+			// Reminders fire one after another as the deadline gets closer
+			if offset, ok := dueReminder(note, remainingTime); ok {
+				if claimAlert(alertKey{noteID: note.ID, offset: offset}) {
+					notify(channel, fmt.Sprintf("ALERT: Note '%s' has %s remaining.\n", note.Title, describeOffset(offset)))
+				}
 			}
 		}(note)
 	}
 
 }
 
+// claimAlert records that an alert is being raised, reporting false when it
+// already was. The lock is held only for the check, so that a slow channel,
+// such as a webhook, does not hold up the alarms of other notes.
+func claimAlert(key alertKey) bool {
+	alertTriggeredLock.Lock()
+	defer alertTriggeredLock.Unlock()
+
+	if alertTriggered[key] {
+		return false
+	}
+	alertTriggered[key] = true
+	return true
+}
+
+// dueReminder returns the closest reminder of note that the remaining time
+// has already reached.
+func dueReminder(note *models.Note, remaining time.Duration) (time.Duration, bool) {
+	var due time.Duration
+	found := false
+	for _, offset := range note.EffectiveReminders().Offsets() {
+		if remaining <= offset && (!found || offset < due) {
+			due, found = offset, true
+		}
+	}
+	return due, found
+}
+
+// describeOffset spells out a reminder offset, such as "1 day" or "30 minutes".
+func describeOffset(offset time.Duration) string {
+	switch {
+	case offset%(24*time.Hour) == 0:
+		return plural(int(offset/(24*time.Hour)), "day")
+	case offset%time.Hour == 0:
+		return plural(int(offset/time.Hour), "hour")
+	default:
+		return plural(int(offset/time.Minute), "minute")
+	}
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+func notify(channel Notifier, msg string) {
+	if err := channel.Notify("Notification", msg); err != nil {
+		fmt.Println("Failed to send notification:", err)
+	}
+}
+
 func displayNotification(msg string) {
 	// Display a notification with the given message
 	notifier.Push("Notification", msg, "", notificator.UR_NORMAL)
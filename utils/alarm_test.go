@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"net/http"
 	"testing"
 	"time"
 
@@ -16,7 +17,7 @@ func TestSetAlarmForNotes(t *testing.T) {
 	}
 
 	// Mock the alertTriggered map to capture whether an alert is triggered
-	alertTriggered = make(map[uint]bool)
+	alertTriggered = make(map[alertKey]bool)
 
 	// Call SetAlarmForNotes with the sample note
 	SetAlarmForNotes([]*models.Note{note})
@@ -25,7 +26,139 @@ func TestSetAlarmForNotes(t *testing.T) {
 	time.Sleep(1 * time.Second)
 
 	// Assertions
-	assert.True(t, alertTriggered[note.ID], "Alert should be triggered for the sample note")
+	assert.True(t, alertTriggered[alertKey{noteID: note.ID, offset: 30 * time.Minute}], "Alert should be triggered for the sample note")
 }
 
 // Add more test cases as needed
+
+type recordingNotifier struct {
+	messages chan string
+}
+
+func (n *recordingNotifier) Notify(title, message string) error {
+	n.messages <- message
+	return nil
+}
+
+func TestSetAlarmForNotesVia(t *testing.T) {
+	note := &models.Note{
+		ID:       42,
+		Title:    "Routed Note",
+		Deadline: time.Now().Add(-time.Minute),
+	}
+
+	alertTriggered = make(map[alertKey]bool)
+	channel := &recordingNotifier{messages: make(chan string, 1)}
+
+	SetAlarmForNotesVia([]*models.Note{note}, channel)
+
+	select {
+	case msg := <-channel.messages:
+		assert.Contains(t, msg, "'Routed Note' is overdue")
+	case <-time.After(time.Second):
+		t.Fatal("expected the alarm to be routed to the channel")
+	}
+}
+
+func TestSetAlarmForNotesViaReminders(t *testing.T) {
+	note := &models.Note{
+		ID:        43,
+		Title:     "Reminded Note",
+		Deadline:  time.Now().Add(90 * time.Minute),
+		Reminders: models.Reminders{2 * 24 * 60, 120},
+	}
+
+	alertTriggered = make(map[alertKey]bool)
+	channel := &recordingNotifier{messages: make(chan string, 1)}
+
+	SetAlarmForNotesVia([]*models.Note{note}, channel)
+
+	select {
+	case msg := <-channel.messages:
+		assert.Contains(t, msg, "'Reminded Note' has 2 hours remaining")
+	case <-time.After(time.Second):
+		t.Fatal("expected the reminder to be routed to the channel")
+	}
+}
+
+func TestSetAlarmForNotesViaFiresEachReminder(t *testing.T) {
+	alertTriggered = make(map[alertKey]bool)
+	channel := &recordingNotifier{messages: make(chan string, 4)}
+	receive := func() string {
+		select {
+		case msg := <-channel.messages:
+			return msg
+		case <-time.After(time.Second):
+			t.Fatal("expected an alarm")
+			return ""
+		}
+	}
+
+	// The note is checked a day before its deadline, then again within the
+	// hour, as the alarm loop would
+	reminders := models.Reminders{24 * 60, 60}
+	for _, remaining := range []time.Duration{23 * time.Hour, 50 * time.Minute, 45 * time.Minute} {
+		SetAlarmForNotesVia([]*models.Note{{ID: 44, Title: "Twice", Deadline: time.Now().Add(remaining), Reminders: reminders}}, channel)
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	assert.Contains(t, receive(), "'Twice' has 1 day remaining")
+	assert.Contains(t, receive(), "'Twice' has 1 hour remaining")
+	assert.Empty(t, channel.messages, "each reminder fires once")
+}
+
+func TestDueReminder(t *testing.T) {
+	_, ok := dueReminder(&models.Note{Reminders: models.Reminders{30}}, time.Hour)
+	assert.False(t, ok)
+
+	offset, ok := dueReminder(&models.Note{}, 50*time.Minute)
+	assert.True(t, ok)
+	assert.Equal(t, time.Hour, offset)
+	assert.Equal(t, "1 hour", describeOffset(offset))
+	assert.Equal(t, "2 days", describeOffset(48*time.Hour))
+	assert.Equal(t, "45 minutes", describeOffset(45*time.Minute))
+}
+
+func TestNotifierForChannel(t *testing.T) {
+	notifier, err := NotifierForChannel(ChannelWebhook, "https://example.com/hook")
+	assert.NoError(t, err)
+	assert.Equal(t, WebhookNotifier{URL: "https://example.com/hook"}, notifier)
+
+	_, err = NotifierForChannel(ChannelWebhook, "")
+	assert.Error(t, err)
+
+	_, err = NotifierForChannel("pager", "")
+	assert.Error(t, err)
+
+	for _, target := range []string{
+		"ftp://example.com/hook",
+		"file:///etc/passwd",
+		"/hook",
+		"http://localhost:8080/hook",
+		"http://127.0.0.1/hook",
+		"http://[::1]/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[fe80::1]/hook",
+		"http://0.0.0.0/hook",
+		"http://10.0.0.5/hook",
+		"http://172.16.0.1/hook",
+		"http://192.168.1.1/hook",
+		"http://100.64.0.1/hook",
+		"http://[fd00::1]/hook",
+		"http://[::ffff:192.168.1.1]/hook",
+	} {
+		_, err = NotifierForChannel(ChannelWebhook, target)
+		assert.Error(t, err, target)
+	}
+}
+
+func TestWebhookClientRefusesBlockedAddresses(t *testing.T) {
+	// A proxy would be dialed in place of the webhook host
+	assert.Nil(t, webhookClient.Transport.(*http.Transport).Proxy)
+
+	// A host name could resolve to a blocked address after validation
+	err := WebhookNotifier{URL: "http://127.0.0.1:1/hook"}.Notify("title", "message")
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "is not allowed")
+	}
+}
@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+const (
+	ChannelDesktop = "desktop"
+	ChannelLog     = "log"
+	ChannelWebhook = "webhook"
+)
+
+// Notifier delivers an alarm message on a channel.
+type Notifier interface {
+	Notify(title, message string) error
+}
+
+// DesktopNotifier shows alarms as desktop notifications.
+type DesktopNotifier struct{}
+
+func (DesktopNotifier) Notify(title, message string) error {
+	displayNotification(message)
+	return nil
+}
+
+// LogNotifier writes alarms to the application log.
+type LogNotifier struct{}
+
+func (LogNotifier) Notify(title, message string) error {
+	log.Printf("%s: %s", title, message)
+	return nil
+}
+
+// WebhookNotifier posts alarms as JSON to a URL.
+type WebhookNotifier struct {
+	URL string
+}
+
+// webhookClient posts alarms. Its dialer refuses blocked addresses too, so
+// that a host name resolving to one cannot get past ValidateWebhookURL. It
+// uses no proxy, since the dialer would then only see the proxy's address.
+var webhookClient = &http.Client{
+	Timeout: 10 * time.Second,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: 5 * time.Second,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if ip := net.ParseIP(host); ip != nil && blockedWebhookIP(ip) {
+					return fmt.Errorf("webhook address %s is not allowed", ip)
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout: 5 * time.Second,
+	},
+}
+
+// ValidateWebhookURL checks that a webhook URL is an absolute http or https
+// URL whose host is not a loopback, link-local, private, shared (CGNAT) or
+// unspecified address, so that webhooks cannot reach the server itself, the
+// networks it sits in, or cloud metadata services.
+func ValidateWebhookURL(raw string) error {
+	target, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL: %w", err)
+	}
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return fmt.Errorf("webhook URL must use http or https")
+	}
+	host := strings.ToLower(strings.TrimSuffix(target.Hostname(), "."))
+	if host == "" {
+		return fmt.Errorf("webhook URL has no host")
+	}
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("webhook host %s is not allowed", host)
+	}
+	if ip := net.ParseIP(host); ip != nil && blockedWebhookIP(ip) {
+		return fmt.Errorf("webhook host %s is not allowed", host)
+	}
+	return nil
+}
+
+// blockedWebhookNets are the ranges, besides those net.IP classifies, that
+// webhooks may not reach: shared address space for carrier-grade NAT, and
+// IPv6 unique local addresses.
+var blockedWebhookNets = []*net.IPNet{
+	mustParseCIDR("100.64.0.0/10"),
+	mustParseCIDR("fc00::/7"),
+}
+
+func mustParseCIDR(cidr string) *net.IPNet {
+	_, network, err := net.ParseCIDR(cidr)
+	if err != nil {
+		panic(err)
+	}
+	return network
+}
+
+func blockedWebhookIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsUnspecified() || ip.IsPrivate() {
+		return true
+	}
+	for _, network := range blockedWebhookNets {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (n WebhookNotifier) Notify(title, message string) error {
+	body, err := json.Marshal(map[string]string{"title": title, "message": message})
+	if err != nil {
+		return err
+	}
+
+	resp, err := webhookClient.Post(n.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// NotifierForChannel returns the notifier for a channel name. The target is
+// the webhook URL for the webhook channel, checked by ValidateWebhookURL, and
+// ignored otherwise.
+func NotifierForChannel(channel, target string) (Notifier, error) {
+	switch channel {
+	case ChannelDesktop, "":
+		return DesktopNotifier{}, nil
+	case ChannelLog:
+		return LogNotifier{}, nil
+	case ChannelWebhook:
+		if target == "" {
+			return nil, fmt.Errorf("webhook channel needs a URL")
+		}
+		if err := ValidateWebhookURL(target); err != nil {
+			return nil, err
+		}
+		return WebhookNotifier{URL: target}, nil
+	}
+	return nil, fmt.Errorf("unknown notification channel %q", channel)
+}